@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/lewtec/rotulador/internal/repository"
+	"github.com/lewtec/rotulador/internal/web"
+	"github.com/spf13/cobra"
+)
+
+// dedupeCmd represents the dedupe command
+var dedupeCmd = &cobra.Command{
+	Use:   "dedupe [flags] database",
+	Short: "Report groups of near-duplicate images",
+	Long: `Group images whose perceptual hashes differ by at most --distance bits.
+
+Perceptual hashes are computed when the server ingests the images directory,
+so resized or re-compressed copies that HashFile treats as different images
+end up in the same group.
+
+Output columns are: group number, distance to the first image of the group,
+sha256 and filename. Groups are separated by a blank line.
+
+Examples:
+  # Default threshold
+  rotulador dedupe annotations.db
+
+  # Only report almost identical images
+  rotulador dedupe --distance 2 annotations.db`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger, err := getLogger(cmd)
+		if err != nil {
+			return err
+		}
+		distance, err := cmd.Flags().GetInt("distance")
+		if err != nil {
+			return err
+		}
+		if distance < 0 || distance > 64 {
+			return fmt.Errorf("--distance must be between 0 and 64, got %d", distance)
+		}
+
+		db, err := web.GetDatabase(args[0])
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := db.Close(); err != nil {
+				web.ReportError(cmd.Context(), err, "msg", "failed to close database")
+			}
+		}()
+
+		missing, err := repository.NewImageRepository(db).CountWithoutPHash(cmd.Context())
+		if err != nil {
+			return err
+		}
+		if missing > 0 {
+			logger.Warn("some images have no perceptual hash yet; start the server to ingest them", "count", missing)
+		}
+
+		groups, err := web.ListNearDuplicates(cmd.Context(), db, distance)
+		if err != nil {
+			return err
+		}
+
+		out := cmd.OutOrStdout()
+		for i, group := range groups {
+			if i > 0 {
+				fmt.Fprintln(out)
+			}
+			for _, img := range group {
+				fmt.Fprintf(out, "%d\t%d\t%s\t%s\n", i+1, web.HammingDistance(group[0].PHash, img.PHash), img.SHA256, img.Filename)
+			}
+		}
+		logger.Info("near-duplicate report", "groups", len(groups), "distance", distance)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(dedupeCmd)
+
+	dedupeCmd.Flags().IntP("distance", "D", web.DefaultDuplicateDistance, "Maximum Hamming distance between perceptual hashes (0-64)")
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/lewtec/rotulador/internal/web"
+)
+
+// resetDedupeFlags restores package-level cobra flag state after a test.
+func resetDedupeFlags(t *testing.T) {
+	t.Helper()
+	t.Cleanup(func() {
+		_ = dedupeCmd.Flags().Set("distance", strconv.Itoa(web.DefaultDuplicateDistance))
+	})
+}
+
+func TestDedupeCmd_GroupsNearDuplicates(t *testing.T) {
+	dbPath, _ := setupQueryTestDB(t)
+	resetDedupeFlags(t)
+
+	db, err := web.GetDatabase(dbPath)
+	if err != nil {
+		t.Fatalf("GetDatabase: %v", err)
+	}
+	_, err = db.Exec(`
+		INSERT INTO images (sha256, filename, phash) VALUES ('ghi789', 'photo-small.jpg', 3);
+		UPDATE images SET phash = 1 WHERE sha256 = 'abc123';
+		UPDATE images SET phash = -1 WHERE sha256 = 'def456';
+	`)
+	if err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	out, errOut, err := executeCommand(t, "dedupe", "--distance", "2", dbPath)
+	if err != nil {
+		t.Fatalf("dedupe: %v (%s)", err, errOut)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected one group of two images, got %q", out)
+	}
+	if !strings.Contains(out, "photo.jpg") || !strings.Contains(out, "photo-small.jpg") {
+		t.Fatalf("expected photo.jpg and photo-small.jpg grouped, got %q", out)
+	}
+	if strings.Contains(out, "other.png") {
+		t.Fatalf("other.png is not a duplicate, got %q", out)
+	}
+}
+
+func TestDedupeCmd_RejectsBadDistance(t *testing.T) {
+	dbPath, _ := setupQueryTestDB(t)
+	resetDedupeFlags(t)
+
+	_, _, err := executeCommand(t, "dedupe", "--distance", "65", dbPath)
+	if err == nil || !strings.Contains(err.Error(), "--distance") {
+		t.Fatalf("err = %v, want --distance validation error", err)
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lewtec/rotulador/internal/domain"
+	"github.com/lewtec/rotulador/internal/repository"
+	"github.com/lewtec/rotulador/internal/storage"
+	"github.com/lewtec/rotulador/internal/web"
+	"github.com/spf13/cobra"
+)
+
+// exportColumns is the CSV header written by 'rotulador export'
+var exportColumns = []string{
+	"sha256", "filename", "task", "stage_index", "username", "option_value", "annotated_at",
+	"format", "width", "height", "size_bytes", "source_path", "captured_at", "camera", "tags",
+	"source_dir", "source_archive", "frame_offset_ms", "label", "reviewer", "review_outcome",
+	"duration_ms",
+}
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export [flags] database",
+	Short: "Export annotations with image metadata as CSV",
+	Long: `Write one CSV row per annotation, joined with the metadata of its image.
+
+--where takes field=value conditions on image metadata and can be repeated.
+Fields are width, height, size_bytes, format, camera, tag, source_path,
+source_dir and captured_at. Numbers and dates accept a >, >=, <, <= or = prefix.
+
+With --config the task column holds task IDs; otherwise only stage_index is
+filled in.
+
+option_value is the annotator's answer. label is the label to train on: the
+latest review of the image in that stage when there is one, otherwise
+option_value. reviewer and review_outcome describe the review of the row's
+own annotation. duration_ms is how long the annotator looked at the image
+before answering, empty when it was not recorded.
+
+With --copy-images the files of the exported images are copied to a
+directory or an s3://bucket/prefix, under the filenames in the CSV, so the
+dataset can be handed over as a whole. They are read from --images, which
+defaults to the images directory next to the database and also accepts a
+bucket.
+
+Examples:
+  rotulador export annotations.db > annotations.csv
+
+  # The annotations of night images with their files, for training elsewhere
+  rotulador export -w tag=night --copy-images ./night annotations.db > night/annotations.csv
+
+  # Only large JPEGs taken in 2024
+  rotulador export -w 'width=>512' -w format=jpeg -w 'captured_at=>=2024-01-01' annotations.db`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		where, err := cmd.Flags().GetStringArray("where")
+		if err != nil {
+			return err
+		}
+		configFile, err := cmd.Flags().GetString("config")
+		if err != nil {
+			return err
+		}
+		imagesLocation, err := cmd.Flags().GetString("images")
+		if err != nil {
+			return err
+		}
+		copyTo, err := cmd.Flags().GetString("copy-images")
+		if err != nil {
+			return err
+		}
+
+		values := url.Values{}
+		for _, condition := range where {
+			field, value, ok := strings.Cut(condition, "=")
+			if !ok || !web.IsImageField(field) {
+				return fmt.Errorf("--where %q: expected field=value with field one of %s", condition, strings.Join(web.ImageFields, ", "))
+			}
+			values.Add(field, value)
+		}
+		filter, err := web.ImageFilterFromValues(values)
+		if err != nil {
+			return err
+		}
+
+		var tasks []string
+		if configFile != "" {
+			config, err := web.LoadConfig(configFile)
+			if err != nil {
+				return fmt.Errorf("load config: %w", err)
+			}
+			for _, task := range config.Tasks {
+				tasks = append(tasks, task.ID)
+			}
+		}
+
+		// Both stores are opened first so a bad location fails before any output
+		var src, dst storage.Store
+		if copyTo != "" {
+			if imagesLocation == "" {
+				imagesLocation = filepath.Join(filepath.Dir(args[0]), "images")
+			}
+			if src, err = storage.Open(imagesLocation); err != nil {
+				return fmt.Errorf("open images storage: %w", err)
+			}
+			if dst, err = storage.Open(copyTo); err != nil {
+				return fmt.Errorf("open --copy-images storage: %w", err)
+			}
+		}
+
+		db, err := web.GetDatabase(args[0])
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := db.Close(); err != nil {
+				web.ReportError(cmd.Context(), err, "msg", "failed to close database")
+			}
+		}()
+
+		exported, err := writeExport(cmd.Context(), cmd.OutOrStdout(), db, filter, tasks)
+		if err != nil || dst == nil {
+			return err
+		}
+		logger, err := getLogger(cmd)
+		if err != nil {
+			return err
+		}
+		return copyImages(cmd.Context(), logger, src, dst, exported)
+	},
+}
+
+// copyImages copies the files named filenames from src to dst under the
+// same names. Files missing from src are logged and skipped.
+func copyImages(ctx context.Context, logger *slog.Logger, src, dst storage.Store, filenames []string) error {
+	copied := 0
+	for _, name := range filenames {
+		err := copyImage(ctx, src, dst, name)
+		if errors.Is(err, fs.ErrNotExist) {
+			logger.Warn("skipping image without a file", "filename", name)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("while copying image %s: %w", name, err)
+		}
+		copied++
+	}
+	logger.Info("copied images", "count", copied, "missing", len(filenames)-copied)
+	return nil
+}
+
+func copyImage(ctx context.Context, src, dst storage.Store, name string) error {
+	f, err := src.Open(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			web.ReportError(ctx, err, "msg", "failed to close image", "filename", name)
+		}
+	}()
+	return dst.Put(ctx, name, f, f.Info().Size)
+}
+
+// exportPageSize is the number of images export loads at a time
+const exportPageSize = 500
+
+// writeExport writes the annotations of the images matching filter as CSV
+// and returns the filenames of the images that have rows. tasks maps stage
+// indexes to task IDs and may be empty.
+func writeExport(ctx context.Context, out io.Writer, db *sql.DB, filter domain.ImageFilter, tasks []string) ([]string, error) {
+	images := repository.NewImageRepository(db)
+	annotations := repository.NewAnnotationRepository(db)
+	reviews := repository.NewReviewRepository(db)
+
+	var exported []string
+	w := csv.NewWriter(out)
+	if err := w.Write(exportColumns); err != nil {
+		return nil, err
+	}
+	var after *domain.Image
+	for {
+		records, err := web.ListImageRecords(ctx, images, filter, after, exportPageSize)
+		if err != nil {
+			return nil, err
+		}
+		if len(records) == 0 {
+			break
+		}
+		last := records[len(records)-1]
+		after = &domain.Image{SHA256: last.SHA256, Filename: last.Filename}
+		for _, rec := range records {
+			anns, err := annotations.GetForImage(ctx, rec.SHA256)
+			if err != nil {
+				return nil, fmt.Errorf("while listing annotations of %s: %w", rec.SHA256, err)
+			}
+			imageReviews, err := reviews.GetForImage(ctx, rec.SHA256)
+			if err != nil {
+				return nil, fmt.Errorf("while listing reviews of %s: %w", rec.SHA256, err)
+			}
+			labels := web.ReviewedLabels(imageReviews)
+			latestReviews := web.LatestReviews(imageReviews)
+			var capturedAt string
+			if rec.CapturedAt != nil {
+				capturedAt = rec.CapturedAt.Format(time.RFC3339)
+			}
+			var frameOffset string
+			if rec.FrameOffsetMS != nil {
+				frameOffset = strconv.FormatInt(*rec.FrameOffsetMS, 10)
+			}
+			if len(anns) > 0 {
+				exported = append(exported, rec.Filename)
+			}
+			for _, ann := range anns {
+				var task string
+				if ann.StageIndex < len(tasks) {
+					task = tasks[ann.StageIndex]
+				}
+				label, ok := labels[ann.StageIndex]
+				if !ok {
+					label = ann.OptionValue
+				}
+				var reviewer, outcome string
+				if review := latestReviews[ann.ID]; review != nil {
+					reviewer, outcome = review.Reviewer, review.Outcome
+				}
+				row := []string{
+					rec.SHA256, rec.Filename, task, strconv.Itoa(ann.StageIndex), ann.Username, ann.OptionValue,
+					ann.AnnotatedAt.UTC().Format(time.RFC3339),
+					rec.Format, optionalInt(int64(rec.Width)), optionalInt(int64(rec.Height)), optionalInt(rec.SizeBytes),
+					rec.SourcePath, capturedAt, rec.Camera, strings.Join(rec.Tags, ";"),
+					rec.SourceDir, rec.SourceArchive, frameOffset, label, reviewer, outcome,
+					optionalInt(ann.Duration.Milliseconds()),
+				}
+				if err := w.Write(row); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	w.Flush()
+	return exported, w.Error()
+}
+
+// optionalInt formats unknown (zero) metadata as an empty cell
+func optionalInt(v int64) string {
+	if v == 0 {
+		return ""
+	}
+	return strconv.FormatInt(v, 10)
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+
+	exportCmd.Flags().StringArrayP("where", "w", nil, "Image metadata condition as field=value (repeatable)")
+	exportCmd.Flags().StringP("config", "c", "", "Config file used to name tasks in the task column")
+	exportCmd.Flags().StringP("images", "i", "", "Images directory or s3://bucket/prefix to copy from (defaults to 'images' next to the database)")
+	exportCmd.Flags().String("copy-images", "", "Copy the files of the exported images to this directory or s3://bucket/prefix")
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/lewtec/rotulador/internal/domain"
+	"github.com/lewtec/rotulador/internal/repository"
+	"github.com/lewtec/rotulador/internal/storage"
+	"github.com/lewtec/rotulador/internal/web"
+	"github.com/spf13/pflag"
+)
+
+func TestExportFiltersByMetadata(t *testing.T) {
+	dbPath, _ := setupQueryTestDB(t)
+
+	db, err := web.GetDatabase(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	images := repository.NewImageRepository(db)
+	if _, err := images.CreateWithMetadata(t.Context(), "abc123", "photo.jpg", domain.ImageMetadata{Format: "jpeg", Width: 1024, Height: 768}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := images.CreateWithMetadata(t.Context(), "def456", "other.png", domain.ImageMetadata{Format: "png", Width: 64, Height: 64}); err != nil {
+		t.Fatal(err)
+	}
+	if err := images.AddTag(t.Context(), "abc123", "outdoor"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { resetExportFlags(t) })
+	stdout, _, err := executeCommand(t, "export", "-w", "width=>512", dbPath)
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	rows, err := csv.NewReader(strings.NewReader(stdout)).ReadAll()
+	if err != nil {
+		t.Fatalf("parse csv: %v\n%s", err, stdout)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("got %d rows, want header and 2 annotations of abc123:\n%s", len(rows), stdout)
+	}
+	for _, row := range rows[1:] {
+		if row[0] != "abc123" || row[7] != "jpeg" || row[8] != "1024" || row[14] != "outdoor" {
+			t.Errorf("unexpected row %v", row)
+		}
+	}
+
+	resetExportFlags(t)
+	if _, _, err := executeCommand(t, "export", "-w", "colour=red", dbPath); err == nil {
+		t.Error("expected error for unknown --where field")
+	}
+}
+
+func TestExportPrefersReviewedLabels(t *testing.T) {
+	dbPath, _ := setupQueryTestDB(t)
+
+	db, err := web.GetDatabase(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ann, err := repository.NewAnnotationRepository(db).Get(t.Context(), "abc123", "admin", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repository.NewReviewRepository(db).Create(t.Context(), ann.ID, "carol", domain.ReviewCorrected, "portrait"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("UPDATE annotations SET duration_ms = 2300 WHERE id = ?", ann.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { resetExportFlags(t) })
+	// cobra only hands the root context to a subcommand without one, and the
+	// test above leaves exportCmd holding its cancelled context.
+	exportCmd.SetContext(t.Context())
+	stdout, _, err := executeCommand(t, "export", dbPath)
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	rows, err := csv.NewReader(strings.NewReader(stdout)).ReadAll()
+	if err != nil {
+		t.Fatalf("parse csv: %v\n%s", err, stdout)
+	}
+	column := map[string]int{}
+	for i, name := range rows[0] {
+		column[name] = i
+	}
+	got := map[string][]string{}
+	for _, row := range rows[1:] {
+		key := row[column["sha256"]] + "/" + row[column["stage_index"]]
+		got[key] = []string{row[column["option_value"]], row[column["label"]], row[column["reviewer"]], row[column["review_outcome"]], row[column["duration_ms"]]}
+	}
+	want := map[string][]string{
+		"abc123/0": {"landscape", "portrait", "carol", "corrected", "2300"},
+		"abc123/1": {"good", "good", "", "", ""},
+		"def456/0": {"portrait", "portrait", "", "", ""},
+	}
+	for key, w := range want {
+		if !slices.Equal(got[key], w) {
+			t.Errorf("%s: option_value, label, reviewer, review_outcome, duration_ms = %v, want %v", key, got[key], w)
+		}
+	}
+}
+
+func TestExportCopiesImages(t *testing.T) {
+	dbPath, _ := setupQueryTestDB(t)
+	images := filepath.Join(filepath.Dir(dbPath), "images")
+	if err := os.MkdirAll(images, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// other.png has no file and is skipped
+	if err := os.WriteFile(filepath.Join(images, "photo.jpg"), []byte("jpeg bytes"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fake := storage.NewFakeS3(t, "datasets")
+	t.Setenv("AWS_ENDPOINT_URL", fake.Server.URL)
+	t.Setenv("AWS_ACCESS_KEY_ID", fake.AccessKey)
+	t.Setenv("AWS_SECRET_ACCESS_KEY", fake.SecretKey)
+	t.Setenv("AWS_REGION", fake.Region)
+
+	t.Cleanup(func() { resetExportFlags(t) })
+	exportCmd.SetContext(t.Context())
+	if _, _, err := executeCommand(t, "export", "--copy-images", "s3://datasets/export", dbPath); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if keys := fake.Keys(); !slices.Equal(keys, []string{"export/photo.jpg"}) {
+		t.Fatalf("bucket keys = %v, want export/photo.jpg", keys)
+	}
+	f, err := fake.Store(t, "export").Open(t.Context(), "photo.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+	if data, err := io.ReadAll(f); err != nil || string(data) != "jpeg bytes" {
+		t.Errorf("copied file = %q, %v", data, err)
+	}
+}
+
+// resetExportFlags clears --where, which otherwise accumulates across runs
+// of the shared command, and the image copy flags.
+func resetExportFlags(t *testing.T) {
+	t.Helper()
+	flag := exportCmd.Flags().Lookup("where")
+	if err := flag.Value.(pflag.SliceValue).Replace(nil); err != nil {
+		t.Fatal(err)
+	}
+	flag.Changed = false
+	for _, name := range []string{"images", "copy-images"} {
+		if err := exportCmd.Flags().Set(name, ""); err != nil {
+			t.Fatal(err)
+		}
+		exportCmd.Flags().Lookup(name).Changed = false
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/lewtec/rotulador/internal/domain"
+	"github.com/lewtec/rotulador/internal/storage"
+	"github.com/lewtec/rotulador/internal/web"
+	"github.com/spf13/cobra"
+)
+
+// fsckCmd represents the fsck command
+var fsckCmd = &cobra.Command{
+	Use:   "fsck [flags] database images_dir",
+	Short: "Check that the database and the images directory agree",
+	Long: `Compare the images table with the files in the images directory and report:
+
+  missing-file   a row whose file is gone
+  orphan-file    a file no row points to
+  hash-mismatch  a file whose content no longer matches its row
+
+Output columns are: kind, sha256, filename and path. Hash mismatches add the
+hash of the current content. Without --fix the command fails when it finds
+any issue.
+
+--fix resolves the issues:
+
+  quarantine  move orphan and mismatched files to --quarantine-dir
+  rehash      record orphan and mismatched files under their real hash
+  purge       delete rows without a matching file, with their annotations
+
+Outside purge, rows without a file are marked missing so they leave the
+annotation queues but keep their annotations.
+
+Only local images directories can be checked. For images in a bucket the
+server marks rows whose object is gone as missing when it starts.
+
+Examples:
+  rotulador fsck annotations.db images
+
+  rotulador fsck --fix quarantine --quarantine-dir /tmp/suspect annotations.db images`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger, err := getLogger(cmd)
+		if err != nil {
+			return err
+		}
+		fix, err := cmd.Flags().GetString("fix")
+		if err != nil {
+			return err
+		}
+		quarantineDir, err := cmd.Flags().GetString("quarantine-dir")
+		if err != nil {
+			return err
+		}
+		switch fix {
+		case "", web.FsckQuarantine, web.FsckRehash, web.FsckPurge:
+		default:
+			return fmt.Errorf("--fix must be %s, %s or %s, got %q", web.FsckQuarantine, web.FsckRehash, web.FsckPurge, fix)
+		}
+		if storage.IsRemote(args[1]) {
+			return fmt.Errorf("%s: fsck is %w", args[1], web.ErrRemoteImages)
+		}
+		imagesDir := filepath.Clean(args[1])
+		if quarantineDir == "" {
+			quarantineDir = imagesDir + ".quarantine"
+		}
+
+		db, err := web.GetDatabase(args[0])
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := db.Close(); err != nil {
+				web.ReportError(cmd.Context(), err, "msg", "failed to close database")
+			}
+		}()
+
+		app := &web.AnnotatorApp{
+			ImagesDir:   imagesDir,
+			Database:    db,
+			Config:      &web.Config{},
+			Logger:      logger,
+			EventSource: domain.EventSourceCLI,
+		}
+		if err := app.PrepareDatabaseMigrations(cmd.Context()); err != nil {
+			return fmt.Errorf("prepare database: %w", err)
+		}
+
+		issues, err := app.CheckImages(cmd.Context())
+		if err != nil {
+			return err
+		}
+		out := cmd.OutOrStdout()
+		for _, issue := range issues {
+			fmt.Fprintf(out, "%s\t%s\t%s\t%s", issue.Kind, issue.SHA256, issue.Filename, issue.Path)
+			if issue.ActualSHA256 != "" {
+				fmt.Fprintf(out, "\t%s", issue.ActualSHA256)
+			}
+			fmt.Fprintln(out)
+		}
+		logger.Info("fsck report", "issues", len(issues))
+		if len(issues) == 0 {
+			return nil
+		}
+		if fix == "" {
+			return fmt.Errorf("found %d issues (hint: use --fix)", len(issues))
+		}
+		if err := app.FixImages(cmd.Context(), issues, fix, quarantineDir); err != nil {
+			return err
+		}
+		logger.Info("fixed issues", "mode", fix, "count", len(issues))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(fsckCmd)
+
+	fsckCmd.Flags().String("fix", "", "Resolve issues: quarantine, rehash or purge")
+	fsckCmd.Flags().String("quarantine-dir", "", "Where --fix quarantine moves files (default <images_dir>.quarantine)")
+}
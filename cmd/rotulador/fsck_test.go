@@ -0,0 +1,106 @@
+package main
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/lewtec/rotulador/internal/web"
+)
+
+// resetFsckFlags restores package-level cobra flag state after a test.
+func resetFsckFlags(t *testing.T) {
+	t.Helper()
+	t.Cleanup(func() {
+		_ = fsckCmd.Flags().Set("fix", "")
+		_ = fsckCmd.Flags().Set("quarantine-dir", "")
+	})
+}
+
+func TestFsckCmd_ReportsAndPurgesMissingFiles(t *testing.T) {
+	dbPath, _ := setupQueryTestDB(t)
+	imagesDir := t.TempDir()
+	resetFsckFlags(t)
+
+	// Neither seeded image has a file
+	out, _, err := executeCommand(t, "fsck", dbPath, imagesDir)
+	if err == nil || !strings.Contains(err.Error(), "found 2 issues") {
+		t.Fatalf("expected 2 issues, got err=%v", err)
+	}
+	if !strings.Contains(out, "missing-file\tabc123\tphoto.jpg") || !strings.Contains(out, "missing-file\tdef456\tother.png") {
+		t.Fatalf("unexpected report %q", out)
+	}
+
+	// cobra keeps the context of the previous run, cancelled by now
+	fsckCmd.SetContext(t.Context())
+	if _, errOut, err := executeCommand(t, "fsck", "--fix", "purge", dbPath, imagesDir); err != nil {
+		t.Fatalf("fsck --fix purge: %v (%s)", err, errOut)
+	}
+	_ = fsckCmd.Flags().Set("fix", "")
+	fsckCmd.SetContext(t.Context())
+	out, _, err = executeCommand(t, "fsck", dbPath, imagesDir)
+	if err != nil || out != "" {
+		t.Fatalf("expected a clean report after purge, got %q, err=%v", out, err)
+	}
+}
+
+func TestFsckCmd_RejectsUnknownFix(t *testing.T) {
+	dbPath, _ := setupQueryTestDB(t)
+	resetFsckFlags(t)
+
+	_, _, err := executeCommand(t, "fsck", "--fix", "shred", dbPath, t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "--fix") {
+		t.Fatalf("expected --fix validation error, got %v", err)
+	}
+}
+
+func TestFsckCmd_KeepsTaskProgress(t *testing.T) {
+	dbPath, _ := setupQueryTestDB(t)
+	resetFsckFlags(t)
+
+	db, err := web.GetDatabase(dbPath)
+	if err != nil {
+		t.Fatalf("GetDatabase: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	// The server syncs the task rules of its config once per process
+	app := &web.AnnotatorApp{
+		ImagesDir: t.TempDir(),
+		Database:  db,
+		Config: &web.Config{Tasks: []*web.ConfigTask{
+			{ID: "orientation", Classes: map[string]*web.ConfigClass{"landscape": {}, "portrait": {}}},
+			{ID: "quality", If: map[string]string{"orientation": "landscape"}, Classes: map[string]*web.ConfigClass{"good": {}}},
+		}},
+		Logger: slog.New(slog.DiscardHandler),
+	}
+	if err := app.PrepareDatabaseMigrations(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.SyncTaskRules(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	eligible := func() map[string]int {
+		t.Helper()
+		counts := map[string]int{}
+		for _, task := range app.Config.Tasks {
+			n, err := app.CountEligibleImages(t.Context(), task.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			counts[task.ID] = n
+		}
+		return counts
+	}
+	before := eligible()
+	if before["orientation"] != 2 || before["quality"] != 1 {
+		t.Fatalf("eligible images before fsck = %v", before)
+	}
+
+	// Reports the missing files, without a task config of its own
+	if _, _, err := executeCommand(t, "fsck", dbPath, t.TempDir()); err == nil {
+		t.Fatal("expected fsck to report the missing files")
+	}
+	if after := eligible(); after["orientation"] != before["orientation"] || after["quality"] != before["quality"] {
+		t.Errorf("eligible images after fsck = %v, want %v", after, before)
+	}
+}
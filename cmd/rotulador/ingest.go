@@ -4,13 +4,24 @@ Copyright © 2023 NAME HERE <EMAIL ADDRESS>
 package main
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"image"
 	"io/fs"
+	"log/slog"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/lewtec/rotulador/internal/domain"
+	"github.com/lewtec/rotulador/internal/repository"
+	"github.com/lewtec/rotulador/internal/storage"
 	"github.com/lewtec/rotulador/internal/web"
 	"github.com/spf13/cobra"
 )
@@ -19,7 +30,47 @@ import (
 var ingestCmd = &cobra.Command{
 	Use:   "ingest",
 	Short: "Ingest a folder of files to a folder of images.",
-	Long:  `Ingest a folder of files that were extracted from somewhere and organize in a flat hierarchy of images.`,
+	Long: `Ingest a folder of files that were extracted from somewhere and organize in a flat hierarchy of images.
+
+Images are re-encoded as <sha256>.png by default. With --keep-original the
+source bytes are copied unchanged as <sha256>.<ext> once they are known to
+decode, which keeps JPEG datasets small and preserves EXIF/ICC metadata.
+
+JPEG, PNG, GIF, WebP, TIFF and BMP are decoded natively. HEIC/HEIF photos
+are converted to PNG with an external converter, heif-convert or magick from
+PATH unless --heic-converter says otherwise. Images in formats browsers
+cannot display, such as TIFF, are converted to PNG when served.
+
+Inputs can also be zip, tar or tar.gz archives. Their members are streamed
+through the same pipeline without unpacking the archive; members that are
+not images, have unsafe paths or exceed the size and compression ratio
+limits are skipped.
+
+Video files are sampled into frames: one every --frame-interval, and with
+--scene-threshold also whenever the picture changes by that many bits of
+perceptual hash. Animated GIF, MJPEG AVI and bare MJPEG streams are decoded
+natively; other formats (mp4, mkv, mov, ...) need ffmpeg, which is looked up
+in PATH unless --ffmpeg says otherwise. Each frame records the video as
+source_path and its time as frame_offset_ms.
+
+With --database the original path, format, dimensions, byte size and EXIF
+capture date and camera of every ingested image are recorded in the images
+table, along with the tags given by --tag. Images read from an archive
+record the archive as source_archive and the member path as source_path.
+
+The output can also be an S3-compatible bucket given as s3://bucket/prefix,
+reached with the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_REGION and,
+for services such as MinIO, AWS_ENDPOINT_URL environment variables.
+
+Once done, the number of ingested images and of skipped files per reason
+(unsupported format, too large, archive limit, ...) is logged.
+
+Example:
+  rotulador ingest --keep-original -d annotations.db ./raw ./images
+  rotulador ingest -d annotations.db --tag night --tag cam_a ./raw ./images
+  rotulador ingest -d annotations.db dump.tar.gz ./images
+  rotulador ingest -d annotations.db --frame-interval 5s --scene-threshold 12 ./dashcam ./images
+  rotulador ingest --keep-original -d annotations.db ./raw s3://datasets/images`,
 	Args: func(cmd *cobra.Command, args []string) error {
 		if err := cobra.MinimumNArgs(2)(cmd, args); err != nil {
 			return err
@@ -31,10 +82,14 @@ var ingestCmd = &cobra.Command{
 			if err != nil {
 				return fmt.Errorf("on %dth argument: %w", i+1, err)
 			}
-			if !fileInfo.IsDir() {
-				return fmt.Errorf("on %dth argument: must be a directory", i+1)
+			if !fileInfo.IsDir() && !web.IsArchive(input) && !web.IsVideo(input) {
+				return fmt.Errorf("on %dth argument: must be a directory, a zip, tar or tar.gz archive or a video", i+1)
 			}
 		}
+		// Buckets need no preparation; a missing one fails on the first upload
+		if storage.IsRemote(output) {
+			return nil
+		}
 		return os.MkdirAll(output, 0777)
 	},
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -48,18 +103,73 @@ var ingestCmd = &cobra.Command{
 		if jobs < 1 {
 			return fmt.Errorf("--jobs must be at least 1, got %d", jobs)
 		}
+		if len(ingestTags) > 0 && ingestDatabase == "" {
+			return fmt.Errorf("--tag requires --database")
+		}
+		if frameInterval < 0 || sceneThreshold < 0 || sceneThreshold > 64 {
+			return fmt.Errorf("--frame-interval must not be negative and --scene-threshold must be between 0 and 64")
+		}
+		video := web.VideoOptions{Interval: frameInterval, SceneThreshold: sceneThreshold, FFmpeg: ffmpegPath}
+		if video.FFmpeg == "" {
+			// Optional: without it only the natively decoded formats work
+			video.FFmpeg, _ = exec.LookPath("ffmpeg")
+		}
+		heic := heicConverter
+		if heic == "" {
+			heic = lookupHEICConverter()
+		}
 		inputs := args[0 : len(args)-1]
-		output := args[len(args)-1]
+		output, err := storage.Open(args[len(args)-1])
+		if err != nil {
+			return fmt.Errorf("open output storage: %w", err)
+		}
+
+		var images domain.ImageRepository
+		if ingestDatabase != "" {
+			db, err := web.GetDatabase(ingestDatabase)
+			if err != nil {
+				return fmt.Errorf("open database: %w", err)
+			}
+			defer func() {
+				if err := db.Close(); err != nil {
+					web.ReportError(cmd.Context(), err, "msg", "failed to close database")
+				}
+			}()
+			if err := runMigrations(db); err != nil {
+				return fmt.Errorf("migrate database: %w", err)
+			}
+			images = repository.NewImageRepository(db)
+		}
 
-		crawledFilepaths := make(chan image.Image, 10) // pipeline
+		crawledFilepaths := make(chan crawledImage, 10) // pipeline
+		skipped := &skipCounter{counts: map[string]int{}}
+		var ingested atomic.Int64
 
 		var wg sync.WaitGroup
-		ingestWorker := func(queue chan image.Image) {
+		ingestWorker := func(queue chan crawledImage) {
 			defer wg.Done()
-			for image := range queue {
-				err := web.IngestImage(image, output)
+			for item := range queue {
+				name, meta, err := ingestOne(cmd.Context(), item, output)
 				if err != nil {
-					web.ReportError(cmd.Context(), err, "msg", "ingesting image failed")
+					web.ReportError(cmd.Context(), err, "msg", "ingesting image failed", "path", item.path)
+					continue
+				}
+				ingested.Add(1)
+				if images == nil {
+					continue
+				}
+				sha := strings.TrimSuffix(name, filepath.Ext(name))
+				if _, err := images.CreateWithMetadata(cmd.Context(), sha, name, meta); err != nil {
+					web.ReportError(cmd.Context(), err, "msg", "recording image failed", "path", item.path)
+					continue
+				}
+				if err := images.SetPHash(cmd.Context(), sha, web.PerceptualHash(item.img)); err != nil {
+					web.ReportError(cmd.Context(), err, "msg", "recording perceptual hash failed", "path", item.path)
+				}
+				for _, tag := range ingestTags {
+					if err := images.AddTag(cmd.Context(), sha, tag); err != nil {
+						web.ReportError(cmd.Context(), err, "msg", "recording tag failed", "path", item.path, "tag", tag)
+					}
 				}
 			}
 		}
@@ -73,6 +183,20 @@ var ingestCmd = &cobra.Command{
 		// range and hung forever on Wait.
 		var walkErr error
 		for _, input := range inputs {
+			if web.IsArchive(input) {
+				if err := crawlArchive(input, crawledFilepaths, skipped, logger); err != nil {
+					walkErr = fmt.Errorf("reading input archive %s: %w", input, err)
+					break
+				}
+				continue
+			}
+			if web.IsVideo(input) {
+				if err := crawlVideo(cmd.Context(), input, video, crawledFilepaths, logger); err != nil {
+					walkErr = fmt.Errorf("reading input video %s: %w", input, err)
+					break
+				}
+				continue
+			}
 			if err := filepath.WalkDir(input, func(path string, info fs.DirEntry, err error) error {
 				if err != nil {
 					return err
@@ -80,14 +204,28 @@ var ingestCmd = &cobra.Command{
 				if info.IsDir() {
 					return nil
 				}
-				img, err := web.DecodeImage(path)
+				if web.IsVideo(path) {
+					if err := crawlVideo(cmd.Context(), path, video, crawledFilepaths, logger); err != nil {
+						if cmd.Context().Err() != nil {
+							return err
+						}
+						logger.Warn("skipping video", "path", path, "err", err)
+						skipped.add(err)
+					}
+					return nil
+				}
+				item, err := crawlFile(cmd.Context(), path, heic)
 				if err != nil {
+					if cmd.Context().Err() != nil {
+						return err
+					}
 					// Mixed input folders commonly contain non-images; skip them.
 					logger.Debug("skipping non-image file", "path", path, "err", err)
+					skipped.add(err)
 					return nil
 				}
-				logger.Info("found image", "path", path)
-				crawledFilepaths <- img
+				logger.Info("found image", "path", item.path)
+				crawledFilepaths <- item
 				return nil
 			}); err != nil {
 				walkErr = fmt.Errorf("walking input directory %s: %w", input, err)
@@ -96,15 +234,181 @@ var ingestCmd = &cobra.Command{
 		}
 		close(crawledFilepaths)
 		wg.Wait()
+		skipped.log(logger, ingested.Load())
 		return walkErr
 	},
 }
 
+// crawledImage is a decoded input file waiting for an ingest worker.
+type crawledImage struct {
+	path string
+	img  image.Image
+	meta domain.ImageMetadata
+	// data holds the bytes of archive members, MJPEG frames and converted
+	// HEIC photos, which have no file of their own
+	data []byte
+}
+
+// skipCounter tallies the input files ingest could not use, per SkipReason.
+type skipCounter struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (s *skipCounter) add(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counts[web.SkipReason(err)]++
+}
+
+// log writes the ingest summary, one line per skip reason.
+func (s *skipCounter) log(logger *slog.Logger, ingested int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	total := 0
+	for _, n := range s.counts {
+		total += n
+	}
+	logger.Info("ingest summary", "ingested", ingested, "skipped", total)
+	reasons := make([]string, 0, len(s.counts))
+	for reason := range s.counts {
+		reasons = append(reasons, reason)
+	}
+	slices.Sort(reasons)
+	for _, reason := range reasons {
+		logger.Info("skipped files", "reason", reason, "count", s.counts[reason])
+	}
+}
+
+// lookupHEICConverter returns the first HEIC converter found in PATH, or ""
+// when there is none.
+func lookupHEICConverter() string {
+	for _, name := range []string{"heif-convert", "magick"} {
+		if path, err := exec.LookPath(name); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// crawlFile decodes a single input file. HEIC photos are converted to PNG
+// first, so they are queued with the converted bytes.
+func crawlFile(ctx context.Context, path, heicConverter string) (crawledImage, error) {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if web.IsHEIC(path) {
+		data, err := web.ConvertHEIC(ctx, heicConverter, path)
+		if err != nil {
+			return crawledImage{}, err
+		}
+		img, meta, err := web.InspectImageBytes(data)
+		if err != nil {
+			return crawledImage{}, err
+		}
+		meta.SourcePath = path
+		return crawledImage{path: path, img: img, meta: meta, data: data}, nil
+	}
+	img, meta, err := web.InspectImage(path)
+	if err != nil {
+		return crawledImage{}, err
+	}
+	meta.SourcePath = path
+	return crawledImage{path: path, img: img, meta: meta}, nil
+}
+
+// crawlArchive queues the images of an archive for the ingest workers.
+func crawlArchive(archivePath string, queue chan<- crawledImage, skipped *skipCounter, logger *slog.Logger) error {
+	if abs, err := filepath.Abs(archivePath); err == nil {
+		archivePath = abs
+	}
+	return web.WalkArchive(archivePath, web.DefaultArchiveLimits, func(member string, data []byte, err error) error {
+		if err != nil {
+			logger.Warn("skipping archive member", "archive", archivePath, "member", member, "err", err)
+			skipped.add(err)
+			return nil
+		}
+		img, meta, err := web.InspectImageBytes(data)
+		if err != nil {
+			logger.Debug("skipping non-image archive member", "archive", archivePath, "member", member, "err", err)
+			skipped.add(err)
+			return nil
+		}
+		meta.SourcePath = member
+		meta.SourceArchive = archivePath
+		logger.Info("found image", "archive", archivePath, "member", member)
+		queue <- crawledImage{path: archivePath + "!/" + member, img: img, meta: meta, data: data}
+		return nil
+	})
+}
+
+// crawlVideo queues the frames of a video sampled according to opts.
+func crawlVideo(ctx context.Context, videoPath string, opts web.VideoOptions, queue chan<- crawledImage, logger *slog.Logger) error {
+	if abs, err := filepath.Abs(videoPath); err == nil {
+		videoPath = abs
+	}
+	frames := 0
+	err := web.ExtractFrames(ctx, videoPath, opts, func(frame web.VideoFrame) error {
+		offset := frame.Offset
+		b := frame.Image.Bounds()
+		meta := domain.ImageMetadata{SourcePath: videoPath, FrameOffset: &offset, Width: b.Dx(), Height: b.Dy()}
+		if frame.JPEG != nil {
+			meta.Format = "jpeg"
+			meta.SizeBytes = int64(len(frame.JPEG))
+		}
+		frames++
+		queue <- crawledImage{path: fmt.Sprintf("%s@%s", videoPath, offset), img: frame.Image, meta: meta, data: frame.JPEG}
+		return nil
+	})
+	logger.Info("sampled video", "path", videoPath, "frames", frames)
+	return err
+}
+
+// ingestOne stores item in output, either byte for byte or re-encoded as
+// PNG, and returns the stored file name with the metadata of that file.
+func ingestOne(ctx context.Context, item crawledImage, output storage.Store) (string, domain.ImageMetadata, error) {
+	if keepOriginal && item.data != nil {
+		name, err := web.IngestOriginalFrom(ctx, bytes.NewReader(item.data), item.meta.Format, output)
+		return name, item.meta, err
+	}
+	// Frames decoded from GIFs or through ffmpeg have no original bytes
+	if keepOriginal && item.meta.FrameOffset == nil {
+		name, err := web.IngestOriginal(ctx, item.path, item.meta.Format, output)
+		return name, item.meta, err
+	}
+	name, err := web.IngestImage(ctx, item.img, output)
+	if err != nil {
+		return "", domain.ImageMetadata{}, err
+	}
+	info, err := output.Stat(ctx, name)
+	if err != nil {
+		return "", domain.ImageMetadata{}, err
+	}
+	meta := item.meta
+	meta.Format = "png"
+	meta.SizeBytes = info.Size
+	return name, meta, nil
+}
+
 var (
-	jobs uint
+	jobs           uint
+	keepOriginal   bool
+	ingestDatabase string
+	ingestTags     []string
+	frameInterval  time.Duration
+	sceneThreshold int
+	ffmpegPath     string
+	heicConverter  string
 )
 
 func init() {
 	rootCmd.AddCommand(ingestCmd)
 	ingestCmd.PersistentFlags().UintVarP(&jobs, "jobs", "j", 1, "Amount of concurrent ingestors (must be >= 1)")
+	ingestCmd.PersistentFlags().BoolVar(&keepOriginal, "keep-original", false, "Copy the original bytes as <sha256>.<ext> instead of re-encoding to PNG")
+	ingestCmd.PersistentFlags().StringVarP(&ingestDatabase, "database", "d", "", "Record source path, format, dimensions and size of ingested images in this database")
+	ingestCmd.PersistentFlags().StringArrayVarP(&ingestTags, "tag", "t", nil, "Tag every ingested image (repeatable, requires --database)")
+	ingestCmd.PersistentFlags().DurationVar(&frameInterval, "frame-interval", time.Second, "Keep one video frame per interval (0 keeps every frame unless --scene-threshold is set)")
+	ingestCmd.PersistentFlags().IntVar(&sceneThreshold, "scene-threshold", 0, "Also keep video frames whose perceptual hash differs from the last kept one by this many bits (0 disables)")
+	ingestCmd.PersistentFlags().StringVar(&ffmpegPath, "ffmpeg", "", "ffmpeg binary for videos without a native decoder (default: ffmpeg from PATH)")
+	ingestCmd.PersistentFlags().StringVar(&heicConverter, "heic-converter", "", "Converter run as '<converter> input output.png' for HEIC/HEIF photos (default: heif-convert or magick from PATH)")
 }
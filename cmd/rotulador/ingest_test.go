@@ -1,12 +1,28 @@
 package main
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/lewtec/rotulador/internal/domain"
+	"github.com/lewtec/rotulador/internal/repository"
+	"github.com/lewtec/rotulador/internal/storage"
+	"github.com/lewtec/rotulador/internal/web"
+	"golang.org/x/image/tiff"
 )
 
 func TestIngestCmd_RejectsZeroJobs(t *testing.T) {
@@ -79,3 +95,314 @@ func TestIngestCmd_WalkErrorDoesNotHang(t *testing.T) {
 		t.Fatalf("error = %v, want walking input directory", err)
 	}
 }
+
+func TestIngestCmd_KeepOriginalRecordsMetadata(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in")
+	out := filepath.Join(dir, "out")
+	dbPath := filepath.Join(dir, "annotations.db")
+	if err := os.MkdirAll(in, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(in, "photo.jpeg")
+	f, err := os.Create(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 24, 12))
+	if err := jpeg.Encode(f, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	original, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prevJobs, prevKeep, prevDB, prevTags := jobs, keepOriginal, ingestDatabase, ingestTags
+	t.Cleanup(func() { jobs, keepOriginal, ingestDatabase, ingestTags = prevJobs, prevKeep, prevDB, prevTags })
+	// cobra only hands the root context to a subcommand without one, and the
+	// tests above leave ingestCmd holding their cancelled contexts.
+	ingestCmd.SetContext(t.Context())
+
+	if _, _, err := executeCommand(t, "ingest", "--keep-original", "-d", dbPath, "--tag", "night", in, out); err != nil {
+		t.Fatalf("ingest: %v", err)
+	}
+
+	sum := sha256.Sum256(original)
+	name := fmt.Sprintf("%x.jpg", sum)
+	copied, err := os.ReadFile(filepath.Join(out, name))
+	if err != nil {
+		t.Fatalf("expected %s in output: %v", name, err)
+	}
+	if !bytes.Equal(copied, original) {
+		t.Error("stored bytes differ from the source file")
+	}
+
+	db, err := web.GetDatabase(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+	got, err := repository.NewImageRepository(db).GetBySHA256(t.Context(), fmt.Sprintf("%x", sum))
+	if err != nil || got == nil {
+		t.Fatalf("GetBySHA256 = %v, %v", got, err)
+	}
+	want := domain.ImageMetadata{SourcePath: src, Format: "jpeg", Width: 24, Height: 12, SizeBytes: int64(len(original))}
+	if got.Filename != name || got.ImageMetadata != want {
+		t.Errorf("recorded %q %+v, want %q %+v", got.Filename, got.ImageMetadata, name, want)
+	}
+	tags, err := repository.NewImageRepository(db).ListTags(t.Context(), got.SHA256)
+	if err != nil || len(tags) != 1 || tags[0] != "night" {
+		t.Errorf("tags = %v, %v, want [night]", tags, err)
+	}
+}
+
+func TestIngestCmd_UploadsToBucket(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in")
+	if err := os.MkdirAll(in, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(filepath.Join(in, "a.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, image.NewGray(image.Rect(0, 0, 8, 8))); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	fake := storage.NewFakeS3(t, "datasets")
+	t.Setenv("AWS_ENDPOINT_URL", fake.Server.URL)
+	t.Setenv("AWS_ACCESS_KEY_ID", fake.AccessKey)
+	t.Setenv("AWS_SECRET_ACCESS_KEY", fake.SecretKey)
+	t.Setenv("AWS_REGION", fake.Region)
+
+	prevJobs, prevKeep, prevDB, prevTags := jobs, keepOriginal, ingestDatabase, ingestTags
+	t.Cleanup(func() { jobs, keepOriginal, ingestDatabase, ingestTags = prevJobs, prevKeep, prevDB, prevTags })
+	ingestCmd.SetContext(t.Context())
+
+	dbPath := filepath.Join(dir, "annotations.db")
+	if _, _, err := executeCommand(t, "ingest", "-d", dbPath, in, "s3://datasets/images"); err != nil {
+		t.Fatalf("ingest: %v", err)
+	}
+	keys := fake.Keys()
+	if len(keys) != 1 || !strings.HasPrefix(keys[0], "images/") || !strings.HasSuffix(keys[0], ".png") {
+		t.Fatalf("bucket keys = %v, want one PNG under images/", keys)
+	}
+	db, err := web.GetDatabase(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+	name := strings.TrimPrefix(keys[0], "images/")
+	got, err := repository.NewImageRepository(db).GetBySHA256(t.Context(), strings.TrimSuffix(name, ".png"))
+	if err != nil || got.Filename != name || got.SizeBytes == 0 {
+		t.Errorf("GetBySHA256 = %+v, %v; want %s with its size", got, err, name)
+	}
+}
+
+func TestIngestCmd_ReadsArchives(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	dbPath := filepath.Join(dir, "annotations.db")
+	archivePath := filepath.Join(dir, "dump.zip")
+
+	var img bytes.Buffer
+	if err := jpeg.Encode(&img, image.NewRGBA(image.Rect(0, 0, 8, 4)), nil); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range map[string][]byte{
+		"cam/photo.jpg":  img.Bytes(),
+		"README.txt":     []byte("not an image"),
+		"../escaped.jpg": img.Bytes(),
+	} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(archivePath, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	prevJobs, prevKeep, prevDB, prevTags := jobs, keepOriginal, ingestDatabase, ingestTags
+	t.Cleanup(func() { jobs, keepOriginal, ingestDatabase, ingestTags = prevJobs, prevKeep, prevDB, prevTags })
+	ingestCmd.SetContext(t.Context())
+
+	if _, _, err := executeCommand(t, "ingest", "--keep-original", "-d", dbPath, archivePath, out); err != nil {
+		t.Fatalf("ingest: %v", err)
+	}
+
+	sum := sha256.Sum256(img.Bytes())
+	if _, err := os.Stat(filepath.Join(out, fmt.Sprintf("%x.jpg", sum))); err != nil {
+		t.Fatalf("archive member not stored: %v", err)
+	}
+	entries, err := os.ReadDir(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("output has %d files, want only the image", len(entries))
+	}
+
+	db, err := web.GetDatabase(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+	got, err := repository.NewImageRepository(db).GetBySHA256(t.Context(), fmt.Sprintf("%x", sum))
+	if err != nil || got == nil {
+		t.Fatalf("GetBySHA256 = %v, %v", got, err)
+	}
+	if got.SourcePath != "cam/photo.jpg" || got.SourceArchive != archivePath {
+		t.Errorf("recorded source %q in %q, want cam/photo.jpg in %q", got.SourcePath, got.SourceArchive, archivePath)
+	}
+}
+
+func TestIngestCmd_SamplesVideoFrames(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in")
+	out := filepath.Join(dir, "out")
+	dbPath := filepath.Join(dir, "annotations.db")
+	if err := os.MkdirAll(in, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Four frames 500ms apart, each a different solid colour
+	anim := &gif.GIF{}
+	for i := range 4 {
+		frame := image.NewPaletted(image.Rect(0, 0, 8, 8), palette.Plan9)
+		for p := range frame.Pix {
+			frame.Pix[p] = uint8(i * 40)
+		}
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, 50)
+	}
+	video := filepath.Join(in, "clip.gif")
+	f, err := os.Create(video)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gif.EncodeAll(f, anim); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	prevJobs, prevKeep, prevDB, prevTags := jobs, keepOriginal, ingestDatabase, ingestTags
+	prevInterval, prevThreshold := frameInterval, sceneThreshold
+	t.Cleanup(func() {
+		jobs, keepOriginal, ingestDatabase, ingestTags = prevJobs, prevKeep, prevDB, prevTags
+		frameInterval, sceneThreshold = prevInterval, prevThreshold
+	})
+	ingestCmd.SetContext(t.Context())
+
+	if _, _, err := executeCommand(t, "ingest", "--keep-original", "--frame-interval", "1s", "-d", dbPath, in, out); err != nil {
+		t.Fatalf("ingest: %v", err)
+	}
+
+	db, err := web.GetDatabase(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+	images, err := repository.NewImageRepository(db).ListFiltered(t.Context(), domain.ImageFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(images) != 2 {
+		t.Fatalf("recorded %d frames, want the ones at 0s and 1s", len(images))
+	}
+	seen := map[time.Duration]bool{}
+	for _, img := range images {
+		if img.FrameOffset == nil || img.SourcePath != video || img.Format != "png" {
+			t.Fatalf("unexpected frame %+v", img)
+		}
+		seen[*img.FrameOffset] = true
+	}
+	if !seen[0] || !seen[time.Second] {
+		t.Errorf("frame offsets = %v, want 0s and 1s", seen)
+	}
+}
+
+func TestIngestCmd_SummarizesSkippedFiles(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake HEIC converter is a shell script")
+	}
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in")
+	out := filepath.Join(dir, "out")
+	if err := os.MkdirAll(in, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	var buf bytes.Buffer
+	if err := tiff.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{
+		"scan.tiff": buf.Bytes(),
+		"IMG.heic":  []byte("converted by the fake converter"),
+		"note.txt":  []byte("not an image"),
+		"todo.md":   []byte("not an image either"),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(in, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	converted := filepath.Join(dir, "converted.png")
+	f, err := os.Create(converted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, image.NewGray(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	converter := filepath.Join(dir, "heif-convert")
+	if err := os.WriteFile(converter, []byte("#!/bin/sh\ncp '"+converted+"' \"$2\"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	prevJobs, prevKeep, prevHEIC := jobs, keepOriginal, heicConverter
+	t.Cleanup(func() { jobs, keepOriginal, heicConverter = prevJobs, prevKeep, prevHEIC })
+	ingestCmd.SetContext(t.Context())
+
+	_, errOut, err := executeCommand(t, "ingest", "--keep-original", "--heic-converter", converter, in, out)
+	if err != nil {
+		t.Fatalf("ingest: %v", err)
+	}
+	for _, want := range []string{"ingested=2 skipped=2", `reason="unsupported format" count=2`} {
+		if !strings.Contains(errOut, want) {
+			t.Errorf("log %q does not contain %q", errOut, want)
+		}
+	}
+	entries, err := os.ReadDir(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exts := map[string]bool{}
+	for _, e := range entries {
+		exts[filepath.Ext(e.Name())] = true
+	}
+	if len(entries) != 2 || !exts[".tiff"] || !exts[".png"] {
+		t.Errorf("stored %v, want the TIFF as is and the HEIC converted to PNG", entries)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/lewtec/rotulador/internal/domain"
 	"github.com/lewtec/rotulador/internal/web"
 	"github.com/spf13/cobra"
 )
@@ -86,10 +87,11 @@ Example:
 
 			logger.Info("Scanning images directory", "absPath", absPath)
 			app := &web.AnnotatorApp{
-				ImagesDir: absPath,
-				Database:  db,
-				Config:    config,
-				Logger:    logger,
+				ImagesDir:   absPath,
+				Database:    db,
+				Config:      config,
+				Logger:      logger,
+				EventSource: domain.EventSourceCLI,
 			}
 
 			if err := app.PrepareDatabase(cmd.Context()); err != nil {
@@ -147,9 +149,21 @@ meta:
 auth:
   admin:
     password: "changeme"
+    admin: true  # Can open /admin pages
   annotator:
     password: "changeme"
 
+# Image leases - an image is reserved for the user it was served to so
+# concurrent annotators do not label the same image (optional)
+# leases:
+#   minutes: 5  # 0 uses the default, a negative value disables leases
+
+# Images directory watcher - files added while the server runs are ingested
+# right away; removed files hide their image from the queues (optional)
+# watch:
+#   disabled: false
+#   on_remove: missing  # or "delete" to also delete the image's annotations
+
 # Tasks - define the annotation workflow
 tasks:
   # Example 1: Simple classification task
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lewtec/rotulador/internal/domain"
+	"github.com/lewtec/rotulador/internal/repository"
+	"github.com/lewtec/rotulador/internal/web"
+	"github.com/spf13/cobra"
+)
+
+var logColumns = []string{"time", "kind", "source", "actor", "username", "task", "stage_index", "sha256", "old_value", "new_value"}
+
+var logCmd = &cobra.Command{
+	Use:   "log [flags] database",
+	Short: "Show the history of annotation changes",
+	Long: `Print the annotation history, newest first, as tab-separated columns.
+
+Every create, overwrite, delete, review and legacy import of an annotation is
+recorded with who made it (actor), whose annotation it is (username), where
+it came from (web, api, import or cli) and the value before and after.
+Deleted images keep their history.
+
+--image takes a SHA256 or a filename. --user matches both the annotator and
+the actor. --since takes a date or an RFC 3339 time.
+
+Examples:
+  rotulador log annotations.db
+
+  # Everything that happened to an image
+  rotulador log --image photo.jpg annotations.db
+
+  # What alice did or had done to her annotations this month
+  rotulador log --user alice --since 2026-10-01 annotations.db`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		image, err := cmd.Flags().GetString("image")
+		if err != nil {
+			return err
+		}
+		user, err := cmd.Flags().GetString("user")
+		if err != nil {
+			return err
+		}
+		stage, err := cmd.Flags().GetInt("stage")
+		if err != nil {
+			return err
+		}
+		since, err := cmd.Flags().GetString("since")
+		if err != nil {
+			return err
+		}
+		limit, err := cmd.Flags().GetInt("limit")
+		if err != nil {
+			return err
+		}
+		configFile, err := cmd.Flags().GetString("config")
+		if err != nil {
+			return err
+		}
+
+		filter := domain.EventFilter{Username: user}
+		if stage >= 0 {
+			filter.StageIndex = &stage
+		}
+		if since != "" {
+			filter.Since, err = parseSince(since)
+			if err != nil {
+				return err
+			}
+		}
+
+		var tasks []string
+		if configFile != "" {
+			config, err := web.LoadConfig(configFile)
+			if err != nil {
+				return fmt.Errorf("load config: %w", err)
+			}
+			for _, task := range config.Tasks {
+				tasks = append(tasks, task.ID)
+			}
+		}
+
+		db, err := web.GetDatabase(args[0])
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := db.Close(); err != nil {
+				web.ReportError(cmd.Context(), err, "msg", "failed to close database")
+			}
+		}()
+
+		if image != "" {
+			filter.ImageSHA256, err = resolveImage(cmd.Context(), db, image)
+			if err != nil {
+				return err
+			}
+		}
+		return writeLog(cmd.Context(), cmd.OutOrStdout(), db, filter, limit, tasks)
+	},
+}
+
+// parseSince reads --since as a date in UTC or an RFC 3339 time.
+func parseSince(v string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("--since %q: expected YYYY-MM-DD or an RFC 3339 time", v)
+	}
+	return t, nil
+}
+
+// resolveImage maps a filename to the SHA256 of its image. Anything else is
+// taken as a SHA256, which also finds the history of deleted images.
+func resolveImage(ctx context.Context, db *sql.DB, ref string) (string, error) {
+	img, err := repository.NewImageRepository(db).GetByFilename(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("while looking up image '%s': %w", ref, err)
+	}
+	if img != nil {
+		return img.SHA256, nil
+	}
+	return ref, nil
+}
+
+func writeLog(ctx context.Context, out io.Writer, db *sql.DB, filter domain.EventFilter, limit int, tasks []string) error {
+	events, err := repository.NewEventRepository(db).List(ctx, filter, limit)
+	if err != nil {
+		return fmt.Errorf("while listing events: %w", err)
+	}
+
+	if _, err := fmt.Fprintln(out, strings.Join(logColumns, "\t")); err != nil {
+		return err
+	}
+	for _, event := range events {
+		var task string
+		if event.StageIndex < len(tasks) {
+			task = tasks[event.StageIndex]
+		}
+		row := []string{
+			event.CreatedAt.UTC().Format(time.RFC3339), event.Kind, event.Source, event.Actor, event.Username,
+			task, strconv.Itoa(event.StageIndex), event.ImageSHA256, event.OldValue, event.NewValue,
+		}
+		if _, err := fmt.Fprintln(out, strings.Join(row, "\t")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(logCmd)
+
+	logCmd.Flags().StringP("image", "I", "", "Only events of this image, by SHA256 or filename")
+	logCmd.Flags().StringP("user", "u", "", "Only events by or about this user")
+	logCmd.Flags().IntP("stage", "s", -1, "Only events of this stage index")
+	logCmd.Flags().String("since", "", "Only events from this date or time on")
+	logCmd.Flags().IntP("limit", "n", 100, "Maximum number of events to show")
+	logCmd.Flags().StringP("config", "c", "", "Config file used to name tasks in the task column")
+}
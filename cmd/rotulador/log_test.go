@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lewtec/rotulador/internal/domain"
+	"github.com/lewtec/rotulador/internal/repository"
+	"github.com/lewtec/rotulador/internal/web"
+	"github.com/spf13/pflag"
+)
+
+func TestLog(t *testing.T) {
+	dbPath, _ := setupQueryTestDB(t)
+
+	db, err := web.GetDatabase(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := repository.NewEventRepository(db)
+	for _, event := range []*domain.AnnotationEvent{
+		{Kind: domain.EventCreate, Source: domain.EventSourceWeb, Actor: "admin", ImageSHA256: "abc123", Username: "admin", NewValue: "landscape"},
+		{Kind: domain.EventReview, Source: domain.EventSourceWeb, Actor: "carol", ImageSHA256: "abc123", Username: "admin", OldValue: "landscape", NewValue: "portrait"},
+		{Kind: domain.EventCreate, Source: domain.EventSourceAPI, Actor: "admin", ImageSHA256: "def456", Username: "admin", NewValue: "portrait"},
+		{Kind: domain.EventDelete, Source: domain.EventSourceCLI, Actor: "fsck", ImageSHA256: "gone", Username: "admin", OldValue: "portrait"},
+	} {
+		if _, err := events.Create(t.Context(), event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { resetLogFlags(t) })
+	stdout, _, err := executeCommand(t, "log", "--image", "photo.jpg", dbPath)
+	if err != nil {
+		t.Fatalf("log: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != 3 || lines[0] != strings.Join(logColumns, "\t") {
+		t.Fatalf("got %d lines, want header and 2 events of photo.jpg:\n%s", len(lines), stdout)
+	}
+	if row := strings.Split(lines[1], "\t"); row[1] != "review" || row[3] != "carol" || row[8] != "landscape" || row[9] != "portrait" {
+		t.Errorf("newest event = %v, want carol's review", row)
+	}
+
+	// Deleted images are found by SHA256
+	resetLogFlags(t)
+	stdout, _, err = executeCommand(t, "log", "-I", "gone", dbPath)
+	if err != nil {
+		t.Fatalf("log: %v", err)
+	}
+	if !strings.Contains(stdout, "delete\tcli\tfsck\tadmin") {
+		t.Errorf("log of a deleted image:\n%s", stdout)
+	}
+
+	resetLogFlags(t)
+	stdout, _, err = executeCommand(t, "log", "-u", "carol", "-n", "5", dbPath)
+	if err != nil {
+		t.Fatalf("log: %v", err)
+	}
+	if got := strings.Count(stdout, "\n"); got != 2 {
+		t.Errorf("log -u carol printed %d lines, want header and the review:\n%s", got, stdout)
+	}
+
+	resetLogFlags(t)
+	if _, _, err := executeCommand(t, "log", "--since", "last week", dbPath); err == nil {
+		t.Error("expected error for an invalid --since")
+	}
+}
+
+func resetLogFlags(t *testing.T) {
+	t.Helper()
+	logCmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		if err := flag.Value.Set(flag.DefValue); err != nil {
+			t.Fatal(err)
+		}
+		flag.Changed = false
+	})
+	// cobra keeps the context of the previous run, cancelled by now
+	logCmd.SetContext(t.Context())
+}
@@ -17,6 +17,8 @@ import (
 	migrateSqlite "github.com/golang-migrate/migrate/v4/database/sqlite"
 	"github.com/golang-migrate/migrate/v4/source/iofs"
 	"github.com/lewtec/rotulador/internal/db/migrations"
+	"github.com/lewtec/rotulador/internal/domain"
+	"github.com/lewtec/rotulador/internal/repository"
 	"github.com/lewtec/rotulador/internal/web"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v3"
@@ -270,6 +272,7 @@ func migrateTaskAnnotations(ctx context.Context, oldDB *sql.DB, newTx *sql.Tx, t
 		}
 	}()
 
+	events := repository.NewEventRepositoryWithTx(newTx)
 	annotationCount := 0
 	for rows.Next() {
 		var ann LegacyAnnotation
@@ -280,15 +283,30 @@ func migrateTaskAnnotations(ctx context.Context, oldDB *sql.DB, newTx *sql.Tx, t
 			logger.Warn("annotation references unknown image, skipping", "image", ann.Image)
 			continue
 		}
-		_, err := newTx.ExecContext(ctx,
+		var annotationID int64
+		err := newTx.QueryRowContext(ctx,
 			`INSERT INTO annotations (image_sha256, username, stage_index, option_value)
 			 VALUES (?, ?, ?, ?)
 			 ON CONFLICT(image_sha256, username, stage_index)
-			 DO UPDATE SET option_value = excluded.option_value`,
-			ann.Image, ann.User, stageIndex, ann.Value)
+			 DO UPDATE SET option_value = excluded.option_value
+			 RETURNING id`,
+			ann.Image, ann.User, stageIndex, ann.Value).Scan(&annotationID)
 		if err != nil {
 			return 0, fmt.Errorf("insert annotation: %w", err)
 		}
+		_, err = events.Create(ctx, &domain.AnnotationEvent{
+			Kind:         domain.EventImport,
+			Source:       domain.EventSourceImport,
+			Actor:        "migrate-legacy-db",
+			ImageSHA256:  ann.Image,
+			StageIndex:   stageIndex,
+			Username:     ann.User,
+			AnnotationID: annotationID,
+			NewValue:     ann.Value,
+		})
+		if err != nil {
+			return 0, fmt.Errorf("record import event: %w", err)
+		}
 		annotationCount++
 	}
 	return annotationCount, rows.Err()
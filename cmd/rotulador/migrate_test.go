@@ -150,4 +150,15 @@ tasks:
 	if value != "good" {
 		t.Fatalf("value = %q", value)
 	}
+
+	var kind, source, newValue string
+	if err := newDB.QueryRow(
+		`SELECT kind, source, new_value FROM annotation_events WHERE image_sha256 = ? AND username = ?`,
+		"abc", "admin",
+	).Scan(&kind, &source, &newValue); err != nil {
+		t.Fatalf("event row: %v", err)
+	}
+	if kind != "import" || source != "import" || newValue != "good" {
+		t.Fatalf("event = %s/%s/%s, want an import of good", kind, source, newValue)
+	}
 }
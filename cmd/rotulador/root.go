@@ -19,6 +19,9 @@ import (
 
 	"io/fs"
 
+	"github.com/lewtec/rotulador/internal/report"
+	"github.com/lewtec/rotulador/internal/storage"
+	"github.com/lewtec/rotulador/internal/tracing"
 	"github.com/lewtec/rotulador/internal/web"
 	"github.com/spf13/cobra"
 )
@@ -148,8 +151,21 @@ With a set of trivial choices scale the classification of a set of images to man
 		if imagesDir == "" {
 			imagesDir = filepath.Join(filepath.Dir(configFile), "images")
 		}
+		store, err := storage.Open(imagesDir)
+		if err != nil {
+			return fmt.Errorf("open images storage: %w", err)
+		}
 
-		// 5. Server startup logic
+		// 5. Determine cacheDir
+		cacheDir, err := cmd.Flags().GetString("cache")
+		if err != nil {
+			return fmt.Errorf("read cache flag: %w", err)
+		}
+		if cacheDir == "" {
+			cacheDir = filepath.Join(filepath.Dir(configFile), "cache")
+		}
+
+		// 6. Server startup logic
 		logger.Info("Initializing project...")
 
 		config, err := web.LoadConfig(configFile)
@@ -157,6 +173,32 @@ With a set of trivial choices scale the classification of a set of images to man
 			return fmt.Errorf("load config: %w", err)
 		}
 
+		stopReporting, err := startReporting(config.Reporting, logger)
+		if err != nil {
+			return fmt.Errorf("start error reporting: %w", err)
+		}
+		defer stopReporting()
+
+		if config.Tracing.Exporter != "" {
+			shutdownTracing, err := tracing.Setup(cmd.Context(), tracing.Options{
+				Exporter: config.Tracing.Exporter,
+				Endpoint: config.Tracing.Endpoint,
+				Stdout:   cmd.OutOrStdout(),
+				Version:  version,
+			})
+			if err != nil {
+				return fmt.Errorf("start tracing: %w", err)
+			}
+			logger.Info("Tracing", "exporter", config.Tracing.Exporter)
+			defer func() {
+				ctx, cancel := context.WithTimeout(context.WithoutCancel(cmd.Context()), 5*time.Second)
+				defer cancel()
+				if err := shutdownTracing(ctx); err != nil {
+					logger.Warn("some spans were not exported", "err", err)
+				}
+			}()
+		}
+
 		db, err := web.GetDatabase(databaseFile)
 		if err != nil {
 			return fmt.Errorf("open database: %w", err)
@@ -169,6 +211,8 @@ With a set of trivial choices scale the classification of a set of images to man
 
 		app := &web.AnnotatorApp{
 			ImagesDir: imagesDir,
+			Store:     store,
+			CacheDir:  cacheDir,
 			Database:  db,
 			Config:    config,
 			Logger:    logger,
@@ -178,6 +222,11 @@ With a set of trivial choices scale the classification of a set of images to man
 		if err := app.PrepareDatabaseMigrations(cmd.Context()); err != nil {
 			return fmt.Errorf("prepare database: %w", err)
 		}
+		// Recompute progress now rather than on the first request when the
+		// tasks changed
+		if err := app.SyncTaskRules(cmd.Context()); err != nil {
+			return fmt.Errorf("sync task rules: %w", err)
+		}
 
 		addr, err := cmd.Flags().GetString("addr")
 		if err != nil {
@@ -194,6 +243,24 @@ With a set of trivial choices scale the classification of a set of images to man
 			logger.Info("  -", "id", task.ID, "name", task.Name)
 		}
 
+		// Pick up files added or removed while the server runs. Started before
+		// the initial ingestion so nothing copied in the meantime is missed.
+		// Buckets have no change notifications; restart to pick up changes.
+		if !config.Watch.Disabled && !storage.IsRemote(imagesDir) {
+			go func() {
+				if err := app.WatchImages(cmd.Context()); err != nil && !errors.Is(err, context.Canceled) {
+					web.ReportError(cmd.Context(), err, "msg", "images directory watcher stopped")
+				}
+			}()
+		}
+
+		// Expired leases no longer reserve anything; drop them as they pile up
+		go func() {
+			if err := app.PruneLeases(cmd.Context()); err != nil && !errors.Is(err, context.Canceled) {
+				web.ReportError(cmd.Context(), err, "msg", "lease pruning stopped")
+			}
+		}()
+
 		// Start image ingestion in background (non-blocking)
 		go func() {
 			if err := app.IngestImages(cmd.Context()); err != nil {
@@ -221,6 +288,26 @@ With a set of trivial choices scale the classification of a set of images to man
 				return cmd.Context()
 			},
 		}
+		if config.Metrics.Enabled && config.Metrics.Listen != "" {
+			logger.Info("Serving metrics", "addr", config.Metrics.Listen)
+			metricsMux := http.NewServeMux()
+			metricsMux.Handle("/metrics", app.MetricsHandler())
+			metricsServer := &http.Server{
+				Addr:              config.Metrics.Listen,
+				Handler:           metricsMux,
+				ReadHeaderTimeout: 10 * time.Second,
+				WriteTimeout:      60 * time.Second,
+				BaseContext: func(_ net.Listener) context.Context {
+					return cmd.Context()
+				},
+			}
+			go func() {
+				if err := serveHTTP(cmd.Context(), metricsServer); err != nil {
+					web.ReportError(cmd.Context(), err, "msg", "metrics server stopped")
+				}
+			}()
+		}
+
 		// Honor SIGINT/SIGTERM (and test timeouts) via command context instead of
 		// blocking forever in ListenAndServe.
 		return serveHTTP(cmd.Context(), server)
@@ -261,6 +348,37 @@ func serveHTTP(ctx context.Context, server *http.Server) error {
 	}
 }
 
+// startReporting sends the errors passed to web.ReportError to the Sentry DSN
+// of the config or of the SENTRY_DSN environment variable, when either is set.
+// The returned function sends the events still queued.
+func startReporting(config web.ConfigReporting, logger *slog.Logger) (func(), error) {
+	dsn := config.DSN
+	if dsn == "" {
+		dsn = os.Getenv("SENTRY_DSN")
+	}
+	if dsn == "" {
+		return func() {}, nil
+	}
+	client, err := report.NewSentry(report.SentryOptions{
+		DSN:         dsn,
+		Release:     version,
+		Environment: config.Environment,
+	})
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("Reporting errors to Sentry")
+	web.SetReporter(report.NewLimiter(client, time.Minute, config.MaxReports()))
+	return func() {
+		web.SetReporter(nil)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := client.Close(ctx); err != nil {
+			logger.Warn("some errors were not sent to Sentry", "err", err)
+		}
+	}, nil
+}
+
 func main() {
 	var logger *slog.Logger
 	// Pre-initialize logger before cobra parsing
@@ -315,7 +433,8 @@ func init() {
 	// Optional flags (only used when not providing a folder argument)
 	rootCmd.Flags().StringP("config", "c", "", "Config file for the annotation")
 	rootCmd.Flags().StringP("database", "d", "", "Database file path (defaults to annotations.db in config file's directory)")
-	rootCmd.Flags().StringP("images", "i", "", "Images directory path (defaults to 'images' in config file's directory)")
+	rootCmd.Flags().StringP("images", "i", "", "Images directory path or s3://bucket/prefix (defaults to 'images' in config file's directory)")
+	rootCmd.Flags().String("cache", "", "Directory for resized image variants (defaults to 'cache' in config file's directory)")
 	rootCmd.Flags().StringP("addr", "a", ":8080", "Address to bind the webserver")
 	rootCmd.PersistentFlags().Bool("json", false, "Enable JSON logging")
 }
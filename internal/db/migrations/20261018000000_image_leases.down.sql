@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_image_leases_expires_at;
+DROP INDEX IF EXISTS idx_image_leases_username;
+DROP TABLE IF EXISTS image_leases;
@@ -0,0 +1,15 @@
+-- Image leases reserve an image for one user on one stage while it is being
+-- annotated, so concurrent annotators are not served the same image.
+-- Expired rows are ignored by every query and reused on the next acquire.
+CREATE TABLE image_leases (
+  image_sha256 TEXT NOT NULL,
+  stage_index INTEGER NOT NULL,
+  username TEXT NOT NULL,
+  leased_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  expires_at TIMESTAMP NOT NULL,
+  PRIMARY KEY(image_sha256, stage_index),
+  FOREIGN KEY(image_sha256) REFERENCES images(sha256) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_image_leases_username ON image_leases(username, stage_index);
+CREATE INDEX idx_image_leases_expires_at ON image_leases(expires_at);
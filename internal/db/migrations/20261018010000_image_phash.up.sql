@@ -0,0 +1,3 @@
+-- Perceptual (difference) hash of the decoded image, computed at ingest time.
+-- Stored as the signed 64-bit reinterpretation of the unsigned hash.
+ALTER TABLE images ADD COLUMN phash INTEGER;
@@ -0,0 +1,5 @@
+ALTER TABLE images DROP COLUMN size_bytes;
+ALTER TABLE images DROP COLUMN height;
+ALTER TABLE images DROP COLUMN width;
+ALTER TABLE images DROP COLUMN format;
+ALTER TABLE images DROP COLUMN source_path;
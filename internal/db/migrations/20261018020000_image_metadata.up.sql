@@ -0,0 +1,7 @@
+-- Metadata captured at ingest. Nullable: rows ingested before this migration
+-- are filled in on the next ingestion pass, source_path only by 'ingest'.
+ALTER TABLE images ADD COLUMN source_path TEXT;
+ALTER TABLE images ADD COLUMN format TEXT;
+ALTER TABLE images ADD COLUMN width INTEGER;
+ALTER TABLE images ADD COLUMN height INTEGER;
+ALTER TABLE images ADD COLUMN size_bytes INTEGER;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_image_tags_tag;
+DROP TABLE IF EXISTS image_tags;
+ALTER TABLE images DROP COLUMN camera;
+ALTER TABLE images DROP COLUMN captured_at;
@@ -0,0 +1,12 @@
+-- EXIF capture date and camera, NULL when the file carries no EXIF data.
+ALTER TABLE images ADD COLUMN captured_at DATETIME;
+ALTER TABLE images ADD COLUMN camera TEXT;
+
+CREATE TABLE image_tags (
+    image_sha256 TEXT NOT NULL,
+    tag TEXT NOT NULL,
+    PRIMARY KEY (image_sha256, tag),
+    FOREIGN KEY (image_sha256) REFERENCES images(sha256) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_image_tags_tag ON image_tags(tag);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_ingest_index_image;
+DROP TABLE IF EXISTS ingest_index;
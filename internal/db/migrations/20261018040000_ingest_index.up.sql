@@ -0,0 +1,12 @@
+-- Remembers which file produced which image so unchanged files are not
+-- decoded and hashed again on every start. path is relative to ImagesDir.
+CREATE TABLE ingest_index (
+    path TEXT PRIMARY KEY,
+    size_bytes INTEGER NOT NULL,
+    mtime_ns INTEGER NOT NULL,
+    image_sha256 TEXT NOT NULL,
+    indexed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (image_sha256) REFERENCES images(sha256) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_ingest_index_image ON ingest_index(image_sha256);
@@ -0,0 +1,3 @@
+-- Set when the last file of an image disappears from the images directory.
+-- Missing images keep their annotations but are left out of queues and counts.
+ALTER TABLE images ADD COLUMN missing_at DATETIME;
@@ -0,0 +1 @@
+ALTER TABLE images DROP COLUMN source_archive;
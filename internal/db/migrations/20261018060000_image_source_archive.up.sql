@@ -0,0 +1,3 @@
+-- Archive an image was read from by 'ingest'; source_path is then the member
+-- path inside it.
+ALTER TABLE images ADD COLUMN source_archive TEXT;
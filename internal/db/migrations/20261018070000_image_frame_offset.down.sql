@@ -0,0 +1 @@
+ALTER TABLE images DROP COLUMN frame_offset_ms;
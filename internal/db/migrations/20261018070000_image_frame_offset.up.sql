@@ -0,0 +1,3 @@
+-- Set for frames 'ingest' extracted from a video; source_path is then the
+-- video and frame_offset_ms the time of the frame in it.
+ALTER TABLE images ADD COLUMN frame_offset_ms INTEGER;
@@ -0,0 +1,3 @@
+-- Whether the annotator ticked "sure" when answering. Annotations recorded
+-- before this column existed are assumed sure.
+ALTER TABLE annotations ADD COLUMN sure BOOLEAN NOT NULL DEFAULT TRUE;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_reviews_annotation_id;
+DROP TABLE IF EXISTS reviews;
@@ -0,0 +1,15 @@
+-- Reviews are second-pass verdicts on annotations. option_value is the label
+-- the reviewer settled on: the annotation's own when accepted, the
+-- correction otherwise.
+CREATE TABLE reviews (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  annotation_id INTEGER NOT NULL,
+  reviewer TEXT NOT NULL,
+  outcome TEXT NOT NULL CHECK (outcome IN ('accepted', 'corrected')),
+  option_value TEXT NOT NULL,
+  reviewed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE(annotation_id, reviewer),
+  FOREIGN KEY(annotation_id) REFERENCES annotations(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_reviews_annotation_id ON reviews(annotation_id);
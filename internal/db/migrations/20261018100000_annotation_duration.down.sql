@@ -0,0 +1 @@
+ALTER TABLE annotations DROP COLUMN duration_ms;
@@ -0,0 +1,3 @@
+-- Milliseconds between the image being shown and the answer being sent.
+-- NULL for annotations recorded before this column existed.
+ALTER TABLE annotations ADD COLUMN duration_ms INTEGER;
@@ -0,0 +1,4 @@
+DROP TRIGGER annotation_events_no_delete;
+DROP TRIGGER annotation_events_no_update;
+DROP INDEX idx_annotation_events_image_sha256;
+DROP TABLE annotation_events;
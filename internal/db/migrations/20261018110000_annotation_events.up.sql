@@ -0,0 +1,30 @@
+-- Append-only history of every change to annotations. Rows outlive the
+-- annotations and images they describe, so there are no foreign keys.
+-- username owns the annotation, actor made the change. old_value and
+-- new_value are NULL when the kind has none: creates have no old value,
+-- deletes no new one.
+CREATE TABLE annotation_events (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  kind TEXT NOT NULL CHECK (kind IN ('create', 'overwrite', 'delete', 'review', 'import')),
+  source TEXT NOT NULL CHECK (source IN ('web', 'api', 'import', 'cli')),
+  actor TEXT NOT NULL,
+  image_sha256 TEXT NOT NULL,
+  stage_index INTEGER NOT NULL,
+  username TEXT NOT NULL,
+  annotation_id INTEGER,
+  old_value TEXT,
+  new_value TEXT,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_annotation_events_image_sha256 ON annotation_events(image_sha256);
+
+CREATE TRIGGER annotation_events_no_update BEFORE UPDATE ON annotation_events
+BEGIN
+  SELECT RAISE(ABORT, 'annotation_events is append-only');
+END;
+
+CREATE TRIGGER annotation_events_no_delete BEFORE DELETE ON annotation_events
+BEGIN
+  SELECT RAISE(ABORT, 'annotation_events is append-only');
+END;
@@ -0,0 +1,22 @@
+DROP TRIGGER task_images_refresh_tag_delete;
+DROP TRIGGER task_images_refresh_tag_insert;
+DROP TRIGGER task_images_refresh_review_delete;
+DROP TRIGGER task_images_refresh_review_update;
+DROP TRIGGER task_images_refresh_review_insert;
+DROP TRIGGER task_images_refresh_annotation_delete;
+DROP TRIGGER task_images_refresh_annotation_update;
+DROP TRIGGER task_images_refresh_annotation_insert;
+DROP TRIGGER task_images_refresh_image_update;
+DROP TRIGGER task_images_refresh_image_insert;
+DROP TRIGGER task_counts_delete;
+DROP TRIGGER task_counts_update;
+DROP TRIGGER task_counts_insert;
+DROP TABLE task_counts;
+DROP INDEX idx_task_images_image_sha256;
+DROP INDEX idx_task_images_status;
+DROP TABLE task_images;
+DROP VIEW task_image_status;
+DROP INDEX idx_annotations_image_stage;
+DROP TABLE task_rules_fingerprint;
+DROP TABLE task_dependencies;
+DROP TABLE task_rules;
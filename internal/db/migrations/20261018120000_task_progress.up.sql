@@ -0,0 +1,325 @@
+-- Per-task progress kept up to date by triggers, so the annotate page reads
+-- counters instead of scanning every image. task_rules and
+-- task_dependencies mirror the tasks of the config, stage by stage, and are
+-- rewritten by the server when they change. Every other table is derived
+-- from them and from images, annotations, reviews and image_tags.
+
+-- The image metadata conditions of a task If clause. NULL matches all.
+CREATE TABLE task_rules (
+  stage_index INTEGER PRIMARY KEY,
+  format TEXT,
+  camera TEXT,
+  tag TEXT,
+  min_width INTEGER,
+  max_width INTEGER,
+  min_height INTEGER,
+  max_height INTEGER,
+  min_size INTEGER,
+  max_size INTEGER,
+  captured_after DATETIME,
+  captured_before DATETIME,
+  source_glob TEXT,
+  dir_glob TEXT
+);
+
+-- The task conditions of a task If clause: an image qualifies once it was
+-- labeled option_value in dep_stage_index, its latest review taking
+-- precedence over its annotations. A dep_stage_index of -1 stands for a key
+-- that is neither a task nor an image field, which no image satisfies.
+CREATE TABLE task_dependencies (
+  stage_index INTEGER NOT NULL,
+  dep_stage_index INTEGER NOT NULL,
+  option_value TEXT NOT NULL,
+  PRIMARY KEY (stage_index, dep_stage_index)
+);
+
+-- Fingerprint of the rules above, to rebuild task_images only when the
+-- tasks of the config change.
+CREATE TABLE task_rules_fingerprint (
+  id INTEGER PRIMARY KEY CHECK (id = 1),
+  fingerprint TEXT NOT NULL
+);
+
+-- Lets the triggers below look up the annotations of one image in one task
+CREATE INDEX idx_annotations_image_stage ON annotations(image_sha256, stage_index);
+
+-- Where every present image stands in every task, computed from scratch.
+-- Only used for one image at a time by the triggers below, and for rebuilds.
+CREATE VIEW task_image_status AS
+SELECT
+  r.stage_index,
+  i.sha256 AS image_sha256,
+  -- A condition on a field the image lacks is NULL, which does not qualify
+  COALESCE((r.format IS NULL OR i.format = r.format)
+    AND (r.camera IS NULL OR i.camera = r.camera)
+    AND (r.min_width IS NULL OR i.width >= r.min_width)
+    AND (r.max_width IS NULL OR i.width <= r.max_width)
+    AND (r.min_height IS NULL OR i.height >= r.min_height)
+    AND (r.max_height IS NULL OR i.height <= r.max_height)
+    AND (r.min_size IS NULL OR i.size_bytes >= r.min_size)
+    AND (r.max_size IS NULL OR i.size_bytes <= r.max_size)
+    AND (r.captured_after IS NULL OR i.captured_at >= r.captured_after)
+    AND (r.captured_before IS NULL OR i.captured_at < r.captured_before)
+    AND (r.source_glob IS NULL OR i.source_path GLOB r.source_glob)
+    AND (r.dir_glob IS NULL OR i.filename GLOB r.dir_glob)
+    AND (r.tag IS NULL OR EXISTS (
+      SELECT 1 FROM image_tags t WHERE t.image_sha256 = i.sha256 AND t.tag = r.tag
+    ))
+    AND NOT EXISTS (
+      SELECT 1 FROM task_dependencies d
+      WHERE d.stage_index = r.stage_index
+        AND NOT COALESCE((
+          SELECT rv.option_value = d.option_value
+          FROM reviews rv
+          JOIN annotations reviewed ON rv.annotation_id = reviewed.id
+          WHERE reviewed.image_sha256 = i.sha256 AND reviewed.stage_index = d.dep_stage_index
+          ORDER BY rv.reviewed_at DESC, rv.id DESC
+          LIMIT 1
+        ), EXISTS (
+          SELECT 1 FROM annotations a
+          WHERE a.image_sha256 = i.sha256
+            AND a.stage_index = d.dep_stage_index
+            AND a.option_value = d.option_value
+        ))
+    ), 0) AS eligible,
+  EXISTS (
+    SELECT 1 FROM annotations a
+    WHERE a.image_sha256 = i.sha256 AND a.stage_index = r.stage_index
+  ) AS annotated,
+  EXISTS (
+    SELECT 1 FROM task_dependencies d
+    JOIN annotations a ON a.stage_index = d.dep_stage_index
+    WHERE d.stage_index = r.stage_index AND a.image_sha256 = i.sha256
+  ) AS dep_annotated
+FROM task_rules r
+JOIN images i ON i.missing_at IS NULL;
+
+-- One row per task and present image. dep_annotated images were annotated
+-- in a dependency task, whatever the label.
+CREATE TABLE task_images (
+  stage_index INTEGER NOT NULL,
+  image_sha256 TEXT NOT NULL,
+  eligible BOOLEAN NOT NULL,
+  annotated BOOLEAN NOT NULL,
+  dep_annotated BOOLEAN NOT NULL,
+  PRIMARY KEY (stage_index, image_sha256),
+  FOREIGN KEY (image_sha256) REFERENCES images(sha256) ON DELETE CASCADE
+) WITHOUT ROWID;
+
+-- Finds the images still to annotate in a task without a scan
+CREATE INDEX idx_task_images_status ON task_images(stage_index, eligible, annotated);
+CREATE INDEX idx_task_images_image_sha256 ON task_images(image_sha256);
+
+-- Totals of task_images per task. available images are eligible and not
+-- annotated yet, filtered ones were annotated in a dependency task but do not
+-- qualify for this one.
+CREATE TABLE task_counts (
+  stage_index INTEGER PRIMARY KEY,
+  total INTEGER NOT NULL DEFAULT 0,
+  eligible INTEGER NOT NULL DEFAULT 0,
+  available INTEGER NOT NULL DEFAULT 0,
+  filtered INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TRIGGER task_counts_insert AFTER INSERT ON task_images
+BEGIN
+  INSERT INTO task_counts (stage_index) VALUES (NEW.stage_index) ON CONFLICT DO NOTHING;
+  UPDATE task_counts SET
+    total = total + 1,
+    eligible = eligible + NEW.eligible,
+    available = available + (NEW.eligible AND NOT NEW.annotated),
+    filtered = filtered + (NOT NEW.eligible AND NEW.dep_annotated)
+  WHERE stage_index = NEW.stage_index;
+END;
+
+CREATE TRIGGER task_counts_update AFTER UPDATE ON task_images
+BEGIN
+  UPDATE task_counts SET
+    eligible = eligible - OLD.eligible + NEW.eligible,
+    available = available - (OLD.eligible AND NOT OLD.annotated) + (NEW.eligible AND NOT NEW.annotated),
+    filtered = filtered - (NOT OLD.eligible AND OLD.dep_annotated) + (NOT NEW.eligible AND NEW.dep_annotated)
+  WHERE stage_index = NEW.stage_index;
+END;
+
+CREATE TRIGGER task_counts_delete AFTER DELETE ON task_images
+BEGIN
+  UPDATE task_counts SET
+    total = total - 1,
+    eligible = eligible - OLD.eligible,
+    available = available - (OLD.eligible AND NOT OLD.annotated),
+    filtered = filtered - (NOT OLD.eligible AND OLD.dep_annotated)
+  WHERE stage_index = OLD.stage_index;
+END;
+
+-- Refresh the rows of an image whenever something its status depends on
+-- changes. Deleted images go through the foreign key of task_images.
+
+CREATE TRIGGER task_images_refresh_image_insert AFTER INSERT ON images
+BEGIN
+  INSERT INTO task_images (stage_index, image_sha256, eligible, annotated, dep_annotated)
+  SELECT stage_index, image_sha256, eligible, annotated, dep_annotated
+  FROM task_image_status WHERE image_sha256 = NEW.sha256
+  ON CONFLICT (stage_index, image_sha256) DO UPDATE SET
+    eligible = excluded.eligible,
+    annotated = excluded.annotated,
+    dep_annotated = excluded.dep_annotated
+  WHERE task_images.eligible != excluded.eligible
+     OR task_images.annotated != excluded.annotated
+     OR task_images.dep_annotated != excluded.dep_annotated;
+  DELETE FROM task_images
+  WHERE image_sha256 = NEW.sha256
+    AND NOT EXISTS (SELECT 1 FROM images WHERE sha256 = NEW.sha256 AND missing_at IS NULL);
+END;
+
+CREATE TRIGGER task_images_refresh_image_update AFTER UPDATE OF filename, missing_at, format, camera, width, height, size_bytes, captured_at, source_path ON images
+BEGIN
+  INSERT INTO task_images (stage_index, image_sha256, eligible, annotated, dep_annotated)
+  SELECT stage_index, image_sha256, eligible, annotated, dep_annotated
+  FROM task_image_status WHERE image_sha256 = NEW.sha256
+  ON CONFLICT (stage_index, image_sha256) DO UPDATE SET
+    eligible = excluded.eligible,
+    annotated = excluded.annotated,
+    dep_annotated = excluded.dep_annotated
+  WHERE task_images.eligible != excluded.eligible
+     OR task_images.annotated != excluded.annotated
+     OR task_images.dep_annotated != excluded.dep_annotated;
+  DELETE FROM task_images
+  WHERE image_sha256 = NEW.sha256
+    AND NOT EXISTS (SELECT 1 FROM images WHERE sha256 = NEW.sha256 AND missing_at IS NULL);
+END;
+
+CREATE TRIGGER task_images_refresh_annotation_insert AFTER INSERT ON annotations
+BEGIN
+  INSERT INTO task_images (stage_index, image_sha256, eligible, annotated, dep_annotated)
+  SELECT stage_index, image_sha256, eligible, annotated, dep_annotated
+  FROM task_image_status WHERE image_sha256 = NEW.image_sha256
+  ON CONFLICT (stage_index, image_sha256) DO UPDATE SET
+    eligible = excluded.eligible,
+    annotated = excluded.annotated,
+    dep_annotated = excluded.dep_annotated
+  WHERE task_images.eligible != excluded.eligible
+     OR task_images.annotated != excluded.annotated
+     OR task_images.dep_annotated != excluded.dep_annotated;
+  DELETE FROM task_images
+  WHERE image_sha256 = NEW.image_sha256
+    AND NOT EXISTS (SELECT 1 FROM images WHERE sha256 = NEW.image_sha256 AND missing_at IS NULL);
+END;
+
+CREATE TRIGGER task_images_refresh_annotation_update AFTER UPDATE OF option_value, stage_index ON annotations
+BEGIN
+  INSERT INTO task_images (stage_index, image_sha256, eligible, annotated, dep_annotated)
+  SELECT stage_index, image_sha256, eligible, annotated, dep_annotated
+  FROM task_image_status WHERE image_sha256 = NEW.image_sha256
+  ON CONFLICT (stage_index, image_sha256) DO UPDATE SET
+    eligible = excluded.eligible,
+    annotated = excluded.annotated,
+    dep_annotated = excluded.dep_annotated
+  WHERE task_images.eligible != excluded.eligible
+     OR task_images.annotated != excluded.annotated
+     OR task_images.dep_annotated != excluded.dep_annotated;
+  DELETE FROM task_images
+  WHERE image_sha256 = NEW.image_sha256
+    AND NOT EXISTS (SELECT 1 FROM images WHERE sha256 = NEW.image_sha256 AND missing_at IS NULL);
+END;
+
+CREATE TRIGGER task_images_refresh_annotation_delete AFTER DELETE ON annotations
+BEGIN
+  INSERT INTO task_images (stage_index, image_sha256, eligible, annotated, dep_annotated)
+  SELECT stage_index, image_sha256, eligible, annotated, dep_annotated
+  FROM task_image_status WHERE image_sha256 = OLD.image_sha256
+  ON CONFLICT (stage_index, image_sha256) DO UPDATE SET
+    eligible = excluded.eligible,
+    annotated = excluded.annotated,
+    dep_annotated = excluded.dep_annotated
+  WHERE task_images.eligible != excluded.eligible
+     OR task_images.annotated != excluded.annotated
+     OR task_images.dep_annotated != excluded.dep_annotated;
+  DELETE FROM task_images
+  WHERE image_sha256 = OLD.image_sha256
+    AND NOT EXISTS (SELECT 1 FROM images WHERE sha256 = OLD.image_sha256 AND missing_at IS NULL);
+END;
+
+CREATE TRIGGER task_images_refresh_review_insert AFTER INSERT ON reviews
+BEGIN
+  INSERT INTO task_images (stage_index, image_sha256, eligible, annotated, dep_annotated)
+  SELECT stage_index, image_sha256, eligible, annotated, dep_annotated
+  FROM task_image_status WHERE image_sha256 = (SELECT image_sha256 FROM annotations WHERE id = NEW.annotation_id)
+  ON CONFLICT (stage_index, image_sha256) DO UPDATE SET
+    eligible = excluded.eligible,
+    annotated = excluded.annotated,
+    dep_annotated = excluded.dep_annotated
+  WHERE task_images.eligible != excluded.eligible
+     OR task_images.annotated != excluded.annotated
+     OR task_images.dep_annotated != excluded.dep_annotated;
+  DELETE FROM task_images
+  WHERE image_sha256 = (SELECT image_sha256 FROM annotations WHERE id = NEW.annotation_id)
+    AND NOT EXISTS (SELECT 1 FROM images WHERE sha256 = (SELECT image_sha256 FROM annotations WHERE id = NEW.annotation_id) AND missing_at IS NULL);
+END;
+
+CREATE TRIGGER task_images_refresh_review_update AFTER UPDATE OF option_value, reviewed_at ON reviews
+BEGIN
+  INSERT INTO task_images (stage_index, image_sha256, eligible, annotated, dep_annotated)
+  SELECT stage_index, image_sha256, eligible, annotated, dep_annotated
+  FROM task_image_status WHERE image_sha256 = (SELECT image_sha256 FROM annotations WHERE id = NEW.annotation_id)
+  ON CONFLICT (stage_index, image_sha256) DO UPDATE SET
+    eligible = excluded.eligible,
+    annotated = excluded.annotated,
+    dep_annotated = excluded.dep_annotated
+  WHERE task_images.eligible != excluded.eligible
+     OR task_images.annotated != excluded.annotated
+     OR task_images.dep_annotated != excluded.dep_annotated;
+  DELETE FROM task_images
+  WHERE image_sha256 = (SELECT image_sha256 FROM annotations WHERE id = NEW.annotation_id)
+    AND NOT EXISTS (SELECT 1 FROM images WHERE sha256 = (SELECT image_sha256 FROM annotations WHERE id = NEW.annotation_id) AND missing_at IS NULL);
+END;
+
+CREATE TRIGGER task_images_refresh_review_delete AFTER DELETE ON reviews
+BEGIN
+  INSERT INTO task_images (stage_index, image_sha256, eligible, annotated, dep_annotated)
+  SELECT stage_index, image_sha256, eligible, annotated, dep_annotated
+  FROM task_image_status WHERE image_sha256 = (SELECT image_sha256 FROM annotations WHERE id = OLD.annotation_id)
+  ON CONFLICT (stage_index, image_sha256) DO UPDATE SET
+    eligible = excluded.eligible,
+    annotated = excluded.annotated,
+    dep_annotated = excluded.dep_annotated
+  WHERE task_images.eligible != excluded.eligible
+     OR task_images.annotated != excluded.annotated
+     OR task_images.dep_annotated != excluded.dep_annotated;
+  DELETE FROM task_images
+  WHERE image_sha256 = (SELECT image_sha256 FROM annotations WHERE id = OLD.annotation_id)
+    AND NOT EXISTS (SELECT 1 FROM images WHERE sha256 = (SELECT image_sha256 FROM annotations WHERE id = OLD.annotation_id) AND missing_at IS NULL);
+END;
+
+CREATE TRIGGER task_images_refresh_tag_insert AFTER INSERT ON image_tags
+BEGIN
+  INSERT INTO task_images (stage_index, image_sha256, eligible, annotated, dep_annotated)
+  SELECT stage_index, image_sha256, eligible, annotated, dep_annotated
+  FROM task_image_status WHERE image_sha256 = NEW.image_sha256
+  ON CONFLICT (stage_index, image_sha256) DO UPDATE SET
+    eligible = excluded.eligible,
+    annotated = excluded.annotated,
+    dep_annotated = excluded.dep_annotated
+  WHERE task_images.eligible != excluded.eligible
+     OR task_images.annotated != excluded.annotated
+     OR task_images.dep_annotated != excluded.dep_annotated;
+  DELETE FROM task_images
+  WHERE image_sha256 = NEW.image_sha256
+    AND NOT EXISTS (SELECT 1 FROM images WHERE sha256 = NEW.image_sha256 AND missing_at IS NULL);
+END;
+
+CREATE TRIGGER task_images_refresh_tag_delete AFTER DELETE ON image_tags
+BEGIN
+  INSERT INTO task_images (stage_index, image_sha256, eligible, annotated, dep_annotated)
+  SELECT stage_index, image_sha256, eligible, annotated, dep_annotated
+  FROM task_image_status WHERE image_sha256 = OLD.image_sha256
+  ON CONFLICT (stage_index, image_sha256) DO UPDATE SET
+    eligible = excluded.eligible,
+    annotated = excluded.annotated,
+    dep_annotated = excluded.dep_annotated
+  WHERE task_images.eligible != excluded.eligible
+     OR task_images.annotated != excluded.annotated
+     OR task_images.dep_annotated != excluded.dep_annotated;
+  DELETE FROM task_images
+  WHERE image_sha256 = OLD.image_sha256
+    AND NOT EXISTS (SELECT 1 FROM images WHERE sha256 = OLD.image_sha256 AND missing_at IS NULL);
+END;
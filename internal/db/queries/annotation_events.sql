@@ -0,0 +1,15 @@
+-- name: CreateAnnotationEvent :one
+INSERT INTO annotation_events (kind, source, actor, image_sha256, stage_index, username, annotation_id, old_value, new_value)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: ListAnnotationEvents :many
+-- Events matching every filter, newest first; a NULL argument matches all.
+-- user matches both the owner of the annotation and the actor.
+SELECT * FROM annotation_events
+WHERE (image_sha256 = sqlc.narg(image_sha256) OR sqlc.narg(image_sha256) IS NULL)
+  AND (stage_index = sqlc.narg(stage_index) OR sqlc.narg(stage_index) IS NULL)
+  AND (username = sqlc.narg(username) OR actor = sqlc.narg(username) OR sqlc.narg(username) IS NULL)
+  AND (created_at >= sqlc.narg(since) OR sqlc.narg(since) IS NULL)
+ORDER BY id DESC
+LIMIT sqlc.arg(limit);
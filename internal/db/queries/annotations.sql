@@ -1,9 +1,11 @@
 -- name: CreateAnnotation :one
-INSERT INTO annotations (image_sha256, username, stage_index, option_value)
-VALUES (?, ?, ?, ?)
+INSERT INTO annotations (image_sha256, username, stage_index, option_value, sure, duration_ms)
+VALUES (?, ?, ?, ?, ?, ?)
 ON CONFLICT(image_sha256, username, stage_index)
 DO UPDATE SET
   option_value = excluded.option_value,
+  sure = excluded.sure,
+  duration_ms = excluded.duration_ms,
   annotated_at = CURRENT_TIMESTAMP
 RETURNING *;
 
@@ -11,6 +13,10 @@ RETURNING *;
 SELECT * FROM annotations
 WHERE image_sha256 = ? AND username = ? AND stage_index = ?;
 
+-- name: GetAnnotationByID :one
+SELECT * FROM annotations
+WHERE id = ?;
+
 -- name: GetAnnotationsForImage :many
 SELECT * FROM annotations
 WHERE image_sha256 = ?
@@ -40,7 +46,7 @@ WITH annotated_images AS (
 SELECT i.*
 FROM images i
 LEFT JOIN annotated_images ai ON i.sha256 = ai.image_sha256
-WHERE ai.image_sha256 IS NULL
+WHERE ai.image_sha256 IS NULL AND i.missing_at IS NULL
 ORDER BY i.filename ASC
 LIMIT ?;
 
@@ -73,12 +79,22 @@ WITH annotated_images AS (
 SELECT COUNT(*)
 FROM images i
 LEFT JOIN annotated_images ai ON i.sha256 = ai.image_sha256
-WHERE ai.image_sha256 IS NULL;
+WHERE ai.image_sha256 IS NULL AND i.missing_at IS NULL;
 
 -- name: GetImageHashesWithAnnotation :many
-SELECT DISTINCT image_sha256
-FROM annotations
-WHERE stage_index = ? AND option_value = ?;
+-- The latest review of an image in a stage takes precedence over its
+-- annotations.
+SELECT DISTINCT a.image_sha256
+FROM annotations a
+WHERE a.stage_index = sqlc.arg(stage_index)
+  AND COALESCE((
+    SELECT r.option_value
+    FROM reviews r
+    JOIN annotations reviewed ON r.annotation_id = reviewed.id
+    WHERE reviewed.image_sha256 = a.image_sha256 AND reviewed.stage_index = a.stage_index
+    ORDER BY r.reviewed_at DESC, r.id DESC
+    LIMIT 1
+  ), a.option_value) = sqlc.arg(option_value);
 
 -- name: CheckAnnotationExistsForImageStage :one
 SELECT CAST(EXISTS (
@@ -94,7 +110,7 @@ WITH annotated_images AS (
 SELECT COUNT(*)
 FROM images i
 LEFT JOIN annotated_images ai ON i.sha256 = ai.image_sha256
-WHERE ai.image_sha256 IS NULL;
+WHERE ai.image_sha256 IS NULL AND i.missing_at IS NULL;
 
 -- name: CountImagesWithAnnotation :one
 SELECT COUNT(DISTINCT image_sha256)
@@ -113,7 +129,7 @@ ORDER BY image_sha256;
 -- name: GetImagesWithoutAnnotationForStage :many
 SELECT i.sha256, i.filename
 FROM images i
-WHERE NOT EXISTS (
+WHERE i.missing_at IS NULL AND NOT EXISTS (
     SELECT 1 FROM annotations a
     WHERE a.image_sha256 = i.sha256 AND a.stage_index = ?
 )
@@ -124,3 +140,113 @@ SELECT COUNT(DISTINCT image_sha256)
 FROM annotations
 WHERE stage_index = ? AND option_value = ?
   AND image_sha256 IN (sqlc.slice('image_hashes'));
+
+-- name: ListAnnotatedImages :many
+-- Images with at least one annotation matching every filter; a NULL
+-- argument matches all annotations. Most recently annotated first.
+SELECT i.sha256, i.filename
+FROM annotations a
+JOIN images i ON i.sha256 = a.image_sha256
+WHERE (a.stage_index = sqlc.narg(stage_index) OR sqlc.narg(stage_index) IS NULL)
+  AND (a.option_value = sqlc.narg(option_value) OR sqlc.narg(option_value) IS NULL)
+  AND (a.username = sqlc.narg(username) OR sqlc.narg(username) IS NULL)
+  AND (a.annotated_at >= sqlc.narg(annotated_after) OR sqlc.narg(annotated_after) IS NULL)
+  AND (a.annotated_at < sqlc.narg(annotated_before) OR sqlc.narg(annotated_before) IS NULL)
+  AND (a.sure = sqlc.narg(sure) OR sqlc.narg(sure) IS NULL)
+  AND (EXISTS (
+    SELECT 1 FROM annotations other
+    WHERE other.image_sha256 = a.image_sha256
+      AND other.stage_index = a.stage_index
+      AND other.option_value <> a.option_value
+  ) OR CAST(sqlc.arg(disagreement_only) AS BOOLEAN) = FALSE)
+GROUP BY i.sha256, i.filename
+ORDER BY MAX(a.annotated_at) DESC, i.sha256
+LIMIT sqlc.arg(limit) OFFSET sqlc.arg(offset);
+
+-- name: CountAnnotatedImages :one
+-- Counts the images ListAnnotatedImages pages through.
+SELECT COUNT(DISTINCT a.image_sha256)
+FROM annotations a
+WHERE (a.stage_index = sqlc.narg(stage_index) OR sqlc.narg(stage_index) IS NULL)
+  AND (a.option_value = sqlc.narg(option_value) OR sqlc.narg(option_value) IS NULL)
+  AND (a.username = sqlc.narg(username) OR sqlc.narg(username) IS NULL)
+  AND (a.annotated_at >= sqlc.narg(annotated_after) OR sqlc.narg(annotated_after) IS NULL)
+  AND (a.annotated_at < sqlc.narg(annotated_before) OR sqlc.narg(annotated_before) IS NULL)
+  AND (a.sure = sqlc.narg(sure) OR sqlc.narg(sure) IS NULL)
+  AND (EXISTS (
+    SELECT 1 FROM annotations other
+    WHERE other.image_sha256 = a.image_sha256
+      AND other.stage_index = a.stage_index
+      AND other.option_value <> a.option_value
+  ) OR CAST(sqlc.arg(disagreement_only) AS BOOLEAN) = FALSE);
+
+-- name: GetUserStageStats :many
+-- Per user and stage: annotations, unsure answers, pairs of answers compared
+-- with other annotators of the same image and how many of them agreed, and
+-- annotations graded against the latest review of their image by someone
+-- else and how many of them matched it.
+WITH graded AS (
+  SELECT
+    a.username,
+    a.stage_index,
+    a.sure,
+    a.option_value,
+    (
+      SELECT COUNT(*) FROM annotations other
+      WHERE other.image_sha256 = a.image_sha256
+        AND other.stage_index = a.stage_index
+        AND other.username <> a.username
+    ) AS compared,
+    (
+      SELECT COUNT(*) FROM annotations other
+      WHERE other.image_sha256 = a.image_sha256
+        AND other.stage_index = a.stage_index
+        AND other.username <> a.username
+        AND other.option_value = a.option_value
+    ) AS agreed,
+    (
+      SELECT r.option_value
+      FROM reviews r
+      JOIN annotations reviewed ON r.annotation_id = reviewed.id
+      WHERE reviewed.image_sha256 = a.image_sha256
+        AND reviewed.stage_index = a.stage_index
+        AND r.reviewer <> a.username
+      ORDER BY r.reviewed_at DESC, r.id DESC
+      LIMIT 1
+    ) AS gold
+  FROM annotations a
+  WHERE (a.annotated_at >= sqlc.narg(annotated_after) OR sqlc.narg(annotated_after) IS NULL)
+    AND (a.annotated_at < sqlc.narg(annotated_before) OR sqlc.narg(annotated_before) IS NULL)
+)
+SELECT
+  username,
+  stage_index,
+  COUNT(*) AS annotations,
+  CAST(SUM(CASE WHEN sure THEN 0 ELSE 1 END) AS INTEGER) AS unsure,
+  CAST(SUM(compared) AS INTEGER) AS compared,
+  CAST(SUM(agreed) AS INTEGER) AS agreed,
+  COUNT(gold) AS graded,
+  CAST(SUM(CASE WHEN gold = option_value THEN 1 ELSE 0 END) AS INTEGER) AS correct
+FROM graded
+GROUP BY username, stage_index
+ORDER BY username, stage_index;
+
+-- name: GetUserDailyCounts :many
+SELECT
+  username,
+  CAST(DATE(annotated_at) AS TEXT) AS day,
+  COUNT(*) AS annotations
+FROM annotations
+WHERE (annotated_at >= sqlc.narg(annotated_after) OR sqlc.narg(annotated_after) IS NULL)
+  AND (annotated_at < sqlc.narg(annotated_before) OR sqlc.narg(annotated_before) IS NULL)
+GROUP BY username, day
+ORDER BY day, username;
+
+-- name: ListAnnotationDurations :many
+-- The annotations of the period with a recorded time-on-image.
+SELECT username, stage_index, option_value, CAST(duration_ms AS INTEGER) AS duration_ms
+FROM annotations
+WHERE duration_ms IS NOT NULL
+  AND (annotated_at >= sqlc.narg(annotated_after) OR sqlc.narg(annotated_after) IS NULL)
+  AND (annotated_at < sqlc.narg(annotated_before) OR sqlc.narg(annotated_before) IS NULL)
+ORDER BY username, stage_index, option_value;
@@ -1,7 +1,25 @@
 -- name: CreateImage :one
 INSERT INTO images (sha256, filename)
 VALUES (?, ?)
-ON CONFLICT(sha256) DO UPDATE SET filename = excluded.filename
+ON CONFLICT(sha256) DO UPDATE SET filename = excluded.filename, missing_at = NULL
+RETURNING *;
+
+-- name: CreateImageWithMetadata :one
+-- A NULL source_path keeps the source recorded by an earlier 'ingest' run.
+INSERT INTO images (sha256, filename, source_path, source_archive, frame_offset_ms, format, width, height, size_bytes, captured_at, camera)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(sha256) DO UPDATE SET
+  filename = excluded.filename,
+  source_path = COALESCE(excluded.source_path, images.source_path),
+  source_archive = CASE WHEN excluded.source_path IS NULL THEN images.source_archive ELSE excluded.source_archive END,
+  frame_offset_ms = CASE WHEN excluded.source_path IS NULL THEN images.frame_offset_ms ELSE excluded.frame_offset_ms END,
+  format = excluded.format,
+  width = excluded.width,
+  height = excluded.height,
+  size_bytes = excluded.size_bytes,
+  captured_at = excluded.captured_at,
+  camera = excluded.camera,
+  missing_at = NULL
 RETURNING *;
 
 -- name: GetImage :one
@@ -14,6 +32,7 @@ WHERE filename = ?;
 
 -- name: ListImages :many
 SELECT * FROM images
+WHERE missing_at IS NULL
 ORDER BY filename;
 
 -- name: ListImagesNotFinished :many
@@ -22,8 +41,71 @@ ORDER BY filename ASC
 LIMIT ?;
 
 -- name: CountImages :one
-SELECT COUNT(*) FROM images;
+SELECT COUNT(*) FROM images
+WHERE missing_at IS NULL;
 
 -- name: DeleteImage :exec
 DELETE FROM images
 WHERE sha256 = ?;
+
+-- name: MarkImageMissing :exec
+UPDATE images SET missing_at = CURRENT_TIMESTAMP
+WHERE sha256 = ? AND missing_at IS NULL;
+
+-- name: UpdateImagePHash :exec
+UPDATE images SET phash = ?
+WHERE sha256 = ?;
+
+-- name: ListImagePHashes :many
+SELECT sha256, filename, phash FROM images
+WHERE phash IS NOT NULL
+ORDER BY filename;
+
+-- name: CountImagesWithoutPHash :one
+SELECT COUNT(*) FROM images
+WHERE phash IS NULL AND missing_at IS NULL;
+
+-- name: ListImagesFiltered :many
+-- Every filter is optional; a NULL argument matches all images. Pages are
+-- sorted by filename and hash and resume after the given image, empty for
+-- the first page; a negative page_limit returns every image.
+SELECT * FROM images
+WHERE (missing_at IS NULL OR CAST(sqlc.arg(include_missing) AS BOOLEAN))
+  AND (filename, sha256) > (sqlc.arg(after_filename), sqlc.arg(after_sha256))
+  AND (format = sqlc.narg(format) OR sqlc.narg(format) IS NULL)
+  AND (camera = sqlc.narg(camera) OR sqlc.narg(camera) IS NULL)
+  AND (width >= sqlc.narg(min_width) OR sqlc.narg(min_width) IS NULL)
+  AND (width <= sqlc.narg(max_width) OR sqlc.narg(max_width) IS NULL)
+  AND (height >= sqlc.narg(min_height) OR sqlc.narg(min_height) IS NULL)
+  AND (height <= sqlc.narg(max_height) OR sqlc.narg(max_height) IS NULL)
+  AND (size_bytes >= sqlc.narg(min_size) OR sqlc.narg(min_size) IS NULL)
+  AND (size_bytes <= sqlc.narg(max_size) OR sqlc.narg(max_size) IS NULL)
+  AND (captured_at >= sqlc.narg(captured_after) OR sqlc.narg(captured_after) IS NULL)
+  AND (captured_at < sqlc.narg(captured_before) OR sqlc.narg(captured_before) IS NULL)
+  AND (source_path GLOB sqlc.narg(source_glob) OR sqlc.narg(source_glob) IS NULL)
+  AND (filename GLOB sqlc.narg(dir_glob) OR sqlc.narg(dir_glob) IS NULL)
+  AND (EXISTS (
+    SELECT 1 FROM image_tags
+    WHERE image_tags.image_sha256 = images.sha256 AND image_tags.tag = sqlc.narg(tag)
+  ) OR sqlc.narg(tag) IS NULL)
+ORDER BY filename, sha256
+LIMIT sqlc.arg(page_limit);
+
+-- name: AddImageTag :exec
+INSERT INTO image_tags (image_sha256, tag)
+VALUES (?, ?)
+ON CONFLICT DO NOTHING;
+
+-- name: RemoveImageTag :exec
+DELETE FROM image_tags
+WHERE image_sha256 = ? AND tag = ?;
+
+-- name: ListImageTags :many
+SELECT tag FROM image_tags
+WHERE image_sha256 = ?
+ORDER BY tag;
+
+-- name: ListTagsOfImages :many
+SELECT image_sha256, tag FROM image_tags
+WHERE image_sha256 IN (sqlc.slice('image_hashes'))
+ORDER BY image_sha256, tag;
@@ -0,0 +1,24 @@
+-- name: UpsertIngestIndexEntry :exec
+INSERT INTO ingest_index (path, size_bytes, mtime_ns, image_sha256)
+VALUES (?, ?, ?, ?)
+ON CONFLICT(path) DO UPDATE SET
+  size_bytes = excluded.size_bytes,
+  mtime_ns = excluded.mtime_ns,
+  image_sha256 = excluded.image_sha256,
+  indexed_at = CURRENT_TIMESTAMP;
+
+-- name: ListIngestIndexEntries :many
+SELECT * FROM ingest_index
+ORDER BY path;
+
+-- name: DeleteIngestIndexEntry :exec
+DELETE FROM ingest_index
+WHERE path = ?;
+
+-- name: GetIngestIndexEntry :one
+SELECT * FROM ingest_index
+WHERE path = ?;
+
+-- name: CountIngestIndexEntriesForImage :one
+SELECT COUNT(*) FROM ingest_index
+WHERE image_sha256 = ?;
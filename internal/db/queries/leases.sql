@@ -0,0 +1,42 @@
+-- name: AcquireLease :one
+-- Takes the lease when it is free, expired, or already held by the same user.
+-- Returns no row when another user holds an active lease.
+INSERT INTO image_leases (image_sha256, stage_index, username, expires_at)
+VALUES (sqlc.arg(image_sha256), sqlc.arg(stage_index), sqlc.arg(username), datetime('now', sqlc.arg(duration)))
+ON CONFLICT(image_sha256, stage_index)
+DO UPDATE SET
+  username = excluded.username,
+  leased_at = CURRENT_TIMESTAMP,
+  expires_at = excluded.expires_at
+WHERE image_leases.username = excluded.username
+   OR image_leases.expires_at <= CURRENT_TIMESTAMP
+RETURNING *;
+
+-- name: ReleaseLease :exec
+DELETE FROM image_leases
+WHERE image_sha256 = ? AND stage_index = ? AND username = ?;
+
+-- name: ReleaseLeaseForImageStage :exec
+DELETE FROM image_leases
+WHERE image_sha256 = ? AND stage_index = ?;
+
+-- name: GetActiveLeaseForUser :one
+SELECT * FROM image_leases
+WHERE username = ? AND stage_index = ? AND expires_at > CURRENT_TIMESTAMP
+ORDER BY leased_at DESC
+LIMIT 1;
+
+-- name: GetLeasedImageHashes :many
+SELECT image_sha256 FROM image_leases
+WHERE stage_index = ? AND username != ? AND expires_at > CURRENT_TIMESTAMP;
+
+-- name: ListActiveLeases :many
+SELECT l.*, i.filename
+FROM image_leases l
+JOIN images i ON l.image_sha256 = i.sha256
+WHERE l.expires_at > CURRENT_TIMESTAMP
+ORDER BY l.stage_index ASC, l.expires_at ASC;
+
+-- name: DeleteExpiredLeases :execrows
+DELETE FROM image_leases
+WHERE expires_at <= CURRENT_TIMESTAMP;
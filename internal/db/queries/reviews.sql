@@ -0,0 +1,56 @@
+-- name: CreateReview :one
+-- A reviewer reviewing the same annotation again replaces their verdict.
+INSERT INTO reviews (annotation_id, reviewer, outcome, option_value)
+VALUES (?, ?, ?, ?)
+ON CONFLICT(annotation_id, reviewer)
+DO UPDATE SET
+  outcome = excluded.outcome,
+  option_value = excluded.option_value,
+  reviewed_at = CURRENT_TIMESTAMP
+RETURNING *;
+
+-- name: DeleteReviewsForAnnotation :execrows
+DELETE FROM reviews
+WHERE annotation_id = ?;
+
+-- name: NextAnnotationToReview :one
+-- The oldest annotation of a stage nobody reviewed yet, leaving out the
+-- reviewer's own answers.
+SELECT a.*, i.filename
+FROM annotations a
+JOIN images i ON a.image_sha256 = i.sha256
+WHERE a.stage_index = sqlc.arg(stage_index)
+  AND a.username != sqlc.arg(reviewer)
+  AND i.missing_at IS NULL
+  AND NOT EXISTS (SELECT 1 FROM reviews r WHERE r.annotation_id = a.id)
+ORDER BY a.annotated_at ASC, a.id ASC
+LIMIT 1;
+
+-- name: CountAnnotationsToReview :one
+SELECT COUNT(*)
+FROM annotations a
+JOIN images i ON a.image_sha256 = i.sha256
+WHERE a.stage_index = sqlc.arg(stage_index)
+  AND a.username != sqlc.arg(reviewer)
+  AND i.missing_at IS NULL
+  AND NOT EXISTS (SELECT 1 FROM reviews r WHERE r.annotation_id = a.id);
+
+-- name: GetReviewsForImage :many
+SELECT r.*, a.stage_index, a.username
+FROM reviews r
+JOIN annotations a ON r.annotation_id = a.id
+WHERE a.image_sha256 = ?
+ORDER BY r.reviewed_at ASC, r.id ASC;
+
+-- name: GetAcceptanceRates :many
+-- Per annotator of a stage, how many of their annotations were reviewed and
+-- how many of those were accepted.
+SELECT
+  a.username,
+  COUNT(*) AS reviewed,
+  CAST(SUM(r.outcome = 'accepted') AS INTEGER) AS accepted
+FROM reviews r
+JOIN annotations a ON r.annotation_id = a.id
+WHERE a.stage_index = ?
+GROUP BY a.username
+ORDER BY a.username;
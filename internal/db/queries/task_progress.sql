@@ -0,0 +1,53 @@
+-- name: GetTaskRulesFingerprint :one
+SELECT fingerprint FROM task_rules_fingerprint WHERE id = 1;
+
+-- name: SetTaskRulesFingerprint :exec
+INSERT INTO task_rules_fingerprint (id, fingerprint) VALUES (1, ?)
+ON CONFLICT (id) DO UPDATE SET fingerprint = excluded.fingerprint;
+
+-- name: DeleteTaskRules :exec
+DELETE FROM task_rules;
+
+-- name: DeleteTaskDependencies :exec
+DELETE FROM task_dependencies;
+
+-- name: CreateTaskRule :exec
+INSERT INTO task_rules (
+  stage_index, format, camera, tag,
+  min_width, max_width, min_height, max_height, min_size, max_size,
+  captured_after, captured_before, source_glob, dir_glob
+) VALUES (
+  sqlc.arg(stage_index), sqlc.narg(format), sqlc.narg(camera), sqlc.narg(tag),
+  sqlc.narg(min_width), sqlc.narg(max_width), sqlc.narg(min_height), sqlc.narg(max_height),
+  sqlc.narg(min_size), sqlc.narg(max_size),
+  sqlc.narg(captured_after), sqlc.narg(captured_before), sqlc.narg(source_glob), sqlc.narg(dir_glob)
+);
+
+-- name: CreateTaskDependency :exec
+INSERT INTO task_dependencies (stage_index, dep_stage_index, option_value)
+VALUES (?, ?, ?);
+
+-- name: DeleteTaskImages :exec
+DELETE FROM task_images;
+
+-- name: DeleteTaskCounts :exec
+DELETE FROM task_counts;
+
+-- name: RebuildTaskImages :exec
+-- The insert triggers of task_images fill task_counts back in.
+INSERT INTO task_images (stage_index, image_sha256, eligible, annotated, dep_annotated)
+SELECT stage_index, image_sha256, eligible, annotated, dep_annotated
+FROM task_image_status;
+
+-- name: GetTaskCounts :one
+SELECT * FROM task_counts WHERE stage_index = ?;
+
+-- name: ListAvailableTaskImages :many
+-- In hash order, which follows idx_task_images_status and spreads the
+-- picks over the dataset.
+SELECT i.*
+FROM task_images t
+JOIN images i ON i.sha256 = t.image_sha256
+WHERE t.stage_index = ? AND t.eligible = 1 AND t.annotated = 0
+ORDER BY t.image_sha256
+LIMIT ?;
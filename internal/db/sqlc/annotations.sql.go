@@ -52,6 +52,49 @@ func (q *Queries) CheckAnnotationExistsForImageStage(ctx context.Context, arg Ch
 	return column_1, err
 }
 
+const countAnnotatedImages = `-- name: CountAnnotatedImages :one
+SELECT COUNT(DISTINCT a.image_sha256)
+FROM annotations a
+WHERE (a.stage_index = ?1 OR ?1 IS NULL)
+  AND (a.option_value = ?2 OR ?2 IS NULL)
+  AND (a.username = ?3 OR ?3 IS NULL)
+  AND (a.annotated_at >= ?4 OR ?4 IS NULL)
+  AND (a.annotated_at < ?5 OR ?5 IS NULL)
+  AND (a.sure = ?6 OR ?6 IS NULL)
+  AND (EXISTS (
+    SELECT 1 FROM annotations other
+    WHERE other.image_sha256 = a.image_sha256
+      AND other.stage_index = a.stage_index
+      AND other.option_value <> a.option_value
+  ) OR CAST(?7 AS BOOLEAN) = FALSE)
+`
+
+type CountAnnotatedImagesParams struct {
+	StageIndex       *int64     `json:"stage_index"`
+	OptionValue      *string    `json:"option_value"`
+	Username         *string    `json:"username"`
+	AnnotatedAfter   *time.Time `json:"annotated_after"`
+	AnnotatedBefore  *time.Time `json:"annotated_before"`
+	Sure             *bool      `json:"sure"`
+	DisagreementOnly bool       `json:"disagreement_only"`
+}
+
+// Counts the images ListAnnotatedImages pages through.
+func (q *Queries) CountAnnotatedImages(ctx context.Context, arg CountAnnotatedImagesParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countAnnotatedImages,
+		arg.StageIndex,
+		arg.OptionValue,
+		arg.Username,
+		arg.AnnotatedAfter,
+		arg.AnnotatedBefore,
+		arg.Sure,
+		arg.DisagreementOnly,
+	)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const countAnnotationsByUser = `-- name: CountAnnotationsByUser :one
 SELECT COUNT(*) FROM annotations
 WHERE username = ?
@@ -121,7 +164,7 @@ WITH annotated_images AS (
 SELECT COUNT(*)
 FROM images i
 LEFT JOIN annotated_images ai ON i.sha256 = ai.image_sha256
-WHERE ai.image_sha256 IS NULL
+WHERE ai.image_sha256 IS NULL AND i.missing_at IS NULL
 `
 
 func (q *Queries) CountImagesWithoutAnnotationForStage(ctx context.Context, stageIndex int64) (int64, error) {
@@ -138,7 +181,7 @@ WITH annotated_images AS (
 SELECT COUNT(*)
 FROM images i
 LEFT JOIN annotated_images ai ON i.sha256 = ai.image_sha256
-WHERE ai.image_sha256 IS NULL
+WHERE ai.image_sha256 IS NULL AND i.missing_at IS NULL
 `
 
 type CountPendingImagesForUserAndStageParams struct {
@@ -154,13 +197,15 @@ func (q *Queries) CountPendingImagesForUserAndStage(ctx context.Context, arg Cou
 }
 
 const createAnnotation = `-- name: CreateAnnotation :one
-INSERT INTO annotations (image_sha256, username, stage_index, option_value)
-VALUES (?, ?, ?, ?)
+INSERT INTO annotations (image_sha256, username, stage_index, option_value, sure, duration_ms)
+VALUES (?, ?, ?, ?, ?, ?)
 ON CONFLICT(image_sha256, username, stage_index)
 DO UPDATE SET
   option_value = excluded.option_value,
+  sure = excluded.sure,
+  duration_ms = excluded.duration_ms,
   annotated_at = CURRENT_TIMESTAMP
-RETURNING id, image_sha256, username, stage_index, option_value, annotated_at
+RETURNING id, image_sha256, username, stage_index, option_value, annotated_at, sure, duration_ms
 `
 
 type CreateAnnotationParams struct {
@@ -168,6 +213,8 @@ type CreateAnnotationParams struct {
 	Username    string `json:"username"`
 	StageIndex  int64  `json:"stage_index"`
 	OptionValue string `json:"option_value"`
+	Sure        bool   `json:"sure"`
+	DurationMs  *int64 `json:"duration_ms"`
 }
 
 func (q *Queries) CreateAnnotation(ctx context.Context, arg CreateAnnotationParams) (Annotation, error) {
@@ -176,6 +223,8 @@ func (q *Queries) CreateAnnotation(ctx context.Context, arg CreateAnnotationPara
 		arg.Username,
 		arg.StageIndex,
 		arg.OptionValue,
+		arg.Sure,
+		arg.DurationMs,
 	)
 	var i Annotation
 	err := row.Scan(
@@ -185,6 +234,8 @@ func (q *Queries) CreateAnnotation(ctx context.Context, arg CreateAnnotationPara
 		&i.StageIndex,
 		&i.OptionValue,
 		&i.AnnotatedAt,
+		&i.Sure,
+		&i.DurationMs,
 	)
 	return i, err
 }
@@ -237,7 +288,7 @@ func (q *Queries) GetAllImageSHA256s(ctx context.Context) ([]string, error) {
 }
 
 const getAnnotation = `-- name: GetAnnotation :one
-SELECT id, image_sha256, username, stage_index, option_value, annotated_at FROM annotations
+SELECT id, image_sha256, username, stage_index, option_value, annotated_at, sure, duration_ms FROM annotations
 WHERE image_sha256 = ? AND username = ? AND stage_index = ?
 `
 
@@ -257,6 +308,29 @@ func (q *Queries) GetAnnotation(ctx context.Context, arg GetAnnotationParams) (A
 		&i.StageIndex,
 		&i.OptionValue,
 		&i.AnnotatedAt,
+		&i.Sure,
+		&i.DurationMs,
+	)
+	return i, err
+}
+
+const getAnnotationByID = `-- name: GetAnnotationByID :one
+SELECT id, image_sha256, username, stage_index, option_value, annotated_at, sure, duration_ms FROM annotations
+WHERE id = ?
+`
+
+func (q *Queries) GetAnnotationByID(ctx context.Context, id int64) (Annotation, error) {
+	row := q.db.QueryRowContext(ctx, getAnnotationByID, id)
+	var i Annotation
+	err := row.Scan(
+		&i.ID,
+		&i.ImageSha256,
+		&i.Username,
+		&i.StageIndex,
+		&i.OptionValue,
+		&i.AnnotatedAt,
+		&i.Sure,
+		&i.DurationMs,
 	)
 	return i, err
 }
@@ -283,7 +357,7 @@ func (q *Queries) GetAnnotationStats(ctx context.Context) (GetAnnotationStatsRow
 }
 
 const getAnnotationsByImageAndUser = `-- name: GetAnnotationsByImageAndUser :many
-SELECT id, image_sha256, username, stage_index, option_value, annotated_at FROM annotations
+SELECT id, image_sha256, username, stage_index, option_value, annotated_at, sure, duration_ms FROM annotations
 WHERE image_sha256 = ? AND username = ?
 ORDER BY stage_index ASC
 `
@@ -309,6 +383,8 @@ func (q *Queries) GetAnnotationsByImageAndUser(ctx context.Context, arg GetAnnot
 			&i.StageIndex,
 			&i.OptionValue,
 			&i.AnnotatedAt,
+			&i.Sure,
+			&i.DurationMs,
 		); err != nil {
 			return nil, err
 		}
@@ -324,7 +400,7 @@ func (q *Queries) GetAnnotationsByImageAndUser(ctx context.Context, arg GetAnnot
 }
 
 const getAnnotationsByUser = `-- name: GetAnnotationsByUser :many
-SELECT a.id, a.image_sha256, a.username, a.stage_index, a.option_value, a.annotated_at, i.filename
+SELECT a.id, a.image_sha256, a.username, a.stage_index, a.option_value, a.annotated_at, a.sure, a.duration_ms, i.filename
 FROM annotations a
 JOIN images i ON a.image_sha256 = i.sha256
 WHERE a.username = ?
@@ -345,6 +421,8 @@ type GetAnnotationsByUserRow struct {
 	StageIndex  int64      `json:"stage_index"`
 	OptionValue string     `json:"option_value"`
 	AnnotatedAt *time.Time `json:"annotated_at"`
+	Sure        bool       `json:"sure"`
+	DurationMs  *int64     `json:"duration_ms"`
 	Filename    string     `json:"filename"`
 }
 
@@ -364,6 +442,8 @@ func (q *Queries) GetAnnotationsByUser(ctx context.Context, arg GetAnnotationsBy
 			&i.StageIndex,
 			&i.OptionValue,
 			&i.AnnotatedAt,
+			&i.Sure,
+			&i.DurationMs,
 			&i.Filename,
 		); err != nil {
 			return nil, err
@@ -380,7 +460,7 @@ func (q *Queries) GetAnnotationsByUser(ctx context.Context, arg GetAnnotationsBy
 }
 
 const getAnnotationsForImage = `-- name: GetAnnotationsForImage :many
-SELECT id, image_sha256, username, stage_index, option_value, annotated_at FROM annotations
+SELECT id, image_sha256, username, stage_index, option_value, annotated_at, sure, duration_ms FROM annotations
 WHERE image_sha256 = ?
 ORDER BY stage_index ASC
 `
@@ -401,6 +481,8 @@ func (q *Queries) GetAnnotationsForImage(ctx context.Context, imageSha256 string
 			&i.StageIndex,
 			&i.OptionValue,
 			&i.AnnotatedAt,
+			&i.Sure,
+			&i.DurationMs,
 		); err != nil {
 			return nil, err
 		}
@@ -457,9 +539,17 @@ func (q *Queries) GetAnnotationsForStageAndValue(ctx context.Context, arg GetAnn
 }
 
 const getImageHashesWithAnnotation = `-- name: GetImageHashesWithAnnotation :many
-SELECT DISTINCT image_sha256
-FROM annotations
-WHERE stage_index = ? AND option_value = ?
+SELECT DISTINCT a.image_sha256
+FROM annotations a
+WHERE a.stage_index = ?1
+  AND COALESCE((
+    SELECT r.option_value
+    FROM reviews r
+    JOIN annotations reviewed ON r.annotation_id = reviewed.id
+    WHERE reviewed.image_sha256 = a.image_sha256 AND reviewed.stage_index = a.stage_index
+    ORDER BY r.reviewed_at DESC, r.id DESC
+    LIMIT 1
+  ), a.option_value) = ?2
 `
 
 type GetImageHashesWithAnnotationParams struct {
@@ -467,6 +557,8 @@ type GetImageHashesWithAnnotationParams struct {
 	OptionValue string `json:"option_value"`
 }
 
+// The latest review of an image in a stage takes precedence over its
+// annotations.
 func (q *Queries) GetImageHashesWithAnnotation(ctx context.Context, arg GetImageHashesWithAnnotationParams) ([]string, error) {
 	rows, err := q.db.QueryContext(ctx, getImageHashesWithAnnotation, arg.StageIndex, arg.OptionValue)
 	if err != nil {
@@ -493,7 +585,7 @@ func (q *Queries) GetImageHashesWithAnnotation(ctx context.Context, arg GetImage
 const getImagesWithoutAnnotationForStage = `-- name: GetImagesWithoutAnnotationForStage :many
 SELECT i.sha256, i.filename
 FROM images i
-WHERE NOT EXISTS (
+WHERE i.missing_at IS NULL AND NOT EXISTS (
     SELECT 1 FROM annotations a
     WHERE a.image_sha256 = i.sha256 AND a.stage_index = ?
 )
@@ -528,14 +620,283 @@ func (q *Queries) GetImagesWithoutAnnotationForStage(ctx context.Context) ([]Get
 	return items, nil
 }
 
+const getUserDailyCounts = `-- name: GetUserDailyCounts :many
+SELECT
+  username,
+  CAST(DATE(annotated_at) AS TEXT) AS day,
+  COUNT(*) AS annotations
+FROM annotations
+WHERE (annotated_at >= ?1 OR ?1 IS NULL)
+  AND (annotated_at < ?2 OR ?2 IS NULL)
+GROUP BY username, day
+ORDER BY day, username
+`
+
+type GetUserDailyCountsParams struct {
+	AnnotatedAfter  *time.Time `json:"annotated_after"`
+	AnnotatedBefore *time.Time `json:"annotated_before"`
+}
+
+type GetUserDailyCountsRow struct {
+	Username    string `json:"username"`
+	Day         string `json:"day"`
+	Annotations int64  `json:"annotations"`
+}
+
+func (q *Queries) GetUserDailyCounts(ctx context.Context, arg GetUserDailyCountsParams) ([]GetUserDailyCountsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUserDailyCounts, arg.AnnotatedAfter, arg.AnnotatedBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetUserDailyCountsRow{}
+	for rows.Next() {
+		var i GetUserDailyCountsRow
+		if err := rows.Scan(&i.Username, &i.Day, &i.Annotations); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUserStageStats = `-- name: GetUserStageStats :many
+WITH graded AS (
+  SELECT
+    a.username,
+    a.stage_index,
+    a.sure,
+    a.option_value,
+    (
+      SELECT COUNT(*) FROM annotations other
+      WHERE other.image_sha256 = a.image_sha256
+        AND other.stage_index = a.stage_index
+        AND other.username <> a.username
+    ) AS compared,
+    (
+      SELECT COUNT(*) FROM annotations other
+      WHERE other.image_sha256 = a.image_sha256
+        AND other.stage_index = a.stage_index
+        AND other.username <> a.username
+        AND other.option_value = a.option_value
+    ) AS agreed,
+    (
+      SELECT r.option_value
+      FROM reviews r
+      JOIN annotations reviewed ON r.annotation_id = reviewed.id
+      WHERE reviewed.image_sha256 = a.image_sha256
+        AND reviewed.stage_index = a.stage_index
+        AND r.reviewer <> a.username
+      ORDER BY r.reviewed_at DESC, r.id DESC
+      LIMIT 1
+    ) AS gold
+  FROM annotations a
+  WHERE (a.annotated_at >= ?1 OR ?1 IS NULL)
+    AND (a.annotated_at < ?2 OR ?2 IS NULL)
+)
+SELECT
+  username,
+  stage_index,
+  COUNT(*) AS annotations,
+  CAST(SUM(CASE WHEN sure THEN 0 ELSE 1 END) AS INTEGER) AS unsure,
+  CAST(SUM(compared) AS INTEGER) AS compared,
+  CAST(SUM(agreed) AS INTEGER) AS agreed,
+  COUNT(gold) AS graded,
+  CAST(SUM(CASE WHEN gold = option_value THEN 1 ELSE 0 END) AS INTEGER) AS correct
+FROM graded
+GROUP BY username, stage_index
+ORDER BY username, stage_index
+`
+
+type GetUserStageStatsParams struct {
+	AnnotatedAfter  *time.Time `json:"annotated_after"`
+	AnnotatedBefore *time.Time `json:"annotated_before"`
+}
+
+type GetUserStageStatsRow struct {
+	Username    string `json:"username"`
+	StageIndex  int64  `json:"stage_index"`
+	Annotations int64  `json:"annotations"`
+	Unsure      int64  `json:"unsure"`
+	Compared    int64  `json:"compared"`
+	Agreed      int64  `json:"agreed"`
+	Graded      int64  `json:"graded"`
+	Correct     int64  `json:"correct"`
+}
+
+// Per user and stage: annotations, unsure answers, pairs of answers compared
+// with other annotators of the same image and how many of them agreed, and
+// annotations graded against the latest review of their image by someone
+// else and how many of them matched it.
+func (q *Queries) GetUserStageStats(ctx context.Context, arg GetUserStageStatsParams) ([]GetUserStageStatsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUserStageStats, arg.AnnotatedAfter, arg.AnnotatedBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetUserStageStatsRow{}
+	for rows.Next() {
+		var i GetUserStageStatsRow
+		if err := rows.Scan(
+			&i.Username,
+			&i.StageIndex,
+			&i.Annotations,
+			&i.Unsure,
+			&i.Compared,
+			&i.Agreed,
+			&i.Graded,
+			&i.Correct,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAnnotatedImages = `-- name: ListAnnotatedImages :many
+SELECT i.sha256, i.filename
+FROM annotations a
+JOIN images i ON i.sha256 = a.image_sha256
+WHERE (a.stage_index = ?1 OR ?1 IS NULL)
+  AND (a.option_value = ?2 OR ?2 IS NULL)
+  AND (a.username = ?3 OR ?3 IS NULL)
+  AND (a.annotated_at >= ?4 OR ?4 IS NULL)
+  AND (a.annotated_at < ?5 OR ?5 IS NULL)
+  AND (a.sure = ?6 OR ?6 IS NULL)
+  AND (EXISTS (
+    SELECT 1 FROM annotations other
+    WHERE other.image_sha256 = a.image_sha256
+      AND other.stage_index = a.stage_index
+      AND other.option_value <> a.option_value
+  ) OR CAST(?7 AS BOOLEAN) = FALSE)
+GROUP BY i.sha256, i.filename
+ORDER BY MAX(a.annotated_at) DESC, i.sha256
+LIMIT ?9 OFFSET ?8
+`
+
+type ListAnnotatedImagesParams struct {
+	StageIndex       *int64     `json:"stage_index"`
+	OptionValue      *string    `json:"option_value"`
+	Username         *string    `json:"username"`
+	AnnotatedAfter   *time.Time `json:"annotated_after"`
+	AnnotatedBefore  *time.Time `json:"annotated_before"`
+	Sure             *bool      `json:"sure"`
+	DisagreementOnly bool       `json:"disagreement_only"`
+	Offset           int64      `json:"offset"`
+	Limit            int64      `json:"limit"`
+}
+
+type ListAnnotatedImagesRow struct {
+	Sha256   string `json:"sha256"`
+	Filename string `json:"filename"`
+}
+
+// Images with at least one annotation matching every filter; a NULL
+// argument matches all annotations. Most recently annotated first.
+func (q *Queries) ListAnnotatedImages(ctx context.Context, arg ListAnnotatedImagesParams) ([]ListAnnotatedImagesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listAnnotatedImages,
+		arg.StageIndex,
+		arg.OptionValue,
+		arg.Username,
+		arg.AnnotatedAfter,
+		arg.AnnotatedBefore,
+		arg.Sure,
+		arg.DisagreementOnly,
+		arg.Offset,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListAnnotatedImagesRow{}
+	for rows.Next() {
+		var i ListAnnotatedImagesRow
+		if err := rows.Scan(&i.Sha256, &i.Filename); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAnnotationDurations = `-- name: ListAnnotationDurations :many
+SELECT username, stage_index, option_value, CAST(duration_ms AS INTEGER) AS duration_ms
+FROM annotations
+WHERE duration_ms IS NOT NULL
+  AND (annotated_at >= ?1 OR ?1 IS NULL)
+  AND (annotated_at < ?2 OR ?2 IS NULL)
+ORDER BY username, stage_index, option_value
+`
+
+type ListAnnotationDurationsParams struct {
+	AnnotatedAfter  *time.Time `json:"annotated_after"`
+	AnnotatedBefore *time.Time `json:"annotated_before"`
+}
+
+type ListAnnotationDurationsRow struct {
+	Username    string `json:"username"`
+	StageIndex  int64  `json:"stage_index"`
+	OptionValue string `json:"option_value"`
+	DurationMs  int64  `json:"duration_ms"`
+}
+
+// The annotations of the period with a recorded time-on-image.
+func (q *Queries) ListAnnotationDurations(ctx context.Context, arg ListAnnotationDurationsParams) ([]ListAnnotationDurationsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listAnnotationDurations, arg.AnnotatedAfter, arg.AnnotatedBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListAnnotationDurationsRow{}
+	for rows.Next() {
+		var i ListAnnotationDurationsRow
+		if err := rows.Scan(
+			&i.Username,
+			&i.StageIndex,
+			&i.OptionValue,
+			&i.DurationMs,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listPendingImagesForUserAndStage = `-- name: ListPendingImagesForUserAndStage :many
 WITH annotated_images AS (
   SELECT image_sha256 FROM annotations WHERE username = ? AND stage_index = ?
 )
-SELECT i.sha256, i.filename, i.ingested_at
+SELECT i.sha256, i.filename, i.ingested_at, i.phash, i.source_path, i.format, i.width, i.height, i.size_bytes, i.captured_at, i.camera, i.missing_at, i.source_archive, i.frame_offset_ms
 FROM images i
 LEFT JOIN annotated_images ai ON i.sha256 = ai.image_sha256
-WHERE ai.image_sha256 IS NULL
+WHERE ai.image_sha256 IS NULL AND i.missing_at IS NULL
 ORDER BY i.filename ASC
 LIMIT ?
 `
@@ -555,7 +916,22 @@ func (q *Queries) ListPendingImagesForUserAndStage(ctx context.Context, arg List
 	items := []Image{}
 	for rows.Next() {
 		var i Image
-		if err := rows.Scan(&i.Sha256, &i.Filename, &i.IngestedAt); err != nil {
+		if err := rows.Scan(
+			&i.Sha256,
+			&i.Filename,
+			&i.IngestedAt,
+			&i.Phash,
+			&i.SourcePath,
+			&i.Format,
+			&i.Width,
+			&i.Height,
+			&i.SizeBytes,
+			&i.CapturedAt,
+			&i.Camera,
+			&i.MissingAt,
+			&i.SourceArchive,
+			&i.FrameOffsetMs,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
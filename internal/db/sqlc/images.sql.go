@@ -7,10 +7,29 @@ package sqlc
 
 import (
 	"context"
+	"strings"
+	"time"
 )
 
+const addImageTag = `-- name: AddImageTag :exec
+INSERT INTO image_tags (image_sha256, tag)
+VALUES (?, ?)
+ON CONFLICT DO NOTHING
+`
+
+type AddImageTagParams struct {
+	ImageSha256 string `json:"image_sha256"`
+	Tag         string `json:"tag"`
+}
+
+func (q *Queries) AddImageTag(ctx context.Context, arg AddImageTagParams) error {
+	_, err := q.db.ExecContext(ctx, addImageTag, arg.ImageSha256, arg.Tag)
+	return err
+}
+
 const countImages = `-- name: CountImages :one
 SELECT COUNT(*) FROM images
+WHERE missing_at IS NULL
 `
 
 func (q *Queries) CountImages(ctx context.Context) (int64, error) {
@@ -20,11 +39,23 @@ func (q *Queries) CountImages(ctx context.Context) (int64, error) {
 	return count, err
 }
 
+const countImagesWithoutPHash = `-- name: CountImagesWithoutPHash :one
+SELECT COUNT(*) FROM images
+WHERE phash IS NULL AND missing_at IS NULL
+`
+
+func (q *Queries) CountImagesWithoutPHash(ctx context.Context) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countImagesWithoutPHash)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createImage = `-- name: CreateImage :one
 INSERT INTO images (sha256, filename)
 VALUES (?, ?)
-ON CONFLICT(sha256) DO UPDATE SET filename = excluded.filename
-RETURNING sha256, filename, ingested_at
+ON CONFLICT(sha256) DO UPDATE SET filename = excluded.filename, missing_at = NULL
+RETURNING sha256, filename, ingested_at, phash, source_path, format, width, height, size_bytes, captured_at, camera, missing_at, source_archive, frame_offset_ms
 `
 
 type CreateImageParams struct {
@@ -35,7 +66,89 @@ type CreateImageParams struct {
 func (q *Queries) CreateImage(ctx context.Context, arg CreateImageParams) (Image, error) {
 	row := q.db.QueryRowContext(ctx, createImage, arg.Sha256, arg.Filename)
 	var i Image
-	err := row.Scan(&i.Sha256, &i.Filename, &i.IngestedAt)
+	err := row.Scan(
+		&i.Sha256,
+		&i.Filename,
+		&i.IngestedAt,
+		&i.Phash,
+		&i.SourcePath,
+		&i.Format,
+		&i.Width,
+		&i.Height,
+		&i.SizeBytes,
+		&i.CapturedAt,
+		&i.Camera,
+		&i.MissingAt,
+		&i.SourceArchive,
+		&i.FrameOffsetMs,
+	)
+	return i, err
+}
+
+const createImageWithMetadata = `-- name: CreateImageWithMetadata :one
+INSERT INTO images (sha256, filename, source_path, source_archive, frame_offset_ms, format, width, height, size_bytes, captured_at, camera)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(sha256) DO UPDATE SET
+  filename = excluded.filename,
+  source_path = COALESCE(excluded.source_path, images.source_path),
+  source_archive = CASE WHEN excluded.source_path IS NULL THEN images.source_archive ELSE excluded.source_archive END,
+  frame_offset_ms = CASE WHEN excluded.source_path IS NULL THEN images.frame_offset_ms ELSE excluded.frame_offset_ms END,
+  format = excluded.format,
+  width = excluded.width,
+  height = excluded.height,
+  size_bytes = excluded.size_bytes,
+  captured_at = excluded.captured_at,
+  camera = excluded.camera,
+  missing_at = NULL
+RETURNING sha256, filename, ingested_at, phash, source_path, format, width, height, size_bytes, captured_at, camera, missing_at, source_archive, frame_offset_ms
+`
+
+type CreateImageWithMetadataParams struct {
+	Sha256        string     `json:"sha256"`
+	Filename      string     `json:"filename"`
+	SourcePath    *string    `json:"source_path"`
+	SourceArchive *string    `json:"source_archive"`
+	FrameOffsetMs *int64     `json:"frame_offset_ms"`
+	Format        *string    `json:"format"`
+	Width         *int64     `json:"width"`
+	Height        *int64     `json:"height"`
+	SizeBytes     *int64     `json:"size_bytes"`
+	CapturedAt    *time.Time `json:"captured_at"`
+	Camera        *string    `json:"camera"`
+}
+
+// A NULL source_path keeps the source recorded by an earlier 'ingest' run.
+func (q *Queries) CreateImageWithMetadata(ctx context.Context, arg CreateImageWithMetadataParams) (Image, error) {
+	row := q.db.QueryRowContext(ctx, createImageWithMetadata,
+		arg.Sha256,
+		arg.Filename,
+		arg.SourcePath,
+		arg.SourceArchive,
+		arg.FrameOffsetMs,
+		arg.Format,
+		arg.Width,
+		arg.Height,
+		arg.SizeBytes,
+		arg.CapturedAt,
+		arg.Camera,
+	)
+	var i Image
+	err := row.Scan(
+		&i.Sha256,
+		&i.Filename,
+		&i.IngestedAt,
+		&i.Phash,
+		&i.SourcePath,
+		&i.Format,
+		&i.Width,
+		&i.Height,
+		&i.SizeBytes,
+		&i.CapturedAt,
+		&i.Camera,
+		&i.MissingAt,
+		&i.SourceArchive,
+		&i.FrameOffsetMs,
+	)
 	return i, err
 }
 
@@ -50,31 +163,126 @@ func (q *Queries) DeleteImage(ctx context.Context, sha256 string) error {
 }
 
 const getImage = `-- name: GetImage :one
-SELECT sha256, filename, ingested_at FROM images
+SELECT sha256, filename, ingested_at, phash, source_path, format, width, height, size_bytes, captured_at, camera, missing_at, source_archive, frame_offset_ms FROM images
 WHERE sha256 = ?
 `
 
 func (q *Queries) GetImage(ctx context.Context, sha256 string) (Image, error) {
 	row := q.db.QueryRowContext(ctx, getImage, sha256)
 	var i Image
-	err := row.Scan(&i.Sha256, &i.Filename, &i.IngestedAt)
+	err := row.Scan(
+		&i.Sha256,
+		&i.Filename,
+		&i.IngestedAt,
+		&i.Phash,
+		&i.SourcePath,
+		&i.Format,
+		&i.Width,
+		&i.Height,
+		&i.SizeBytes,
+		&i.CapturedAt,
+		&i.Camera,
+		&i.MissingAt,
+		&i.SourceArchive,
+		&i.FrameOffsetMs,
+	)
 	return i, err
 }
 
 const getImageByFilename = `-- name: GetImageByFilename :one
-SELECT sha256, filename, ingested_at FROM images
+SELECT sha256, filename, ingested_at, phash, source_path, format, width, height, size_bytes, captured_at, camera, missing_at, source_archive, frame_offset_ms FROM images
 WHERE filename = ?
 `
 
 func (q *Queries) GetImageByFilename(ctx context.Context, filename string) (Image, error) {
 	row := q.db.QueryRowContext(ctx, getImageByFilename, filename)
 	var i Image
-	err := row.Scan(&i.Sha256, &i.Filename, &i.IngestedAt)
+	err := row.Scan(
+		&i.Sha256,
+		&i.Filename,
+		&i.IngestedAt,
+		&i.Phash,
+		&i.SourcePath,
+		&i.Format,
+		&i.Width,
+		&i.Height,
+		&i.SizeBytes,
+		&i.CapturedAt,
+		&i.Camera,
+		&i.MissingAt,
+		&i.SourceArchive,
+		&i.FrameOffsetMs,
+	)
 	return i, err
 }
 
+const listImagePHashes = `-- name: ListImagePHashes :many
+SELECT sha256, filename, phash FROM images
+WHERE phash IS NOT NULL
+ORDER BY filename
+`
+
+type ListImagePHashesRow struct {
+	Sha256   string `json:"sha256"`
+	Filename string `json:"filename"`
+	Phash    *int64 `json:"phash"`
+}
+
+func (q *Queries) ListImagePHashes(ctx context.Context) ([]ListImagePHashesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listImagePHashes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListImagePHashesRow{}
+	for rows.Next() {
+		var i ListImagePHashesRow
+		if err := rows.Scan(&i.Sha256, &i.Filename, &i.Phash); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listImageTags = `-- name: ListImageTags :many
+SELECT tag FROM image_tags
+WHERE image_sha256 = ?
+ORDER BY tag
+`
+
+func (q *Queries) ListImageTags(ctx context.Context, imageSha256 string) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, listImageTags, imageSha256)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []string{}
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		items = append(items, tag)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listImages = `-- name: ListImages :many
-SELECT sha256, filename, ingested_at FROM images
+SELECT sha256, filename, ingested_at, phash, source_path, format, width, height, size_bytes, captured_at, camera, missing_at, source_archive, frame_offset_ms FROM images
+WHERE missing_at IS NULL
 ORDER BY filename
 `
 
@@ -87,7 +295,125 @@ func (q *Queries) ListImages(ctx context.Context) ([]Image, error) {
 	items := []Image{}
 	for rows.Next() {
 		var i Image
-		if err := rows.Scan(&i.Sha256, &i.Filename, &i.IngestedAt); err != nil {
+		if err := rows.Scan(
+			&i.Sha256,
+			&i.Filename,
+			&i.IngestedAt,
+			&i.Phash,
+			&i.SourcePath,
+			&i.Format,
+			&i.Width,
+			&i.Height,
+			&i.SizeBytes,
+			&i.CapturedAt,
+			&i.Camera,
+			&i.MissingAt,
+			&i.SourceArchive,
+			&i.FrameOffsetMs,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listImagesFiltered = `-- name: ListImagesFiltered :many
+SELECT sha256, filename, ingested_at, phash, source_path, format, width, height, size_bytes, captured_at, camera, missing_at, source_archive, frame_offset_ms FROM images
+WHERE (missing_at IS NULL OR CAST(?1 AS BOOLEAN))
+  AND (filename, sha256) > (?2, ?3)
+  AND (format = ?4 OR ?4 IS NULL)
+  AND (camera = ?5 OR ?5 IS NULL)
+  AND (width >= ?6 OR ?6 IS NULL)
+  AND (width <= ?7 OR ?7 IS NULL)
+  AND (height >= ?8 OR ?8 IS NULL)
+  AND (height <= ?9 OR ?9 IS NULL)
+  AND (size_bytes >= ?10 OR ?10 IS NULL)
+  AND (size_bytes <= ?11 OR ?11 IS NULL)
+  AND (captured_at >= ?12 OR ?12 IS NULL)
+  AND (captured_at < ?13 OR ?13 IS NULL)
+  AND (source_path GLOB ?14 OR ?14 IS NULL)
+  AND (filename GLOB ?15 OR ?15 IS NULL)
+  AND (EXISTS (
+    SELECT 1 FROM image_tags
+    WHERE image_tags.image_sha256 = images.sha256 AND image_tags.tag = ?16
+  ) OR ?16 IS NULL)
+ORDER BY filename, sha256
+LIMIT ?17
+`
+
+type ListImagesFilteredParams struct {
+	IncludeMissing bool       `json:"include_missing"`
+	AfterFilename  string     `json:"after_filename"`
+	AfterSha256    string     `json:"after_sha256"`
+	Format         *string    `json:"format"`
+	Camera         *string    `json:"camera"`
+	MinWidth       *int64     `json:"min_width"`
+	MaxWidth       *int64     `json:"max_width"`
+	MinHeight      *int64     `json:"min_height"`
+	MaxHeight      *int64     `json:"max_height"`
+	MinSize        *int64     `json:"min_size"`
+	MaxSize        *int64     `json:"max_size"`
+	CapturedAfter  *time.Time `json:"captured_after"`
+	CapturedBefore *time.Time `json:"captured_before"`
+	SourceGlob     *string    `json:"source_glob"`
+	DirGlob        *string    `json:"dir_glob"`
+	Tag            *string    `json:"tag"`
+	PageLimit      int64      `json:"page_limit"`
+}
+
+// Every filter is optional; a NULL argument matches all images. Pages are
+// sorted by filename and hash and resume after the given image, empty for
+// the first page; a negative page_limit returns every image.
+func (q *Queries) ListImagesFiltered(ctx context.Context, arg ListImagesFilteredParams) ([]Image, error) {
+	rows, err := q.db.QueryContext(ctx, listImagesFiltered,
+		arg.IncludeMissing,
+		arg.AfterFilename,
+		arg.AfterSha256,
+		arg.Format,
+		arg.Camera,
+		arg.MinWidth,
+		arg.MaxWidth,
+		arg.MinHeight,
+		arg.MaxHeight,
+		arg.MinSize,
+		arg.MaxSize,
+		arg.CapturedAfter,
+		arg.CapturedBefore,
+		arg.SourceGlob,
+		arg.DirGlob,
+		arg.Tag,
+		arg.PageLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Image{}
+	for rows.Next() {
+		var i Image
+		if err := rows.Scan(
+			&i.Sha256,
+			&i.Filename,
+			&i.IngestedAt,
+			&i.Phash,
+			&i.SourcePath,
+			&i.Format,
+			&i.Width,
+			&i.Height,
+			&i.SizeBytes,
+			&i.CapturedAt,
+			&i.Camera,
+			&i.MissingAt,
+			&i.SourceArchive,
+			&i.FrameOffsetMs,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -102,7 +428,7 @@ func (q *Queries) ListImages(ctx context.Context) ([]Image, error) {
 }
 
 const listImagesNotFinished = `-- name: ListImagesNotFinished :many
-SELECT sha256, filename, ingested_at FROM images
+SELECT sha256, filename, ingested_at, phash, source_path, format, width, height, size_bytes, captured_at, camera, missing_at, source_archive, frame_offset_ms FROM images
 ORDER BY filename ASC
 LIMIT ?
 `
@@ -116,7 +442,22 @@ func (q *Queries) ListImagesNotFinished(ctx context.Context, limit int64) ([]Ima
 	items := []Image{}
 	for rows.Next() {
 		var i Image
-		if err := rows.Scan(&i.Sha256, &i.Filename, &i.IngestedAt); err != nil {
+		if err := rows.Scan(
+			&i.Sha256,
+			&i.Filename,
+			&i.IngestedAt,
+			&i.Phash,
+			&i.SourcePath,
+			&i.Format,
+			&i.Width,
+			&i.Height,
+			&i.SizeBytes,
+			&i.CapturedAt,
+			&i.Camera,
+			&i.MissingAt,
+			&i.SourceArchive,
+			&i.FrameOffsetMs,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -129,3 +470,82 @@ func (q *Queries) ListImagesNotFinished(ctx context.Context, limit int64) ([]Ima
 	}
 	return items, nil
 }
+
+const listTagsOfImages = `-- name: ListTagsOfImages :many
+SELECT image_sha256, tag FROM image_tags
+WHERE image_sha256 IN (/*SLICE:image_hashes*/?)
+ORDER BY image_sha256, tag
+`
+
+func (q *Queries) ListTagsOfImages(ctx context.Context, imageHashes []string) ([]ImageTag, error) {
+	query := listTagsOfImages
+	var queryParams []interface{}
+	if len(imageHashes) > 0 {
+		for _, v := range imageHashes {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:image_hashes*/?", strings.Repeat(",?", len(imageHashes))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:image_hashes*/?", "NULL", 1)
+	}
+	rows, err := q.db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ImageTag{}
+	for rows.Next() {
+		var i ImageTag
+		if err := rows.Scan(&i.ImageSha256, &i.Tag); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markImageMissing = `-- name: MarkImageMissing :exec
+UPDATE images SET missing_at = CURRENT_TIMESTAMP
+WHERE sha256 = ? AND missing_at IS NULL
+`
+
+func (q *Queries) MarkImageMissing(ctx context.Context, sha256 string) error {
+	_, err := q.db.ExecContext(ctx, markImageMissing, sha256)
+	return err
+}
+
+const removeImageTag = `-- name: RemoveImageTag :exec
+DELETE FROM image_tags
+WHERE image_sha256 = ? AND tag = ?
+`
+
+type RemoveImageTagParams struct {
+	ImageSha256 string `json:"image_sha256"`
+	Tag         string `json:"tag"`
+}
+
+func (q *Queries) RemoveImageTag(ctx context.Context, arg RemoveImageTagParams) error {
+	_, err := q.db.ExecContext(ctx, removeImageTag, arg.ImageSha256, arg.Tag)
+	return err
+}
+
+const updateImagePHash = `-- name: UpdateImagePHash :exec
+UPDATE images SET phash = ?
+WHERE sha256 = ?
+`
+
+type UpdateImagePHashParams struct {
+	Phash  *int64 `json:"phash"`
+	Sha256 string `json:"sha256"`
+}
+
+func (q *Queries) UpdateImagePHash(ctx context.Context, arg UpdateImagePHashParams) error {
+	_, err := q.db.ExecContext(ctx, updateImagePHash, arg.Phash, arg.Sha256)
+	return err
+}
@@ -15,10 +15,119 @@ type Annotation struct {
 	StageIndex  int64      `json:"stage_index"`
 	OptionValue string     `json:"option_value"`
 	AnnotatedAt *time.Time `json:"annotated_at"`
+	Sure        bool       `json:"sure"`
+	DurationMs  *int64     `json:"duration_ms"`
+}
+
+type AnnotationEvent struct {
+	ID           int64     `json:"id"`
+	Kind         string    `json:"kind"`
+	Source       string    `json:"source"`
+	Actor        string    `json:"actor"`
+	ImageSha256  string    `json:"image_sha256"`
+	StageIndex   int64     `json:"stage_index"`
+	Username     string    `json:"username"`
+	AnnotationID *int64    `json:"annotation_id"`
+	OldValue     *string   `json:"old_value"`
+	NewValue     *string   `json:"new_value"`
+	CreatedAt    time.Time `json:"created_at"`
 }
 
 type Image struct {
-	Sha256     string     `json:"sha256"`
-	Filename   string     `json:"filename"`
-	IngestedAt *time.Time `json:"ingested_at"`
+	Sha256        string     `json:"sha256"`
+	Filename      string     `json:"filename"`
+	IngestedAt    *time.Time `json:"ingested_at"`
+	Phash         *int64     `json:"phash"`
+	SourcePath    *string    `json:"source_path"`
+	Format        *string    `json:"format"`
+	Width         *int64     `json:"width"`
+	Height        *int64     `json:"height"`
+	SizeBytes     *int64     `json:"size_bytes"`
+	CapturedAt    *time.Time `json:"captured_at"`
+	Camera        *string    `json:"camera"`
+	MissingAt     *time.Time `json:"missing_at"`
+	SourceArchive *string    `json:"source_archive"`
+	FrameOffsetMs *int64     `json:"frame_offset_ms"`
+}
+
+type ImageLease struct {
+	ImageSha256 string    `json:"image_sha256"`
+	StageIndex  int64     `json:"stage_index"`
+	Username    string    `json:"username"`
+	LeasedAt    time.Time `json:"leased_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+type ImageTag struct {
+	ImageSha256 string `json:"image_sha256"`
+	Tag         string `json:"tag"`
+}
+
+type IngestIndex struct {
+	Path        string     `json:"path"`
+	SizeBytes   int64      `json:"size_bytes"`
+	MtimeNs     int64      `json:"mtime_ns"`
+	ImageSha256 string     `json:"image_sha256"`
+	IndexedAt   *time.Time `json:"indexed_at"`
+}
+
+type Review struct {
+	ID           int64      `json:"id"`
+	AnnotationID int64      `json:"annotation_id"`
+	Reviewer     string     `json:"reviewer"`
+	Outcome      string     `json:"outcome"`
+	OptionValue  string     `json:"option_value"`
+	ReviewedAt   *time.Time `json:"reviewed_at"`
+}
+
+type TaskCount struct {
+	StageIndex int64 `json:"stage_index"`
+	Total      int64 `json:"total"`
+	Eligible   int64 `json:"eligible"`
+	Available  int64 `json:"available"`
+	Filtered   int64 `json:"filtered"`
+}
+
+type TaskDependency struct {
+	StageIndex    int64  `json:"stage_index"`
+	DepStageIndex int64  `json:"dep_stage_index"`
+	OptionValue   string `json:"option_value"`
+}
+
+type TaskImage struct {
+	StageIndex   int64  `json:"stage_index"`
+	ImageSha256  string `json:"image_sha256"`
+	Eligible     bool   `json:"eligible"`
+	Annotated    bool   `json:"annotated"`
+	DepAnnotated bool   `json:"dep_annotated"`
+}
+
+type TaskImageStatus struct {
+	StageIndex   int64       `json:"stage_index"`
+	ImageSha256  string      `json:"image_sha256"`
+	Eligible     interface{} `json:"eligible"`
+	Annotated    int64       `json:"annotated"`
+	DepAnnotated int64       `json:"dep_annotated"`
+}
+
+type TaskRule struct {
+	StageIndex     int64      `json:"stage_index"`
+	Format         *string    `json:"format"`
+	Camera         *string    `json:"camera"`
+	Tag            *string    `json:"tag"`
+	MinWidth       *int64     `json:"min_width"`
+	MaxWidth       *int64     `json:"max_width"`
+	MinHeight      *int64     `json:"min_height"`
+	MaxHeight      *int64     `json:"max_height"`
+	MinSize        *int64     `json:"min_size"`
+	MaxSize        *int64     `json:"max_size"`
+	CapturedAfter  *time.Time `json:"captured_after"`
+	CapturedBefore *time.Time `json:"captured_before"`
+	SourceGlob     *string    `json:"source_glob"`
+	DirGlob        *string    `json:"dir_glob"`
+}
+
+type TaskRulesFingerprint struct {
+	ID          int64  `json:"id"`
+	Fingerprint string `json:"fingerprint"`
 }
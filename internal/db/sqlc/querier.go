@@ -9,21 +9,49 @@ import (
 )
 
 type Querier interface {
+	// Takes the lease when it is free, expired, or already held by the same user.
+	// Returns no row when another user holds an active lease.
+	AcquireLease(ctx context.Context, arg AcquireLeaseParams) (ImageLease, error)
+	AddImageTag(ctx context.Context, arg AddImageTagParams) error
 	CheckAnnotationExists(ctx context.Context, arg CheckAnnotationExistsParams) (int64, error)
 	CheckAnnotationExistsForImageStage(ctx context.Context, arg CheckAnnotationExistsForImageStageParams) (int64, error)
+	// Counts the images ListAnnotatedImages pages through.
+	CountAnnotatedImages(ctx context.Context, arg CountAnnotatedImagesParams) (int64, error)
 	CountAnnotationsByUser(ctx context.Context, username string) (int64, error)
+	CountAnnotationsToReview(ctx context.Context, arg CountAnnotationsToReviewParams) (int64, error)
 	CountImages(ctx context.Context) (int64, error)
 	CountImagesWithAnnotation(ctx context.Context, arg CountImagesWithAnnotationParams) (int64, error)
 	CountImagesWithAnnotationInList(ctx context.Context, arg CountImagesWithAnnotationInListParams) (int64, error)
 	CountImagesWithoutAnnotationForStage(ctx context.Context, stageIndex int64) (int64, error)
+	CountImagesWithoutPHash(ctx context.Context) (int64, error)
+	CountIngestIndexEntriesForImage(ctx context.Context, imageSha256 string) (int64, error)
 	CountPendingImagesForUserAndStage(ctx context.Context, arg CountPendingImagesForUserAndStageParams) (int64, error)
 	CreateAnnotation(ctx context.Context, arg CreateAnnotationParams) (Annotation, error)
+	CreateAnnotationEvent(ctx context.Context, arg CreateAnnotationEventParams) (AnnotationEvent, error)
 	CreateImage(ctx context.Context, arg CreateImageParams) (Image, error)
+	// A NULL source_path keeps the source recorded by an earlier 'ingest' run.
+	CreateImageWithMetadata(ctx context.Context, arg CreateImageWithMetadataParams) (Image, error)
+	// A reviewer reviewing the same annotation again replaces their verdict.
+	CreateReview(ctx context.Context, arg CreateReviewParams) (Review, error)
+	CreateTaskDependency(ctx context.Context, arg CreateTaskDependencyParams) error
+	CreateTaskRule(ctx context.Context, arg CreateTaskRuleParams) error
 	DeleteAnnotation(ctx context.Context, id int64) error
 	DeleteAnnotationsForImage(ctx context.Context, imageSha256 string) error
+	DeleteExpiredLeases(ctx context.Context) (int64, error)
 	DeleteImage(ctx context.Context, sha256 string) error
+	DeleteIngestIndexEntry(ctx context.Context, path string) error
+	DeleteReviewsForAnnotation(ctx context.Context, annotationID int64) (int64, error)
+	DeleteTaskCounts(ctx context.Context) error
+	DeleteTaskDependencies(ctx context.Context) error
+	DeleteTaskImages(ctx context.Context) error
+	DeleteTaskRules(ctx context.Context) error
+	// Per annotator of a stage, how many of their annotations were reviewed and
+	// how many of those were accepted.
+	GetAcceptanceRates(ctx context.Context, stageIndex int64) ([]GetAcceptanceRatesRow, error)
+	GetActiveLeaseForUser(ctx context.Context, arg GetActiveLeaseForUserParams) (ImageLease, error)
 	GetAllImageSHA256s(ctx context.Context) ([]string, error)
 	GetAnnotation(ctx context.Context, arg GetAnnotationParams) (Annotation, error)
+	GetAnnotationByID(ctx context.Context, id int64) (Annotation, error)
 	GetAnnotationStats(ctx context.Context) (GetAnnotationStatsRow, error)
 	GetAnnotationsByImageAndUser(ctx context.Context, arg GetAnnotationsByImageAndUserParams) ([]Annotation, error)
 	GetAnnotationsByUser(ctx context.Context, arg GetAnnotationsByUserParams) ([]GetAnnotationsByUserRow, error)
@@ -31,11 +59,56 @@ type Querier interface {
 	GetAnnotationsForStageAndValue(ctx context.Context, arg GetAnnotationsForStageAndValueParams) ([]GetAnnotationsForStageAndValueRow, error)
 	GetImage(ctx context.Context, sha256 string) (Image, error)
 	GetImageByFilename(ctx context.Context, filename string) (Image, error)
+	// The latest review of an image in a stage takes precedence over its
+	// annotations.
 	GetImageHashesWithAnnotation(ctx context.Context, arg GetImageHashesWithAnnotationParams) ([]string, error)
 	GetImagesWithoutAnnotationForStage(ctx context.Context) ([]GetImagesWithoutAnnotationForStageRow, error)
+	GetIngestIndexEntry(ctx context.Context, path string) (IngestIndex, error)
+	GetLeasedImageHashes(ctx context.Context, arg GetLeasedImageHashesParams) ([]string, error)
+	GetReviewsForImage(ctx context.Context, imageSha256 string) ([]GetReviewsForImageRow, error)
+	GetTaskCounts(ctx context.Context, stageIndex int64) (TaskCount, error)
+	GetTaskRulesFingerprint(ctx context.Context) (string, error)
+	GetUserDailyCounts(ctx context.Context, arg GetUserDailyCountsParams) ([]GetUserDailyCountsRow, error)
+	// Per user and stage: annotations, unsure answers, pairs of answers compared
+	// with other annotators of the same image and how many of them agreed, and
+	// annotations graded against the latest review of their image by someone
+	// else and how many of them matched it.
+	GetUserStageStats(ctx context.Context, arg GetUserStageStatsParams) ([]GetUserStageStatsRow, error)
+	ListActiveLeases(ctx context.Context) ([]ListActiveLeasesRow, error)
+	// Images with at least one annotation matching every filter; a NULL
+	// argument matches all annotations. Most recently annotated first.
+	ListAnnotatedImages(ctx context.Context, arg ListAnnotatedImagesParams) ([]ListAnnotatedImagesRow, error)
+	// The annotations of the period with a recorded time-on-image.
+	ListAnnotationDurations(ctx context.Context, arg ListAnnotationDurationsParams) ([]ListAnnotationDurationsRow, error)
+	// Events matching every filter, newest first; a NULL argument matches all.
+	// user matches both the owner of the annotation and the actor.
+	ListAnnotationEvents(ctx context.Context, arg ListAnnotationEventsParams) ([]AnnotationEvent, error)
+	// In hash order, which follows idx_task_images_status and spreads the
+	// picks over the dataset.
+	ListAvailableTaskImages(ctx context.Context, arg ListAvailableTaskImagesParams) ([]Image, error)
+	ListImagePHashes(ctx context.Context) ([]ListImagePHashesRow, error)
+	ListImageTags(ctx context.Context, imageSha256 string) ([]string, error)
 	ListImages(ctx context.Context) ([]Image, error)
+	// Every filter is optional; a NULL argument matches all images. Pages are
+	// sorted by filename and hash and resume after the given image, empty for
+	// the first page; a negative page_limit returns every image.
+	ListImagesFiltered(ctx context.Context, arg ListImagesFilteredParams) ([]Image, error)
 	ListImagesNotFinished(ctx context.Context, limit int64) ([]Image, error)
+	ListIngestIndexEntries(ctx context.Context) ([]IngestIndex, error)
 	ListPendingImagesForUserAndStage(ctx context.Context, arg ListPendingImagesForUserAndStageParams) ([]Image, error)
+	ListTagsOfImages(ctx context.Context, imageHashes []string) ([]ImageTag, error)
+	MarkImageMissing(ctx context.Context, sha256 string) error
+	// The oldest annotation of a stage nobody reviewed yet, leaving out the
+	// reviewer's own answers.
+	NextAnnotationToReview(ctx context.Context, arg NextAnnotationToReviewParams) (NextAnnotationToReviewRow, error)
+	// The insert triggers of task_images fill task_counts back in.
+	RebuildTaskImages(ctx context.Context) error
+	ReleaseLease(ctx context.Context, arg ReleaseLeaseParams) error
+	ReleaseLeaseForImageStage(ctx context.Context, arg ReleaseLeaseForImageStageParams) error
+	RemoveImageTag(ctx context.Context, arg RemoveImageTagParams) error
+	SetTaskRulesFingerprint(ctx context.Context, fingerprint string) error
+	UpdateImagePHash(ctx context.Context, arg UpdateImagePHashParams) error
+	UpsertIngestIndexEntry(ctx context.Context, arg UpsertIngestIndexEntryParams) error
 }
 
 var _ Querier = (*Queries)(nil)
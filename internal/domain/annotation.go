@@ -12,6 +12,11 @@ type Annotation struct {
 	Username    string
 	StageIndex  int
 	OptionValue string
+	// Sure is false when the annotator was unsure of the answer
+	Sure bool
+	// Duration is how long the image was shown before the answer, zero
+	// when unknown
+	Duration    time.Duration
 	AnnotatedAt time.Time
 }
 
@@ -21,6 +26,21 @@ type AnnotationWithImage struct {
 	ImageFilename string
 }
 
+// AnnotationFilter selects annotated images by their annotations. Zero
+// fields match everything.
+type AnnotationFilter struct {
+	// StageIndex is a pointer because stage 0 is a real stage
+	StageIndex  *int
+	OptionValue string
+	Username    string
+	// AnnotatedAfter is inclusive, AnnotatedBefore exclusive
+	AnnotatedAfter, AnnotatedBefore time.Time
+	UnsureOnly                      bool
+	// DisagreementOnly keeps images that annotators answered differently
+	// in the same stage
+	DisagreementOnly bool
+}
+
 // AnnotationStats provides statistics about annotations
 type AnnotationStats struct {
 	AnnotatedImages  int64
@@ -28,10 +48,44 @@ type AnnotationStats struct {
 	TotalUsers       int64
 }
 
+// UserStageStats counts the annotations of a user in a stage
+type UserStageStats struct {
+	Username    string
+	StageIndex  int
+	Annotations int64
+	Unsure      int64
+	// Compared counts the answers other annotators gave to the same images,
+	// Agreed those equal to the user's
+	Compared, Agreed int64
+	// Graded counts the annotations whose image was reviewed by someone
+	// else, Correct those matching the latest review
+	Graded, Correct int64
+}
+
+// UserDayCount is how many annotations a user made on a day
+type UserDayCount struct {
+	Username string
+	// Day is YYYY-MM-DD in UTC
+	Day         string
+	Annotations int64
+}
+
+// AnnotationDuration is how long a user took to answer an image
+type AnnotationDuration struct {
+	Username    string
+	StageIndex  int
+	OptionValue string
+	Duration    time.Duration
+}
+
 // AnnotationRepository defines the interface for annotation storage operations
 type AnnotationRepository interface {
 	// Create creates or updates an annotation (upsert)
-	Create(ctx context.Context, imageSHA256 string, username string, stageIndex int, optionValue string) (*Annotation, error)
+	// duration is the time-on-image, zero when unknown
+	Create(ctx context.Context, imageSHA256 string, username string, stageIndex int, optionValue string, sure bool, duration time.Duration) (*Annotation, error)
+
+	// GetByID retrieves an annotation by its ID
+	GetByID(ctx context.Context, id int64) (*Annotation, error)
 
 	// Get retrieves a specific annotation
 	Get(ctx context.Context, imageSHA256 string, username string, stageIndex int) (*Annotation, error)
@@ -62,4 +116,23 @@ type AnnotationRepository interface {
 
 	// GetStats returns overall annotation statistics
 	GetStats(ctx context.Context) (*AnnotationStats, error)
+
+	// ListAnnotatedImages retrieves a page of the images with annotations
+	// matching filter, most recently annotated first
+	ListAnnotatedImages(ctx context.Context, filter AnnotationFilter, limit, offset int) ([]*Image, error)
+
+	// CountAnnotatedImages returns how many images ListAnnotatedImages pages through
+	CountAnnotatedImages(ctx context.Context, filter AnnotationFilter) (int64, error)
+
+	// UserStageStats returns the statistics of every user in every stage
+	// for annotations made in [after, before); zero times are unbounded
+	UserStageStats(ctx context.Context, after, before time.Time) ([]*UserStageStats, error)
+
+	// UserDailyCounts returns how many annotations each user made per day
+	// in [after, before)
+	UserDailyCounts(ctx context.Context, after, before time.Time) ([]*UserDayCount, error)
+
+	// ListDurations returns the time-on-image of the annotations made in
+	// [after, before) that have one
+	ListDurations(ctx context.Context, after, before time.Time) ([]*AnnotationDuration, error)
 }
@@ -0,0 +1,65 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Annotation event kinds
+const (
+	// EventCreate is the first answer of a user to an image in a stage
+	EventCreate = "create"
+	// EventOverwrite replaces the answer of a user
+	EventOverwrite = "overwrite"
+	// EventDelete removes an annotation along with its image
+	EventDelete = "delete"
+	// EventReview is the verdict of a reviewer on an annotation
+	EventReview = "review"
+	// EventImport brings an annotation from a legacy database
+	EventImport = "import"
+)
+
+// Annotation event sources
+const (
+	EventSourceWeb    = "web"
+	EventSourceAPI    = "api"
+	EventSourceImport = "import"
+	EventSourceCLI    = "cli"
+)
+
+// AnnotationEvent is an entry of the append-only history of annotations.
+// Username owns the annotation and Actor made the change, which differ for
+// reviews and deletes. OldValue is empty for creates and NewValue for deletes.
+type AnnotationEvent struct {
+	ID           int64
+	Kind         string
+	Source       string
+	Actor        string
+	ImageSHA256  string
+	StageIndex   int
+	Username     string
+	AnnotationID int64
+	OldValue     string
+	NewValue     string
+	CreatedAt    time.Time
+}
+
+// EventFilter selects annotation events. Zero fields match everything.
+type EventFilter struct {
+	ImageSHA256 string
+	// StageIndex is a pointer because stage 0 is a real stage
+	StageIndex *int
+	// Username matches both the owner of the annotation and the actor
+	Username string
+	Since    time.Time
+}
+
+// EventRepository defines the interface for the annotation history. There
+// is no way to change or remove an event.
+type EventRepository interface {
+	// Create appends an event; ID and CreatedAt are filled in by the database
+	Create(ctx context.Context, event *AnnotationEvent) (*AnnotationEvent, error)
+
+	// List returns at most limit events matching filter, newest first
+	List(ctx context.Context, filter EventFilter, limit int) ([]*AnnotationEvent, error)
+}
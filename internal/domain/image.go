@@ -2,6 +2,7 @@ package domain
 
 import (
 	"context"
+	"path"
 	"time"
 )
 
@@ -10,6 +11,59 @@ type Image struct {
 	SHA256     string
 	Filename   string
 	IngestedAt time.Time
+	// PHash is the perceptual difference hash, nil until computed at ingest
+	PHash *uint64
+	// MissingAt is set once no file in the images directory holds the image
+	MissingAt time.Time
+	ImageMetadata
+}
+
+// SourceDir is the directory of the image relative to the images directory,
+// empty for images at its root.
+func (img *Image) SourceDir() string {
+	dir := path.Dir(img.Filename)
+	if dir == "." {
+		return ""
+	}
+	return dir
+}
+
+// ImageMetadata describes the stored file. Fields are zero when unknown.
+type ImageMetadata struct {
+	// SourcePath is where 'ingest' found the file before copying it, or the
+	// member path when it was read from SourceArchive
+	SourcePath    string
+	SourceArchive string
+	// FrameOffset is set for frames extracted from the video at SourcePath
+	FrameOffset *time.Duration
+	// Format is the decoder name (jpeg, png, gif, ...)
+	Format    string
+	Width     int
+	Height    int
+	SizeBytes int64
+	// CapturedAt and Camera come from EXIF
+	CapturedAt time.Time
+	Camera     string
+}
+
+// ImageFilter selects images by metadata. Zero fields match everything.
+type ImageFilter struct {
+	Format string
+	Camera string
+	Tag    string
+	// SourcePrefix matches the start of the source path
+	SourcePrefix string
+	// SourceDir matches images in a directory of the images directory and
+	// its subdirectories
+	SourceDir            string
+	MinWidth, MaxWidth   int
+	MinHeight, MaxHeight int
+	MinSize, MaxSize     int64
+	// CapturedAfter is inclusive, CapturedBefore exclusive
+	CapturedAfter, CapturedBefore time.Time
+	// IncludeMissing also matches images whose file was removed, which are
+	// left out otherwise
+	IncludeMissing bool
 }
 
 // ImageRepository defines the interface for image storage operations
@@ -17,6 +71,9 @@ type ImageRepository interface {
 	// Create creates a new image record
 	Create(ctx context.Context, sha256, filename string) (*Image, error)
 
+	// CreateWithMetadata creates or updates an image record including its metadata
+	CreateWithMetadata(ctx context.Context, sha256, filename string, meta ImageMetadata) (*Image, error)
+
 	// GetBySHA256 retrieves an image by its SHA256 hash
 	GetBySHA256(ctx context.Context, sha256 string) (*Image, error)
 
@@ -31,4 +88,36 @@ type ImageRepository interface {
 
 	// Delete removes an image by SHA256
 	Delete(ctx context.Context, sha256 string) error
+
+	// MarkMissing flags an image whose files were removed from the images directory
+	MarkMissing(ctx context.Context, sha256 string) error
+
+	// SetPHash stores the perceptual hash of an image
+	SetPHash(ctx context.Context, sha256 string, phash uint64) error
+
+	// ListWithPHash retrieves all images that have a perceptual hash
+	ListWithPHash(ctx context.Context) ([]*Image, error)
+
+	// CountWithoutPHash returns how many present images still lack a perceptual hash
+	CountWithoutPHash(ctx context.Context) (int64, error)
+
+	// ListFiltered retrieves the images matching filter
+	ListFiltered(ctx context.Context, filter ImageFilter) ([]*Image, error)
+
+	// ListPage retrieves up to limit images matching filter that sort after
+	// the image after, in filename order. A nil after starts from the first
+	// image and a zero limit lists them all.
+	ListPage(ctx context.Context, filter ImageFilter, after *Image, limit int) ([]*Image, error)
+
+	// AddTag attaches a user tag to an image
+	AddTag(ctx context.Context, sha256, tag string) error
+
+	// RemoveTag detaches a user tag from an image
+	RemoveTag(ctx context.Context, sha256, tag string) error
+
+	// ListTags retrieves the tags of an image
+	ListTags(ctx context.Context, sha256 string) ([]string, error)
+
+	// ListTagsOf retrieves the tags of several images, keyed by hash
+	ListTagsOf(ctx context.Context, sha256s []string) (map[string][]string, error)
 }
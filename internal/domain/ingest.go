@@ -0,0 +1,34 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// IngestIndexEntry records the image a file in the images directory produced,
+// keyed by path, size and modification time
+type IngestIndexEntry struct {
+	// Path is relative to the images directory
+	Path        string
+	SizeBytes   int64
+	ModTime     time.Time
+	ImageSHA256 string
+}
+
+// IngestIndexRepository defines the interface for ingest index storage operations
+type IngestIndexRepository interface {
+	// Put creates or replaces the entry for a path
+	Put(ctx context.Context, entry IngestIndexEntry) error
+
+	// Get retrieves the entry for a path, nil when the path is not indexed
+	Get(ctx context.Context, path string) (*IngestIndexEntry, error)
+
+	// List retrieves all entries
+	List(ctx context.Context) ([]*IngestIndexEntry, error)
+
+	// Delete removes the entry for a path
+	Delete(ctx context.Context, path string) error
+
+	// CountForImage returns how many paths hold an image
+	CountForImage(ctx context.Context, imageSHA256 string) (int64, error)
+}
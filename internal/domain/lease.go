@@ -0,0 +1,45 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Lease reserves an image for a single user on a single stage until ExpiresAt
+type Lease struct {
+	ImageSHA256 string
+	StageIndex  int
+	Username    string
+	LeasedAt    time.Time
+	ExpiresAt   time.Time
+}
+
+// LeaseWithImage extends Lease with image information
+type LeaseWithImage struct {
+	Lease
+	ImageFilename string
+}
+
+// LeaseRepository defines the interface for image lease storage operations
+type LeaseRepository interface {
+	// Acquire reserves an image for a user, returning nil when another user holds an active lease
+	Acquire(ctx context.Context, imageSHA256 string, stageIndex int, username string, duration time.Duration) (*Lease, error)
+
+	// Release drops the lease held by a user on an image
+	Release(ctx context.Context, imageSHA256 string, stageIndex int, username string) error
+
+	// ReleaseAny drops the lease on an image regardless of who holds it
+	ReleaseAny(ctx context.Context, imageSHA256 string, stageIndex int) error
+
+	// GetActiveForUser returns the most recent active lease of a user on a stage
+	GetActiveForUser(ctx context.Context, username string, stageIndex int) (*Lease, error)
+
+	// GetLeasedByOthers returns image hashes with an active lease on a stage held by someone other than username
+	GetLeasedByOthers(ctx context.Context, stageIndex int, username string) ([]string, error)
+
+	// ListActive returns every active lease
+	ListActive(ctx context.Context) ([]*LeaseWithImage, error)
+
+	// DeleteExpired removes expired leases and returns how many were removed
+	DeleteExpired(ctx context.Context) (int64, error)
+}
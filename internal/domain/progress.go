@@ -0,0 +1,50 @@
+package domain
+
+import "context"
+
+// UnknownDependency is the stage index a TaskDependency takes for an If key
+// that is neither a task nor an image field. No image satisfies it.
+const UnknownDependency = -1
+
+// TaskRule is what qualifies an image for a task: the metadata conditions of
+// its If clause, and the labels it needs in earlier tasks.
+type TaskRule struct {
+	StageIndex   int
+	Filter       ImageFilter
+	Dependencies []TaskDependency
+}
+
+// TaskDependency requires an image to be labeled Value in the task at
+// StageIndex, its latest review taking precedence over its annotations.
+type TaskDependency struct {
+	StageIndex int
+	Value      string
+}
+
+// TaskCounts are the images of a task by status. Missing images are left out.
+type TaskCounts struct {
+	// Total is the number of images in the dataset
+	Total int
+	// Eligible images satisfy the rule of the task
+	Eligible int
+	// Available images are eligible and not annotated yet
+	Available int
+	// Filtered images were annotated in a dependency but do not qualify
+	Filtered int
+}
+
+// TaskProgressRepository keeps the status of every image in every task up to
+// date as annotations, reviews and images change, so progress can be read
+// without scanning the dataset.
+type TaskProgressRepository interface {
+	// SyncRules replaces the rules of the tasks and recomputes the status of
+	// every image when they differ from the stored ones. It reports whether
+	// they did.
+	SyncRules(ctx context.Context, rules []TaskRule) (bool, error)
+
+	// Counts returns the totals of a task
+	Counts(ctx context.Context, stageIndex int) (*TaskCounts, error)
+
+	// ListAvailable returns up to limit images still to annotate in a task
+	ListAvailable(ctx context.Context, stageIndex int, limit int) ([]*Image, error)
+}
@@ -0,0 +1,73 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Review outcomes
+const (
+	// ReviewAccepted confirms the label of the annotation
+	ReviewAccepted = "accepted"
+	// ReviewCorrected replaces the label of the annotation
+	ReviewCorrected = "corrected"
+)
+
+// Review is a second-pass verdict on an annotation. OptionValue is the label
+// the reviewer settled on, which takes precedence over the annotations of the
+// image in that stage.
+type Review struct {
+	ID           int64
+	AnnotationID int64
+	Reviewer     string
+	Outcome      string
+	OptionValue  string
+	ReviewedAt   time.Time
+}
+
+// ReviewWithAnnotation extends Review with the annotation it is about
+type ReviewWithAnnotation struct {
+	Review
+	StageIndex int
+	// Annotator is the user who wrote the reviewed annotation
+	Annotator string
+}
+
+// AcceptanceRate counts the reviewed annotations of an annotator
+type AcceptanceRate struct {
+	Username string
+	Reviewed int64
+	Accepted int64
+}
+
+// Rate is the fraction of reviewed annotations that were accepted
+func (r AcceptanceRate) Rate() float64 {
+	if r.Reviewed == 0 {
+		return 0
+	}
+	return float64(r.Accepted) / float64(r.Reviewed)
+}
+
+// ReviewRepository defines the interface for review storage operations
+type ReviewRepository interface {
+	// Create records the verdict of a reviewer on an annotation, replacing
+	// their previous one
+	Create(ctx context.Context, annotationID int64, reviewer, outcome, optionValue string) (*Review, error)
+
+	// DeleteForAnnotation removes the reviews of an annotation and returns
+	// how many there were
+	DeleteForAnnotation(ctx context.Context, annotationID int64) (int64, error)
+
+	// NextToReview returns the oldest unreviewed annotation of a stage by
+	// someone other than reviewer, or nil when there is none
+	NextToReview(ctx context.Context, stageIndex int, reviewer string) (*AnnotationWithImage, error)
+
+	// CountToReview returns how many annotations NextToReview still has to go through
+	CountToReview(ctx context.Context, stageIndex int, reviewer string) (int64, error)
+
+	// GetForImage retrieves the reviews of all annotations of an image, oldest first
+	GetForImage(ctx context.Context, imageSHA256 string) ([]*ReviewWithAnnotation, error)
+
+	// AcceptanceRates returns the acceptance rate of each annotator of a stage
+	AcceptanceRates(ctx context.Context, stageIndex int) ([]*AcceptanceRate, error)
+}
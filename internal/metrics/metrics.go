@@ -0,0 +1,39 @@
+// Package metrics holds the Prometheus registry of the process-wide metrics
+// and serves it along with the metrics computed on every scrape.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry holds the process-wide metrics, with the Go runtime and process
+// ones.
+var Registry = prometheus.NewRegistry()
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Register adds c to Registry and returns it, so metrics can be declared as
+// package variables. Registering two collectors with the same name panics.
+func Register[C prometheus.Collector](c C) C {
+	Registry.MustRegister(c)
+	return c
+}
+
+// Handler serves the metrics of Registry and extra. extra holds families
+// computed for this scrape only, like gauges read from the database.
+func Handler(extra ...prometheus.Collector) http.Handler {
+	scrape := prometheus.NewRegistry()
+	scrape.MustRegister(extra...)
+	return promhttp.HandlerFor(prometheus.Gatherers{Registry, scrape}, promhttp.HandlerOpts{
+		ErrorHandling: promhttp.HTTPErrorOnError,
+	})
+}
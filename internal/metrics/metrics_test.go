@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+)
+
+func TestHandler(t *testing.T) {
+	requests := Register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "test_requests_total",
+		Help: "Requests handled.",
+	}, []string{"route"}))
+	requests.WithLabelValues(`/"quoted"`).Add(3)
+	queue := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "test_queue_depth",
+		Help: "Images\nleft.",
+	}, []string{"task"})
+	queue.WithLabelValues("quality").Set(5)
+
+	rec := httptest.NewRecorder()
+	Handler(queue).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(rec.Body)
+	if err != nil {
+		t.Fatalf("metrics are not in the text format: %v", err)
+	}
+
+	for name, want := range map[string]float64{"test_requests_total": 3, "test_queue_depth": 5} {
+		family := families[name]
+		if family == nil || len(family.Metric) != 1 {
+			t.Errorf("%s = %v, want one sample", name, family)
+			continue
+		}
+		m := family.Metric[0]
+		got := m.GetCounter().GetValue() + m.GetGauge().GetValue()
+		if got != want {
+			t.Errorf("%s = %v, want %v", name, got, want)
+		}
+	}
+	if label := families["test_requests_total"].Metric[0].Label[0]; label.GetValue() != `/"quoted"` {
+		t.Errorf("route label = %q, want the quotes back", label.GetValue())
+	}
+	if families["test_queue_depth"].GetHelp() != "Images\nleft." {
+		t.Errorf("help = %q", families["test_queue_depth"].GetHelp())
+	}
+	if families["go_goroutines"] == nil {
+		t.Error("metrics do not include the Go runtime")
+	}
+}
+
+func TestRegisterTwicePanics(t *testing.T) {
+	Register(prometheus.NewCounter(prometheus.CounterOpts{Name: "test_twice_total", Help: "Test."}))
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a name twice did not panic")
+		}
+	}()
+	Register(prometheus.NewCounter(prometheus.CounterOpts{Name: "test_twice_total", Help: "Test."}))
+}
@@ -0,0 +1,85 @@
+package report
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Limiter keeps an error that repeats, like a broken disk failing every
+// request, from flooding the reporter it wraps. Each fingerprint is passed
+// on at most once per window, with the number of occurrences suppressed
+// since the last one, and no more than max events in total are passed on
+// per window.
+type Limiter struct {
+	next   Reporter
+	window time.Duration
+	max    int
+	// now is replaced in tests
+	now func() time.Time
+
+	mu          sync.Mutex
+	windowStart time.Time
+	sent        int
+	seen        map[string]*occurrences
+}
+
+type occurrences struct {
+	lastSent   time.Time
+	suppressed int
+}
+
+// NewLimiter wraps next. A max of zero or less does not cap the total.
+func NewLimiter(next Reporter, window time.Duration, max int) *Limiter {
+	return &Limiter{
+		next:   next,
+		window: window,
+		max:    max,
+		now:    time.Now,
+		seen:   make(map[string]*occurrences),
+	}
+}
+
+// Report passes event on unless its fingerprint was passed on within the
+// window or the total for the window was reached.
+func (l *Limiter) Report(ctx context.Context, event *Event) {
+	fingerprint := event.Fingerprint()
+
+	l.mu.Lock()
+	now := l.now()
+	if now.Sub(l.windowStart) >= l.window {
+		l.windowStart, l.sent = now, 0
+		// Forget fingerprints that went quiet so the map does not grow
+		for key, o := range l.seen {
+			if now.Sub(o.lastSent) >= l.window && o.suppressed == 0 {
+				delete(l.seen, key)
+			}
+		}
+	}
+	o := l.seen[fingerprint]
+	if o == nil {
+		o = &occurrences{}
+		l.seen[fingerprint] = o
+	}
+	if (!o.lastSent.IsZero() && now.Sub(o.lastSent) < l.window) || (l.max > 0 && l.sent >= l.max) {
+		o.suppressed++
+		l.mu.Unlock()
+		return
+	}
+	suppressed := o.suppressed
+	o.lastSent, o.suppressed = now, 0
+	l.sent++
+	l.mu.Unlock()
+
+	if suppressed > 0 {
+		copied := *event
+		copied.Extra = make(map[string]string, len(event.Extra)+1)
+		for key, value := range event.Extra {
+			copied.Extra[key] = value
+		}
+		copied.Extra["suppressed"] = strconv.Itoa(suppressed)
+		event = &copied
+	}
+	l.next.Report(ctx, event)
+}
@@ -0,0 +1,71 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type recorder struct {
+	events []*Event
+}
+
+func (r *recorder) Report(ctx context.Context, event *Event) {
+	r.events = append(r.events, event)
+}
+
+func TestLimiterDeduplicates(t *testing.T) {
+	rec := &recorder{}
+	limiter := NewLimiter(rec, time.Minute, 0)
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	report := func(err error) {
+		limiter.Report(ctx, NewEvent(ctx, err, 0, "msg", "same place"))
+	}
+	for range 5 {
+		report(errors.New("disk full"))
+	}
+	if len(rec.events) != 1 {
+		t.Fatalf("got %d events, want 1 for repeats within the window", len(rec.events))
+	}
+
+	now = now.Add(time.Minute)
+	report(errors.New("disk full"))
+	if len(rec.events) != 2 {
+		t.Fatalf("got %d events, want 2 after the window", len(rec.events))
+	}
+	if got := rec.events[1].Extra["suppressed"]; got != "4" {
+		t.Errorf("suppressed = %q, want 4", got)
+	}
+	if _, ok := rec.events[0].Extra["suppressed"]; ok {
+		t.Error("first event should not count suppressed repeats")
+	}
+}
+
+func TestLimiterCapsTotal(t *testing.T) {
+	rec := &recorder{}
+	limiter := NewLimiter(rec, time.Minute, 2)
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	report := func(msg string) {
+		limiter.Report(ctx, NewEvent(ctx, errors.New("boom"), 0, "msg", msg))
+	}
+	// Distinct messages give distinct fingerprints
+	for _, msg := range []string{"a", "b", "c"} {
+		report(msg)
+	}
+	if len(rec.events) != 2 {
+		t.Fatalf("got %d events, want the cap of 2", len(rec.events))
+	}
+
+	now = now.Add(time.Minute)
+	report("c")
+	if len(rec.events) != 3 || rec.events[2].Extra["suppressed"] != "1" {
+		t.Errorf("capped event should be reported in the next window with its count, got %+v", rec.events[len(rec.events)-1])
+	}
+}
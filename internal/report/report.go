@@ -0,0 +1,106 @@
+// Package report sends the errors the app runs into to an error tracker,
+// with what the request that hit them was about.
+package report
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"time"
+)
+
+// Reporter receives errors. Implementations must be safe for concurrent use
+// and should not block the caller on the network.
+type Reporter interface {
+	Report(ctx context.Context, event *Event)
+}
+
+// Event is an error along with where it happened.
+type Event struct {
+	Err error
+	// Message is the "msg" argument of the report, a fixed description of
+	// what failed like "error getting image"
+	Message string
+	// Extra holds the other key-value arguments of the report
+	Extra map[string]string
+	// Stack holds the program counters of the caller, innermost first
+	Stack []uintptr
+	Time  time.Time
+	// Scope is the request the error happened in, nil outside of one
+	Scope *Scope
+}
+
+// NewEvent builds the event of err reported with slog-style key-value args.
+// skip is the number of frames above the caller of NewEvent to leave out of
+// the stack, like runtime.Callers.
+func NewEvent(ctx context.Context, err error, skip int, args ...any) *Event {
+	event := &Event{
+		Err:   err,
+		Extra: make(map[string]string),
+		Time:  time.Now(),
+		Scope: ScopeFrom(ctx),
+	}
+	for i := 0; i+1 < len(args); i += 2 {
+		key := fmt.Sprint(args[i])
+		value := fmt.Sprint(args[i+1])
+		if key == "msg" {
+			event.Message = value
+			continue
+		}
+		event.Extra[key] = value
+	}
+	pcs := make([]uintptr, 64)
+	event.Stack = pcs[:runtime.Callers(skip+2, pcs)]
+	return event
+}
+
+// Fingerprint groups events of the same problem: the same call site
+// reporting the same kind of error. It leaves the error text out because it
+// often holds IDs and paths.
+func (e *Event) Fingerprint() string {
+	location := "unknown"
+	if len(e.Stack) > 0 {
+		frame, _ := runtime.CallersFrames(e.Stack[:1]).Next()
+		location = fmt.Sprintf("%s:%d", frame.Function, frame.Line)
+	}
+	return fmt.Sprintf("%s|%s|%T", location, e.Message, rootCause(e.Err))
+}
+
+// rootCause unwraps err down to the error that started the chain.
+func rootCause(err error) error {
+	for {
+		next, ok := err.(interface{ Unwrap() error })
+		if !ok || next.Unwrap() == nil {
+			return err
+		}
+		err = next.Unwrap()
+	}
+}
+
+// Scope describes the HTTP request an error happened in. Task and Image are
+// empty for pages not about one.
+type Scope struct {
+	// Route is the pattern of the handler that served the request
+	Route  string
+	Method string
+	URL    string
+	User   string
+	Task   string
+	Image  string
+}
+
+type scopeKey struct{}
+
+// WithScope attaches the scope of a request to ctx.
+func WithScope(ctx context.Context, scope *Scope) context.Context {
+	return context.WithValue(ctx, scopeKey{}, scope)
+}
+
+// ScopeFrom returns the scope attached to ctx, or nil.
+func ScopeFrom(ctx context.Context) *Scope {
+	if ctx == nil {
+		return nil
+	}
+	scope, _ := ctx.Value(scopeKey{}).(*Scope)
+	return scope
+}
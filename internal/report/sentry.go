@@ -0,0 +1,320 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sentryQueueSize is how many events wait to be sent before new ones are
+// dropped.
+const sentryQueueSize = 100
+
+// SentryOptions configures a Sentry client.
+type SentryOptions struct {
+	// DSN is the project key URL shown in the Sentry project settings, like
+	// https://<key>@sentry.example.com/<project>
+	DSN         string
+	Release     string
+	Environment string
+	// HTTPClient defaults to a client with a 10 second timeout
+	HTTPClient *http.Client
+}
+
+// Sentry sends events to a Sentry-compatible server through its envelope
+// endpoint. Events are sent from a background goroutine so Report never
+// waits on the network; Close sends those still queued.
+type Sentry struct {
+	opts       SentryOptions
+	endpoint   string
+	auth       string
+	serverName string
+
+	queue chan []byte
+	done  chan struct{}
+	// mu guards closed, so Report never sends on the closed queue
+	mu     sync.Mutex
+	closed bool
+}
+
+// NewSentry parses the DSN in opts and starts the sending goroutine.
+func NewSentry(opts SentryOptions) (*Sentry, error) {
+	endpoint, key, err := parseDSN(opts.DSN)
+	if err != nil {
+		return nil, err
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	hostname, _ := os.Hostname()
+	s := &Sentry{
+		opts:       opts,
+		endpoint:   endpoint,
+		auth:       fmt.Sprintf("Sentry sentry_version=7, sentry_client=rotulador/%s, sentry_key=%s", opts.Release, key),
+		serverName: hostname,
+		queue:      make(chan []byte, sentryQueueSize),
+		done:       make(chan struct{}),
+	}
+	go s.run()
+	return s, nil
+}
+
+// parseDSN returns the envelope endpoint and public key of dsn.
+func parseDSN(dsn string) (endpoint, key string, err error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "", "", fmt.Errorf("while parsing Sentry DSN: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", "", fmt.Errorf("Sentry DSN must be an http or https URL")
+	}
+	key = u.User.Username()
+	if key == "" {
+		return "", "", fmt.Errorf("Sentry DSN has no public key")
+	}
+	path := strings.Trim(u.Path, "/")
+	project := path
+	prefix := ""
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		prefix, project = "/"+path[:i], path[i+1:]
+	}
+	if project == "" {
+		return "", "", fmt.Errorf("Sentry DSN has no project ID")
+	}
+	endpoint = fmt.Sprintf("%s://%s%s/api/%s/envelope/", u.Scheme, u.Host, prefix, project)
+	return endpoint, key, nil
+}
+
+// Report queues event to be sent, dropping it if the queue is full or the
+// client is closed.
+func (s *Sentry) Report(ctx context.Context, event *Event) {
+	envelope, err := s.envelope(event)
+	if err != nil {
+		slog.Warn("while encoding Sentry event", "err", err)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		slog.Warn("Sentry client is closed, dropping event", "msg", event.Message)
+		return
+	}
+	select {
+	case s.queue <- envelope:
+	default:
+		slog.Warn("Sentry queue is full, dropping event", "msg", event.Message)
+	}
+}
+
+// Close sends the queued events and stops the sending goroutine, giving up
+// when ctx is done.
+func (s *Sentry) Close(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Sentry) run() {
+	defer close(s.done)
+	for envelope := range s.queue {
+		if err := s.send(envelope); err != nil {
+			slog.Warn("while sending event to Sentry", "err", err)
+		}
+	}
+}
+
+func (s *Sentry) send(envelope []byte) error {
+	req, err := http.NewRequest(http.MethodPost, s.endpoint, bytes.NewReader(envelope))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", s.auth)
+	res, err := s.opts.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("Sentry answered %s", res.Status)
+	}
+	return nil
+}
+
+type sentryEvent struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Platform    string            `json:"platform"`
+	Level       string            `json:"level"`
+	Logger      string            `json:"logger"`
+	ServerName  string            `json:"server_name,omitempty"`
+	Release     string            `json:"release,omitempty"`
+	Environment string            `json:"environment,omitempty"`
+	Message     string            `json:"message,omitempty"`
+	Exception   *sentryExceptions `json:"exception,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	User        *sentryUser       `json:"user,omitempty"`
+	Request     *sentryRequest    `json:"request,omitempty"`
+	Extra       map[string]string `json:"extra,omitempty"`
+	Fingerprint []string          `json:"fingerprint"`
+}
+
+type sentryExceptions struct {
+	Values []sentryException `json:"values"`
+}
+
+type sentryException struct {
+	Type       string            `json:"type"`
+	Value      string            `json:"value"`
+	Stacktrace *sentryStacktrace `json:"stacktrace,omitempty"`
+}
+
+type sentryStacktrace struct {
+	Frames []sentryFrame `json:"frames"`
+}
+
+type sentryFrame struct {
+	Function string `json:"function"`
+	Module   string `json:"module,omitempty"`
+	Filename string `json:"filename"`
+	AbsPath  string `json:"abs_path"`
+	Lineno   int    `json:"lineno"`
+	InApp    bool   `json:"in_app"`
+}
+
+type sentryUser struct {
+	Username string `json:"username"`
+}
+
+type sentryRequest struct {
+	URL    string `json:"url"`
+	Method string `json:"method"`
+}
+
+// envelope encodes event as an envelope with a single event item.
+func (s *Sentry) envelope(event *Event) ([]byte, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	payload := sentryEvent{
+		EventID:     hex.EncodeToString(id),
+		Timestamp:   event.Time.UTC().Format(time.RFC3339Nano),
+		Platform:    "go",
+		Level:       "error",
+		Logger:      "rotulador",
+		ServerName:  s.serverName,
+		Release:     s.opts.Release,
+		Environment: s.opts.Environment,
+		Message:     event.Message,
+		Extra:       event.Extra,
+		Fingerprint: []string{event.Fingerprint()},
+	}
+	if event.Err != nil {
+		payload.Exception = &sentryExceptions{Values: []sentryException{{
+			Type:       fmt.Sprintf("%T", rootCause(event.Err)),
+			Value:      event.Err.Error(),
+			Stacktrace: stacktrace(event.Stack),
+		}}}
+	}
+	if scope := event.Scope; scope != nil {
+		payload.Tags = make(map[string]string)
+		for key, value := range map[string]string{
+			"route":  scope.Route,
+			"method": scope.Method,
+			"task":   scope.Task,
+			"image":  scope.Image,
+		} {
+			if value != "" {
+				payload.Tags[key] = value
+			}
+		}
+		if scope.User != "" {
+			payload.User = &sentryUser{Username: scope.User}
+		}
+		payload.Request = &sentryRequest{URL: scope.URL, Method: scope.Method}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	header, err := json.Marshal(map[string]string{
+		"event_id": payload.EventID,
+		"sent_at":  time.Now().UTC().Format(time.RFC3339Nano),
+		"dsn":      s.opts.DSN,
+	})
+	if err != nil {
+		return nil, err
+	}
+	itemHeader, err := json.Marshal(map[string]any{"type": "event", "length": len(body)})
+	if err != nil {
+		return nil, err
+	}
+	return bytes.Join([][]byte{header, itemHeader, body, nil}, []byte("\n")), nil
+}
+
+// stacktrace converts pcs, innermost first, to Sentry frames, which go
+// outermost first.
+func stacktrace(pcs []uintptr) *sentryStacktrace {
+	if len(pcs) == 0 {
+		return nil
+	}
+	var frames []sentryFrame
+	iter := runtime.CallersFrames(pcs)
+	for {
+		frame, more := iter.Next()
+		module, function := splitFunction(frame.Function)
+		frames = append(frames, sentryFrame{
+			Function: function,
+			Module:   module,
+			Filename: frame.File[strings.LastIndex(frame.File, "/")+1:],
+			AbsPath:  frame.File,
+			Lineno:   frame.Line,
+			InApp:    strings.HasPrefix(module, "github.com/lewtec/rotulador"),
+		})
+		if !more {
+			break
+		}
+	}
+	for i, j := 0, len(frames)-1; i < j; i, j = i+1, j-1 {
+		frames[i], frames[j] = frames[j], frames[i]
+	}
+	return &sentryStacktrace{Frames: frames}
+}
+
+// splitFunction splits a qualified name like
+// github.com/lewtec/rotulador/internal/web.(*AnnotatorApp).handleHome
+// into its package path and function.
+func splitFunction(name string) (module, function string) {
+	slash := strings.LastIndex(name, "/")
+	dot := strings.Index(name[slash+1:], ".")
+	if dot < 0 {
+		return "", name
+	}
+	dot += slash + 1
+	return name[:dot], name[dot+1:]
+}
+
+var _ Reporter = (*Sentry)(nil)
+var _ Reporter = (*Limiter)(nil)
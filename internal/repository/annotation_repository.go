@@ -4,9 +4,11 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"time"
 
 	"github.com/lewtec/rotulador/internal/domain"
 	"github.com/lewtec/rotulador/internal/db/sqlc"
+	"github.com/lewtec/rotulador/internal/tracing"
 )
 
 // AnnotationRepository implements domain.AnnotationRepository using SQLC
@@ -17,24 +19,29 @@ type AnnotationRepository struct {
 // NewAnnotationRepository creates a new AnnotationRepository
 func NewAnnotationRepository(db *sql.DB) *AnnotationRepository {
 	return &AnnotationRepository{
-		queries: sqlc.New(db),
+		queries: sqlc.New(timed(db)),
 	}
 }
 
 // NewAnnotationRepositoryWithTx creates a new AnnotationRepository with a transaction
 func NewAnnotationRepositoryWithTx(tx *sql.Tx) *AnnotationRepository {
 	return &AnnotationRepository{
-		queries: sqlc.New(tx),
+		queries: sqlc.New(timed(tx)),
 	}
 }
 
 // Create creates or updates an annotation (upsert)
-func (r *AnnotationRepository) Create(ctx context.Context, imageSHA256 string, username string, stageIndex int, optionValue string) (*domain.Annotation, error) {
+func (r *AnnotationRepository) Create(ctx context.Context, imageSHA256 string, username string, stageIndex int, optionValue string, sure bool, duration time.Duration) (*domain.Annotation, error) {
+	ctx, span := tracing.Start(ctx, "AnnotationRepository.Create")
+	defer span.End()
+
 	params := sqlc.CreateAnnotationParams{
 		ImageSha256: imageSHA256,
 		Username:    username,
 		StageIndex:  int64(stageIndex),
 		OptionValue: optionValue,
+		Sure:        sure,
+		DurationMs:  nullInt64(duration.Milliseconds()),
 	}
 
 	ann, err := r.queries.CreateAnnotation(ctx, params)
@@ -45,8 +52,27 @@ func (r *AnnotationRepository) Create(ctx context.Context, imageSHA256 string, u
 	return toDomainAnnotation(ann), nil
 }
 
+// GetByID retrieves an annotation by its ID
+func (r *AnnotationRepository) GetByID(ctx context.Context, id int64) (*domain.Annotation, error) {
+	ctx, span := tracing.Start(ctx, "AnnotationRepository.GetByID")
+	defer span.End()
+
+	ann, err := r.queries.GetAnnotationByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return toDomainAnnotation(ann), nil
+}
+
 // Get retrieves a specific annotation
 func (r *AnnotationRepository) Get(ctx context.Context, imageSHA256 string, username string, stageIndex int) (*domain.Annotation, error) {
+	ctx, span := tracing.Start(ctx, "AnnotationRepository.Get")
+	defer span.End()
+
 	params := sqlc.GetAnnotationParams{
 		ImageSha256: imageSHA256,
 		Username:    username,
@@ -66,6 +92,9 @@ func (r *AnnotationRepository) Get(ctx context.Context, imageSHA256 string, user
 
 // GetForImage retrieves all annotations for a specific image
 func (r *AnnotationRepository) GetForImage(ctx context.Context, imageSHA256 string) ([]*domain.Annotation, error) {
+	ctx, span := tracing.Start(ctx, "AnnotationRepository.GetForImage")
+	defer span.End()
+
 	anns, err := r.queries.GetAnnotationsForImage(ctx, imageSHA256)
 	if err != nil {
 		return nil, err
@@ -81,6 +110,9 @@ func (r *AnnotationRepository) GetForImage(ctx context.Context, imageSHA256 stri
 
 // GetByUser retrieves annotations by a specific user (paginated)
 func (r *AnnotationRepository) GetByUser(ctx context.Context, username string, limit, offset int) ([]*domain.AnnotationWithImage, error) {
+	ctx, span := tracing.Start(ctx, "AnnotationRepository.GetByUser")
+	defer span.End()
+
 	params := sqlc.GetAnnotationsByUserParams{
 		Username: username,
 		Limit:    int64(limit),
@@ -101,6 +133,7 @@ func (r *AnnotationRepository) GetByUser(ctx context.Context, username string, l
 				Username:    row.Username,
 				StageIndex:  int(row.StageIndex),
 				OptionValue: row.OptionValue,
+				Sure:        row.Sure,
 			},
 			ImageFilename: row.Filename,
 		}
@@ -115,6 +148,9 @@ func (r *AnnotationRepository) GetByUser(ctx context.Context, username string, l
 
 // GetByImageAndUser retrieves all annotations for an image by a specific user
 func (r *AnnotationRepository) GetByImageAndUser(ctx context.Context, imageSHA256 string, username string) ([]*domain.Annotation, error) {
+	ctx, span := tracing.Start(ctx, "AnnotationRepository.GetByImageAndUser")
+	defer span.End()
+
 	params := sqlc.GetAnnotationsByImageAndUserParams{
 		ImageSha256: imageSHA256,
 		Username:    username,
@@ -135,11 +171,17 @@ func (r *AnnotationRepository) GetByImageAndUser(ctx context.Context, imageSHA25
 
 // CountByUser returns the total number of annotations by a user
 func (r *AnnotationRepository) CountByUser(ctx context.Context, username string) (int64, error) {
+	ctx, span := tracing.Start(ctx, "AnnotationRepository.CountByUser")
+	defer span.End()
+
 	return r.queries.CountAnnotationsByUser(ctx, username)
 }
 
 // ListPendingImagesForUserAndStage finds images that need annotation by a user for a specific stage
 func (r *AnnotationRepository) ListPendingImagesForUserAndStage(ctx context.Context, username string, stageIndex int, limit int) ([]*domain.Image, error) {
+	ctx, span := tracing.Start(ctx, "AnnotationRepository.ListPendingImagesForUserAndStage")
+	defer span.End()
+
 	params := sqlc.ListPendingImagesForUserAndStageParams{
 		Username:   username,
 		StageIndex: int64(stageIndex),
@@ -161,6 +203,9 @@ func (r *AnnotationRepository) ListPendingImagesForUserAndStage(ctx context.Cont
 
 // Exists checks if an annotation exists
 func (r *AnnotationRepository) Exists(ctx context.Context, imageSHA256 string, username string, stageIndex int) (bool, error) {
+	ctx, span := tracing.Start(ctx, "AnnotationRepository.Exists")
+	defer span.End()
+
 	params := sqlc.CheckAnnotationExistsParams{
 		ImageSha256: imageSHA256,
 		Username:    username,
@@ -177,16 +222,25 @@ func (r *AnnotationRepository) Exists(ctx context.Context, imageSHA256 string, u
 
 // Delete removes an annotation by ID
 func (r *AnnotationRepository) Delete(ctx context.Context, id int64) error {
+	ctx, span := tracing.Start(ctx, "AnnotationRepository.Delete")
+	defer span.End()
+
 	return r.queries.DeleteAnnotation(ctx, id)
 }
 
 // DeleteForImage removes all annotations for an image
 func (r *AnnotationRepository) DeleteForImage(ctx context.Context, imageSHA256 string) error {
+	ctx, span := tracing.Start(ctx, "AnnotationRepository.DeleteForImage")
+	defer span.End()
+
 	return r.queries.DeleteAnnotationsForImage(ctx, imageSHA256)
 }
 
 // GetStats returns overall annotation statistics
 func (r *AnnotationRepository) GetStats(ctx context.Context) (*domain.AnnotationStats, error) {
+	ctx, span := tracing.Start(ctx, "AnnotationRepository.GetStats")
+	defer span.End()
+
 	stats, err := r.queries.GetAnnotationStats(ctx)
 	if err != nil {
 		return nil, err
@@ -199,6 +253,147 @@ func (r *AnnotationRepository) GetStats(ctx context.Context) (*domain.Annotation
 	}, nil
 }
 
+// ListAnnotatedImages retrieves a page of the images with annotations matching filter
+func (r *AnnotationRepository) ListAnnotatedImages(ctx context.Context, filter domain.AnnotationFilter, limit, offset int) ([]*domain.Image, error) {
+	ctx, span := tracing.Start(ctx, "AnnotationRepository.ListAnnotatedImages")
+	defer span.End()
+
+	rows, err := r.queries.ListAnnotatedImages(ctx, sqlc.ListAnnotatedImagesParams{
+		StageIndex:       stageIndexFilter(filter.StageIndex),
+		OptionValue:      nullString(filter.OptionValue),
+		Username:         nullString(filter.Username),
+		AnnotatedAfter:   nullTime(filter.AnnotatedAfter),
+		AnnotatedBefore:  nullTime(filter.AnnotatedBefore),
+		Sure:             sureFilter(filter.UnsureOnly),
+		DisagreementOnly: filter.DisagreementOnly,
+		Limit:            int64(limit),
+		Offset:           int64(offset),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*domain.Image, len(rows))
+	for i, row := range rows {
+		result[i] = &domain.Image{SHA256: row.Sha256, Filename: row.Filename}
+	}
+
+	return result, nil
+}
+
+// CountAnnotatedImages returns how many images ListAnnotatedImages pages through
+func (r *AnnotationRepository) CountAnnotatedImages(ctx context.Context, filter domain.AnnotationFilter) (int64, error) {
+	ctx, span := tracing.Start(ctx, "AnnotationRepository.CountAnnotatedImages")
+	defer span.End()
+
+	return r.queries.CountAnnotatedImages(ctx, sqlc.CountAnnotatedImagesParams{
+		StageIndex:       stageIndexFilter(filter.StageIndex),
+		OptionValue:      nullString(filter.OptionValue),
+		Username:         nullString(filter.Username),
+		AnnotatedAfter:   nullTime(filter.AnnotatedAfter),
+		AnnotatedBefore:  nullTime(filter.AnnotatedBefore),
+		Sure:             sureFilter(filter.UnsureOnly),
+		DisagreementOnly: filter.DisagreementOnly,
+	})
+}
+
+// UserStageStats returns the statistics of every user in every stage for
+// annotations made in [after, before)
+func (r *AnnotationRepository) UserStageStats(ctx context.Context, after, before time.Time) ([]*domain.UserStageStats, error) {
+	ctx, span := tracing.Start(ctx, "AnnotationRepository.UserStageStats")
+	defer span.End()
+
+	rows, err := r.queries.GetUserStageStats(ctx, sqlc.GetUserStageStatsParams{
+		AnnotatedAfter:  nullTime(after),
+		AnnotatedBefore: nullTime(before),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*domain.UserStageStats, len(rows))
+	for i, row := range rows {
+		result[i] = &domain.UserStageStats{
+			Username:    row.Username,
+			StageIndex:  int(row.StageIndex),
+			Annotations: row.Annotations,
+			Unsure:      row.Unsure,
+			Compared:    row.Compared,
+			Agreed:      row.Agreed,
+			Graded:      row.Graded,
+			Correct:     row.Correct,
+		}
+	}
+
+	return result, nil
+}
+
+// UserDailyCounts returns how many annotations each user made per day in
+// [after, before)
+func (r *AnnotationRepository) UserDailyCounts(ctx context.Context, after, before time.Time) ([]*domain.UserDayCount, error) {
+	ctx, span := tracing.Start(ctx, "AnnotationRepository.UserDailyCounts")
+	defer span.End()
+
+	rows, err := r.queries.GetUserDailyCounts(ctx, sqlc.GetUserDailyCountsParams{
+		AnnotatedAfter:  nullTime(after),
+		AnnotatedBefore: nullTime(before),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*domain.UserDayCount, len(rows))
+	for i, row := range rows {
+		result[i] = &domain.UserDayCount{Username: row.Username, Day: row.Day, Annotations: row.Annotations}
+	}
+
+	return result, nil
+}
+
+// ListDurations returns the time-on-image of the annotations made in
+// [after, before) that have one
+func (r *AnnotationRepository) ListDurations(ctx context.Context, after, before time.Time) ([]*domain.AnnotationDuration, error) {
+	ctx, span := tracing.Start(ctx, "AnnotationRepository.ListDurations")
+	defer span.End()
+
+	rows, err := r.queries.ListAnnotationDurations(ctx, sqlc.ListAnnotationDurationsParams{
+		AnnotatedAfter:  nullTime(after),
+		AnnotatedBefore: nullTime(before),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*domain.AnnotationDuration, len(rows))
+	for i, row := range rows {
+		result[i] = &domain.AnnotationDuration{
+			Username:    row.Username,
+			StageIndex:  int(row.StageIndex),
+			OptionValue: row.OptionValue,
+			Duration:    time.Duration(row.DurationMs) * time.Millisecond,
+		}
+	}
+
+	return result, nil
+}
+
+func stageIndexFilter(stageIndex *int) *int64 {
+	if stageIndex == nil {
+		return nil
+	}
+	v := int64(*stageIndex)
+	return &v
+}
+
+// sureFilter matches only unsure annotations when asked to, otherwise all
+func sureFilter(unsureOnly bool) *bool {
+	if !unsureOnly {
+		return nil
+	}
+	sure := false
+	return &sure
+}
+
 // toDomainAnnotation converts a sqlc.Annotation to domain.Annotation
 func toDomainAnnotation(ann sqlc.Annotation) *domain.Annotation {
 	d := &domain.Annotation{
@@ -207,20 +402,30 @@ func toDomainAnnotation(ann sqlc.Annotation) *domain.Annotation {
 		Username:    ann.Username,
 		StageIndex:  int(ann.StageIndex),
 		OptionValue: ann.OptionValue,
+		Sure:        ann.Sure,
 	}
 	if ann.AnnotatedAt != nil {
 		d.AnnotatedAt = *ann.AnnotatedAt
 	}
+	if ann.DurationMs != nil {
+		d.Duration = time.Duration(*ann.DurationMs) * time.Millisecond
+	}
 	return d
 }
 
 // CountImagesWithoutAnnotationForStage counts images without any annotation for a stage
 func (r *AnnotationRepository) CountImagesWithoutAnnotationForStage(ctx context.Context, stageIndex int64) (int64, error) {
+	ctx, span := tracing.Start(ctx, "AnnotationRepository.CountImagesWithoutAnnotationForStage")
+	defer span.End()
+
 	return r.queries.CountImagesWithoutAnnotationForStage(ctx, stageIndex)
 }
 
 // GetImageHashesWithAnnotation returns image SHA256 hashes that have a specific annotation value for a stage
 func (r *AnnotationRepository) GetImageHashesWithAnnotation(ctx context.Context, stageIndex int64, optionValue string) ([]string, error) {
+	ctx, span := tracing.Start(ctx, "AnnotationRepository.GetImageHashesWithAnnotation")
+	defer span.End()
+
 	params := sqlc.GetImageHashesWithAnnotationParams{
 		StageIndex:  stageIndex,
 		OptionValue: optionValue,
@@ -230,6 +435,9 @@ func (r *AnnotationRepository) GetImageHashesWithAnnotation(ctx context.Context,
 
 // CountPendingImagesForUserAndStage counts images needing annotation by a user for a specific stage
 func (r *AnnotationRepository) CountPendingImagesForUserAndStage(ctx context.Context, username string, stageIndex int64) (int64, error) {
+	ctx, span := tracing.Start(ctx, "AnnotationRepository.CountPendingImagesForUserAndStage")
+	defer span.End()
+
 	params := sqlc.CountPendingImagesForUserAndStageParams{
 		Username:   username,
 		StageIndex: stageIndex,
@@ -239,6 +447,9 @@ func (r *AnnotationRepository) CountPendingImagesForUserAndStage(ctx context.Con
 
 // CheckAnnotationExists checks if any annotation exists for an image at a stage (any user)
 func (r *AnnotationRepository) CheckAnnotationExists(ctx context.Context, imageSHA256 string, username string, stageIndex int64) (bool, error) {
+	ctx, span := tracing.Start(ctx, "AnnotationRepository.CheckAnnotationExists")
+	defer span.End()
+
 	// If username is empty, check if any annotation exists for this image+stage using optimized query
 	if username == "" {
 		params := sqlc.CheckAnnotationExistsForImageStageParams{
@@ -2,7 +2,11 @@ package repository
 
 import (
 	"context"
+	"slices"
 	"testing"
+	"time"
+
+	"github.com/lewtec/rotulador/internal/domain"
 )
 
 func setupTestRepositories(t *testing.T) (*ImageRepository, *AnnotationRepository, context.Context) {
@@ -23,7 +27,7 @@ func TestAnnotationRepository_Create(t *testing.T) {
 	}
 
 	t.Run("creates annotation successfully", func(t *testing.T) {
-		ann, err := annRepo.Create(ctx, img.SHA256, "testuser", 0, "good")
+		ann, err := annRepo.Create(ctx, img.SHA256, "testuser", 0, "good", true, 0)
 		if err != nil {
 			t.Fatalf("Create() error = %v", err)
 		}
@@ -50,10 +54,10 @@ func TestAnnotationRepository_Create(t *testing.T) {
 
 	t.Run("upserts existing annotation", func(t *testing.T) {
 		// Create initial annotation
-		ann1, _ := annRepo.Create(ctx, img.SHA256, "user2", 0, "bad")
+		ann1, _ := annRepo.Create(ctx, img.SHA256, "user2", 0, "bad", true, 0)
 
 		// Update with new value
-		ann2, err := annRepo.Create(ctx, img.SHA256, "user2", 0, "good")
+		ann2, err := annRepo.Create(ctx, img.SHA256, "user2", 0, "good", true, 0)
 		if err != nil {
 			t.Fatalf("Create() error = %v", err)
 		}
@@ -72,7 +76,7 @@ func TestAnnotationRepository_Get(t *testing.T) {
 
 	// Create test data
 	img, _ := imgRepo.Create(ctx, "/test/image.jpg", "test.jpg")
-	created, _ := annRepo.Create(ctx, img.SHA256, "testuser", 0, "good")
+	created, _ := annRepo.Create(ctx, img.SHA256, "testuser", 0, "good", true, 0)
 
 	t.Run("retrieves existing annotation", func(t *testing.T) {
 		ann, err := annRepo.Get(ctx, img.SHA256, "testuser", 0)
@@ -104,13 +108,13 @@ func TestAnnotationRepository_GetForImage(t *testing.T) {
 
 	// Create test data
 	img, _ := imgRepo.Create(ctx, "/test/image.jpg", "test.jpg")
-	if _, err := annRepo.Create(ctx, img.SHA256, "user1", 0, "good"); err != nil {
+	if _, err := annRepo.Create(ctx, img.SHA256, "user1", 0, "good", true, 0); err != nil {
 		t.Fatal(err)
 	}
-	if _, err := annRepo.Create(ctx, img.SHA256, "user2", 0, "bad"); err != nil {
+	if _, err := annRepo.Create(ctx, img.SHA256, "user2", 0, "bad", true, 0); err != nil {
 		t.Fatal(err)
 	}
-	if _, err := annRepo.Create(ctx, img.SHA256, "user1", 1, "true"); err != nil {
+	if _, err := annRepo.Create(ctx, img.SHA256, "user1", 1, "true", true, 0); err != nil {
 		t.Fatal(err)
 	}
 
@@ -137,13 +141,13 @@ func TestAnnotationRepository_GetByUser(t *testing.T) {
 	// Create test data
 	img1, _ := imgRepo.Create(ctx, "/test/image1.jpg", "image1.jpg")
 	img2, _ := imgRepo.Create(ctx, "/test/image2.jpg", "image2.jpg")
-	if _, err := annRepo.Create(ctx, img1.SHA256, "testuser", 0, "good"); err != nil {
+	if _, err := annRepo.Create(ctx, img1.SHA256, "testuser", 0, "good", true, 0); err != nil {
 		t.Fatal(err)
 	}
-	if _, err := annRepo.Create(ctx, img2.SHA256, "testuser", 0, "bad"); err != nil {
+	if _, err := annRepo.Create(ctx, img2.SHA256, "testuser", 0, "bad", true, 0); err != nil {
 		t.Fatal(err)
 	}
-	if _, err := annRepo.Create(ctx, img1.SHA256, "otheruser", 0, "good"); err != nil {
+	if _, err := annRepo.Create(ctx, img1.SHA256, "otheruser", 0, "good", true, 0); err != nil {
 		t.Fatal(err)
 	}
 
@@ -200,13 +204,13 @@ func TestAnnotationRepository_GetByImageAndUser(t *testing.T) {
 
 	// Create test data
 	img, _ := imgRepo.Create(ctx, "/test/image.jpg", "test.jpg")
-	if _, err := annRepo.Create(ctx, img.SHA256, "testuser", 0, "good"); err != nil {
+	if _, err := annRepo.Create(ctx, img.SHA256, "testuser", 0, "good", true, 0); err != nil {
 		t.Fatal(err)
 	}
-	if _, err := annRepo.Create(ctx, img.SHA256, "testuser", 1, "true"); err != nil {
+	if _, err := annRepo.Create(ctx, img.SHA256, "testuser", 1, "true", true, 0); err != nil {
 		t.Fatal(err)
 	}
-	if _, err := annRepo.Create(ctx, img.SHA256, "otheruser", 0, "bad"); err != nil {
+	if _, err := annRepo.Create(ctx, img.SHA256, "otheruser", 0, "bad", true, 0); err != nil {
 		t.Fatal(err)
 	}
 
@@ -235,13 +239,13 @@ func TestAnnotationRepository_CountByUser(t *testing.T) {
 	// Create test data
 	img1, _ := imgRepo.Create(ctx, "/test/image1.jpg", "image1.jpg")
 	img2, _ := imgRepo.Create(ctx, "/test/image2.jpg", "image2.jpg")
-	if _, err := annRepo.Create(ctx, img1.SHA256, "testuser", 0, "good"); err != nil {
+	if _, err := annRepo.Create(ctx, img1.SHA256, "testuser", 0, "good", true, 0); err != nil {
 		t.Fatal(err)
 	}
-	if _, err := annRepo.Create(ctx, img2.SHA256, "testuser", 0, "bad"); err != nil {
+	if _, err := annRepo.Create(ctx, img2.SHA256, "testuser", 0, "bad", true, 0); err != nil {
 		t.Fatal(err)
 	}
-	if _, err := annRepo.Create(ctx, img1.SHA256, "otheruser", 0, "good"); err != nil {
+	if _, err := annRepo.Create(ctx, img1.SHA256, "otheruser", 0, "good", true, 0); err != nil {
 		t.Fatal(err)
 	}
 
@@ -266,12 +270,12 @@ func TestAnnotationRepository_ListPendingImagesForUserAndStage(t *testing.T) {
 	img3, _ := imgRepo.Create(ctx, "/test/image3.jpg", "image3.jpg")
 
 	// testuser annotated stage 0 of img1
-	if _, err := annRepo.Create(ctx, img1.SHA256, "testuser", 0, "good"); err != nil {
+	if _, err := annRepo.Create(ctx, img1.SHA256, "testuser", 0, "good", true, 0); err != nil {
 		t.Fatal(err)
 	}
 
 	// otheruser annotated stage 0 of img2
-	if _, err := annRepo.Create(ctx, img2.SHA256, "otheruser", 0, "bad"); err != nil {
+	if _, err := annRepo.Create(ctx, img2.SHA256, "otheruser", 0, "bad", true, 0); err != nil {
 		t.Fatal(err)
 	}
 
@@ -311,7 +315,7 @@ func TestAnnotationRepository_Exists(t *testing.T) {
 
 	// Create test data
 	img, _ := imgRepo.Create(ctx, "/test/image.jpg", "test.jpg")
-	if _, err := annRepo.Create(ctx, img.SHA256, "testuser", 0, "good"); err != nil {
+	if _, err := annRepo.Create(ctx, img.SHA256, "testuser", 0, "good", true, 0); err != nil {
 		t.Fatal(err)
 	}
 
@@ -343,7 +347,7 @@ func TestAnnotationRepository_Delete(t *testing.T) {
 
 	// Create test data
 	img, _ := imgRepo.Create(ctx, "/test/image.jpg", "test.jpg")
-	ann, _ := annRepo.Create(ctx, img.SHA256, "testuser", 0, "good")
+	ann, _ := annRepo.Create(ctx, img.SHA256, "testuser", 0, "good", true, 0)
 
 	t.Run("deletes annotation", func(t *testing.T) {
 		err := annRepo.Delete(ctx, ann.ID)
@@ -364,10 +368,10 @@ func TestAnnotationRepository_DeleteForImage(t *testing.T) {
 
 	// Create test data
 	img, _ := imgRepo.Create(ctx, "/test/image.jpg", "test.jpg")
-	if _, err := annRepo.Create(ctx, img.SHA256, "user1", 0, "good"); err != nil {
+	if _, err := annRepo.Create(ctx, img.SHA256, "user1", 0, "good", true, 0); err != nil {
 		t.Fatal(err)
 	}
-	if _, err := annRepo.Create(ctx, img.SHA256, "user2", 0, "bad"); err != nil {
+	if _, err := annRepo.Create(ctx, img.SHA256, "user2", 0, "bad", true, 0); err != nil {
 		t.Fatal(err)
 	}
 
@@ -391,13 +395,13 @@ func TestAnnotationRepository_GetStats(t *testing.T) {
 	// Create test data
 	img1, _ := imgRepo.Create(ctx, "/test/image1.jpg", "image1.jpg")
 	img2, _ := imgRepo.Create(ctx, "/test/image2.jpg", "image2.jpg")
-	if _, err := annRepo.Create(ctx, img1.SHA256, "user1", 0, "good"); err != nil {
+	if _, err := annRepo.Create(ctx, img1.SHA256, "user1", 0, "good", true, 0); err != nil {
 		t.Fatal(err)
 	}
-	if _, err := annRepo.Create(ctx, img1.SHA256, "user2", 0, "bad"); err != nil {
+	if _, err := annRepo.Create(ctx, img1.SHA256, "user2", 0, "bad", true, 0); err != nil {
 		t.Fatal(err)
 	}
-	if _, err := annRepo.Create(ctx, img2.SHA256, "user1", 0, "good"); err != nil {
+	if _, err := annRepo.Create(ctx, img2.SHA256, "user1", 0, "good", true, 0); err != nil {
 		t.Fatal(err)
 	}
 
@@ -419,6 +423,81 @@ func TestAnnotationRepository_GetStats(t *testing.T) {
 	})
 }
 
+func TestAnnotationRepository_ListAnnotatedImages(t *testing.T) {
+	imgRepo, annRepo, ctx := setupTestRepositories(t)
+
+	for _, name := range []string{"a", "b", "c", "d"} {
+		if _, err := imgRepo.Create(ctx, name, name+".jpg"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	annotations := []struct {
+		image, user string
+		stage       int
+		value       string
+		sure        bool
+	}{
+		{"a", "user1", 0, "good", true},
+		{"a", "user2", 0, "bad", true},
+		{"b", "user1", 0, "good", false},
+		{"b", "user1", 1, "yes", true},
+		{"c", "user2", 1, "no", true},
+	}
+	for _, a := range annotations {
+		if _, err := annRepo.Create(ctx, a.image, a.user, a.stage, a.value, a.sure, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stage0, stage1 := 0, 1
+	tests := []struct {
+		name   string
+		filter domain.AnnotationFilter
+		want   []string
+	}{
+		{"everything", domain.AnnotationFilter{}, []string{"a", "b", "c"}},
+		{"stage", domain.AnnotationFilter{StageIndex: &stage1}, []string{"b", "c"}},
+		{"class", domain.AnnotationFilter{StageIndex: &stage0, OptionValue: "good"}, []string{"a", "b"}},
+		{"annotator", domain.AnnotationFilter{Username: "user2"}, []string{"a", "c"}},
+		{"unsure", domain.AnnotationFilter{UnsureOnly: true}, []string{"b"}},
+		{"disagreement", domain.AnnotationFilter{DisagreementOnly: true}, []string{"a"}},
+		{"disagreement in stage 1", domain.AnnotationFilter{StageIndex: &stage1, DisagreementOnly: true}, nil},
+		{"future", domain.AnnotationFilter{AnnotatedAfter: time.Now().Add(time.Hour)}, nil},
+		{"past", domain.AnnotationFilter{AnnotatedBefore: time.Now().Add(-time.Hour)}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			images, err := annRepo.ListAnnotatedImages(ctx, tt.filter, 10, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, img := range images {
+				got = append(got, img.SHA256)
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ListAnnotatedImages() = %v, want %v", got, tt.want)
+			}
+			count, err := annRepo.CountAnnotatedImages(ctx, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != int64(len(tt.want)) {
+				t.Errorf("CountAnnotatedImages() = %d, want %d", count, len(tt.want))
+			}
+		})
+	}
+
+	page, err := annRepo.ListAnnotatedImages(ctx, domain.AnnotationFilter{}, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 1 {
+		t.Errorf("second page of 2 = %d images, want 1", len(page))
+	}
+}
+
 // Benchmark tests
 func BenchmarkAnnotationRepository_Create(b *testing.B) {
 	db := SetupTestDB(&testing.T{})
@@ -437,7 +516,7 @@ func BenchmarkAnnotationRepository_Create(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		if _, err := annRepo.Create(ctx, img.SHA256, "testuser", 0, "good"); err != nil {
+		if _, err := annRepo.Create(ctx, img.SHA256, "testuser", 0, "good", true, 0); err != nil {
 			b.Error(err)
 		}
 	}
@@ -458,7 +537,7 @@ func BenchmarkAnnotationRepository_GetForImage(b *testing.B) {
 	// Create test data
 	img, _ := imgRepo.Create(ctx, "/test/image.jpg", "test.jpg")
 	for i := 0; i < 10; i++ {
-		if _, err := annRepo.Create(ctx, img.SHA256, "testuser", i, "good"); err != nil {
+		if _, err := annRepo.Create(ctx, img.SHA256, "testuser", i, "good", true, 0); err != nil {
 			b.Fatal(err)
 		}
 	}
@@ -476,8 +555,86 @@ func TestAnnotationRepository_CreateRequiresExistingImage(t *testing.T) {
 
 	// Schema declares FK(image_sha256) → images(sha256). With foreign_keys ON,
 	// inserting an annotation for a missing image must fail.
-	_, err := annRepo.Create(ctx, "deadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeef", "user", 0, "good")
+	_, err := annRepo.Create(ctx, "deadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeefdeadbeef", "user", 0, "good", true, 0)
 	if err == nil {
 		t.Fatal("Create() without parent image succeeded; foreign_keys not enforced?")
 	}
 }
+
+func TestAnnotationRepository_UserStageStats(t *testing.T) {
+	db := SetupTestDB(t)
+	t.Cleanup(func() { CleanupTestDB(t, db) })
+	imgRepo, annRepo, reviewRepo := NewImageRepository(db), NewAnnotationRepository(db), NewReviewRepository(db)
+	ctx := t.Context()
+
+	for _, name := range []string{"a", "b", "c"} {
+		if _, err := imgRepo.Create(ctx, name, name+".jpg"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var bobOnC *domain.Annotation
+	for _, a := range []struct {
+		image, user, value string
+		sure               bool
+		duration           time.Duration
+	}{
+		{"a", "alice", "good", true, 1500 * time.Millisecond},
+		{"a", "bob", "good", true, 0},
+		{"a", "carol", "bad", false, 0},
+		{"b", "alice", "bad", false, 4 * time.Second},
+		{"c", "bob", "good", true, 0},
+	} {
+		ann, err := annRepo.Create(ctx, a.image, a.user, 0, a.value, a.sure, a.duration)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.image == "c" {
+			bobOnC = ann
+		}
+	}
+	// carol's review of c grades bob there, and nobody else
+	if _, err := reviewRepo.Create(ctx, bobOnC.ID, "carol", domain.ReviewCorrected, "bad"); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := annRepo.UserStageStats(ctx, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]domain.UserStageStats{}
+	for _, s := range stats {
+		got[s.Username] = *s
+	}
+	want := map[string]domain.UserStageStats{
+		"alice": {Username: "alice", Annotations: 2, Unsure: 1, Compared: 2, Agreed: 1},
+		"bob":   {Username: "bob", Annotations: 2, Compared: 2, Agreed: 1, Graded: 1},
+		"carol": {Username: "carol", Annotations: 1, Unsure: 1, Compared: 2},
+	}
+	for user, w := range want {
+		if got[user] != w {
+			t.Errorf("UserStageStats()[%s] = %+v, want %+v", user, got[user], w)
+		}
+	}
+
+	days, err := annRepo.UserDailyCounts(ctx, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	today := time.Now().UTC().Format(time.DateOnly)
+	if len(days) != 3 || days[0].Day != today || days[0].Username != "alice" || days[0].Annotations != 2 {
+		t.Errorf("UserDailyCounts() = %+v, want one row per user for %s", days, today)
+	}
+
+	durations, err := annRepo.ListDurations(ctx, time.Time{}, time.Now().Add(-time.Hour))
+	if err != nil || len(durations) != 0 {
+		t.Errorf("ListDurations() before an hour ago = %v, %v; want nothing", durations, err)
+	}
+	// Annotations without a time-on-image are left out
+	durations, err = annRepo.ListDurations(ctx, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(durations) != 2 || *durations[0] != (domain.AnnotationDuration{Username: "alice", OptionValue: "bad", Duration: 4 * time.Second}) {
+		t.Errorf("ListDurations() = %v, want alice's two annotations", durations)
+	}
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lewtec/rotulador/internal/db/sqlc"
+	"github.com/lewtec/rotulador/internal/domain"
+	"github.com/lewtec/rotulador/internal/tracing"
+)
+
+// EventRepository implements domain.EventRepository using SQLC
+type EventRepository struct {
+	queries *sqlc.Queries
+}
+
+// NewEventRepository creates a new EventRepository
+func NewEventRepository(db *sql.DB) *EventRepository {
+	return &EventRepository{
+		queries: sqlc.New(timed(db)),
+	}
+}
+
+// NewEventRepositoryWithTx creates a new EventRepository with a transaction
+func NewEventRepositoryWithTx(tx *sql.Tx) *EventRepository {
+	return &EventRepository{
+		queries: sqlc.New(timed(tx)),
+	}
+}
+
+// Create appends an event to the annotation history
+func (r *EventRepository) Create(ctx context.Context, event *domain.AnnotationEvent) (*domain.AnnotationEvent, error) {
+	ctx, span := tracing.Start(ctx, "EventRepository.Create")
+	defer span.End()
+
+	row, err := r.queries.CreateAnnotationEvent(ctx, sqlc.CreateAnnotationEventParams{
+		Kind:         event.Kind,
+		Source:       event.Source,
+		Actor:        event.Actor,
+		ImageSha256:  event.ImageSHA256,
+		StageIndex:   int64(event.StageIndex),
+		Username:     event.Username,
+		AnnotationID: nullInt64(event.AnnotationID),
+		OldValue:     nullString(event.OldValue),
+		NewValue:     nullString(event.NewValue),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toDomainEvent(row), nil
+}
+
+// List returns at most limit events matching filter, newest first
+func (r *EventRepository) List(ctx context.Context, filter domain.EventFilter, limit int) ([]*domain.AnnotationEvent, error) {
+	ctx, span := tracing.Start(ctx, "EventRepository.List")
+	defer span.End()
+
+	rows, err := r.queries.ListAnnotationEvents(ctx, sqlc.ListAnnotationEventsParams{
+		ImageSha256: nullString(filter.ImageSHA256),
+		StageIndex:  stageIndexFilter(filter.StageIndex),
+		Username:    nullString(filter.Username),
+		Since:       nullTime(filter.Since),
+		Limit:       int64(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*domain.AnnotationEvent, len(rows))
+	for i, row := range rows {
+		result[i] = toDomainEvent(row)
+	}
+
+	return result, nil
+}
+
+// toDomainEvent converts a sqlc.AnnotationEvent to domain.AnnotationEvent
+func toDomainEvent(event sqlc.AnnotationEvent) *domain.AnnotationEvent {
+	d := &domain.AnnotationEvent{
+		ID:          event.ID,
+		Kind:        event.Kind,
+		Source:      event.Source,
+		Actor:       event.Actor,
+		ImageSHA256: event.ImageSha256,
+		StageIndex:  int(event.StageIndex),
+		Username:    event.Username,
+		CreatedAt:   event.CreatedAt,
+	}
+	if event.AnnotationID != nil {
+		d.AnnotationID = *event.AnnotationID
+	}
+	if event.OldValue != nil {
+		d.OldValue = *event.OldValue
+	}
+	if event.NewValue != nil {
+		d.NewValue = *event.NewValue
+	}
+	return d
+}
+
+// Verify that EventRepository implements domain.EventRepository
+var _ domain.EventRepository = (*EventRepository)(nil)
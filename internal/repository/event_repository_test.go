@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lewtec/rotulador/internal/domain"
+)
+
+func TestEventRepository(t *testing.T) {
+	db := SetupTestDB(t)
+	t.Cleanup(func() { CleanupTestDB(t, db) })
+	repo := NewEventRepository(db)
+	ctx := t.Context()
+
+	for _, event := range []*domain.AnnotationEvent{
+		{Kind: domain.EventCreate, Source: domain.EventSourceWeb, Actor: "alice", ImageSHA256: "a", Username: "alice", AnnotationID: 1, NewValue: "good"},
+		{Kind: domain.EventOverwrite, Source: domain.EventSourceWeb, Actor: "alice", ImageSHA256: "a", Username: "alice", AnnotationID: 1, OldValue: "good", NewValue: "bad"},
+		{Kind: domain.EventReview, Source: domain.EventSourceWeb, Actor: "carol", ImageSHA256: "a", Username: "alice", AnnotationID: 1, OldValue: "bad", NewValue: "good"},
+		{Kind: domain.EventCreate, Source: domain.EventSourceAPI, Actor: "bob", ImageSHA256: "b", StageIndex: 1, Username: "bob", AnnotationID: 2, NewValue: "yes"},
+	} {
+		if _, err := repo.Create(ctx, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := repo.Create(ctx, &domain.AnnotationEvent{Kind: "rename", Source: domain.EventSourceWeb, Actor: "alice", ImageSHA256: "a", Username: "alice"}); err == nil {
+		t.Error("Create() with an unknown kind succeeded")
+	}
+
+	events, err := repo.List(ctx, domain.EventFilter{ImageSHA256: "a"}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 3 || events[0].Kind != domain.EventReview || events[2].Kind != domain.EventCreate {
+		t.Fatalf("List(image a) = %+v, want its 3 events, newest first", events)
+	}
+	if events[1].OldValue != "good" || events[1].NewValue != "bad" || events[2].OldValue != "" || events[0].CreatedAt.IsZero() {
+		t.Errorf("overwrite = %+v, create = %+v", events[1], events[2])
+	}
+
+	// The reviewer's events match their name too
+	if events, err := repo.List(ctx, domain.EventFilter{Username: "carol"}, 10); err != nil || len(events) != 1 {
+		t.Errorf("List(carol) = %d events, %v; want the review", len(events), err)
+	}
+	stage := 0
+	if events, err := repo.List(ctx, domain.EventFilter{StageIndex: &stage}, 10); err != nil || len(events) != 3 {
+		t.Errorf("List(stage 0) = %d events, %v; want 3", len(events), err)
+	}
+	if events, err := repo.List(ctx, domain.EventFilter{}, 2); err != nil || len(events) != 2 || events[0].ImageSHA256 != "b" {
+		t.Errorf("List(limit 2) = %+v, %v; want the 2 newest", events, err)
+	}
+	if events, err := repo.List(ctx, domain.EventFilter{Since: time.Now().Add(-time.Hour)}, 10); err != nil || len(events) != 4 {
+		t.Errorf("List(since an hour ago) = %d events, %v; want all 4", len(events), err)
+	}
+	if events, err := repo.List(ctx, domain.EventFilter{Since: time.Now().Add(time.Hour)}, 10); err != nil || len(events) != 0 {
+		t.Errorf("List(since an hour from now) = %d events, %v; want none", len(events), err)
+	}
+
+	// The history is append-only
+	if _, err := db.ExecContext(ctx, "UPDATE annotation_events SET new_value = 'bad'"); err == nil {
+		t.Error("updating an event succeeded")
+	}
+	if _, err := db.ExecContext(ctx, "DELETE FROM annotation_events"); err == nil {
+		t.Error("deleting an event succeeded")
+	}
+}
@@ -4,9 +4,12 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"strings"
+	"time"
 
 	"github.com/lewtec/rotulador/internal/domain"
 	"github.com/lewtec/rotulador/internal/db/sqlc"
+	"github.com/lewtec/rotulador/internal/tracing"
 )
 
 // ImageRepository implements domain.ImageRepository using SQLC
@@ -17,19 +20,22 @@ type ImageRepository struct {
 // NewImageRepository creates a new ImageRepository
 func NewImageRepository(db *sql.DB) *ImageRepository {
 	return &ImageRepository{
-		queries: sqlc.New(db),
+		queries: sqlc.New(timed(db)),
 	}
 }
 
 // NewImageRepositoryWithTx creates a new ImageRepository with a transaction
 func NewImageRepositoryWithTx(tx *sql.Tx) *ImageRepository {
 	return &ImageRepository{
-		queries: sqlc.New(tx),
+		queries: sqlc.New(timed(tx)),
 	}
 }
 
 // Create creates a new image record
 func (r *ImageRepository) Create(ctx context.Context, sha256, filename string) (*domain.Image, error) {
+	ctx, span := tracing.Start(ctx, "ImageRepository.Create")
+	defer span.End()
+
 	params := sqlc.CreateImageParams{
 		Sha256:   sha256,
 		Filename: filename,
@@ -43,8 +49,38 @@ func (r *ImageRepository) Create(ctx context.Context, sha256, filename string) (
 	return toDomainImage(img), nil
 }
 
+// CreateWithMetadata creates or updates an image record including its metadata
+func (r *ImageRepository) CreateWithMetadata(ctx context.Context, sha256, filename string, meta domain.ImageMetadata) (*domain.Image, error) {
+	ctx, span := tracing.Start(ctx, "ImageRepository.CreateWithMetadata")
+	defer span.End()
+
+	params := sqlc.CreateImageWithMetadataParams{
+		Sha256:        sha256,
+		Filename:      filename,
+		SourcePath:    nullString(meta.SourcePath),
+		SourceArchive: nullString(meta.SourceArchive),
+		FrameOffsetMs: frameOffsetMs(meta.FrameOffset),
+		Format:        nullString(meta.Format),
+		Width:         nullInt64(int64(meta.Width)),
+		Height:        nullInt64(int64(meta.Height)),
+		SizeBytes:     nullInt64(meta.SizeBytes),
+		CapturedAt:    nullTime(meta.CapturedAt),
+		Camera:        nullString(meta.Camera),
+	}
+
+	img, err := r.queries.CreateImageWithMetadata(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	return toDomainImage(img), nil
+}
+
 // GetBySHA256 retrieves an image by its SHA256 hash
 func (r *ImageRepository) GetBySHA256(ctx context.Context, sha256 string) (*domain.Image, error) {
+	ctx, span := tracing.Start(ctx, "ImageRepository.GetBySHA256")
+	defer span.End()
+
 	img, err := r.queries.GetImage(ctx, sha256)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -58,6 +94,9 @@ func (r *ImageRepository) GetBySHA256(ctx context.Context, sha256 string) (*doma
 
 // GetByFilename retrieves an image by its filename
 func (r *ImageRepository) GetByFilename(ctx context.Context, filename string) (*domain.Image, error) {
+	ctx, span := tracing.Start(ctx, "ImageRepository.GetByFilename")
+	defer span.End()
+
 	img, err := r.queries.GetImageByFilename(ctx, filename)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -71,6 +110,9 @@ func (r *ImageRepository) GetByFilename(ctx context.Context, filename string) (*
 
 // List retrieves all images
 func (r *ImageRepository) List(ctx context.Context) ([]*domain.Image, error) {
+	ctx, span := tracing.Start(ctx, "ImageRepository.List")
+	defer span.End()
+
 	images, err := r.queries.ListImages(ctx)
 	if err != nil {
 		return nil, err
@@ -86,25 +128,271 @@ func (r *ImageRepository) List(ctx context.Context) ([]*domain.Image, error) {
 
 // Count returns the total number of images
 func (r *ImageRepository) Count(ctx context.Context) (int64, error) {
+	ctx, span := tracing.Start(ctx, "ImageRepository.Count")
+	defer span.End()
+
 	return r.queries.CountImages(ctx)
 }
 
 // Delete removes an image by SHA256 hash
 func (r *ImageRepository) Delete(ctx context.Context, sha256 string) error {
+	ctx, span := tracing.Start(ctx, "ImageRepository.Delete")
+	defer span.End()
+
 	return r.queries.DeleteImage(ctx, sha256)
 }
 
+// MarkMissing flags an image whose files were removed from the images directory
+func (r *ImageRepository) MarkMissing(ctx context.Context, sha256 string) error {
+	ctx, span := tracing.Start(ctx, "ImageRepository.MarkMissing")
+	defer span.End()
+
+	return r.queries.MarkImageMissing(ctx, sha256)
+}
+
+// SetPHash stores the perceptual hash of an image
+func (r *ImageRepository) SetPHash(ctx context.Context, sha256 string, phash uint64) error {
+	ctx, span := tracing.Start(ctx, "ImageRepository.SetPHash")
+	defer span.End()
+
+	value := int64(phash)
+	return r.queries.UpdateImagePHash(ctx, sqlc.UpdateImagePHashParams{
+		Phash:  &value,
+		Sha256: sha256,
+	})
+}
+
+// ListWithPHash retrieves all images that have a perceptual hash
+func (r *ImageRepository) ListWithPHash(ctx context.Context) ([]*domain.Image, error) {
+	ctx, span := tracing.Start(ctx, "ImageRepository.ListWithPHash")
+	defer span.End()
+
+	rows, err := r.queries.ListImagePHashes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*domain.Image, len(rows))
+	for i, row := range rows {
+		result[i] = &domain.Image{
+			SHA256:   row.Sha256,
+			Filename: row.Filename,
+			PHash:    toPHash(row.Phash),
+		}
+	}
+
+	return result, nil
+}
+
+// CountWithoutPHash returns how many present images still lack a perceptual
+// hash; missing images can't be hashed anymore
+func (r *ImageRepository) CountWithoutPHash(ctx context.Context) (int64, error) {
+	ctx, span := tracing.Start(ctx, "ImageRepository.CountWithoutPHash")
+	defer span.End()
+
+	return r.queries.CountImagesWithoutPHash(ctx)
+}
+
+// ListFiltered retrieves the images matching filter
+func (r *ImageRepository) ListFiltered(ctx context.Context, filter domain.ImageFilter) ([]*domain.Image, error) {
+	return r.ListPage(ctx, filter, nil, 0)
+}
+
+// ListPage retrieves up to limit images matching filter that sort after the
+// image after, in filename order
+func (r *ImageRepository) ListPage(ctx context.Context, filter domain.ImageFilter, after *domain.Image, limit int) ([]*domain.Image, error) {
+	ctx, span := tracing.Start(ctx, "ImageRepository.ListPage")
+	defer span.End()
+
+	params := sqlc.ListImagesFilteredParams{
+		IncludeMissing: filter.IncludeMissing,
+		PageLimit:      -1,
+		Format:         nullString(filter.Format),
+		Camera:         nullString(filter.Camera),
+		MinWidth:       nullInt64(int64(filter.MinWidth)),
+		MaxWidth:       nullInt64(int64(filter.MaxWidth)),
+		MinHeight:      nullInt64(int64(filter.MinHeight)),
+		MaxHeight:      nullInt64(int64(filter.MaxHeight)),
+		MinSize:        nullInt64(filter.MinSize),
+		MaxSize:        nullInt64(filter.MaxSize),
+		CapturedAfter:  nullTime(filter.CapturedAfter),
+		CapturedBefore: nullTime(filter.CapturedBefore),
+		Tag:            nullString(filter.Tag),
+	}
+	params.SourceGlob, params.DirGlob = sourceGlobs(filter)
+	if after != nil {
+		params.AfterFilename, params.AfterSha256 = after.Filename, after.SHA256
+	}
+	if limit > 0 {
+		params.PageLimit = int64(limit)
+	}
+
+	images, err := r.queries.ListImagesFiltered(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*domain.Image, len(images))
+	for i, img := range images {
+		result[i] = toDomainImage(img)
+	}
+
+	return result, nil
+}
+
+// globEscaper turns GLOB wildcards into single-character classes
+var globEscaper = strings.NewReplacer(`*`, `[*]`, `?`, `[?]`, `[`, `[[]`)
+
+// sourceGlobs turns the source prefix and directory of a filter into GLOB
+// patterns, nil when unset
+func sourceGlobs(filter domain.ImageFilter) (source, dir *string) {
+	if filter.SourcePrefix != "" {
+		glob := globEscaper.Replace(filter.SourcePrefix) + "*"
+		source = &glob
+	}
+	if filter.SourceDir != "" {
+		glob := globEscaper.Replace(filter.SourceDir) + "/*"
+		dir = &glob
+	}
+	return source, dir
+}
+
+// AddTag attaches a user tag to an image
+func (r *ImageRepository) AddTag(ctx context.Context, sha256, tag string) error {
+	ctx, span := tracing.Start(ctx, "ImageRepository.AddTag")
+	defer span.End()
+
+	return r.queries.AddImageTag(ctx, sqlc.AddImageTagParams{
+		ImageSha256: sha256,
+		Tag:         tag,
+	})
+}
+
+// RemoveTag detaches a user tag from an image
+func (r *ImageRepository) RemoveTag(ctx context.Context, sha256, tag string) error {
+	ctx, span := tracing.Start(ctx, "ImageRepository.RemoveTag")
+	defer span.End()
+
+	return r.queries.RemoveImageTag(ctx, sqlc.RemoveImageTagParams{
+		ImageSha256: sha256,
+		Tag:         tag,
+	})
+}
+
+// ListTags retrieves the tags of an image
+func (r *ImageRepository) ListTags(ctx context.Context, sha256 string) ([]string, error) {
+	ctx, span := tracing.Start(ctx, "ImageRepository.ListTags")
+	defer span.End()
+
+	return r.queries.ListImageTags(ctx, sha256)
+}
+
+// ListTagsOf retrieves the tags of several images, keyed by hash
+func (r *ImageRepository) ListTagsOf(ctx context.Context, sha256s []string) (map[string][]string, error) {
+	ctx, span := tracing.Start(ctx, "ImageRepository.ListTagsOf")
+	defer span.End()
+
+	tags := make(map[string][]string, len(sha256s))
+	if len(sha256s) == 0 {
+		return tags, nil
+	}
+	rows, err := r.queries.ListTagsOfImages(ctx, sha256s)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		tags[row.ImageSha256] = append(tags[row.ImageSha256], row.Tag)
+	}
+	return tags, nil
+}
+
 // toDomainImage converts a sqlc.Image to domain.Image
 func toDomainImage(img sqlc.Image) *domain.Image {
 	d := &domain.Image{
 		SHA256:   img.Sha256,
 		Filename: img.Filename,
+		PHash:    toPHash(img.Phash),
 	}
 	if img.IngestedAt != nil {
 		d.IngestedAt = *img.IngestedAt
 	}
+	if img.SourcePath != nil {
+		d.SourcePath = *img.SourcePath
+	}
+	if img.SourceArchive != nil {
+		d.SourceArchive = *img.SourceArchive
+	}
+	if img.FrameOffsetMs != nil {
+		offset := time.Duration(*img.FrameOffsetMs) * time.Millisecond
+		d.FrameOffset = &offset
+	}
+	if img.Format != nil {
+		d.Format = *img.Format
+	}
+	if img.Width != nil {
+		d.Width = int(*img.Width)
+	}
+	if img.Height != nil {
+		d.Height = int(*img.Height)
+	}
+	if img.SizeBytes != nil {
+		d.SizeBytes = *img.SizeBytes
+	}
+	if img.CapturedAt != nil {
+		d.CapturedAt = *img.CapturedAt
+	}
+	if img.MissingAt != nil {
+		d.MissingAt = *img.MissingAt
+	}
+	if img.Camera != nil {
+		d.Camera = *img.Camera
+	}
 	return d
 }
 
+// frameOffsetMs stores a frame offset in milliseconds, nil for still images
+func frameOffsetMs(offset *time.Duration) *int64 {
+	if offset == nil {
+		return nil
+	}
+	ms := offset.Milliseconds()
+	return &ms
+}
+
+// nullString maps the empty string to NULL
+func nullString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// nullTime maps the zero time to NULL. Times are stored in UTC so they
+// compare correctly as text.
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	t = t.UTC()
+	return &t
+}
+
+// nullInt64 maps zero to NULL
+func nullInt64(v int64) *int64 {
+	if v == 0 {
+		return nil
+	}
+	return &v
+}
+
+// toPHash reinterprets the signed SQLite integer as the unsigned hash
+func toPHash(v *int64) *uint64 {
+	if v == nil {
+		return nil
+	}
+	h := uint64(*v)
+	return &h
+}
+
 // Verify that ImageRepository implements domain.ImageRepository
 var _ domain.ImageRepository = (*ImageRepository)(nil)
@@ -2,7 +2,12 @@ package repository
 
 import (
 	"context"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/lewtec/rotulador/internal/domain"
 )
 
 func TestImageRepository_Create(t *testing.T) {
@@ -240,3 +245,346 @@ func BenchmarkImageRepository_GetBySHA256(b *testing.B) {
 		}
 	}
 }
+
+func TestImageRepository_PHash(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	repo := NewImageRepository(db)
+	ctx := t.Context()
+
+	if _, err := repo.Create(ctx, "hash1", "image1.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Create(ctx, "hash2", "image2.jpg"); err != nil {
+		t.Fatal(err)
+	}
+
+	missing, err := repo.CountWithoutPHash(ctx)
+	if err != nil {
+		t.Fatalf("CountWithoutPHash() error = %v", err)
+	}
+	if missing != 2 {
+		t.Errorf("CountWithoutPHash() = %d, want 2", missing)
+	}
+
+	// Missing images can't be hashed, so they aren't pending either
+	if _, err := repo.Create(ctx, "hash3", "image3.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.MarkMissing(ctx, "hash3"); err != nil {
+		t.Fatal(err)
+	}
+	if missing, err := repo.CountWithoutPHash(ctx); err != nil || missing != 2 {
+		t.Errorf("CountWithoutPHash() with a missing image = %d, %v; want 2", missing, err)
+	}
+
+	// High bit set must survive the round trip through a signed column
+	const phash = uint64(0xF0F0F0F0F0F0F0F1)
+	if err := repo.SetPHash(ctx, "hash1", phash); err != nil {
+		t.Fatalf("SetPHash() error = %v", err)
+	}
+
+	images, err := repo.ListWithPHash(ctx)
+	if err != nil {
+		t.Fatalf("ListWithPHash() error = %v", err)
+	}
+	if len(images) != 1 {
+		t.Fatalf("Got %d images, want 1", len(images))
+	}
+	if images[0].PHash == nil || *images[0].PHash != phash {
+		t.Errorf("PHash = %v, want %x", images[0].PHash, phash)
+	}
+
+	img, err := repo.GetBySHA256(ctx, "hash2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.PHash != nil {
+		t.Errorf("PHash = %x, want nil", *img.PHash)
+	}
+}
+
+func TestImageRepository_CreateWithMetadata(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	repo := NewImageRepository(db)
+	ctx := t.Context()
+
+	meta := domain.ImageMetadata{
+		SourcePath: "/data/raw/car.jpeg",
+		Format:     "jpeg",
+		Width:      640,
+		Height:     480,
+		SizeBytes:  12345,
+	}
+	img, err := repo.CreateWithMetadata(ctx, "hash1", "hash1.jpg", meta)
+	if err != nil {
+		t.Fatalf("CreateWithMetadata() error = %v", err)
+	}
+	if img.ImageMetadata != meta {
+		t.Errorf("ImageMetadata = %+v, want %+v", img.ImageMetadata, meta)
+	}
+
+	t.Run("re-ingest without source path keeps it", func(t *testing.T) {
+		again, err := repo.CreateWithMetadata(ctx, "hash1", "hash1.jpg", domain.ImageMetadata{
+			Format: "jpeg", Width: 640, Height: 480, SizeBytes: 12345,
+		})
+		if err != nil {
+			t.Fatalf("CreateWithMetadata() error = %v", err)
+		}
+		if again.SourcePath != meta.SourcePath {
+			t.Errorf("SourcePath = %q, want %q", again.SourcePath, meta.SourcePath)
+		}
+	})
+
+	t.Run("plain create leaves metadata empty", func(t *testing.T) {
+		plain, err := repo.Create(ctx, "hash2", "hash2.png")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if plain.ImageMetadata != (domain.ImageMetadata{}) {
+			t.Errorf("ImageMetadata = %+v, want zero", plain.ImageMetadata)
+		}
+	})
+}
+
+func TestImageRepository_ListFiltered(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	repo := NewImageRepository(db)
+	ctx := t.Context()
+
+	shot := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	fixtures := []struct {
+		sha  string
+		meta domain.ImageMetadata
+	}{
+		{"big", domain.ImageMetadata{SourcePath: "/data/cam_a/1.jpg", Format: "jpeg", Width: 1024, Height: 768, SizeBytes: 500, CapturedAt: shot, Camera: "Canon EOS 5D"}},
+		{"small", domain.ImageMetadata{SourcePath: "/data/cam_b/2.png", Format: "png", Width: 256, Height: 256, SizeBytes: 100}},
+		{"bare", domain.ImageMetadata{}},
+	}
+	for _, f := range fixtures {
+		if _, err := repo.CreateWithMetadata(ctx, f.sha, f.sha+".img", f.meta); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.AddTag(ctx, "small", "night"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.AddTag(ctx, "small", "night"); err != nil {
+		t.Fatalf("AddTag twice: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		filter domain.ImageFilter
+		want   []string
+	}{
+		{"no filter", domain.ImageFilter{}, []string{"bare", "big", "small"}},
+		{"min width", domain.ImageFilter{MinWidth: 513}, []string{"big"}},
+		{"max width", domain.ImageFilter{MaxWidth: 512}, []string{"small"}},
+		{"format", domain.ImageFilter{Format: "png"}, []string{"small"}},
+		{"camera", domain.ImageFilter{Camera: "Canon EOS 5D"}, []string{"big"}},
+		{"tag", domain.ImageFilter{Tag: "night"}, []string{"small"}},
+		{"source prefix", domain.ImageFilter{SourcePrefix: "/data/cam_a/"}, []string{"big"}},
+		{"source prefix wildcard is literal", domain.ImageFilter{SourcePrefix: "/data/cam__/"}, nil},
+		{"captured range", domain.ImageFilter{CapturedAfter: shot, CapturedBefore: shot.Add(time.Hour)}, []string{"big"}},
+		{"captured before", domain.ImageFilter{CapturedBefore: shot}, nil},
+		{"size", domain.ImageFilter{MinSize: 200, MaxSize: 1000}, []string{"big"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			images, err := repo.ListFiltered(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("ListFiltered() error = %v", err)
+			}
+			var got []string
+			for _, img := range images {
+				got = append(got, img.SHA256)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("ListFiltered() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	big, err := repo.GetBySHA256(ctx, "big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !big.CapturedAt.Equal(shot) || big.Camera != "Canon EOS 5D" {
+		t.Errorf("EXIF fields = %v %q, want %v %q", big.CapturedAt, big.Camera, shot, "Canon EOS 5D")
+	}
+
+	if err := repo.RemoveTag(ctx, "small", "night"); err != nil {
+		t.Fatal(err)
+	}
+	tags, err := repo.ListTags(ctx, "small")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 0 {
+		t.Errorf("ListTags() after RemoveTag = %v, want none", tags)
+	}
+}
+
+func TestImageRepository_ListPage(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	repo := NewImageRepository(db)
+	ctx := t.Context()
+
+	// Hashes break ties between images sharing a filename
+	for _, img := range [][2]string{{"c", "a.png"}, {"a", "a.png"}, {"b", "b.png"}, {"d", "c.png"}, {"e", "d.png"}} {
+		if _, err := repo.Create(ctx, img[0], img[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.MarkMissing(ctx, "d"); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	var after *domain.Image
+	for {
+		page, err := repo.ListPage(ctx, domain.ImageFilter{}, after, 2)
+		if err != nil {
+			t.Fatalf("ListPage() error = %v", err)
+		}
+		if len(page) > 2 {
+			t.Fatalf("ListPage() returned %d images, want at most 2", len(page))
+		}
+		if len(page) == 0 {
+			break
+		}
+		for _, img := range page {
+			got = append(got, img.SHA256)
+		}
+		after = page[len(page)-1]
+	}
+	if want := "a,c,b,e"; strings.Join(got, ",") != want {
+		t.Errorf("pages = %v, want %s", got, want)
+	}
+}
+
+func TestImageRepository_ListTagsOf(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	repo := NewImageRepository(db)
+	ctx := t.Context()
+
+	for _, hash := range []string{"hash1", "hash2", "hash3"} {
+		if _, err := repo.Create(ctx, hash, hash+".png"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tag := range [][2]string{{"hash1", "night"}, {"hash1", "day"}, {"hash2", "rain"}, {"hash3", "snow"}} {
+		if err := repo.AddTag(ctx, tag[0], tag[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tags, err := repo.ListTagsOf(ctx, []string{"hash1", "hash2", "unknown"})
+	if err != nil {
+		t.Fatalf("ListTagsOf() error = %v", err)
+	}
+	want := map[string][]string{"hash1": {"day", "night"}, "hash2": {"rain"}}
+	if !reflect.DeepEqual(tags, want) {
+		t.Errorf("ListTagsOf() = %v, want %v", tags, want)
+	}
+	if tags, err := repo.ListTagsOf(ctx, nil); err != nil || len(tags) != 0 {
+		t.Errorf("ListTagsOf(nil) = %v, %v", tags, err)
+	}
+}
+
+func TestImageRepository_MarkMissing(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	repo := NewImageRepository(db)
+	ctx := t.Context()
+
+	for _, hash := range []string{"hash1", "hash2"} {
+		if _, err := repo.Create(ctx, hash, hash+".png"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.MarkMissing(ctx, "hash1"); err != nil {
+		t.Fatalf("MarkMissing() error = %v", err)
+	}
+
+	images, err := repo.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(images) != 1 || images[0].SHA256 != "hash2" {
+		t.Errorf("List() = %v, want only hash2", images)
+	}
+	if count, err := repo.Count(ctx); err != nil || count != 1 {
+		t.Errorf("Count() = %d, %v, want 1", count, err)
+	}
+	if images, err := repo.ListFiltered(ctx, domain.ImageFilter{}); err != nil || len(images) != 1 {
+		t.Errorf("ListFiltered() = %v, %v, want only hash2", images, err)
+	}
+	if images, err := repo.ListFiltered(ctx, domain.ImageFilter{IncludeMissing: true}); err != nil || len(images) != 2 {
+		t.Errorf("ListFiltered(IncludeMissing) = %v, %v, want both images", images, err)
+	}
+	missing, err := repo.GetBySHA256(ctx, "hash1")
+	if err != nil || missing == nil || missing.MissingAt.IsZero() {
+		t.Fatalf("GetBySHA256() = %+v, %v, want MissingAt set", missing, err)
+	}
+
+	// Ingesting the image again brings it back
+	if _, err := repo.Create(ctx, "hash1", "renamed.png"); err != nil {
+		t.Fatal(err)
+	}
+	if count, err := repo.Count(ctx); err != nil || count != 2 {
+		t.Errorf("Count() after re-create = %d, %v, want 2", count, err)
+	}
+}
+
+func TestImageRepository_ListFilteredBySourceDir(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	repo := NewImageRepository(db)
+	ctx := t.Context()
+	for sha, filename := range map[string]string{
+		"root":  "root.png",
+		"a":     "cameraA/1.png",
+		"night": "cameraA/night/2.png",
+		"b":     "cameraAB/3.png",
+		"star":  "cam*/4.png",
+	} {
+		if _, err := repo.Create(ctx, sha, filename); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		dir  string
+		want []string
+	}{
+		{"cameraA", []string{"a", "night"}},
+		{"cameraA/night", []string{"night"}},
+		{"cam*", []string{"star"}},
+	}
+	for _, tt := range tests {
+		images, err := repo.ListFiltered(ctx, domain.ImageFilter{SourceDir: tt.dir})
+		if err != nil {
+			t.Fatalf("ListFiltered(%q) error = %v", tt.dir, err)
+		}
+		var got []string
+		for _, img := range images {
+			got = append(got, img.SHA256)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("ListFiltered(%q) = %v, want %v", tt.dir, got, tt.want)
+		}
+	}
+}
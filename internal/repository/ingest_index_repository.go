@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lewtec/rotulador/internal/db/sqlc"
+	"github.com/lewtec/rotulador/internal/domain"
+	"github.com/lewtec/rotulador/internal/tracing"
+)
+
+// IngestIndexRepository implements domain.IngestIndexRepository using SQLC
+type IngestIndexRepository struct {
+	queries *sqlc.Queries
+}
+
+// NewIngestIndexRepository creates a new IngestIndexRepository
+func NewIngestIndexRepository(db *sql.DB) *IngestIndexRepository {
+	return &IngestIndexRepository{
+		queries: sqlc.New(timed(db)),
+	}
+}
+
+// NewIngestIndexRepositoryWithTx creates a new IngestIndexRepository with a transaction
+func NewIngestIndexRepositoryWithTx(tx *sql.Tx) *IngestIndexRepository {
+	return &IngestIndexRepository{
+		queries: sqlc.New(timed(tx)),
+	}
+}
+
+// Put creates or replaces the entry for a path
+func (r *IngestIndexRepository) Put(ctx context.Context, entry domain.IngestIndexEntry) error {
+	ctx, span := tracing.Start(ctx, "IngestIndexRepository.Put")
+	defer span.End()
+
+	return r.queries.UpsertIngestIndexEntry(ctx, sqlc.UpsertIngestIndexEntryParams{
+		Path:        entry.Path,
+		SizeBytes:   entry.SizeBytes,
+		MtimeNs:     entry.ModTime.UnixNano(),
+		ImageSha256: entry.ImageSHA256,
+	})
+}
+
+// Get retrieves the entry for a path, nil when the path is not indexed
+func (r *IngestIndexRepository) Get(ctx context.Context, path string) (*domain.IngestIndexEntry, error) {
+	ctx, span := tracing.Start(ctx, "IngestIndexRepository.Get")
+	defer span.End()
+
+	row, err := r.queries.GetIngestIndexEntry(ctx, path)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return toDomainIngestIndexEntry(row), nil
+}
+
+// List retrieves all entries
+func (r *IngestIndexRepository) List(ctx context.Context) ([]*domain.IngestIndexEntry, error) {
+	ctx, span := tracing.Start(ctx, "IngestIndexRepository.List")
+	defer span.End()
+
+	rows, err := r.queries.ListIngestIndexEntries(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*domain.IngestIndexEntry, len(rows))
+	for i, row := range rows {
+		result[i] = toDomainIngestIndexEntry(row)
+	}
+
+	return result, nil
+}
+
+// Delete removes the entry for a path
+func (r *IngestIndexRepository) Delete(ctx context.Context, path string) error {
+	ctx, span := tracing.Start(ctx, "IngestIndexRepository.Delete")
+	defer span.End()
+
+	return r.queries.DeleteIngestIndexEntry(ctx, path)
+}
+
+// CountForImage returns how many paths hold an image
+func (r *IngestIndexRepository) CountForImage(ctx context.Context, imageSHA256 string) (int64, error) {
+	ctx, span := tracing.Start(ctx, "IngestIndexRepository.CountForImage")
+	defer span.End()
+
+	return r.queries.CountIngestIndexEntriesForImage(ctx, imageSHA256)
+}
+
+// toDomainIngestIndexEntry converts a sqlc.IngestIndex to domain.IngestIndexEntry
+func toDomainIngestIndexEntry(row sqlc.IngestIndex) *domain.IngestIndexEntry {
+	return &domain.IngestIndexEntry{
+		Path:        row.Path,
+		SizeBytes:   row.SizeBytes,
+		ModTime:     time.Unix(0, row.MtimeNs),
+		ImageSHA256: row.ImageSha256,
+	}
+}
+
+// Ensure IngestIndexRepository implements domain.IngestIndexRepository
+var _ domain.IngestIndexRepository = (*IngestIndexRepository)(nil)
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lewtec/rotulador/internal/domain"
+)
+
+func TestIngestIndexRepository(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	imgRepo := NewImageRepository(db)
+	repo := NewIngestIndexRepository(db)
+	ctx := t.Context()
+
+	for _, hash := range []string{"hash1", "hash2"} {
+		if _, err := imgRepo.Create(ctx, hash, hash+".png"); err != nil {
+			t.Fatalf("Failed to create test image: %v", err)
+		}
+	}
+
+	modTime := time.Unix(1700000000, 123456789)
+	entry := domain.IngestIndexEntry{Path: "a.png", SizeBytes: 10, ModTime: modTime, ImageSHA256: "hash1"}
+	if err := repo.Put(ctx, entry); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	t.Run("round trips with nanosecond mtime", func(t *testing.T) {
+		entries, err := repo.List(ctx)
+		if err != nil {
+			t.Fatalf("List() error = %v", err)
+		}
+		if len(entries) != 1 || !entries[0].ModTime.Equal(modTime) || entries[0].ImageSHA256 != "hash1" {
+			t.Errorf("List() = %+v, want %+v", entries, entry)
+		}
+	})
+
+	t.Run("put replaces the entry", func(t *testing.T) {
+		entry.ImageSHA256 = "hash2"
+		entry.SizeBytes = 20
+		if err := repo.Put(ctx, entry); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+		entries, err := repo.List(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 || entries[0].ImageSHA256 != "hash2" || entries[0].SizeBytes != 20 {
+			t.Errorf("List() = %+v, want hash2 with size 20", entries)
+		}
+	})
+
+	t.Run("deleting the image drops the entry", func(t *testing.T) {
+		if err := imgRepo.Delete(ctx, "hash2"); err != nil {
+			t.Fatal(err)
+		}
+		entries, err := repo.List(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 0 {
+			t.Errorf("List() = %+v, want none", entries)
+		}
+	})
+}
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lewtec/rotulador/internal/db/sqlc"
+	"github.com/lewtec/rotulador/internal/domain"
+	"github.com/lewtec/rotulador/internal/tracing"
+)
+
+// LeaseRepository implements domain.LeaseRepository using SQLC
+type LeaseRepository struct {
+	queries *sqlc.Queries
+}
+
+// NewLeaseRepository creates a new LeaseRepository
+func NewLeaseRepository(db *sql.DB) *LeaseRepository {
+	return &LeaseRepository{
+		queries: sqlc.New(timed(db)),
+	}
+}
+
+// NewLeaseRepositoryWithTx creates a new LeaseRepository with a transaction
+func NewLeaseRepositoryWithTx(tx *sql.Tx) *LeaseRepository {
+	return &LeaseRepository{
+		queries: sqlc.New(timed(tx)),
+	}
+}
+
+// Acquire reserves an image for a user, returning nil when another user holds an active lease
+func (r *LeaseRepository) Acquire(ctx context.Context, imageSHA256 string, stageIndex int, username string, duration time.Duration) (*domain.Lease, error) {
+	ctx, span := tracing.Start(ctx, "LeaseRepository.Acquire")
+	defer span.End()
+
+	params := sqlc.AcquireLeaseParams{
+		ImageSha256: imageSHA256,
+		StageIndex:  int64(stageIndex),
+		Username:    username,
+		// SQLite datetime() modifier, e.g. "+300 seconds"
+		Duration: fmt.Sprintf("+%d seconds", int64(duration/time.Second)),
+	}
+
+	lease, err := r.queries.AcquireLease(ctx, params)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return toDomainLease(lease), nil
+}
+
+// Release drops the lease held by a user on an image
+func (r *LeaseRepository) Release(ctx context.Context, imageSHA256 string, stageIndex int, username string) error {
+	ctx, span := tracing.Start(ctx, "LeaseRepository.Release")
+	defer span.End()
+
+	return r.queries.ReleaseLease(ctx, sqlc.ReleaseLeaseParams{
+		ImageSha256: imageSHA256,
+		StageIndex:  int64(stageIndex),
+		Username:    username,
+	})
+}
+
+// ReleaseAny drops the lease on an image regardless of who holds it
+func (r *LeaseRepository) ReleaseAny(ctx context.Context, imageSHA256 string, stageIndex int) error {
+	ctx, span := tracing.Start(ctx, "LeaseRepository.ReleaseAny")
+	defer span.End()
+
+	return r.queries.ReleaseLeaseForImageStage(ctx, sqlc.ReleaseLeaseForImageStageParams{
+		ImageSha256: imageSHA256,
+		StageIndex:  int64(stageIndex),
+	})
+}
+
+// GetActiveForUser returns the most recent active lease of a user on a stage
+func (r *LeaseRepository) GetActiveForUser(ctx context.Context, username string, stageIndex int) (*domain.Lease, error) {
+	ctx, span := tracing.Start(ctx, "LeaseRepository.GetActiveForUser")
+	defer span.End()
+
+	lease, err := r.queries.GetActiveLeaseForUser(ctx, sqlc.GetActiveLeaseForUserParams{
+		Username:   username,
+		StageIndex: int64(stageIndex),
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return toDomainLease(lease), nil
+}
+
+// GetLeasedByOthers returns image hashes with an active lease on a stage held by someone other than username
+func (r *LeaseRepository) GetLeasedByOthers(ctx context.Context, stageIndex int, username string) ([]string, error) {
+	ctx, span := tracing.Start(ctx, "LeaseRepository.GetLeasedByOthers")
+	defer span.End()
+
+	return r.queries.GetLeasedImageHashes(ctx, sqlc.GetLeasedImageHashesParams{
+		StageIndex: int64(stageIndex),
+		Username:   username,
+	})
+}
+
+// ListActive returns every active lease
+func (r *LeaseRepository) ListActive(ctx context.Context) ([]*domain.LeaseWithImage, error) {
+	ctx, span := tracing.Start(ctx, "LeaseRepository.ListActive")
+	defer span.End()
+
+	rows, err := r.queries.ListActiveLeases(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*domain.LeaseWithImage, len(rows))
+	for i, row := range rows {
+		result[i] = &domain.LeaseWithImage{
+			Lease: domain.Lease{
+				ImageSHA256: row.ImageSha256,
+				StageIndex:  int(row.StageIndex),
+				Username:    row.Username,
+				LeasedAt:    row.LeasedAt,
+				ExpiresAt:   row.ExpiresAt,
+			},
+			ImageFilename: row.Filename,
+		}
+	}
+
+	return result, nil
+}
+
+// DeleteExpired removes expired leases and returns how many were removed
+func (r *LeaseRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	ctx, span := tracing.Start(ctx, "LeaseRepository.DeleteExpired")
+	defer span.End()
+
+	return r.queries.DeleteExpiredLeases(ctx)
+}
+
+// toDomainLease converts a sqlc.ImageLease to domain.Lease
+func toDomainLease(lease sqlc.ImageLease) *domain.Lease {
+	return &domain.Lease{
+		ImageSHA256: lease.ImageSha256,
+		StageIndex:  int(lease.StageIndex),
+		Username:    lease.Username,
+		LeasedAt:    lease.LeasedAt,
+		ExpiresAt:   lease.ExpiresAt,
+	}
+}
+
+// Verify that LeaseRepository implements domain.LeaseRepository
+var _ domain.LeaseRepository = (*LeaseRepository)(nil)
@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLeaseRepository_Acquire(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	imgRepo := NewImageRepository(db)
+	repo := NewLeaseRepository(db)
+	ctx := t.Context()
+
+	img, err := imgRepo.Create(ctx, "abcdef1234567890", "image.jpg")
+	if err != nil {
+		t.Fatalf("Failed to create test image: %v", err)
+	}
+
+	t.Run("acquires a free image", func(t *testing.T) {
+		lease, err := repo.Acquire(ctx, img.SHA256, 0, "alice", 5*time.Minute)
+		if err != nil {
+			t.Fatalf("Acquire() error = %v", err)
+		}
+		if lease == nil {
+			t.Fatal("Expected lease, got nil")
+		}
+		if lease.Username != "alice" {
+			t.Errorf("Username = %v, want alice", lease.Username)
+		}
+		if !lease.ExpiresAt.After(lease.LeasedAt) {
+			t.Errorf("ExpiresAt %v should be after LeasedAt %v", lease.ExpiresAt, lease.LeasedAt)
+		}
+	})
+
+	t.Run("same user can renew", func(t *testing.T) {
+		lease, err := repo.Acquire(ctx, img.SHA256, 0, "alice", 5*time.Minute)
+		if err != nil {
+			t.Fatalf("Acquire() error = %v", err)
+		}
+		if lease == nil {
+			t.Fatal("Expected renewed lease, got nil")
+		}
+	})
+
+	t.Run("other user is refused", func(t *testing.T) {
+		lease, err := repo.Acquire(ctx, img.SHA256, 0, "bob", 5*time.Minute)
+		if err != nil {
+			t.Fatalf("Acquire() error = %v", err)
+		}
+		if lease != nil {
+			t.Fatalf("Expected nil lease for bob, got %+v", lease)
+		}
+	})
+
+	t.Run("other stage is independent", func(t *testing.T) {
+		lease, err := repo.Acquire(ctx, img.SHA256, 1, "bob", 5*time.Minute)
+		if err != nil {
+			t.Fatalf("Acquire() error = %v", err)
+		}
+		if lease == nil {
+			t.Fatal("Expected lease on stage 1, got nil")
+		}
+	})
+}
+
+func TestLeaseRepository_Expiry(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	imgRepo := NewImageRepository(db)
+	repo := NewLeaseRepository(db)
+	ctx := t.Context()
+
+	img, err := imgRepo.Create(ctx, "abcdef1234567890", "image.jpg")
+	if err != nil {
+		t.Fatalf("Failed to create test image: %v", err)
+	}
+	if _, err := repo.Acquire(ctx, img.SHA256, 0, "alice", 5*time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	MustExec(t, db, "UPDATE image_leases SET expires_at = datetime('now', '-1 seconds')")
+
+	leased, err := repo.GetLeasedByOthers(ctx, 0, "bob")
+	if err != nil {
+		t.Fatalf("GetLeasedByOthers() error = %v", err)
+	}
+	if len(leased) != 0 {
+		t.Errorf("Expired lease should not be reported, got %v", leased)
+	}
+
+	lease, err := repo.Acquire(ctx, img.SHA256, 0, "bob", 5*time.Minute)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	if lease == nil || lease.Username != "bob" {
+		t.Fatalf("Expected bob to take over expired lease, got %+v", lease)
+	}
+}
+
+func TestLeaseRepository_ReleaseAndList(t *testing.T) {
+	db := SetupTestDB(t)
+	defer CleanupTestDB(t, db)
+
+	imgRepo := NewImageRepository(db)
+	repo := NewLeaseRepository(db)
+	ctx := t.Context()
+
+	img1, _ := imgRepo.Create(ctx, "hash1", "image1.jpg")
+	img2, _ := imgRepo.Create(ctx, "hash2", "image2.jpg")
+	if _, err := repo.Acquire(ctx, img1.SHA256, 0, "alice", 5*time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Acquire(ctx, img2.SHA256, 0, "bob", 5*time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	leases, err := repo.ListActive(ctx)
+	if err != nil {
+		t.Fatalf("ListActive() error = %v", err)
+	}
+	if len(leases) != 2 {
+		t.Fatalf("Got %d leases, want 2", len(leases))
+	}
+	if leases[0].ImageFilename == "" {
+		t.Error("ImageFilename should not be empty")
+	}
+
+	leased, err := repo.GetLeasedByOthers(ctx, 0, "alice")
+	if err != nil {
+		t.Fatalf("GetLeasedByOthers() error = %v", err)
+	}
+	if len(leased) != 1 || leased[0] != img2.SHA256 {
+		t.Errorf("GetLeasedByOthers() = %v, want [%s]", leased, img2.SHA256)
+	}
+
+	// Releasing someone else's lease is a no-op
+	if err := repo.Release(ctx, img2.SHA256, 0, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if own, _ := repo.GetActiveForUser(ctx, "bob", 0); own == nil {
+		t.Fatal("bob's lease should survive a release by alice")
+	}
+
+	if err := repo.Release(ctx, img2.SHA256, 0, "bob"); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if own, _ := repo.GetActiveForUser(ctx, "bob", 0); own != nil {
+		t.Errorf("Expected no active lease for bob, got %+v", own)
+	}
+}
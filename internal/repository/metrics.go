@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/lewtec/rotulador/internal/db/sqlc"
+	"github.com/lewtec/rotulador/internal/metrics"
+	"github.com/lewtec/rotulador/internal/tracing"
+	"github.com/prometheus/client_golang/prometheus"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+)
+
+// queryDuration is the latency of the sqlc queries, labeled with their name.
+// Queries returning rows are timed until the first row is ready, not until
+// the caller is done reading them.
+var queryDuration = metrics.Register(prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "rotulador_db_query_duration_seconds",
+	Help:    "Latency of database queries by sqlc query name.",
+	Buckets: prometheus.DefBuckets,
+}, []string{"query"}))
+
+// timedDB records the latency of every query run through it, in
+// queryDuration and as a span.
+type timedDB struct {
+	db sqlc.DBTX
+}
+
+// timed wraps db so the queries of a repository show up in queryDuration
+// and in traces.
+func timed(db sqlc.DBTX) sqlc.DBTX {
+	return timedDB{db: db}
+}
+
+func (t timedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, done := observeQuery(ctx, query)
+	result, err := t.db.ExecContext(ctx, query, args...)
+	done(err)
+	return result, err
+}
+
+func (t timedDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return t.db.PrepareContext(ctx, query)
+}
+
+func (t timedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, done := observeQuery(ctx, query)
+	rows, err := t.db.QueryContext(ctx, query, args...)
+	done(err)
+	return rows, err
+}
+
+func (t timedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, done := observeQuery(ctx, query)
+	row := t.db.QueryRowContext(ctx, query, args...)
+	done(row.Err())
+	return row
+}
+
+// observeQuery starts the span of query. The returned function ends it and
+// records the latency.
+func observeQuery(ctx context.Context, query string) (context.Context, func(error)) {
+	start := time.Now()
+	name := queryName(query)
+	ctx, span := tracing.Start(ctx, name,
+		semconv.DBSystemNameSQLite,
+		semconv.DBQuerySummary(name),
+		semconv.DBQueryText(query),
+	)
+	return ctx, func(err error) {
+		queryDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+		tracing.Fail(span, err)
+		span.End()
+	}
+}
+
+// queryName is the name sqlc puts in the first line of every query, like
+// "CreateAnnotation" in "-- name: CreateAnnotation :one".
+func queryName(query string) string {
+	rest, ok := strings.CutPrefix(query, "-- name: ")
+	if !ok {
+		return "unknown"
+	}
+	name, _, _ := strings.Cut(rest, " ")
+	return name
+}
@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/lewtec/rotulador/internal/metrics"
+)
+
+func TestQueryDurationMetric(t *testing.T) {
+	imgRepo, _, ctx := setupTestRepositories(t)
+	if _, err := imgRepo.Create(ctx, "a", "a.jpg"); err != nil {
+		t.Fatal(err)
+	}
+
+	families, err := metrics.Registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count uint64
+	for _, family := range families {
+		if family.GetName() != "rotulador_db_query_duration_seconds" {
+			continue
+		}
+		for _, m := range family.Metric {
+			if m.Label[0].GetValue() == "CreateImage" {
+				count = m.GetHistogram().GetSampleCount()
+			}
+		}
+	}
+	if count == 0 {
+		t.Error("rotulador_db_query_duration_seconds has no CreateImage observation")
+	}
+	if got := queryName("SELECT 1"); got != "unknown" {
+		t.Errorf("queryName() of a query not generated by sqlc = %q, want unknown", got)
+	}
+}
@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lewtec/rotulador/internal/db/sqlc"
+	"github.com/lewtec/rotulador/internal/domain"
+	"github.com/lewtec/rotulador/internal/tracing"
+)
+
+// ReviewRepository implements domain.ReviewRepository using SQLC
+type ReviewRepository struct {
+	queries *sqlc.Queries
+}
+
+// NewReviewRepository creates a new ReviewRepository
+func NewReviewRepository(db *sql.DB) *ReviewRepository {
+	return &ReviewRepository{
+		queries: sqlc.New(timed(db)),
+	}
+}
+
+// NewReviewRepositoryWithTx creates a new ReviewRepository with a transaction
+func NewReviewRepositoryWithTx(tx *sql.Tx) *ReviewRepository {
+	return &ReviewRepository{
+		queries: sqlc.New(timed(tx)),
+	}
+}
+
+// Create records the verdict of a reviewer on an annotation, replacing their previous one
+func (r *ReviewRepository) Create(ctx context.Context, annotationID int64, reviewer, outcome, optionValue string) (*domain.Review, error) {
+	ctx, span := tracing.Start(ctx, "ReviewRepository.Create")
+	defer span.End()
+
+	review, err := r.queries.CreateReview(ctx, sqlc.CreateReviewParams{
+		AnnotationID: annotationID,
+		Reviewer:     reviewer,
+		Outcome:      outcome,
+		OptionValue:  optionValue,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toDomainReview(review), nil
+}
+
+// DeleteForAnnotation removes the reviews of an annotation and returns how
+// many there were
+func (r *ReviewRepository) DeleteForAnnotation(ctx context.Context, annotationID int64) (int64, error) {
+	ctx, span := tracing.Start(ctx, "ReviewRepository.DeleteForAnnotation")
+	defer span.End()
+
+	return r.queries.DeleteReviewsForAnnotation(ctx, annotationID)
+}
+
+// NextToReview returns the oldest unreviewed annotation of a stage by someone other than reviewer
+func (r *ReviewRepository) NextToReview(ctx context.Context, stageIndex int, reviewer string) (*domain.AnnotationWithImage, error) {
+	ctx, span := tracing.Start(ctx, "ReviewRepository.NextToReview")
+	defer span.End()
+
+	row, err := r.queries.NextAnnotationToReview(ctx, sqlc.NextAnnotationToReviewParams{
+		StageIndex: int64(stageIndex),
+		Reviewer:   reviewer,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	ann := &domain.AnnotationWithImage{
+		Annotation: domain.Annotation{
+			ID:          row.ID,
+			ImageSHA256: row.ImageSha256,
+			Username:    row.Username,
+			StageIndex:  int(row.StageIndex),
+			OptionValue: row.OptionValue,
+			Sure:        row.Sure,
+		},
+		ImageFilename: row.Filename,
+	}
+	if row.AnnotatedAt != nil {
+		ann.AnnotatedAt = *row.AnnotatedAt
+	}
+	return ann, nil
+}
+
+// CountToReview returns how many annotations NextToReview still has to go through
+func (r *ReviewRepository) CountToReview(ctx context.Context, stageIndex int, reviewer string) (int64, error) {
+	ctx, span := tracing.Start(ctx, "ReviewRepository.CountToReview")
+	defer span.End()
+
+	return r.queries.CountAnnotationsToReview(ctx, sqlc.CountAnnotationsToReviewParams{
+		StageIndex: int64(stageIndex),
+		Reviewer:   reviewer,
+	})
+}
+
+// GetForImage retrieves the reviews of all annotations of an image, oldest first
+func (r *ReviewRepository) GetForImage(ctx context.Context, imageSHA256 string) ([]*domain.ReviewWithAnnotation, error) {
+	ctx, span := tracing.Start(ctx, "ReviewRepository.GetForImage")
+	defer span.End()
+
+	rows, err := r.queries.GetReviewsForImage(ctx, imageSHA256)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*domain.ReviewWithAnnotation, len(rows))
+	for i, row := range rows {
+		result[i] = &domain.ReviewWithAnnotation{
+			Review: *toDomainReview(sqlc.Review{
+				ID:           row.ID,
+				AnnotationID: row.AnnotationID,
+				Reviewer:     row.Reviewer,
+				Outcome:      row.Outcome,
+				OptionValue:  row.OptionValue,
+				ReviewedAt:   row.ReviewedAt,
+			}),
+			StageIndex: int(row.StageIndex),
+			Annotator:  row.Username,
+		}
+	}
+
+	return result, nil
+}
+
+// AcceptanceRates returns the acceptance rate of each annotator of a stage
+func (r *ReviewRepository) AcceptanceRates(ctx context.Context, stageIndex int) ([]*domain.AcceptanceRate, error) {
+	ctx, span := tracing.Start(ctx, "ReviewRepository.AcceptanceRates")
+	defer span.End()
+
+	rows, err := r.queries.GetAcceptanceRates(ctx, int64(stageIndex))
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*domain.AcceptanceRate, len(rows))
+	for i, row := range rows {
+		result[i] = &domain.AcceptanceRate{
+			Username: row.Username,
+			Reviewed: row.Reviewed,
+			Accepted: row.Accepted,
+		}
+	}
+
+	return result, nil
+}
+
+// toDomainReview converts a sqlc.Review to domain.Review
+func toDomainReview(review sqlc.Review) *domain.Review {
+	d := &domain.Review{
+		ID:           review.ID,
+		AnnotationID: review.AnnotationID,
+		Reviewer:     review.Reviewer,
+		Outcome:      review.Outcome,
+		OptionValue:  review.OptionValue,
+	}
+	if review.ReviewedAt != nil {
+		d.ReviewedAt = *review.ReviewedAt
+	}
+	return d
+}
+
+// Verify that ReviewRepository implements domain.ReviewRepository
+var _ domain.ReviewRepository = (*ReviewRepository)(nil)
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/lewtec/rotulador/internal/domain"
+)
+
+func TestReviewRepository(t *testing.T) {
+	db := SetupTestDB(t)
+	t.Cleanup(func() { CleanupTestDB(t, db) })
+	imgRepo, annRepo, reviewRepo := NewImageRepository(db), NewAnnotationRepository(db), NewReviewRepository(db)
+	ctx := t.Context()
+
+	for _, hash := range []string{"a", "b"} {
+		if _, err := imgRepo.Create(ctx, hash, hash+".jpg"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	alice, err := annRepo.Create(ctx, "a", "alice", 0, "good", true, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := annRepo.Create(ctx, "b", "bob", 0, "good", true, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := annRepo.Create(ctx, "a", "bob", 1, "yes", true, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// The reviewer's own annotations are left out
+	next, err := reviewRepo.NextToReview(ctx, 0, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next == nil || next.ID != bob.ID || next.ImageFilename != "b.jpg" {
+		t.Fatalf("NextToReview(alice) = %+v, want bob's annotation", next)
+	}
+	if n, err := reviewRepo.CountToReview(ctx, 0, "carol"); err != nil || n != 2 {
+		t.Errorf("CountToReview(carol) = %d, %v; want 2", n, err)
+	}
+
+	if _, err := reviewRepo.Create(ctx, alice.ID, "carol", domain.ReviewAccepted, "good"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reviewRepo.Create(ctx, bob.ID, "carol", domain.ReviewAccepted, "good"); err != nil {
+		t.Fatal(err)
+	}
+	// Reviewing again replaces the verdict
+	if _, err := reviewRepo.Create(ctx, bob.ID, "carol", domain.ReviewCorrected, "bad"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reviewRepo.Create(ctx, bob.ID, "carol", "maybe", "bad"); err == nil {
+		t.Error("Create() with an unknown outcome succeeded")
+	}
+
+	if next, err := reviewRepo.NextToReview(ctx, 0, "carol"); err != nil || next != nil {
+		t.Errorf("NextToReview after reviewing everything = %+v, %v; want nil", next, err)
+	}
+
+	rates, err := reviewRepo.AcceptanceRates(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []domain.AcceptanceRate{{Username: "alice", Reviewed: 1, Accepted: 1}, {Username: "bob", Reviewed: 1, Accepted: 0}}
+	if len(rates) != len(want) || *rates[0] != want[0] || *rates[1] != want[1] {
+		t.Errorf("AcceptanceRates() = %+v, want %+v", rates, want)
+	}
+
+	reviews, err := reviewRepo.GetForImage(ctx, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reviews) != 1 || reviews[0].Annotator != "bob" || reviews[0].Outcome != domain.ReviewCorrected || reviews[0].StageIndex != 0 {
+		t.Errorf("GetForImage(b) = %+v, want bob's corrected review", reviews)
+	}
+
+	// The reviewed label takes precedence over the annotation
+	for value, want := range map[string][]string{"good": {"a"}, "bad": {"b"}} {
+		hashes, err := annRepo.GetImageHashesWithAnnotation(ctx, 0, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		slices.Sort(hashes)
+		if !slices.Equal(hashes, want) {
+			t.Errorf("GetImageHashesWithAnnotation(%s) = %v, want %v", value, hashes, want)
+		}
+	}
+}
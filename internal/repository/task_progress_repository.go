@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/lewtec/rotulador/internal/db/sqlc"
+	"github.com/lewtec/rotulador/internal/domain"
+	"github.com/lewtec/rotulador/internal/tracing"
+)
+
+// TaskProgressRepository implements domain.TaskProgressRepository using SQLC.
+// The status of the images is kept up to date by triggers, see the
+// task_progress migration.
+type TaskProgressRepository struct {
+	queries *sqlc.Queries
+}
+
+// NewTaskProgressRepository creates a new TaskProgressRepository
+func NewTaskProgressRepository(db *sql.DB) *TaskProgressRepository {
+	return &TaskProgressRepository{
+		queries: sqlc.New(timed(db)),
+	}
+}
+
+// NewTaskProgressRepositoryWithTx creates a new TaskProgressRepository with a
+// transaction. SyncRules should run in one so readers never see the tables
+// half rebuilt.
+func NewTaskProgressRepositoryWithTx(tx *sql.Tx) *TaskProgressRepository {
+	return &TaskProgressRepository{
+		queries: sqlc.New(timed(tx)),
+	}
+}
+
+// SyncRules stores the rules and rebuilds the status of every image, unless
+// the rules are the same as the ones stored last time
+func (r *TaskProgressRepository) SyncRules(ctx context.Context, rules []domain.TaskRule) (bool, error) {
+	ctx, span := tracing.Start(ctx, "TaskProgressRepository.SyncRules")
+	defer span.End()
+
+	var params []sqlc.CreateTaskRuleParams
+	var deps []sqlc.CreateTaskDependencyParams
+	for _, rule := range rules {
+		param := sqlc.CreateTaskRuleParams{
+			StageIndex:     int64(rule.StageIndex),
+			Format:         nullString(rule.Filter.Format),
+			Camera:         nullString(rule.Filter.Camera),
+			Tag:            nullString(rule.Filter.Tag),
+			MinWidth:       nullInt64(int64(rule.Filter.MinWidth)),
+			MaxWidth:       nullInt64(int64(rule.Filter.MaxWidth)),
+			MinHeight:      nullInt64(int64(rule.Filter.MinHeight)),
+			MaxHeight:      nullInt64(int64(rule.Filter.MaxHeight)),
+			MinSize:        nullInt64(rule.Filter.MinSize),
+			MaxSize:        nullInt64(rule.Filter.MaxSize),
+			CapturedAfter:  nullTime(rule.Filter.CapturedAfter),
+			CapturedBefore: nullTime(rule.Filter.CapturedBefore),
+		}
+		param.SourceGlob, param.DirGlob = sourceGlobs(rule.Filter)
+		params = append(params, param)
+		for _, dep := range rule.Dependencies {
+			deps = append(deps, sqlc.CreateTaskDependencyParams{
+				StageIndex:    int64(rule.StageIndex),
+				DepStageIndex: int64(dep.StageIndex),
+				OptionValue:   dep.Value,
+			})
+		}
+	}
+
+	encoded, err := json.Marshal(struct {
+		Rules        []sqlc.CreateTaskRuleParams
+		Dependencies []sqlc.CreateTaskDependencyParams
+	}{params, deps})
+	if err != nil {
+		return false, fmt.Errorf("while encoding task rules: %w", err)
+	}
+	sum := sha256.Sum256(encoded)
+	fingerprint := hex.EncodeToString(sum[:])
+
+	stored, err := r.queries.GetTaskRulesFingerprint(ctx)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, fmt.Errorf("while reading task rules fingerprint: %w", err)
+	}
+	if stored == fingerprint {
+		return false, nil
+	}
+
+	if err := r.queries.DeleteTaskDependencies(ctx); err != nil {
+		return false, fmt.Errorf("while deleting task dependencies: %w", err)
+	}
+	if err := r.queries.DeleteTaskRules(ctx); err != nil {
+		return false, fmt.Errorf("while deleting task rules: %w", err)
+	}
+	for _, param := range params {
+		if err := r.queries.CreateTaskRule(ctx, param); err != nil {
+			return false, fmt.Errorf("while storing rule of task %d: %w", param.StageIndex, err)
+		}
+	}
+	for _, dep := range deps {
+		if err := r.queries.CreateTaskDependency(ctx, dep); err != nil {
+			return false, fmt.Errorf("while storing dependency of task %d: %w", dep.StageIndex, err)
+		}
+	}
+
+	if err := r.queries.DeleteTaskImages(ctx); err != nil {
+		return false, fmt.Errorf("while clearing task images: %w", err)
+	}
+	if err := r.queries.DeleteTaskCounts(ctx); err != nil {
+		return false, fmt.Errorf("while clearing task counts: %w", err)
+	}
+	if err := r.queries.RebuildTaskImages(ctx); err != nil {
+		return false, fmt.Errorf("while rebuilding task images: %w", err)
+	}
+	if err := r.queries.SetTaskRulesFingerprint(ctx, fingerprint); err != nil {
+		return false, fmt.Errorf("while storing task rules fingerprint: %w", err)
+	}
+	return true, nil
+}
+
+// Counts returns the totals of a task, all zero when it has no images
+func (r *TaskProgressRepository) Counts(ctx context.Context, stageIndex int) (*domain.TaskCounts, error) {
+	ctx, span := tracing.Start(ctx, "TaskProgressRepository.Counts")
+	defer span.End()
+
+	counts, err := r.queries.GetTaskCounts(ctx, int64(stageIndex))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &domain.TaskCounts{}, nil
+		}
+		return nil, err
+	}
+
+	return &domain.TaskCounts{
+		Total:     int(counts.Total),
+		Eligible:  int(counts.Eligible),
+		Available: int(counts.Available),
+		Filtered:  int(counts.Filtered),
+	}, nil
+}
+
+// ListAvailable returns up to limit images still to annotate in a task, in
+// hash order
+func (r *TaskProgressRepository) ListAvailable(ctx context.Context, stageIndex int, limit int) ([]*domain.Image, error) {
+	ctx, span := tracing.Start(ctx, "TaskProgressRepository.ListAvailable")
+	defer span.End()
+
+	images, err := r.queries.ListAvailableTaskImages(ctx, sqlc.ListAvailableTaskImagesParams{
+		StageIndex: int64(stageIndex),
+		Limit:      int64(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*domain.Image, len(images))
+	for i, img := range images {
+		result[i] = toDomainImage(img)
+	}
+	return result, nil
+}
+
+// Verify that TaskProgressRepository implements domain.TaskProgressRepository
+var _ domain.TaskProgressRepository = (*TaskProgressRepository)(nil)
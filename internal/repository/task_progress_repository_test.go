@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/lewtec/rotulador/internal/domain"
+)
+
+func TestTaskProgressRepository(t *testing.T) {
+	db := SetupTestDB(t)
+	t.Cleanup(func() { CleanupTestDB(t, db) })
+	imgRepo, annRepo, reviewRepo := NewImageRepository(db), NewAnnotationRepository(db), NewReviewRepository(db)
+	repo := NewTaskProgressRepository(db)
+	ctx := t.Context()
+
+	// Images present before the rules are stored are picked up by the rebuild
+	for _, hash := range []string{"a", "b"} {
+		if _, err := imgRepo.Create(ctx, hash, hash+".jpg"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wide := domain.ImageMetadata{Format: "png", Width: 2000, Height: 100}
+	if _, err := imgRepo.CreateWithMetadata(ctx, "c", "c.png", wide); err != nil {
+		t.Fatal(err)
+	}
+
+	// 0: quality, 1: detail if quality is good, 2: wide pngs tagged night
+	rules := []domain.TaskRule{
+		{StageIndex: 0},
+		{StageIndex: 1, Dependencies: []domain.TaskDependency{{StageIndex: 0, Value: "good"}}},
+		{StageIndex: 2, Filter: domain.ImageFilter{Format: "png", MinWidth: 1000, Tag: "night"}},
+	}
+	changed, err := repo.SyncRules(ctx, rules)
+	if err != nil || !changed {
+		t.Fatalf("first SyncRules() = %v, %v; want changed", changed, err)
+	}
+	if changed, err := repo.SyncRules(ctx, rules); err != nil || changed {
+		t.Fatalf("SyncRules() with the same rules = %v, %v; want unchanged", changed, err)
+	}
+
+	expect := func(stageIndex int, want domain.TaskCounts) {
+		t.Helper()
+		got, err := repo.Counts(ctx, stageIndex)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *got != want {
+			t.Errorf("Counts(%d) = %+v, want %+v", stageIndex, *got, want)
+		}
+	}
+	expect(0, domain.TaskCounts{Total: 3, Eligible: 3, Available: 3})
+	expect(1, domain.TaskCounts{Total: 3})
+	expect(2, domain.TaskCounts{Total: 3})
+
+	good, err := annRepo.Create(ctx, "a", "alice", 0, "good", true, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := annRepo.Create(ctx, "b", "alice", 0, "bad", true, 0); err != nil {
+		t.Fatal(err)
+	}
+	expect(0, domain.TaskCounts{Total: 3, Eligible: 3, Available: 1})
+	expect(1, domain.TaskCounts{Total: 3, Eligible: 1, Available: 1, Filtered: 1})
+
+	available, err := repo.ListAvailable(ctx, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(available) != 1 || available[0].SHA256 != "a" || available[0].Filename != "a.jpg" {
+		t.Errorf("ListAvailable(1) = %v, want image a", available)
+	}
+
+	// A review correcting the label takes precedence over the annotation
+	if _, err := reviewRepo.Create(ctx, good.ID, "carol", domain.ReviewCorrected, "bad"); err != nil {
+		t.Fatal(err)
+	}
+	expect(1, domain.TaskCounts{Total: 3, Filtered: 2})
+
+	if err := imgRepo.AddTag(ctx, "c", "night"); err != nil {
+		t.Fatal(err)
+	}
+	expect(2, domain.TaskCounts{Total: 3, Eligible: 1, Available: 1})
+	if err := imgRepo.RemoveTag(ctx, "c", "night"); err != nil {
+		t.Fatal(err)
+	}
+	expect(2, domain.TaskCounts{Total: 3})
+
+	// Missing and deleted images leave every task
+	if err := imgRepo.MarkMissing(ctx, "c"); err != nil {
+		t.Fatal(err)
+	}
+	expect(0, domain.TaskCounts{Total: 2, Eligible: 2})
+	if err := imgRepo.Delete(ctx, "b"); err != nil {
+		t.Fatal(err)
+	}
+	expect(1, domain.TaskCounts{Total: 1, Filtered: 1})
+
+	// New rules are applied to the images already there
+	rules[1].Dependencies[0].Value = "bad"
+	if changed, err := repo.SyncRules(ctx, rules); err != nil || !changed {
+		t.Fatalf("SyncRules() with new rules = %v, %v; want changed", changed, err)
+	}
+	expect(1, domain.TaskCounts{Total: 1, Eligible: 1, Available: 1})
+}
+
+func TestTaskProgressRepository_UnknownDependency(t *testing.T) {
+	db := SetupTestDB(t)
+	t.Cleanup(func() { CleanupTestDB(t, db) })
+	repo := NewTaskProgressRepository(db)
+	ctx := t.Context()
+
+	if _, err := NewImageRepository(db).Create(ctx, "a", "a.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	rules := []domain.TaskRule{{StageIndex: 0, Dependencies: []domain.TaskDependency{{StageIndex: domain.UnknownDependency, Value: "x"}}}}
+	if _, err := repo.SyncRules(ctx, rules); err != nil {
+		t.Fatal(err)
+	}
+	counts, err := repo.Counts(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts.Total != 1 || counts.Eligible != 0 {
+		t.Errorf("Counts(0) = %+v, want no eligible image", *counts)
+	}
+
+	// Tasks without images have all counters at zero
+	if counts, err := repo.Counts(ctx, 5); err != nil || *counts != (domain.TaskCounts{}) {
+		t.Errorf("Counts(5) = %+v, %v; want zeros", counts, err)
+	}
+}
@@ -20,6 +20,8 @@ func SetupTestDB(t *testing.T) *sql.DB {
 	if err != nil {
 		t.Fatalf("failed to open test database: %v", err)
 	}
+	// Every connection to :memory: is a separate, empty database
+	db.SetMaxOpenConns(1)
 
 	// Use golang-migrate to run migrations from embedded files
 	driver, err := sqlite.WithInstance(db, &sqlite.Config{})
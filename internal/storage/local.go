@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Local stores files in a directory of the local filesystem.
+type Local struct {
+	dir string
+}
+
+// NewLocal returns the store rooted at dir.
+func NewLocal(dir string) *Local {
+	return &Local{dir: filepath.Clean(dir)}
+}
+
+// Dir returns the directory the store is rooted at.
+func (l *Local) Dir() string {
+	return l.dir
+}
+
+// Path returns the file of the local filesystem holding name.
+func (l *Local) Path(name string) (string, error) {
+	if err := checkName("open", name); err != nil {
+		return "", err
+	}
+	return filepath.Join(l.dir, filepath.FromSlash(name)), nil
+}
+
+// localFile is an *os.File with the Info it was opened with.
+type localFile struct {
+	*os.File
+	info Info
+}
+
+func (f *localFile) Info() Info { return f.info }
+
+// Open opens a file for reading
+func (l *Local) Open(ctx context.Context, name string) (File, error) {
+	path, err := l.Path(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, errors.Join(err, f.Close())
+	}
+	if !stat.Mode().IsRegular() {
+		return nil, errors.Join(&fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}, f.Close())
+	}
+	return &localFile{File: f, info: Info{Name: name, Size: stat.Size(), ModTime: stat.ModTime()}}, nil
+}
+
+// Stat describes a file without opening it
+func (l *Local) Stat(ctx context.Context, name string) (Info, error) {
+	path, err := l.Path(name)
+	if err != nil {
+		return Info{}, err
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return Info{}, err
+	}
+	if !stat.Mode().IsRegular() {
+		return Info{}, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return Info{Name: name, Size: stat.Size(), ModTime: stat.ModTime()}, nil
+}
+
+// Put writes the file through a temporary file in the same directory, so
+// readers never see it partially written
+func (l *Local) Put(ctx context.Context, name string, r io.Reader, size int64) error {
+	path, err := l.Path(name)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tempFile := filepath.Join(dir, fmt.Sprintf(".%s.tmp", uuid.New()))
+	f, err := os.Create(tempFile)
+	if err != nil {
+		return err
+	}
+	written, err := io.Copy(f, r)
+	if err == nil && written != size {
+		err = fmt.Errorf("wrote %d bytes of %s, expected %d", written, name, size)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tempFile, path)
+	}
+	if err != nil {
+		return errors.Join(err, os.Remove(tempFile))
+	}
+	return nil
+}
+
+// isTempFile reports whether base is a file Put has not renamed yet.
+func isTempFile(base string) bool {
+	return strings.HasPrefix(base, ".") && strings.HasSuffix(base, ".tmp")
+}
+
+// Delete removes a file. Deleting a missing file is not an error.
+func (l *Local) Delete(ctx context.Context, name string) error {
+	path, err := l.Path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Walk calls fn for every regular file below the directory, subdirectories
+// included. Symbolic links are not followed.
+func (l *Local) Walk(ctx context.Context, fn func(Info) error) error {
+	return filepath.WalkDir(l.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if entry.IsDir() || !entry.Type().IsRegular() || isTempFile(entry.Name()) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return fmt.Errorf("while reading file info of '%s': %w", path, err)
+		}
+		rel, err := filepath.Rel(l.dir, path)
+		if err != nil {
+			return err
+		}
+		return fn(Info{Name: filepath.ToSlash(rel), Size: info.Size(), ModTime: info.ModTime()})
+	})
+}
+
+// Verify that Local implements Store
+var _ Store = (*Local)(nil)
@@ -0,0 +1,25 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocal(t *testing.T) {
+	dir := t.TempDir()
+	testStore(t, NewLocal(dir))
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if isTempFile(entry.Name()) {
+			t.Errorf("temporary file %s left behind", entry.Name())
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "sub dir", "b+c.jpg")); err != nil {
+		t.Errorf("file not stored under its name: %v", err)
+	}
+}
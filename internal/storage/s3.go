@@ -0,0 +1,283 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// MaxPresignExpiry is the longest a presigned URL can be valid
+const MaxPresignExpiry = 7 * 24 * time.Hour
+
+// S3Options configures an S3-compatible store.
+type S3Options struct {
+	// Endpoint is the URL of the service, like https://s3.eu-west-1.amazonaws.com
+	// or http://localhost:9000 for MinIO. It cannot have a path.
+	Endpoint string
+	Region   string
+	Bucket   string
+	// Prefix is prepended to the names of the files, like "images/"
+	Prefix       string
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	// PathStyle addresses the bucket as endpoint/bucket rather than
+	// bucket.endpoint, as most S3-compatible services expect
+	PathStyle bool
+	// Transport defaults to the one of minio-go; requests are bounded by
+	// their context
+	Transport http.RoundTripper
+	// MaxRetries bounds the attempts of a request failing transiently,
+	// 10 when zero
+	MaxRetries int
+}
+
+// S3 stores files as the objects of a bucket, through minio-go.
+type S3 struct {
+	opts   S3Options
+	client *minio.Client
+}
+
+// NewS3 checks opts and returns the store. No request is made.
+func NewS3(opts S3Options) (*S3, error) {
+	if opts.Bucket == "" {
+		return nil, errors.New("S3 storage needs a bucket")
+	}
+	endpoint, err := url.Parse(opts.Endpoint)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, fmt.Errorf("S3 endpoint must be an http or https URL, got %q", opts.Endpoint)
+	}
+	if strings.Trim(endpoint.Path, "/") != "" || endpoint.RawQuery != "" {
+		return nil, fmt.Errorf("S3 endpoint cannot have a path or query, got %q", opts.Endpoint)
+	}
+	if opts.Region == "" {
+		opts.Region = "us-east-1"
+	}
+	if opts.Prefix != "" && !strings.HasSuffix(opts.Prefix, "/") {
+		opts.Prefix += "/"
+	}
+	lookup := minio.BucketLookupDNS
+	if opts.PathStyle {
+		lookup = minio.BucketLookupPath
+	}
+	// With the region set, minio-go never asks the service for the bucket location
+	client, err := minio.New(endpoint.Host, &minio.Options{
+		Creds:        credentials.NewStaticV4(opts.AccessKey, opts.SecretKey, opts.SessionToken),
+		Secure:       endpoint.Scheme == "https",
+		Region:       opts.Region,
+		BucketLookup: lookup,
+		Transport:    opts.Transport,
+		MaxRetries:   opts.MaxRetries,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("while creating S3 client: %w", err)
+	}
+	// Requests go to the endpoint as given, not to its dualstack variant
+	client.SetS3EnableDualstack(false)
+	return &S3{opts: opts, client: client}, nil
+}
+
+// key returns the object key of a file name
+func (s *S3) key(op, name string) (string, error) {
+	if err := checkName(op, name); err != nil {
+		return "", err
+	}
+	return s.opts.Prefix + name, nil
+}
+
+// s3Error describes an error of minio-go about name. Missing objects match
+// fs.ErrNotExist; the S3 error code is kept in the message.
+func s3Error(op, name string, err error) error {
+	resp := minio.ToErrorResponse(err)
+	switch {
+	case resp.Code == "NoSuchKey":
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	case resp.Code != "":
+		return fmt.Errorf("S3 %s %s: %s: %w", op, name, resp.Code, err)
+	}
+	return fmt.Errorf("S3 %s %s: %w", op, name, err)
+}
+
+// Open opens an object for reading. The object is fetched on the first
+// read, from the current offset, so seeking costs no request.
+func (s *S3) Open(ctx context.Context, name string) (File, error) {
+	info, err := s.Stat(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return &s3File{ctx: ctx, store: s, info: info}, nil
+}
+
+// Stat describes an object without downloading it
+func (s *S3) Stat(ctx context.Context, name string) (Info, error) {
+	key, err := s.key("stat", name)
+	if err != nil {
+		return Info{}, err
+	}
+	object, err := s.client.StatObject(ctx, s.opts.Bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return Info{}, s3Error("stat", name, err)
+	}
+	return Info{Name: name, Size: object.Size, ModTime: object.LastModified}, nil
+}
+
+// Put uploads an object, typed after the extension of name. The body is
+// sent unsigned, so it streams even over plain HTTP.
+func (s *S3) Put(ctx context.Context, name string, r io.Reader, size int64) error {
+	key, err := s.key("put", name)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.PutObject(ctx, s.opts.Bucket, key, r, size, minio.PutObjectOptions{
+		ContentType:          mime.TypeByExtension(path.Ext(name)),
+		DisableContentSha256: true,
+	})
+	if err != nil {
+		return s3Error("put", name, err)
+	}
+	return nil
+}
+
+// Delete removes an object. Deleting a missing object is not an error.
+func (s *S3) Delete(ctx context.Context, name string) error {
+	key, err := s.key("delete", name)
+	if err != nil {
+		return err
+	}
+	if err := s.client.RemoveObject(ctx, s.opts.Bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		if err = s3Error("delete", name, err); !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Walk lists the objects under the prefix. Keys that are not valid names,
+// like the "dir/" markers some tools create, are skipped.
+func (s *S3) Walk(ctx context.Context, fn func(Info) error) error {
+	// Stops the listing when fn fails
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	objects := s.client.ListObjects(ctx, s.opts.Bucket, minio.ListObjectsOptions{
+		Prefix:    s.opts.Prefix,
+		Recursive: true,
+	})
+	for object := range objects {
+		if object.Err != nil {
+			return fmt.Errorf("while listing bucket %s: %w", s.opts.Bucket, object.Err)
+		}
+		name := strings.TrimPrefix(object.Key, s.opts.Prefix)
+		if checkName("walk", name) != nil {
+			continue
+		}
+		if err := fn(Info{Name: name, Size: object.Size, ModTime: object.LastModified}); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// PresignGet returns a URL to download name without credentials during
+// expires, at most MaxPresignExpiry.
+func (s *S3) PresignGet(name string, expires time.Duration) (string, error) {
+	key, err := s.key("presign", name)
+	if err != nil {
+		return "", err
+	}
+	if expires < time.Second || expires > MaxPresignExpiry {
+		return "", fmt.Errorf("presigned URL expiry must be between 1s and %s, got %s", MaxPresignExpiry, expires)
+	}
+	// Presigning is computed locally, the context is never used for a request
+	u, err := s.client.PresignedGetObject(context.Background(), s.opts.Bucket, key, expires, nil)
+	if err != nil {
+		return "", s3Error("presign", name, err)
+	}
+	return u.String(), nil
+}
+
+// s3File reads an object lazily: the body is requested from the current
+// offset on the first read after opening or seeking.
+type s3File struct {
+	ctx    context.Context
+	store  *S3
+	info   Info
+	offset int64
+	body   *minio.Object
+}
+
+func (f *s3File) Info() Info { return f.info }
+
+func (f *s3File) Read(p []byte) (int, error) {
+	if f.offset >= f.info.Size {
+		return 0, io.EOF
+	}
+	if f.body == nil {
+		var opts minio.GetObjectOptions
+		if f.offset > 0 {
+			if err := opts.SetRange(f.offset, 0); err != nil {
+				return 0, err
+			}
+		}
+		body, err := f.store.client.GetObject(f.ctx, f.store.opts.Bucket, f.store.opts.Prefix+f.info.Name, opts)
+		if err != nil {
+			return 0, s3Error("read", f.info.Name, err)
+		}
+		f.body = body
+	}
+	n, err := f.body.Read(p)
+	f.offset += int64(n)
+	switch {
+	case err == io.EOF && f.offset < f.info.Size:
+		err = io.ErrUnexpectedEOF
+	case err != nil && err != io.EOF:
+		err = s3Error("read", f.info.Name, err)
+	}
+	return n, err
+}
+
+func (f *s3File) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.info.Size
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.info.Name, Err: fs.ErrInvalid}
+	}
+	if offset != f.offset {
+		if err := f.Close(); err != nil {
+			return 0, err
+		}
+		f.offset = offset
+	}
+	return offset, nil
+}
+
+// Close drops the body being read, if any. The file can still be read
+// again after seeking.
+func (f *s3File) Close() error {
+	if f.body == nil {
+		return nil
+	}
+	err := f.body.Close()
+	f.body = nil
+	return err
+}
+
+// Verify that S3 implements Store and Presigner
+var (
+	_ Store     = (*S3)(nil)
+	_ Presigner = (*S3)(nil)
+)
@@ -0,0 +1,115 @@
+// Package storage keeps the image files, either in a local directory or in
+// an S3-compatible bucket.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Info describes a stored file.
+type Info struct {
+	// Name is slash-separated and relative to the root of the store
+	Name    string
+	Size    int64
+	ModTime time.Time
+}
+
+// File is a stored file open for reading.
+type File interface {
+	io.ReadSeekCloser
+	// Info describes the file as it was when opened
+	Info() Info
+}
+
+// Store holds files by name. Names are slash-separated paths relative to the
+// root of the store, valid according to fs.ValidPath. Missing files are
+// reported with errors matching fs.ErrNotExist, invalid names with errors
+// matching fs.ErrInvalid.
+type Store interface {
+	// Open opens a file for reading
+	Open(ctx context.Context, name string) (File, error)
+	// Stat describes a file without opening it
+	Stat(ctx context.Context, name string) (Info, error)
+	// Put stores size bytes read from r as name, replacing any previous
+	// file. Readers never see a partially written file.
+	Put(ctx context.Context, name string, r io.Reader, size int64) error
+	// Delete removes a file. Deleting a missing file is not an error.
+	Delete(ctx context.Context, name string) error
+	// Walk calls fn for every file in the store, in no particular order,
+	// stopping at the first error
+	Walk(ctx context.Context, fn func(Info) error) error
+}
+
+// Presigner is implemented by stores that can hand out temporary URLs to
+// their files, so clients download them without going through the server.
+type Presigner interface {
+	// PresignGet returns a URL to download name during expires
+	PresignGet(name string, expires time.Duration) (string, error)
+}
+
+// checkName rejects names that could escape the root of the store.
+func checkName(op, name string) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return nil
+}
+
+// Open returns the store at location: a local directory, or a bucket given
+// as s3://bucket/prefix. Buckets are reached with the standard AWS
+// environment variables: AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY,
+// AWS_SESSION_TOKEN, AWS_REGION and AWS_ENDPOINT_URL for S3-compatible
+// services such as MinIO, which are addressed path-style.
+func Open(location string) (Store, error) {
+	return open(location, os.Getenv)
+}
+
+func open(location string, getenv func(string) string) (Store, error) {
+	if !IsRemote(location) {
+		return NewLocal(location), nil
+	}
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("while parsing storage location: %w", err)
+	}
+	prefix := strings.Trim(u.Path, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	opts := S3Options{
+		Bucket:       u.Host,
+		Prefix:       prefix,
+		Region:       firstNonEmpty(getenv("AWS_REGION"), getenv("AWS_DEFAULT_REGION"), "us-east-1"),
+		AccessKey:    getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey:    getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken: getenv("AWS_SESSION_TOKEN"),
+		Endpoint:     firstNonEmpty(getenv("AWS_ENDPOINT_URL_S3"), getenv("AWS_ENDPOINT_URL")),
+	}
+	if opts.Endpoint != "" {
+		opts.PathStyle = true
+	} else {
+		opts.Endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", opts.Region)
+	}
+	return NewS3(opts)
+}
+
+// IsRemote reports whether location names a bucket rather than a directory.
+func IsRemote(location string) bool {
+	return strings.HasPrefix(location, "s3://")
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
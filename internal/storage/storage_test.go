@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testStore runs the behaviour every Store shares against an empty store.
+func testStore(t *testing.T, store Store) {
+	ctx := t.Context()
+	put := func(name, content string) {
+		t.Helper()
+		if err := store.Put(ctx, name, strings.NewReader(content), int64(len(content))); err != nil {
+			t.Fatalf("Put(%q) error = %v", name, err)
+		}
+	}
+	put("a.png", "first")
+	put("a.png", "0123456789")
+	put("sub dir/b+c.jpg", "b")
+	put("empty.gif", "")
+
+	f, err := store.Open(ctx, "a.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info := f.Info(); info.Name != "a.png" || info.Size != 10 || info.ModTime.IsZero() {
+		t.Errorf("Info() = %+v", info)
+	}
+	if _, err := f.Seek(4, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	head := make([]byte, 3)
+	if _, err := io.ReadFull(f, head); err != nil || string(head) != "456" {
+		t.Errorf("read after seek = %q, %v; want 456", head, err)
+	}
+	if end, err := f.Seek(0, io.SeekEnd); err != nil || end != 10 {
+		t.Errorf("Seek(0, SeekEnd) = %d, %v", end, err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := io.ReadAll(f); err != nil || string(data) != "0123456789" {
+		t.Errorf("ReadAll() = %q, %v", data, err)
+	}
+	if err := f.Close(); err != nil {
+		t.Error(err)
+	}
+
+	if info, err := store.Stat(ctx, "sub dir/b+c.jpg"); err != nil || info.Size != 1 {
+		t.Errorf("Stat() = %+v, %v", info, err)
+	}
+	if _, err := store.Open(ctx, "missing.png"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Open() of a missing file error = %v, want fs.ErrNotExist", err)
+	}
+	if _, err := store.Stat(ctx, "sub dir"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat() of a directory error = %v, want fs.ErrNotExist", err)
+	}
+	for _, name := range []string{"../a.png", "/a.png", "", "."} {
+		if _, err := store.Open(ctx, name); !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("Open(%q) error = %v, want fs.ErrInvalid", name, err)
+		}
+	}
+
+	var names []string
+	err = store.Walk(ctx, func(info Info) error {
+		names = append(names, info.Name)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(names)
+	if want := []string{"a.png", "empty.gif", "sub dir/b+c.jpg"}; !slices.Equal(names, want) {
+		t.Errorf("Walk() names = %v, want %v", names, want)
+	}
+	stop := errors.New("stop")
+	if err := store.Walk(ctx, func(Info) error { return stop }); !errors.Is(err, stop) {
+		t.Errorf("Walk() error = %v, want the callback error", err)
+	}
+
+	if err := store.Delete(ctx, "a.png"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(ctx, "a.png"); err != nil {
+		t.Errorf("Delete() of a missing file error = %v", err)
+	}
+	if _, err := store.Stat(ctx, "a.png"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat() after Delete() error = %v, want fs.ErrNotExist", err)
+	}
+
+	// A short reader leaves the previous file in place
+	put("c.png", "kept")
+	if err := store.Put(ctx, "c.png", bytes.NewReader([]byte("ab")), 3); err == nil {
+		t.Error("Put() with a short reader succeeded")
+	}
+	if info, err := store.Stat(ctx, "c.png"); err != nil || info.Size != 4 {
+		t.Errorf("Stat() after failed Put() = %+v, %v; want the previous file", info, err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	env := map[string]string{
+		"AWS_ACCESS_KEY_ID":     "key",
+		"AWS_SECRET_ACCESS_KEY": "secret",
+		"AWS_REGION":            "sa-east-1",
+	}
+	store, err := open("s3://bucket/some/prefix/", func(name string) string { return env[name] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	s3 := store.(*S3)
+	if s3.opts.Bucket != "bucket" || s3.opts.Prefix != "some/prefix/" || s3.opts.PathStyle {
+		t.Errorf("options = %+v", s3.opts)
+	}
+	if got := presignedObject(t, s3); got != "https://bucket.s3.sa-east-1.amazonaws.com/some/prefix/a.png" {
+		t.Errorf("presigned URL = %s", got)
+	}
+
+	env["AWS_ENDPOINT_URL"] = "http://localhost:9000"
+	store, err = open("s3://bucket", func(name string) string { return env[name] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := presignedObject(t, store.(*S3)); got != "http://localhost:9000/bucket/a.png" {
+		t.Errorf("presigned URL with an endpoint = %s", got)
+	}
+
+	store, err = open("images", func(string) string { return "" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if local, ok := store.(*Local); !ok || local.Dir() != "images" {
+		t.Errorf("open(images) = %#v, want a local store", store)
+	}
+}
+
+// presignedObject returns the URL of a.png in s, without the signature
+func presignedObject(t *testing.T, s *S3) string {
+	t.Helper()
+	presigned, err := s.PresignGet("a.png", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	object, _, _ := strings.Cut(presigned, "?")
+	return object
+}
@@ -0,0 +1,344 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	// sigAlgorithm is AWS Signature Version 4, the only one S3 still accepts
+	sigAlgorithm = "AWS4-HMAC-SHA256"
+	// unsignedPayload marks request bodies left out of the signature
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+	amzDateFormat   = "20060102T150405Z"
+)
+
+// FakeS3 is an in-memory S3-compatible server for tests. It serves a single
+// bucket path-style and rejects requests not signed with its credentials,
+// checking AWS Signature Version 4 independently of the client.
+type FakeS3 struct {
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	Region    string
+	// PageSize bounds the keys of a listing, so tests cover continuation
+	PageSize int
+	// Server is the underlying test server
+	Server *httptest.Server
+
+	mu      sync.Mutex
+	objects map[string]fakeObject
+	// now is replaced in tests to check presigned URLs signed at a known time
+	now func() time.Time
+}
+
+type fakeObject struct {
+	data    []byte
+	modTime time.Time
+}
+
+// NewFakeS3 starts a FakeS3 serving bucket, stopped when the test ends.
+func NewFakeS3(t *testing.T, bucket string) *FakeS3 {
+	t.Helper()
+	f := &FakeS3{
+		Bucket:    bucket,
+		AccessKey: "AKIAFAKE",
+		SecretKey: "fake-secret",
+		Region:    "us-east-1",
+		PageSize:  1000,
+		objects:   map[string]fakeObject{},
+		now:       time.Now,
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(f.Server.Close)
+	return f
+}
+
+// Options returns the options to reach the fake with NewS3. Requests are
+// not retried: the fake never fails transiently.
+func (f *FakeS3) Options() S3Options {
+	return S3Options{
+		Endpoint:   f.Server.URL,
+		Region:     f.Region,
+		Bucket:     f.Bucket,
+		AccessKey:  f.AccessKey,
+		SecretKey:  f.SecretKey,
+		PathStyle:  true,
+		MaxRetries: 1,
+	}
+}
+
+// Store returns a store of the bucket under prefix.
+func (f *FakeS3) Store(t *testing.T, prefix string) *S3 {
+	t.Helper()
+	opts := f.Options()
+	opts.Prefix = prefix
+	s, err := NewS3(opts)
+	if err != nil {
+		t.Fatalf("failed to create S3 store: %v", err)
+	}
+	return s
+}
+
+// Keys returns the keys of all objects, sorted.
+func (f *FakeS3) Keys() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	keys := make([]string, 0, len(f.objects))
+	for key := range f.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (f *FakeS3) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if !f.authorized(r) {
+		writeFakeError(w, http.StatusForbidden, "SignatureDoesNotMatch")
+		return
+	}
+	key, ok := strings.CutPrefix(r.URL.Path, "/"+f.Bucket+"/")
+	if !ok && r.URL.Path != "/"+f.Bucket {
+		writeFakeError(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	if key == "" {
+		if r.Method != http.MethodGet || r.URL.Query().Get("list-type") != "2" {
+			writeFakeError(w, http.StatusNotImplemented, "NotImplemented")
+			return
+		}
+		f.list(w, r)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		object, ok := f.objects[key]
+		if !ok {
+			writeFakeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		http.ServeContent(w, r, key, object.modTime, bytes.NewReader(object.data))
+	case http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeFakeError(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		if hash := r.Header.Get("X-Amz-Content-Sha256"); hash != unsignedPayload {
+			sum := sha256.Sum256(data)
+			if hash != hex.EncodeToString(sum[:]) {
+				writeFakeError(w, http.StatusBadRequest, "XAmzContentSHA256Mismatch")
+				return
+			}
+		}
+		// Last-Modified has a precision of a second
+		f.objects[key] = fakeObject{data: data, modTime: time.Now().UTC().Truncate(time.Second)}
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeFakeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+// list answers ListObjectsV2, using the last key of a page as the
+// continuation token.
+func (f *FakeS3) list(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	prefix, after := query.Get("prefix"), query.Get("continuation-token")
+
+	f.mu.Lock()
+	var keys []string
+	for key := range f.objects {
+		if strings.HasPrefix(key, prefix) && key > after {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	type content struct {
+		Key          string
+		Size         int64
+		LastModified string
+	}
+	var result struct {
+		XMLName               xml.Name `xml:"ListBucketResult"`
+		Contents              []content
+		IsTruncated           bool
+		NextContinuationToken string `xml:",omitempty"`
+	}
+	for i, key := range keys {
+		if i == f.PageSize {
+			result.IsTruncated = true
+			result.NextContinuationToken = keys[i-1]
+			break
+		}
+		object := f.objects[key]
+		result.Contents = append(result.Contents, content{
+			Key:          key,
+			Size:         int64(len(object.data)),
+			LastModified: object.modTime.Format("2006-01-02T15:04:05.000Z"),
+		})
+	}
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/xml")
+	_ = xml.NewEncoder(w).Encode(result)
+}
+
+// authorized checks the signature of r, sent either in the Authorization
+// header or in the query of a presigned URL.
+func (f *FakeS3) authorized(r *http.Request) bool {
+	query := r.URL.Query()
+	var credential, signedHeaders, given, date, payloadHash, canonicalQ string
+	if query.Has("X-Amz-Signature") {
+		credential = query.Get("X-Amz-Credential")
+		signedHeaders = query.Get("X-Amz-SignedHeaders")
+		given = query.Get("X-Amz-Signature")
+		date = query.Get("X-Amz-Date")
+		payloadHash = unsignedPayload
+		canonicalQ = canonicalQuery(query, "X-Amz-Signature")
+		signedAt, err := time.Parse(amzDateFormat, date)
+		expires, _ := strconv.Atoi(query.Get("X-Amz-Expires"))
+		if err != nil || f.now().Sub(signedAt) > time.Duration(expires)*time.Second {
+			return false
+		}
+	} else {
+		auth, ok := strings.CutPrefix(r.Header.Get("Authorization"), sigAlgorithm+" ")
+		if !ok {
+			return false
+		}
+		for field := range strings.SplitSeq(auth, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+			switch name {
+			case "Credential":
+				credential = value
+			case "SignedHeaders":
+				signedHeaders = value
+			case "Signature":
+				given = value
+			}
+		}
+		date = r.Header.Get("X-Amz-Date")
+		payloadHash = r.Header.Get("X-Amz-Content-Sha256")
+		canonicalQ = canonicalQuery(query, "")
+	}
+	signedAt, err := time.Parse(amzDateFormat, date)
+	if err != nil {
+		return false
+	}
+	scope := signedAt.Format("20060102") + "/" + f.Region + "/s3/aws4_request"
+	if credential != f.AccessKey+"/"+scope {
+		return false
+	}
+	canonical := canonicalRequest(r, canonicalQ, strings.Split(signedHeaders, ";"), payloadHash)
+	return hmac.Equal([]byte(given), []byte(signature(f.SecretKey, signedAt, f.Region, canonical)))
+}
+
+func writeFakeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_ = xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string
+		Message string
+	}{Code: code, Message: http.StatusText(status)})
+}
+
+// canonicalRequest is the SigV4 canonical form of r, as received. query is
+// the canonical query string, signed the sorted lowercase names of the
+// signed headers.
+func canonicalRequest(r *http.Request, query string, signed []string, payloadHash string) string {
+	var b strings.Builder
+	b.WriteString(r.Method + "\n")
+	escapedPath := r.URL.EscapedPath()
+	if escapedPath == "" {
+		escapedPath = "/"
+	}
+	b.WriteString(escapedPath + "\n")
+	b.WriteString(query + "\n")
+	for _, name := range signed {
+		value := strings.Join(r.Header.Values(name), ",")
+		if name == "host" {
+			value = r.Host
+			if value == "" {
+				value = r.URL.Host
+			}
+		}
+		b.WriteString(name + ":" + strings.Join(strings.Fields(value), " ") + "\n")
+	}
+	b.WriteString("\n" + strings.Join(signed, ";") + "\n" + payloadHash)
+	return b.String()
+}
+
+// canonicalQuery encodes query sorted by key then value, leaving out skip.
+func canonicalQuery(query url.Values, skip string) string {
+	var params []string
+	for key, values := range query {
+		if key == skip {
+			continue
+		}
+		for _, value := range values {
+			params = append(params, uriEncode(key)+"="+uriEncode(value))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+// uriEncode percent-encodes everything but the unreserved characters of RFC
+// 3986.
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// signature signs the canonical request with the key derived for the day of
+// t, the region and S3.
+func signature(secret string, t time.Time, region, canonical string) string {
+	hash := sha256.Sum256([]byte(canonical))
+	date := t.Format("20060102")
+	stringToSign := strings.Join([]string{
+		sigAlgorithm,
+		t.Format(amzDateFormat),
+		date + "/" + region + "/s3/aws4_request",
+		hex.EncodeToString(hash[:]),
+	}, "\n")
+	key := hmacSHA256([]byte("AWS4"+secret), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,42 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+)
+
+func TestSetupStdout(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	var out bytes.Buffer
+	shutdown, err := Setup(t.Context(), Options{Exporter: ExporterStdout, Stdout: &out, Version: "1.2.3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, parent := Start(t.Context(), "parent")
+	_, child := Start(ctx, "child")
+	Fail(child, errors.New("boom"))
+	child.End()
+	parent.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{`"Name": "parent"`, `"Name": "child"`, `"boom"`, `"rotulador"`, `"1.2.3"`} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("stdout export should contain %s, got:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestSetupUnknownExporter(t *testing.T) {
+	if _, err := Setup(t.Context(), Options{Exporter: "jaeger"}); err == nil {
+		t.Fatal("unknown exporter should fail")
+	}
+}
@@ -4,6 +4,18 @@ import "github.com/lewtec/rotulador/internal/ui/components"
 
 type HomeData struct {
 	Description string
+	// Ingest is set while the images directory is being ingested
+	Ingest *IngestStatus
+	// Leaderboard links to /stats/leaderboard
+	Leaderboard bool
+}
+
+type IngestStatus struct {
+	Done    int
+	Total   int
+	Percent int
+	Rate    string
+	ETA     string
 }
 
 type ClassButton struct {
@@ -17,14 +29,42 @@ type AnnotateProgress struct {
 	TotalCount     int
 }
 
+// UpcomingImage is an image the annotation page loads ahead, so it can be
+// shown as soon as the current one is answered.
+type UpcomingImage struct {
+	ID       string `json:"id"`
+	Filename string `json:"filename"`
+	URL      string `json:"url"`
+}
+
 type AnnotateData struct {
 	TaskID        string
 	TaskName      string
 	ImageID       string
 	ImageFilename string
+	ImageURL      string
 	Classes       []ClassButton
 	PhaseProgress *components.Progress
 	Progress      *AnnotateProgress
+	Upcoming      []UpcomingImage
+}
+
+// GridImage is a thumbnail on the grid annotation page.
+type GridImage struct {
+	ID       string
+	Filename string
+	URL      string
+}
+
+type AnnotateGridData struct {
+	TaskID       string
+	TaskName     string
+	DefaultClass string
+	// Size is the number of images per page
+	Size          int
+	Classes       []ClassButton
+	Images        []GridImage
+	PhaseProgress *components.Progress
 }
 
 type HelpClass struct {
@@ -52,3 +92,204 @@ type HelpData struct {
 	Detail *HelpTask
 	Tasks  []HelpTask
 }
+
+// LeaseRow is one active image reservation on the admin leases page.
+type LeaseRow struct {
+	TaskID        string
+	TaskName      string
+	ImageID       string
+	ImageFilename string
+	Username      string
+	ExpiresIn     string
+}
+
+type AdminLeasesData struct {
+	Leases []LeaseRow
+}
+
+type DuplicateImage struct {
+	ID       string
+	Filename string
+	// Distance is the Hamming distance to the first image of the group.
+	Distance int
+}
+
+type DuplicateGroup struct {
+	Images []DuplicateImage
+}
+
+type AdminDuplicatesData struct {
+	Distance     int
+	WithoutPHash int
+	Groups       []DuplicateGroup
+}
+
+// BrowseOption is a choice of a /browse filter select.
+type BrowseOption struct {
+	ID   string
+	Name string // i18n message id; empty shows the ID
+}
+
+// BrowseFilter holds the /browse filters as submitted, to refill the form.
+type BrowseFilter struct {
+	Task         string
+	Class        string
+	User         string
+	From         string
+	To           string
+	Unsure       bool
+	Disagreement bool
+}
+
+type BrowseData struct {
+	Filter  BrowseFilter
+	Tasks   []BrowseOption
+	Classes []BrowseOption
+	Users   []string
+	Images  []GridImage
+	Total   int
+	Page    int
+	Pages   int
+	// PrevURL and NextURL keep the filters; empty on the first and last page
+	PrevURL string
+	NextURL string
+}
+
+// ImageAnnotation is one answer on the image detail page.
+type ImageAnnotation struct {
+	TaskName    string // i18n message id
+	Class       string // i18n message id
+	User        string
+	Sure        bool
+	AnnotatedAt string
+	// BrowseURL lists the images with the same answer
+	BrowseURL string
+	// Reviewer is set once the annotation was reviewed; ReviewedClass is
+	// the correction when Corrected
+	Reviewer      string
+	Corrected     bool
+	ReviewedClass string
+}
+
+type BrowseImageData struct {
+	ID          string
+	Filename    string
+	URL         string
+	SourcePath  string
+	Format      string
+	Width       int
+	Height      int
+	Annotations []ImageAnnotation
+	// History lists the changes to the annotations of the image, newest first
+	History []ImageEvent
+}
+
+// ImageEvent is one entry of the history on the image detail page.
+type ImageEvent struct {
+	At       string
+	Kind     string // i18n message id
+	TaskName string // i18n message id
+	// User owns the annotation; Actor made the change when someone else did
+	User     string
+	Actor    string
+	Source   string
+	OldClass string // i18n message id
+	NewClass string // i18n message id
+}
+
+// AcceptanceRow is the share of an annotator's reviewed annotations that
+// reviewers accepted.
+type AcceptanceRow struct {
+	Username string
+	Reviewed int
+	Accepted int
+	Percent  int
+}
+
+type ReviewData struct {
+	TaskID   string
+	TaskName string
+	// AnnotationID is 0 when nothing is left to review
+	AnnotationID  int64
+	ImageID       string
+	ImageFilename string
+	ImageURL      string
+	Annotator     string
+	// Label is the class ID the annotator picked
+	Label   string
+	Sure    bool
+	Pending int
+	Classes []ClassButton
+	Rates   []AcceptanceRow
+}
+
+type ReviewTask struct {
+	ID      string
+	Name    string
+	Pending int
+}
+
+type ReviewIndexData struct {
+	Tasks []ReviewTask
+}
+
+// StatsPeriod is the date range of the /stats pages, both dates inclusive
+// and optional.
+type StatsPeriod struct {
+	From string
+	To   string
+}
+
+type StatsRow struct {
+	Username    string
+	TaskName    string
+	Annotations int
+	UnsureRate  string
+	// Median is empty when unknown
+	Median    string
+	Agreement string
+	Gold      string
+	// TooFast flags users whose median time-on-image is suspiciously low
+	TooFast bool
+}
+
+type StatsClassRow struct {
+	TaskName  string
+	ClassName string
+	// Timed counts the annotations with a recorded time-on-image
+	Timed  int
+	Median string
+	Total  string
+}
+
+type StatsDay struct {
+	Day string
+	// Counts has a column per StatsData.Users
+	Counts []int
+	Total  int
+}
+
+type StatsData struct {
+	Period      StatsPeriod
+	Leaderboard bool
+	Rows        []StatsRow
+	Classes     []StatsClassRow
+	Users       []string
+	Days        []StatsDay
+	// TooFast lists the users whose median time-on-image is below MinMedian
+	TooFast   []string
+	MinMedian string
+}
+
+type LeaderboardRow struct {
+	Rank        int
+	Username    string
+	Annotations int
+	// Current marks the user looking at the leaderboard
+	Current bool
+}
+
+type LeaderboardData struct {
+	Period StatsPeriod
+	Rows   []LeaderboardRow
+}
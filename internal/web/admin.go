@@ -0,0 +1,143 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lewtec/rotulador/internal/ui/pages"
+)
+
+// isAdmin reports whether the authenticated user has admin: true in the config.
+func (a *AnnotatorApp) isAdmin(r *http.Request) bool {
+	username, _, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	item, ok := a.Config.Authentication[username]
+	return ok && item.Admin
+}
+
+// isReviewer reports whether the authenticated user may review and browse
+// other users' annotations: reviewers and admins.
+func (a *AnnotatorApp) isReviewer(r *http.Request) bool {
+	username, _, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	item, ok := a.Config.Authentication[username]
+	return ok && (item.Reviewer || item.Admin)
+}
+
+// handleAdminLeases lists active image leases. POST releases a single lease.
+func (a *AnnotatorApp) handleAdminLeases(w http.ResponseWriter, r *http.Request) {
+	if !a.isAdmin(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		if err := r.ParseForm(); err != nil {
+			ReportError(r.Context(), err, "msg", "failed to parse form")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		stageIndex := a.findTaskIndex(r.FormValue("task"))
+		if stageIndex == -1 || r.FormValue("image") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if err := a.leaseRepo.ReleaseAny(r.Context(), r.FormValue("image"), stageIndex); err != nil {
+			ReportError(r.Context(), err, "msg", "error releasing lease")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/admin/leases", http.StatusSeeOther)
+		return
+	}
+
+	leases, err := a.leaseRepo.ListActive(r.Context())
+	if err != nil {
+		ReportError(r.Context(), err, "msg", "error listing leases")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	rows := make([]pages.LeaseRow, 0, len(leases))
+	for _, lease := range leases {
+		row := pages.LeaseRow{
+			ImageID:       lease.ImageSHA256,
+			ImageFilename: lease.ImageFilename,
+			Username:      lease.Username,
+			ExpiresIn:     lease.ExpiresAt.Sub(now).Round(time.Second).String(),
+		}
+		if lease.StageIndex < len(a.Config.Tasks) {
+			row.TaskID = a.Config.Tasks[lease.StageIndex].ID
+			row.TaskName = a.Config.Tasks[lease.StageIndex].ShortName
+		}
+		rows = append(rows, row)
+	}
+
+	err = Render(r.Context(), w, pages.AdminLeases(PageShell("Active leases"), pages.AdminLeasesData{
+		Leases: rows,
+	}))
+	if err != nil {
+		ReportError(r.Context(), err, "msg", "error rendering leases template")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// handleAdminDuplicates groups near-duplicate images by perceptual hash.
+// The ?distance= query parameter overrides DefaultDuplicateDistance.
+func (a *AnnotatorApp) handleAdminDuplicates(w http.ResponseWriter, r *http.Request) {
+	if !a.isAdmin(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	distance := DefaultDuplicateDistance
+	if v := r.URL.Query().Get("distance"); v != "" {
+		d, err := strconv.Atoi(v)
+		if err != nil || d < 0 || d > 64 {
+			http.Error(w, "distance must be between 0 and 64", http.StatusBadRequest)
+			return
+		}
+		distance = d
+	}
+
+	groups, err := ListNearDuplicates(r.Context(), a.Database, distance)
+	if err != nil {
+		ReportError(r.Context(), err, "msg", "error listing near-duplicates")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	missing, err := a.imageRepo.CountWithoutPHash(r.Context())
+	if err != nil {
+		ReportError(r.Context(), err, "msg", "error counting images without perceptual hash")
+		missing = 0
+	}
+
+	data := pages.AdminDuplicatesData{
+		Distance:     distance,
+		WithoutPHash: int(missing),
+		Groups:       make([]pages.DuplicateGroup, 0, len(groups)),
+	}
+	for _, group := range groups {
+		dg := pages.DuplicateGroup{Images: make([]pages.DuplicateImage, 0, len(group))}
+		for _, img := range group {
+			dg.Images = append(dg.Images, pages.DuplicateImage{
+				ID:       img.SHA256,
+				Filename: img.Filename,
+				Distance: HammingDistance(group[0].PHash, img.PHash),
+			})
+		}
+		data.Groups = append(data.Groups, dg)
+	}
+
+	err = Render(r.Context(), w, pages.AdminDuplicates(PageShell("Near-duplicates"), data))
+	if err != nil {
+		ReportError(r.Context(), err, "msg", "error rendering duplicates template")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,222 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lewtec/rotulador/internal/domain"
+)
+
+// ImageRecord is the JSON representation of an image and its metadata.
+type ImageRecord struct {
+	SHA256        string     `json:"sha256"`
+	Filename      string     `json:"filename"`
+	IngestedAt    time.Time  `json:"ingested_at"`
+	SourcePath    string     `json:"source_path,omitempty"`
+	SourceArchive string     `json:"source_archive,omitempty"`
+	FrameOffsetMS *int64     `json:"frame_offset_ms,omitempty"`
+	SourceDir     string     `json:"source_dir,omitempty"`
+	Format        string     `json:"format,omitempty"`
+	Width         int        `json:"width,omitempty"`
+	Height        int        `json:"height,omitempty"`
+	SizeBytes     int64      `json:"size_bytes,omitempty"`
+	CapturedAt    *time.Time `json:"captured_at,omitempty"`
+	Camera        string     `json:"camera,omitempty"`
+	Tags          []string   `json:"tags"`
+}
+
+// NewImageRecord converts img and its tags to an ImageRecord.
+func NewImageRecord(img *domain.Image, tags []string) ImageRecord {
+	rec := ImageRecord{
+		SHA256:        img.SHA256,
+		Filename:      img.Filename,
+		IngestedAt:    img.IngestedAt,
+		SourcePath:    img.SourcePath,
+		SourceArchive: img.SourceArchive,
+		SourceDir:     img.SourceDir(),
+		Format:        img.Format,
+		Width:         img.Width,
+		Height:        img.Height,
+		SizeBytes:     img.SizeBytes,
+		Camera:        img.Camera,
+		Tags:          tags,
+	}
+	if img.FrameOffset != nil {
+		ms := img.FrameOffset.Milliseconds()
+		rec.FrameOffsetMS = &ms
+	}
+	if !img.CapturedAt.IsZero() {
+		capturedAt := img.CapturedAt
+		rec.CapturedAt = &capturedAt
+	}
+	if rec.Tags == nil {
+		rec.Tags = []string{}
+	}
+	return rec
+}
+
+// ListImageRecords returns a page of the images matching filter with their
+// tags, as ImageRepository.ListPage selects it.
+func ListImageRecords(ctx context.Context, images domain.ImageRepository, filter domain.ImageFilter, after *domain.Image, limit int) ([]ImageRecord, error) {
+	list, err := images.ListPage(ctx, filter, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("while listing images: %w", err)
+	}
+	hashes := make([]string, len(list))
+	for i, img := range list {
+		hashes[i] = img.SHA256
+	}
+	tags, err := images.ListTagsOf(ctx, hashes)
+	if err != nil {
+		return nil, fmt.Errorf("while listing tags: %w", err)
+	}
+	records := make([]ImageRecord, 0, len(list))
+	for _, img := range list {
+		records = append(records, NewImageRecord(img, tags[img.SHA256]))
+	}
+	return records, nil
+}
+
+const (
+	// apiImagesPageSize is the default number of images of an /api/images page
+	apiImagesPageSize = 500
+	// apiImagesMaxPageSize bounds the limit parameter of /api/images
+	apiImagesMaxPageSize = 5000
+)
+
+// handleAPIImages lists images as JSON. Query parameters named after image
+// fields filter the list, e.g. /api/images?width=>512&format=jpeg&tag=night.
+// Images come in pages of up to limit images; when there may be more, the
+// Link header points to the next page, which starts after the image whose
+// hash is in the after parameter.
+func (a *AnnotatorApp) handleAPIImages(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	filter, err := ImageFilterFromValues(query)
+	if errors.Is(err, ErrInvalidImageCondition) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		ReportError(r.Context(), err, "msg", "error parsing image filter")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	limit := apiImagesPageSize
+	if value := query.Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > apiImagesMaxPageSize {
+			http.Error(w, fmt.Sprintf("limit must be a number from 1 to %d", apiImagesMaxPageSize), http.StatusBadRequest)
+			return
+		}
+	}
+	var after *domain.Image
+	if hash := query.Get("after"); hash != "" {
+		after, err = a.imageRepo.GetBySHA256(r.Context(), hash)
+		if err != nil {
+			ReportError(r.Context(), err, "msg", "error loading page cursor", "sha256", hash)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if after == nil {
+			http.Error(w, "after is not the hash of an image", http.StatusBadRequest)
+			return
+		}
+	}
+
+	records, err := ListImageRecords(r.Context(), a.imageRepo, filter, after, limit)
+	if err != nil {
+		ReportError(r.Context(), err, "msg", "error listing images")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if len(records) == limit {
+		next := *r.URL
+		query.Set("after", records[len(records)-1].SHA256)
+		next.RawQuery = query.Encode()
+		w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", next.RequestURI()))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(records); err != nil {
+		ReportError(r.Context(), err, "msg", "error encoding images")
+	}
+}
+
+// APIAnnotation is the JSON body of an answer posted to
+// /api/annotate/{task}/{sha256}.
+type APIAnnotation struct {
+	Class string `json:"class"`
+	Sure  bool   `json:"sure"`
+	// ElapsedMS is the time spent on the image, if the client measured it
+	ElapsedMS int64 `json:"elapsed_ms,omitempty"`
+}
+
+// handleAPIAnnotate records an answer sent by a script as JSON. The history
+// credits it to the API, while the annotation pages post forms to
+// /annotate/{task}/{sha256}.
+func (a *AnnotatorApp) handleAPIAnnotate(w http.ResponseWriter, r *http.Request, taskID, imageID string) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	task := a.GetTask(taskID)
+	if task == nil {
+		http.NotFoundHandler().ServeHTTP(w, r)
+		return
+	}
+	var body APIAnnotation
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, fmt.Sprintf("invalid annotation: %s", err), http.StatusBadRequest)
+		return
+	}
+	if _, ok := task.Classes[body.Class]; !ok {
+		http.Error(w, fmt.Sprintf("unknown class: %q", body.Class), http.StatusBadRequest)
+		return
+	}
+	if body.ElapsedMS < 0 {
+		http.Error(w, fmt.Sprintf("%s: %d", ErrInvalidElapsed, body.ElapsedMS), http.StatusBadRequest)
+		return
+	}
+	img, err := a.imageRepo.GetBySHA256(r.Context(), imageID)
+	if err != nil {
+		ReportError(r.Context(), err, "msg", "error looking up image", "sha256", imageID)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if img == nil {
+		http.NotFoundHandler().ServeHTTP(w, r)
+		return
+	}
+
+	// authenticationMiddleware already validated these credentials
+	user, _, _ := r.BasicAuth()
+	if user == "" {
+		w.Header().Set("WWW-Authenticate", `Basic realm="rotulador"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	err = a.SubmitAnnotation(r.Context(), AnnotationResponse{
+		ImageID:  imageID,
+		TaskID:   taskID,
+		User:     user,
+		Value:    body.Class,
+		Sure:     body.Sure,
+		Duration: time.Duration(body.ElapsedMS) * time.Millisecond,
+		Source:   domain.EventSourceAPI,
+	})
+	if err != nil {
+		ReportError(r.Context(), err, "msg", "error while submitting annotation")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,146 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lewtec/rotulador/internal/domain"
+)
+
+func TestHandleAPIImagesFilters(t *testing.T) {
+	a := newTestApp(t, &Config{})
+	ctx := t.Context()
+	for sha, width := range map[string]int{"wide": 1024, "narrow": 256} {
+		meta := domain.ImageMetadata{Format: "png", Width: width, Height: 100}
+		if _, err := a.imageRepo.CreateWithMetadata(ctx, sha, sha+".png", meta); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.imageRepo.AddTag(ctx, "narrow", "night"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"width=>512", "wide"},
+		{"tag=night", "narrow"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		a.handleAPIImages(rec, httptest.NewRequest(http.MethodGet, "/api/images?"+tt.query, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d", tt.query, rec.Code)
+		}
+		var got []ImageRecord
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 || got[0].SHA256 != tt.want {
+			t.Errorf("%s: got %+v, want only %s", tt.query, got, tt.want)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	a.handleAPIImages(rec, httptest.NewRequest(http.MethodGet, "/api/images?width=wide", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid filter status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHandleAPIImagesPages(t *testing.T) {
+	a := newTestApp(t, &Config{})
+	ctx := t.Context()
+	for _, sha := range []string{"a", "b", "c"} {
+		if _, err := a.imageRepo.Create(ctx, sha, sha+".png"); err != nil {
+			t.Fatal(err)
+		}
+		if err := a.imageRepo.AddTag(ctx, sha, "tag-"+sha); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []string
+	target := "/api/images?limit=2"
+	for target != "" {
+		rec := httptest.NewRecorder()
+		a.handleAPIImages(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d", target, rec.Code)
+		}
+		var page []ImageRecord
+		if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+			t.Fatal(err)
+		}
+		for _, img := range page {
+			if len(img.Tags) != 1 || img.Tags[0] != "tag-"+img.SHA256 {
+				t.Errorf("%s tags = %v", img.SHA256, img.Tags)
+			}
+			got = append(got, img.SHA256)
+		}
+		target = ""
+		if link := rec.Header().Get("Link"); link != "" {
+			next, ok := strings.CutSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`)
+			if !ok {
+				t.Fatalf("Link = %q", link)
+			}
+			target = next
+		}
+	}
+	if strings.Join(got, ",") != "a,b,c" {
+		t.Errorf("pages = %v, want a,b,c", got)
+	}
+
+	for _, query := range []string{"limit=0", "limit=many", "after=unknown"} {
+		rec := httptest.NewRecorder()
+		a.handleAPIImages(rec, httptest.NewRequest(http.MethodGet, "/api/images?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", query, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestHandleAPIAnnotate(t *testing.T) {
+	a := newBrowseApp(t)
+	post := func(target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.SetBasicAuth("alice", "")
+		rec := httptest.NewRecorder()
+		a.handleAnnotateAPI(rec, req)
+		return rec
+	}
+
+	if rec := post("/api/annotate/quality/hash3", `{"class": "bad", "elapsed_ms": 1500}`); rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	ann, err := a.annotationRepo.Get(t.Context(), "hash3", "alice", 0)
+	if err != nil || ann == nil {
+		t.Fatalf("annotation = %+v, %v", ann, err)
+	}
+	if ann.OptionValue != "bad" || ann.Sure || ann.Duration != 1500*time.Millisecond {
+		t.Errorf("annotation = %+v, want an unsure bad after 1.5s", ann)
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		body   string
+		status int
+	}{
+		{"unknown class", "/api/annotate/quality/hash3", `{"class": "meh"}`, http.StatusBadRequest},
+		{"invalid JSON", "/api/annotate/quality/hash3", `class=bad`, http.StatusBadRequest},
+		{"negative elapsed", "/api/annotate/quality/hash3", `{"class": "bad", "elapsed_ms": -1}`, http.StatusBadRequest},
+		{"unknown image", "/api/annotate/quality/unknown", `{"class": "bad"}`, http.StatusNotFound},
+		{"unknown task", "/api/annotate/missing/hash3", `{"class": "bad"}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if rec := post(tt.target, tt.body); rec.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.status)
+		}
+	}
+}
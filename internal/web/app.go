@@ -5,14 +5,16 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"io"
-	"io/fs"
 	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"math/rand"
 
@@ -22,7 +24,10 @@ import (
 	"github.com/lewtec/rotulador/internal/db/migrations"
 	"github.com/lewtec/rotulador/internal/domain"
 	"github.com/lewtec/rotulador/internal/repository"
+	"github.com/lewtec/rotulador/internal/storage"
+	"github.com/lewtec/rotulador/internal/tracing"
 	"github.com/lewtec/rotulador/internal/ui/pages"
+	"go.opentelemetry.io/otel/attribute"
 	moderncsqlite "modernc.org/sqlite"
 )
 
@@ -34,20 +39,41 @@ func (e appError) Error() string { return string(e) }
 
 // App-level error table. Dynamic detail is attached with fmt.Errorf %w.
 const (
-	ErrTaskNotFound   appError = "task not found"
-	ErrImageNotFound  appError = "image not found"
-	ErrDatasetNotFlat appError = "datasets must be organized in a flat folder structure (hint: use the 'ingest' subcommand)"
-	ErrPathTraversal  appError = "path traversal detected"
+	ErrTaskNotFound  appError = "task not found"
+	ErrImageNotFound appError = "image not found"
+	ErrPathTraversal appError = "path traversal detected"
+	ErrRemoteImages  appError = "only possible for images in a local directory"
 )
 
 type AnnotatorApp struct {
-	ImagesDir      string
-	Database       *sql.DB
-	Config         *Config
-	Logger         *slog.Logger
-	OffsetAdvance  int
+	// ImagesDir is where the images are, also used to describe Store in logs
+	ImagesDir string
+	// Store holds the image files; nil stores them locally in ImagesDir
+	Store storage.Store
+	// CacheDir holds resized image variants; empty renders them per request
+	CacheDir      string
+	Database      *sql.DB
+	Config        *Config
+	Logger        *slog.Logger
+	OffsetAdvance int
+	// EventSource is recorded in the history for changes the app makes on
+	// its own, such as images deleted by ingest. CLI commands set it to
+	// domain.EventSourceCLI; empty is domain.EventSourceWeb.
+	EventSource    string
 	imageRepo      *repository.ImageRepository
 	annotationRepo *repository.AnnotationRepository
+	leaseRepo      *repository.LeaseRepository
+	reviewRepo     *repository.ReviewRepository
+	ingestRepo     *repository.IngestIndexRepository
+	eventRepo      *repository.EventRepository
+	progressRepo   *repository.TaskProgressRepository
+	ingest         ingestState
+	// migrated is set once the schema is up to date, for /readyz
+	migrated atomic.Bool
+	// rules guards syncing the task rules with the progress tables, done
+	// once per process
+	rules       sync.Mutex
+	rulesSynced atomic.Bool
 }
 
 func (a *AnnotatorApp) init() {
@@ -57,9 +83,27 @@ func (a *AnnotatorApp) init() {
 	if a.OffsetAdvance == 0 {
 		a.OffsetAdvance = 10
 	}
+	if a.Store == nil {
+		a.Store = storage.NewLocal(a.ImagesDir)
+	}
 	// Initialize repositories
 	a.imageRepo = repository.NewImageRepository(a.Database)
 	a.annotationRepo = repository.NewAnnotationRepository(a.Database)
+	a.leaseRepo = repository.NewLeaseRepository(a.Database)
+	a.reviewRepo = repository.NewReviewRepository(a.Database)
+	a.ingestRepo = repository.NewIngestIndexRepository(a.Database)
+	a.eventRepo = repository.NewEventRepository(a.Database)
+	a.progressRepo = repository.NewTaskProgressRepository(a.Database)
+	if a.EventSource == "" {
+		a.EventSource = domain.EventSourceWeb
+	}
+}
+
+// isLocalStore reports whether the images are files of ImagesDir, which
+// watching and checking them rely on.
+func (a *AnnotatorApp) isLocalStore() bool {
+	local, ok := a.Store.(*storage.Local)
+	return ok && local.Dir() == filepath.Clean(a.ImagesDir)
 }
 
 type AnnotationStep struct {
@@ -88,235 +132,64 @@ type PhaseProgress struct {
 	NotYetAnnotatedPercent float64 // Percentage of not yet annotated images
 }
 
-// getCachedImageList returns the list of all images, using cache if available
-func (a *AnnotatorApp) getCachedImageList(ctx context.Context) ([]*domain.Image, error) {
-	// Try to get from cache first
-	if cache := GetRequestCache(ctx); cache != nil {
-		if images, ok := cache.GetImages(); ok {
-			return images, nil
-		}
+// taskCounts returns the counters of a task, syncing the task rules first
+// when needed
+func (a *AnnotatorApp) taskCounts(ctx context.Context, taskID string) (*domain.TaskCounts, error) {
+	stageIndex := a.findTaskIndex(taskID)
+	if stageIndex == -1 {
+		return nil, fmt.Errorf("%w: %s", ErrTaskNotFound, taskID)
 	}
-
-	// Cache miss or no cache available, fetch from database
-	images, err := a.imageRepo.List(ctx)
-	if err != nil {
+	if err := a.SyncTaskRules(ctx); err != nil {
 		return nil, err
 	}
-
-	// Store in cache if available
-	if cache := GetRequestCache(ctx); cache != nil {
-		cache.SetImages(images)
+	counts, err := a.progressRepo.Counts(ctx, stageIndex)
+	if err != nil {
+		return nil, fmt.Errorf("while reading progress of task %s: %w", taskID, err)
 	}
-
-	return images, nil
+	return counts, nil
 }
 
 // CountEligibleImages counts all images that are eligible for this task (regardless of annotation status)
 func (a *AnnotatorApp) CountEligibleImages(ctx context.Context, taskID string) (int, error) {
-	// Find stage index for this task
-	stageIndex := a.findTaskIndex(taskID)
-	if stageIndex == -1 {
-		return 0, fmt.Errorf("%w: %s", ErrTaskNotFound, taskID)
-	}
-
-	task := a.Config.Tasks[stageIndex]
-
-	// If no dependencies, all images are eligible
-	if len(task.If) == 0 {
-		count, err := a.imageRepo.Count(ctx)
-		return int(count), err
-	}
+	ctx, span := tracing.Start(ctx, "CountEligibleImages", attribute.String("rotulador.task", taskID))
+	defer span.End()
 
-	// Pre-fetch all dependency data before looping (optimization: move queries outside loop)
-	imageHashesByDep, err := a.getDependencyImageHashes(ctx, task)
+	counts, err := a.taskCounts(ctx, taskID)
 	if err != nil {
 		return 0, err
 	}
-
-	// Get all images and filter by dependencies (using cache)
-	allImages, err := a.getCachedImageList(ctx)
-	if err != nil {
-		return 0, fmt.Errorf("while listing images: %w", err)
-	}
-
-	validCount := 0
-	for _, img := range allImages {
-		valid := true
-		// Check each dependency using pre-fetched map
-		for depTaskID := range task.If {
-			if !imageHashesByDep[depTaskID][img.SHA256] {
-				valid = false
-				break
-			}
-		}
-
-		if valid {
-			validCount++
-		}
-	}
-
-	return validCount, nil
+	return counts.Eligible, nil
 }
 
+// CountAvailableImages counts the images eligible for this task and not annotated yet
 func (a *AnnotatorApp) CountAvailableImages(ctx context.Context, taskID string) (int, error) {
-	// Find stage index for this task
-	stageIndex := a.findTaskIndex(taskID)
-	if stageIndex == -1 {
-		return 0, fmt.Errorf("%w: %s", ErrTaskNotFound, taskID)
-	}
-
-	task := a.Config.Tasks[stageIndex]
+	ctx, span := tracing.Start(ctx, "CountAvailableImages", attribute.String("rotulador.task", taskID))
+	defer span.End()
 
-	// Count images without annotation for this stage
-	count, err := a.annotationRepo.CountImagesWithoutAnnotationForStage(ctx, int64(stageIndex))
+	counts, err := a.taskCounts(ctx, taskID)
 	if err != nil {
-		return 0, fmt.Errorf("while counting available images: %w", err)
-	}
-
-	// Handle task dependencies (If field)
-	// If there are dependencies, we need to filter images that meet the criteria
-	if len(task.If) > 0 {
-		// Pre-fetch all dependency data before looping (optimization: move queries outside loop)
-		imageHashesByDep, err := a.getDependencyImageHashes(ctx, task)
-		if err != nil {
-			return 0, err
-		}
-
-		// Get all candidate images (using cache)
-		allImages, err := a.getCachedImageList(ctx)
-		if err != nil {
-			return 0, fmt.Errorf("while listing images: %w", err)
-		}
-
-		validCount := 0
-		for _, img := range allImages {
-			valid := true
-			// Check each dependency using pre-fetched map
-			for depTaskID := range task.If {
-				if !imageHashesByDep[depTaskID][img.SHA256] {
-					valid = false
-					break
-				}
-			}
-
-			if valid {
-				// Check if this image has annotation for current stage
-				hasAnnotation, err := a.annotationRepo.CheckAnnotationExists(ctx, img.SHA256, "", int64(stageIndex))
-				if err != nil {
-					return 0, err
-				}
-				if !hasAnnotation {
-					validCount++
-				}
-			}
-		}
-		return validCount, nil
+		return 0, err
 	}
-
-	return int(count), nil
+	return counts.Available, nil
 }
 
 // GetPhaseProgressStats calculates comprehensive progress statistics for a task
 func (a *AnnotatorApp) GetPhaseProgressStats(ctx context.Context, taskID string) (*PhaseProgress, error) {
-	// Get total images in the entire dataset
-	totalCount, err := a.imageRepo.Count(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("while counting total images: %w", err)
-	}
+	ctx, span := tracing.Start(ctx, "GetPhaseProgressStats", attribute.String("rotulador.task", taskID))
+	defer span.End()
 
-	// Get eligible images (that pass filters from previous phases)
-	eligibleCount, err := a.CountEligibleImages(ctx, taskID)
+	counts, err := a.taskCounts(ctx, taskID)
 	if err != nil {
-		return nil, fmt.Errorf("while counting eligible images: %w", err)
-	}
-
-	// Get available images (eligible but not yet annotated)
-	availableCount, err := a.CountAvailableImages(ctx, taskID)
-	if err != nil {
-		return nil, fmt.Errorf("while counting available images: %w", err)
-	}
-
-	// Calculate completed and pending
-	completed := eligibleCount - availableCount
-	if completed < 0 {
-		completed = 0
+		return nil, err
 	}
-	pending := availableCount
-
-	total := int(totalCount)
-	notEligible := total - eligibleCount
 
-	// Now differentiate between filtered (annotated with wrong class) and not yet annotated
-	var filteredWrongClass, notYetAnnotated int
-
-	// Find task and check if it has dependencies
-	stageIndex := a.findTaskIndex(taskID)
-
-	if stageIndex != -1 {
-		task := a.Config.Tasks[stageIndex]
-
-		// If task has dependencies, analyze the not-eligible images
-		if len(task.If) > 0 {
-			// Pre-fetch all dependency data before looping (optimization: move queries outside loop)
-			imageHashesByDep, err := a.getDependencyImageHashes(ctx, task)
-			if err != nil {
-				return nil, err
-			}
-
-			// Get all images (using cache)
-			allImages, err := a.getCachedImageList(ctx)
-			if err != nil {
-				return nil, fmt.Errorf("while listing images: %w", err)
-			}
-
-			// Get images that passed the filter (eligible)
-			eligibleHashes := make(map[string]bool)
-			for _, img := range allImages {
-				valid := true
-				// Check each dependency using pre-fetched map
-				for depTaskID := range task.If {
-					if !imageHashesByDep[depTaskID][img.SHA256] {
-						valid = false
-						break
-					}
-				}
-
-				if valid {
-					eligibleHashes[img.SHA256] = true
-				}
-			}
-
-			// Check not-eligible images to see if they were annotated in dependency phase
-			for _, img := range allImages {
-				if !eligibleHashes[img.SHA256] {
-					// This image is not eligible - check if it was annotated in dependency phase
-					annotatedInDep := false
-					for depTaskID := range task.If {
-						depStageIndex := a.findTaskIndex(depTaskID)
-						if depStageIndex == -1 {
-							continue
-						}
-
-						// Check if this image has ANY annotation in the dependency phase
-						hasAnnotation, err := a.annotationRepo.CheckAnnotationExists(ctx, img.SHA256, "", int64(depStageIndex))
-						if err == nil && hasAnnotation {
-							annotatedInDep = true
-							break
-						}
-					}
-
-					if annotatedInDep {
-						filteredWrongClass++
-					} else {
-						notYetAnnotated++
-					}
-				}
-			}
-		} else {
-			// No dependencies, so all not-eligible images are "not yet annotated"
-			notYetAnnotated = notEligible
-		}
-	}
+	total := counts.Total
+	completed := counts.Eligible - counts.Available
+	pending := counts.Available
+	// Not eligible images either were annotated with another class in a
+	// dependency task, or are still waiting for it
+	filteredWrongClass := counts.Filtered
+	notYetAnnotated := total - counts.Eligible - counts.Filtered
 
 	// Calculate percentages
 	var completedPercent, pendingPercent, filteredPercent, notYetAnnotatedPercent float64
@@ -340,11 +213,29 @@ func (a *AnnotatorApp) GetPhaseProgressStats(ctx context.Context, taskID string)
 	}, nil
 }
 
-func (a *AnnotatorApp) NextAnnotationStep(ctx context.Context, taskID string) (*AnnotationStep, error) {
+// NextAnnotationStep picks the next image to annotate for a task. When
+// username is set and leases are enabled, images reserved by other users are
+// skipped and the selected image is reserved for username. A user who already
+// holds an unanswered lease on the task gets that image back.
+func (a *AnnotatorApp) NextAnnotationStep(ctx context.Context, taskID string, username string) (*AnnotationStep, error) {
+	return a.nextAnnotationStep(ctx, taskID, username, true)
+}
+
+// peekAnnotationStep is NextAnnotationStep without reserving a new image.
+// It tells where to go after an answer: the page showing the image reserves
+// it, and the annotation page, saving in the background, never shows it.
+func (a *AnnotatorApp) peekAnnotationStep(ctx context.Context, taskID string, username string) (*AnnotationStep, error) {
+	return a.nextAnnotationStep(ctx, taskID, username, false)
+}
+
+func (a *AnnotatorApp) nextAnnotationStep(ctx context.Context, taskID string, username string, reserve bool) (*AnnotationStep, error) {
+	ctx, span := tracing.Start(ctx, "NextAnnotationStep", attribute.String("rotulador.task", taskID))
+	defer span.End()
+
 	// If no task specified, try each task in order
 	if taskID == "" {
 		for _, task := range a.Config.Tasks {
-			step, err := a.NextAnnotationStep(ctx, task.ID)
+			step, err := a.nextAnnotationStep(ctx, task.ID, username, reserve)
 			if err != nil {
 				return nil, err
 			}
@@ -362,86 +253,167 @@ func (a *AnnotatorApp) NextAnnotationStep(ctx context.Context, taskID string) (*
 		return nil, fmt.Errorf("%w: %s", ErrTaskNotFound, taskID)
 	}
 
-	task := a.Config.Tasks[stageIndex]
-
-	// Pre-fetch all dependency data before looping (optimization: move queries outside loop)
-	imageHashesByDep := make(map[string]map[string]bool)
-	if len(task.If) > 0 {
-		var err error
-		imageHashesByDep, err = a.getDependencyImageHashes(ctx, task)
+	if username != "" && a.Config.Leases.Enabled() {
+		step, err := a.resumeLease(ctx, taskID, stageIndex, username)
 		if err != nil {
 			return nil, err
 		}
+		if step != nil {
+			return step, nil
+		}
 	}
 
-	// Get images without annotation for this stage (using cache)
-	allImages, err := a.getCachedImageList(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("while listing images: %w", err)
+	steps, err := a.selectSteps(ctx, taskID, username, 1, nil, reserve)
+	if err != nil || len(steps) == 0 {
+		return nil, err
+	}
+	return steps[0], nil
+}
+
+// selectSteps picks up to n random images still to be annotated on a task,
+// skipping the ones in exclude and the ones other users reserved. With
+// reserve and leases enabled for username each selected image is reserved
+// for them.
+func (a *AnnotatorApp) selectSteps(ctx context.Context, taskID string, username string, n int, exclude map[string]bool, reserve bool) ([]*AnnotationStep, error) {
+	ctx, span := tracing.Start(ctx, "selectSteps", attribute.String("rotulador.task", taskID))
+	defer span.End()
+
+	stageIndex := a.findTaskIndex(taskID)
+	if stageIndex == -1 {
+		return nil, fmt.Errorf("%w: %s", ErrTaskNotFound, taskID)
+	}
+	useLeases := username != "" && a.Config.Leases.Enabled()
+	if err := a.SyncTaskRules(ctx); err != nil {
+		return nil, err
 	}
 
-	// Filter images based on dependencies and annotation status
-	var candidateImages []string
-	for _, img := range allImages {
-		// Check if image already has annotation for this stage
-		hasAnnotation, err := a.annotationRepo.CheckAnnotationExists(ctx, img.SHA256, "", int64(stageIndex))
+	// Images reserved by other annotators are not candidates
+	leasedByOthers := make(map[string]bool)
+	if useLeases {
+		hashes, err := a.leaseRepo.GetLeasedByOthers(ctx, stageIndex, username)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("while listing leased images: %w", err)
 		}
-		if hasAnnotation {
-			continue // Skip images that already have annotation
+		for _, hash := range hashes {
+			leasedByOthers[hash] = true
 		}
+	}
 
-		// Check task dependencies (If field) using pre-fetched map
-		valid := true
-		if len(task.If) > 0 {
-			for depTaskID := range task.If {
-				if !imageHashesByDep[depTaskID][img.SHA256] {
-					valid = false
-					break
-				}
-			}
+	// Limit candidates to OffsetAdvance (plus the extra steps asked for),
+	// reading past the ones skipped below
+	want := a.OffsetAdvance + n - 1
+	available, err := a.progressRepo.ListAvailable(ctx, stageIndex, want+len(exclude)+len(leasedByOthers))
+	if err != nil {
+		return nil, fmt.Errorf("while listing available images: %w", err)
+	}
+	var candidateImages []*domain.Image
+	for _, img := range available {
+		if leasedByOthers[img.SHA256] || exclude[img.SHA256] {
+			continue
 		}
+		candidateImages = append(candidateImages, img)
+		if len(candidateImages) >= want {
+			break
+		}
+	}
 
-		if valid {
-			candidateImages = append(candidateImages, img.SHA256)
-			// Limit candidates to OffsetAdvance for performance
-			if len(candidateImages) >= a.OffsetAdvance {
-				break
+	// Randomly select images. With leases, another annotator may reserve a
+	// candidate between listing and acquiring, so fall through to the next
+	// one instead of serving a contested image.
+	rand.Shuffle(len(candidateImages), func(i, j int) {
+		candidateImages[i], candidateImages[j] = candidateImages[j], candidateImages[i]
+	})
+	var steps []*AnnotationStep
+	for _, selected := range candidateImages {
+		if len(steps) == n {
+			break
+		}
+		if useLeases && reserve {
+			lease, err := a.leaseRepo.Acquire(ctx, selected.SHA256, stageIndex, username, a.Config.Leases.Duration())
+			if err != nil {
+				return nil, fmt.Errorf("while reserving image: %w", err)
+			}
+			if lease == nil {
+				continue
 			}
 		}
+
+		steps = append(steps, &AnnotationStep{
+			TaskID:    taskID,
+			ImageID:   selected.SHA256,
+			ImageName: selected.Filename,
+		})
 	}
+	return steps, nil
+}
 
-	// No images available
-	if len(candidateImages) == 0 {
+// resumeLease returns the image username already holds on a stage, renewing
+// the lease, as long as it has not been annotated in the meantime.
+func (a *AnnotatorApp) resumeLease(ctx context.Context, taskID string, stageIndex int, username string) (*AnnotationStep, error) {
+	lease, err := a.leaseRepo.GetActiveForUser(ctx, username, stageIndex)
+	if err != nil {
+		return nil, fmt.Errorf("while looking up lease: %w", err)
+	}
+	if lease == nil {
 		return nil, nil
 	}
 
-	// Randomly select one image SHA256
-	selectedSHA256 := candidateImages[rand.Intn(len(candidateImages))]
+	hasAnnotation, err := a.annotationRepo.CheckAnnotationExists(ctx, lease.ImageSHA256, "", int64(stageIndex))
+	if err != nil {
+		return nil, err
+	}
+	if hasAnnotation {
+		if err := a.leaseRepo.Release(ctx, lease.ImageSHA256, stageIndex, username); err != nil {
+			return nil, fmt.Errorf("while releasing lease: %w", err)
+		}
+		return nil, nil
+	}
 
-	// Get image details
-	selectedImage, err := a.imageRepo.GetBySHA256(ctx, selectedSHA256)
+	img, err := a.imageRepo.GetBySHA256(ctx, lease.ImageSHA256)
 	if err != nil {
 		return nil, fmt.Errorf("while getting image details: %w", err)
 	}
+	if img == nil {
+		return nil, nil
+	}
+	if _, err := a.leaseRepo.Acquire(ctx, lease.ImageSHA256, stageIndex, username, a.Config.Leases.Duration()); err != nil {
+		return nil, fmt.Errorf("while renewing lease: %w", err)
+	}
 
 	return &AnnotationStep{
 		TaskID:    taskID,
-		ImageID:   selectedSHA256,
-		ImageName: selectedImage.Filename,
+		ImageID:   img.SHA256,
+		ImageName: img.Filename,
 	}, nil
 }
 
+// ReserveImage reserves an image for username on a task. It reports false when
+// another user holds an active lease on it. With leases disabled or no user it
+// always succeeds.
+func (a *AnnotatorApp) ReserveImage(ctx context.Context, taskID, imageID, username string) (bool, error) {
+	if username == "" || !a.Config.Leases.Enabled() {
+		return true, nil
+	}
+	stageIndex := a.findTaskIndex(taskID)
+	if stageIndex == -1 {
+		return false, fmt.Errorf("%w: %s", ErrTaskNotFound, taskID)
+	}
+	lease, err := a.leaseRepo.Acquire(ctx, imageID, stageIndex, username, a.Config.Leases.Duration())
+	if err != nil {
+		return false, fmt.Errorf("while reserving image: %w", err)
+	}
+	return lease != nil, nil
+}
+
 func (a *AnnotatorApp) GetImageFilename(ctx context.Context, sha256 string) (filename string, err error) {
 	// Get image from repository using SHA256 hash
 	img, err := a.imageRepo.GetBySHA256(ctx, sha256)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return "", fmt.Errorf("%w: %s", ErrImageNotFound, sha256)
-		}
 		return "", err
 	}
+	if img == nil {
+		return "", fmt.Errorf("%w: %s", ErrImageNotFound, sha256)
+	}
 
 	return img.Filename, nil
 }
@@ -452,9 +424,59 @@ type AnnotationResponse struct {
 	User    string
 	Value   string
 	Sure    bool
+	// Duration is the time-on-image measured by the page, zero when unknown
+	Duration time.Duration
+	// Source is where the answer came from for the history, one of the
+	// domain.EventSource constants; empty is domain.EventSourceWeb
+	Source string
 }
 
+// SubmitAnnotation records an answer and its event in the history.
 func (a *AnnotatorApp) SubmitAnnotation(ctx context.Context, annotation AnnotationResponse) error {
+	return a.SubmitAnnotations(ctx, []AnnotationResponse{annotation})
+}
+
+// SubmitAnnotations records a batch of annotations in a single transaction,
+// each exactly like SubmitAnnotation: either all of them are saved or none.
+func (a *AnnotatorApp) SubmitAnnotations(ctx context.Context, annotations []AnnotationResponse) error {
+	for _, annotation := range annotations {
+		if a.findTaskIndex(annotation.TaskID) == -1 {
+			return fmt.Errorf("%w: %s", ErrTaskNotFound, annotation.TaskID)
+		}
+	}
+	tx, err := a.Database.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("while starting transaction: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			ReportError(ctx, err, "msg", "failed to roll back annotation batch")
+		}
+	}()
+
+	annotationRepo := repository.NewAnnotationRepositoryWithTx(tx)
+	leaseRepo := repository.NewLeaseRepositoryWithTx(tx)
+	eventRepo := repository.NewEventRepositoryWithTx(tx)
+	reviewRepo := repository.NewReviewRepositoryWithTx(tx)
+	for _, annotation := range annotations {
+		if err := a.submitAnnotation(ctx, annotationRepo, leaseRepo, eventRepo, reviewRepo, annotation); err != nil {
+			return fmt.Errorf("while submitting annotation of '%s': %w", annotation.ImageID, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("while committing annotations: %w", err)
+	}
+	for _, annotation := range annotations {
+		source := annotation.Source
+		if source == "" {
+			source = domain.EventSourceWeb
+		}
+		annotationsSubmitted.WithLabelValues(annotation.TaskID, annotation.User, source).Inc()
+	}
+	return nil
+}
+
+func (a *AnnotatorApp) submitAnnotation(ctx context.Context, annotationRepo domain.AnnotationRepository, leaseRepo domain.LeaseRepository, eventRepo domain.EventRepository, reviewRepo domain.ReviewRepository, annotation AnnotationResponse) error {
 	// Find stage index for this task
 	stageIndex := a.findTaskIndex(annotation.TaskID)
 	if stageIndex == -1 {
@@ -462,14 +484,100 @@ func (a *AnnotatorApp) SubmitAnnotation(ctx context.Context, annotation Annotati
 	}
 
 	// ImageID is already the SHA256 hash, use it directly
-	_, err := a.annotationRepo.Create(ctx, annotation.ImageID, annotation.User, stageIndex, annotation.Value)
+	previous, err := annotationRepo.Get(ctx, annotation.ImageID, annotation.User, stageIndex)
+	if err != nil {
+		return fmt.Errorf("while getting previous annotation: %w", err)
+	}
+	ann, err := annotationRepo.Create(ctx, annotation.ImageID, annotation.User, stageIndex, annotation.Value, annotation.Sure, annotation.Duration)
 	if err != nil {
 		return fmt.Errorf("while creating annotation: %w", err)
 	}
 
+	event := &domain.AnnotationEvent{
+		Kind:         domain.EventCreate,
+		Source:       annotation.Source,
+		Actor:        annotation.User,
+		ImageSHA256:  ann.ImageSHA256,
+		StageIndex:   stageIndex,
+		Username:     ann.Username,
+		AnnotationID: ann.ID,
+		NewValue:     ann.OptionValue,
+	}
+	if event.Source == "" {
+		event.Source = domain.EventSourceWeb
+	}
+	if previous != nil {
+		event.Kind = domain.EventOverwrite
+		event.OldValue = previous.OptionValue
+	}
+	if _, err := eventRepo.Create(ctx, event); err != nil {
+		return fmt.Errorf("while recording annotation event: %w", err)
+	}
+
+	// The overwrite keeps the annotation id, so verdicts on the old answer
+	// would otherwise stick to the new one and override it
+	if previous != nil && previous.OptionValue != ann.OptionValue {
+		if _, err := reviewRepo.DeleteForAnnotation(ctx, ann.ID); err != nil {
+			return fmt.Errorf("while dropping reviews of the previous answer: %w", err)
+		}
+	}
+
+	// The image is answered for this stage; nobody needs it reserved anymore
+	if err := leaseRepo.ReleaseAny(ctx, annotation.ImageID, stageIndex); err != nil {
+		return fmt.Errorf("while releasing lease: %w", err)
+	}
+
 	return nil
 }
 
+// ErrInvalidElapsed is returned for a time-on-image that is not a
+// non-negative number of milliseconds.
+const ErrInvalidElapsed appError = "invalid elapsed time"
+
+// parseElapsed reads the elapsed_ms field the annotation pages send with an
+// answer. It is optional: older pages and scripts do not send it.
+func parseElapsed(v string) (time.Duration, error) {
+	if v == "" {
+		return 0, nil
+	}
+	ms, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || ms < 0 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidElapsed, v)
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// classButtons lists the classes of a task sorted by ID, the first nine
+// with keyboard shortcuts 1-9.
+func classButtons(task *ConfigTask) []pages.ClassButton {
+	classNames := make([]string, 0, len(task.Classes))
+	for class := range task.Classes {
+		classNames = append(classNames, class)
+	}
+	sort.Strings(classNames)
+
+	classes := []pages.ClassButton{}
+	keyIndex := 1
+	for _, className := range classNames {
+		classMeta := task.Classes[className]
+		key := ""
+		if keyIndex <= 9 {
+			key = fmt.Sprintf("%d", keyIndex)
+			keyIndex++
+		}
+		name := ""
+		if classMeta != nil {
+			name = classMeta.Name
+		}
+		classes = append(classes, pages.ClassButton{
+			ID:   className,
+			Name: name,
+			Key:  key,
+		})
+	}
+	return classes
+}
+
 func (a *AnnotatorApp) GetTask(taskID string) *ConfigTask {
 	for _, currentTask := range a.Config.Tasks {
 		if currentTask.ID == taskID {
@@ -546,6 +654,8 @@ func (a *AnnotatorApp) GetHTTPHandler() http.Handler {
 
 		err := Render(r.Context(), w, pages.Home(PageShell("Welcome to Rotulador"), pages.HomeData{
 			Description: a.Config.Meta.Description,
+			Ingest:      ingestStatusUI(a.IngestProgress()),
+			Leaderboard: a.Config.Stats.Leaderboard,
 		}))
 		if err != nil {
 			ReportError(r.Context(), err, "msg", "error rendering home template")
@@ -615,10 +725,12 @@ func (a *AnnotatorApp) GetHTTPHandler() http.Handler {
 	// Annotate pages
 	mux.HandleFunc("/annotate/", func(w http.ResponseWriter, r *http.Request) {
 		itemPath := pathParts(r.URL.Path)
+		// authenticationMiddleware already validated these credentials
+		user, _, _ := r.BasicAuth()
 
 		if len(itemPath) != 3 {
 			taskID := r.URL.Query().Get("task")
-			step, err := a.NextAnnotationStep(r.Context(), taskID)
+			step, err := a.NextAnnotationStep(r.Context(), taskID, user)
 			if err != nil {
 				ReportError(r.Context(), err, "msg", "error in annotate when getting next step from scratch")
 				w.WriteHeader(500)
@@ -635,6 +747,10 @@ func (a *AnnotatorApp) GetHTTPHandler() http.Handler {
 			return
 		}
 
+		if itemPath[2] == "grid" {
+			a.handleAnnotateGrid(w, r)
+			return
+		}
 		taskID := itemPath[1]
 		imageID := itemPath[2]
 		task := a.GetTask(taskID)
@@ -669,32 +785,40 @@ func (a *AnnotatorApp) GetHTTPHandler() http.Handler {
 			a.Logger.Debug("Selected class", "class", selectedClass, "empty", selectedClass == "", "valid", isClassValid)
 			sure := r.FormValue("sure") == "on"
 			a.Logger.Debug("Sure", "sure", sure)
-			user, _, ok := r.BasicAuth()
-			if !ok {
+			duration, err := parseElapsed(r.FormValue("elapsed_ms"))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if user == "" {
 				w.Header().Set("WWW-Authenticate", `Basic realm="rotulador"`)
 				w.WriteHeader(http.StatusUnauthorized)
 				return
 			}
-			err := a.SubmitAnnotation(r.Context(), AnnotationResponse{
-				ImageID: imageID,
-				TaskID:  taskID,
-				User:    user,
-				Value:   selectedClass,
-				Sure:    sure,
+			err = a.SubmitAnnotation(r.Context(), AnnotationResponse{
+				ImageID:  imageID,
+				TaskID:   taskID,
+				User:     user,
+				Value:    selectedClass,
+				Sure:     sure,
+				Duration: duration,
+				Source:   domain.EventSourceWeb,
 			})
 			if err != nil {
 				ReportError(r.Context(), err, "msg", "error while submitting annotation")
 				w.WriteHeader(http.StatusInternalServerError)
 				return
 			}
-			step, err := a.NextAnnotationStep(r.Context(), taskID)
+			// Only where to go next: the annotation page saves in the
+			// background and reserves the image it shows on its own
+			step, err := a.peekAnnotationStep(r.Context(), taskID, user)
 			if err != nil {
 				ReportError(r.Context(), err, "msg", "error while getting next step")
 				w.WriteHeader(http.StatusInternalServerError)
 				return
 			}
 			if step == nil {
-				step, err = a.NextAnnotationStep(r.Context(), "")
+				step, err = a.peekAnnotationStep(r.Context(), "", user)
 				if err != nil {
 					ReportError(r.Context(), err, "msg", "error while getting next step at the end of task")
 					w.WriteHeader(http.StatusInternalServerError)
@@ -711,50 +835,46 @@ func (a *AnnotatorApp) GetHTTPHandler() http.Handler {
 			return
 		}
 
-		// Build classes with keyboard shortcuts
-		classNames := make([]string, 0, len(task.Classes))
-		for class := range task.Classes {
-			classNames = append(classNames, class)
+		// Someone else is already looking at this image; hand out another one
+		reserved, err := a.ReserveImage(r.Context(), taskID, imageID, user)
+		if err != nil {
+			ReportError(r.Context(), err, "msg", "error reserving image", "sha256", imageID)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
 		}
-		sort.Strings(classNames)
-
-		classes := []pages.ClassButton{}
-		keyIndex := 1
-		for _, className := range classNames {
-			classMeta := task.Classes[className]
-			key := ""
-			if keyIndex <= 9 {
-				key = fmt.Sprintf("%d", keyIndex)
-				keyIndex++
-			}
-			name := ""
-			if classMeta != nil {
-				name = classMeta.Name
-			}
-			classes = append(classes, pages.ClassButton{
-				ID:   className,
-				Name: name,
-				Key:  key,
-			})
+		if !reserved {
+			http.Redirect(w, r, fmt.Sprintf("/annotate?task=%s", taskID), http.StatusSeeOther)
+			return
 		}
 
+		classes := classButtons(task)
+
 		phaseProgress, err := a.GetPhaseProgressStats(r.Context(), taskID)
 		if err != nil {
 			ReportError(r.Context(), err, "msg", "error getting phase progress")
 			phaseProgress = &PhaseProgress{}
 		}
 
+		// Loaded ahead so answering swaps to the next image without waiting
+		upcoming, err := a.UpcomingSteps(r.Context(), taskID, user, []string{imageID}, a.Config.Prefetch.Count())
+		if err != nil {
+			ReportError(r.Context(), err, "msg", "error getting upcoming steps")
+			upcoming = nil
+		}
+
 		err = Render(r.Context(), w, pages.Annotate(PageShell("annotation"), pages.AnnotateData{
 			TaskID:        taskID,
 			TaskName:      task.Name,
 			ImageID:       imageID,
 			ImageFilename: imageFilename,
+			ImageURL:      annotateAssetURL(imageID),
 			Classes:       classes,
 			PhaseProgress: ProgressUI(phaseProgress),
 			Progress: &pages.AnnotateProgress{
 				CompletedCount: phaseProgress.Completed,
 				TotalCount:     phaseProgress.Completed + phaseProgress.Pending,
 			},
+			Upcoming: upcomingUI(upcoming),
 		}))
 		if err != nil {
 			ReportError(r.Context(), err, "msg", "error rendering annotate template")
@@ -762,88 +882,82 @@ func (a *AnnotatorApp) GetHTTPHandler() http.Handler {
 		}
 	})
 
-	// Asset handler - serves images by SHA256 hash
-	mux.HandleFunc("/asset/", func(w http.ResponseWriter, r *http.Request) {
-		itemPath := pathParts(r.URL.Path)
-		if len(itemPath) != 2 {
-			http.NotFoundHandler().ServeHTTP(w, r)
-			return
-		}
-		sha256 := itemPath[1]
-		a.Logger.Debug("http: fetching asset", "sha256", sha256)
+	// Admin pages
+	mux.HandleFunc("/admin/leases", a.handleAdminLeases)
+	mux.HandleFunc("/admin/duplicates", a.handleAdminDuplicates)
+	mux.HandleFunc("/browse", a.handleBrowse)
+	mux.HandleFunc("/browse/", a.handleBrowse)
+	mux.HandleFunc("/review", a.handleReview)
+	mux.HandleFunc("/review/", a.handleReview)
+	mux.HandleFunc("/stats", a.handleStats)
+	mux.HandleFunc("/stats/", a.handleStats)
+	mux.HandleFunc("/api/images", a.handleAPIImages)
+	mux.HandleFunc("/api/annotate/", a.handleAnnotateAPI)
+	mux.HandleFunc("/healthz", a.handleHealthz)
+	mux.HandleFunc("/readyz", a.handleReadyz)
 
-		// Get image filename from repository
-		filename, err := a.GetImageFilename(r.Context(), sha256)
-		if err != nil {
-			a.Logger.Warn("http: asset was not found", "sha256", sha256, "err", err)
-			http.NotFoundHandler().ServeHTTP(w, r)
-			return
-		}
-
-		a.Logger.Debug("http: asset is", "sha256", sha256, "filename", filename)
-		fullPath, err := secureJoin(a.ImagesDir, filename)
-		if err != nil {
-			a.Logger.Warn("http: asset path security check failed", "sha256", sha256, "filename", filename, "err", err)
-			http.NotFoundHandler().ServeHTTP(w, r)
-			return
-		}
-		f, err := os.Open(fullPath)
-		if errors.Is(err, os.ErrNotExist) {
-			http.NotFoundHandler().ServeHTTP(w, r)
-			return
-		}
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			ReportError(r.Context(), err, "msg", "error: http: while serving image asset")
-			return
-		}
-		defer func() {
-			if err := f.Close(); err != nil {
-				ReportError(r.Context(), err, "msg", "failed to close asset file")
-			}
-		}()
-		if _, err := io.Copy(w, f); err != nil {
-			ReportError(r.Context(), err, "msg", "error: http: while copying image asset")
-		}
-	})
+	// Asset handler - serves images by SHA256 hash
+	mux.HandleFunc("/asset/", a.handleAsset)
 
 	a.Logger.Debug("images dir", "dir", a.ImagesDir)
 
 	var handler http.Handler = mux
 	loggerMiddleware := NewHTTPLogger(a.Logger)
 	handler = i18nMiddleware(handler)
+	handler = reportScopeMiddleware(mux, handler)
 	handler = loggerMiddleware.Middleware(handler)
 	handler = a.authenticationMiddleware(handler)
-	handler = requestCacheMiddleware(handler)
+	if a.Config.Metrics.Enabled && a.Config.Metrics.Listen == "" {
+		handler = a.withMetricsEndpoint(handler)
+	}
+	handler = metricsMiddleware(mux, handler)
+	handler = tracingMiddleware(mux, handler)
 	return handler
 }
 
 func (a *AnnotatorApp) authenticationMiddleware(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Load balancers and orchestrators probe without credentials
+		if r.URL.Path == "/healthz" || r.URL.Path == "/readyz" {
+			handler.ServeHTTP(w, r)
+			return
+		}
 		username, password, ok := r.BasicAuth()
 		if ok {
 			var item *ConfigAuth
 			item, ok = a.Config.Authentication[username]
 			if ok {
 				// SECURITY: Use bcrypt to compare the provided password with the stored hash.
-				if CheckPasswordHash(password, item.Password) {
+				// bcrypt is slow on purpose, so it gets a span of its own.
+				_, span := tracing.Start(r.Context(), "CheckPasswordHash")
+				valid := CheckPasswordHash(password, item.Password)
+				span.End()
+				if valid {
 					a.Logger.Info("auth for user: success", "username", username)
 					handler.ServeHTTP(w, r)
 					return
 				}
 				a.Logger.Warn("auth for user: bad password", "username", username)
+				authFailures.WithLabelValues("bad_password").Inc()
 			} else {
 				a.Logger.Warn("auth for user: no such user", "username", username)
+				authFailures.WithLabelValues("unknown_user").Inc()
 			}
 		} else {
 			a.Logger.Warn("auth: no credentials provided")
+			authFailures.WithLabelValues("no_credentials").Inc()
 		}
 		a.Logger.Warn("auth: not ok")
-		w.Header().Set("WWW-Authenticate", `Basic realm="restricted", charset="UTF-8"`)
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		unauthorized(w)
 	})
 }
 
+// unauthorized asks the browser for credentials.
+func unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="restricted", charset="UTF-8"`)
+	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+}
+
 // PrepareDatabase runs both database migrations and image ingestion synchronously.
 // For better startup performance, consider using PrepareDatabaseMigrations() synchronously
 // and IngestImages() asynchronously instead.
@@ -877,56 +991,11 @@ func (a *AnnotatorApp) PrepareDatabaseMigrations(ctx context.Context) error {
 	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
 		return err
 	}
+	a.migrated.Store(true)
 	a.Logger.Info("PrepareDatabaseMigrations: migrations completed successfully")
 	return nil
 }
 
-// IngestImages scans the images directory and loads all images into the database.
-// This can be called asynchronously after the HTTP server starts.
-func (a *AnnotatorApp) IngestImages(ctx context.Context) error {
-	a.Logger.Info("IngestImages: starting image ingestion from directory", "dir", a.ImagesDir)
-
-	err := filepath.WalkDir(a.ImagesDir, func(fullPath string, info fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if fullPath == a.ImagesDir {
-			return nil
-		}
-		if info.IsDir() {
-			return fmt.Errorf("while checking if item '%s' is a file: %w", fullPath, ErrDatasetNotFlat)
-		}
-
-		a.Logger.Debug("IngestImages: processing image", "path", fullPath)
-
-		// Verify it's an image
-		_, err = DecodeImage(fullPath)
-		if err != nil {
-			return fmt.Errorf("while checking if item '%s' is an image: %w", fullPath, err)
-		}
-
-		// Hash the file to get SHA256
-		fileHash, err := HashFile(fullPath)
-		if err != nil {
-			return fmt.Errorf("while hashing image '%s': %w", fullPath, err)
-		}
-
-		// Use repository to create image (with upsert behavior via ON CONFLICT)
-		_, err = a.imageRepo.Create(ctx, fileHash, info.Name())
-		if err != nil && !isSQLiteConstraint(err) {
-			return fmt.Errorf("while inserting image '%s': %w", fullPath, err)
-		}
-
-		return nil
-	})
-	if err != nil {
-		return fmt.Errorf("while ingesting images: %w", err)
-	}
-
-	a.Logger.Info("IngestImages: completed successfully!")
-	return nil
-}
-
 // isSQLiteConstraint reports whether err is a SQLite constraint violation
 // (including UNIQUE). Low 8 bits of the modernc code are SQLITE_CONSTRAINT (19).
 func isSQLiteConstraint(err error) bool {
@@ -938,6 +1007,7 @@ func isSQLiteConstraint(err error) bool {
 }
 
 // secureJoin joins baseDir and filename and ensures the result is within baseDir.
+// filename is slash-separated and may point into subdirectories.
 // It resolves baseDir to an absolute path to prevent traversal issues with relative paths.
 func secureJoin(baseDir, filename string) (string, error) {
 	absBase, err := filepath.Abs(baseDir)
@@ -945,7 +1015,7 @@ func secureJoin(baseDir, filename string) (string, error) {
 		return "", fmt.Errorf("invalid base directory: %w", err)
 	}
 
-	fullPath := filepath.Join(absBase, filename)
+	fullPath := filepath.Join(absBase, filepath.FromSlash(filename))
 
 	if !strings.HasPrefix(fullPath, absBase+string(os.PathSeparator)) {
 		return "", fmt.Errorf("%w: %s", ErrPathTraversal, filename)
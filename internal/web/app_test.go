@@ -2,9 +2,16 @@ package web
 
 import (
 	"errors"
+	"image"
+	"image/png"
+	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/lewtec/rotulador/internal/repository"
 )
 
 func TestErrTaskNotFoundSentinel(t *testing.T) {
@@ -21,7 +28,7 @@ func TestErrTaskNotFoundSentinel(t *testing.T) {
 		t.Fatalf("CountAvailableImages: got %v, want ErrTaskNotFound", err)
 	}
 
-	_, err = a.NextAnnotationStep(ctx, "missing-task")
+	_, err = a.NextAnnotationStep(ctx, "missing-task", "")
 	if !errors.Is(err, ErrTaskNotFound) {
 		t.Fatalf("NextAnnotationStep: got %v, want ErrTaskNotFound", err)
 	}
@@ -53,3 +60,183 @@ func TestSecureJoinPathTraversalSentinel(t *testing.T) {
 		t.Fatalf("secureJoin: got %v, want ErrPathTraversal", err)
 	}
 }
+
+// newTestApp builds an AnnotatorApp over a migrated in-memory database.
+func newTestApp(t *testing.T, cfg *Config) *AnnotatorApp {
+	t.Helper()
+	db := repository.SetupTestDB(t)
+	t.Cleanup(func() { repository.CleanupTestDB(t, db) })
+	a := &AnnotatorApp{
+		ImagesDir: t.TempDir(),
+		Database:  db,
+		Config:    cfg,
+		Logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	a.init()
+	return a
+}
+
+func TestNextAnnotationStepLeases(t *testing.T) {
+	a := newTestApp(t, &Config{
+		Tasks: []*ConfigTask{{ID: "quality", Classes: map[string]*ConfigClass{"good": {}}}},
+	})
+	ctx := t.Context()
+	for _, hash := range []string{"hash1", "hash2"} {
+		if _, err := a.imageRepo.Create(ctx, hash, hash+".png"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	alice, err := a.NextAnnotationStep(ctx, "quality", "alice")
+	if err != nil || alice == nil {
+		t.Fatalf("alice step = %v, err = %v", alice, err)
+	}
+	bob, err := a.NextAnnotationStep(ctx, "quality", "bob")
+	if err != nil || bob == nil {
+		t.Fatalf("bob step = %v, err = %v", bob, err)
+	}
+	if alice.ImageID == bob.ImageID {
+		t.Fatalf("alice and bob were both served %s", alice.ImageID)
+	}
+
+	// Reloading hands alice her reserved image back
+	again, err := a.NextAnnotationStep(ctx, "quality", "alice")
+	if err != nil || again == nil || again.ImageID != alice.ImageID {
+		t.Fatalf("alice reload = %v, err = %v, want %s", again, err, alice.ImageID)
+	}
+
+	// Nothing left for a third user while both images are reserved
+	carol, err := a.NextAnnotationStep(ctx, "quality", "carol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if carol != nil {
+		t.Fatalf("carol step = %+v, want nil", carol)
+	}
+
+	// Submitting releases the lease and the image leaves the queue
+	if err := a.SubmitAnnotation(ctx, AnnotationResponse{ImageID: alice.ImageID, TaskID: "quality", User: "alice", Value: "good"}); err != nil {
+		t.Fatal(err)
+	}
+	leases, err := a.leaseRepo.ListActive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leases) != 1 || leases[0].Username != "bob" {
+		t.Fatalf("active leases = %+v, want only bob's", leases)
+	}
+}
+
+func TestNextAnnotationStepLeasesDisabled(t *testing.T) {
+	a := newTestApp(t, &Config{
+		Tasks:  []*ConfigTask{{ID: "quality", Classes: map[string]*ConfigClass{"good": {}}}},
+		Leases: ConfigLeases{Minutes: -1},
+	})
+	ctx := t.Context()
+	if _, err := a.imageRepo.Create(ctx, "hash1", "hash1.png"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, user := range []string{"alice", "bob"} {
+		step, err := a.NextAnnotationStep(ctx, "quality", user)
+		if err != nil || step == nil || step.ImageID != "hash1" {
+			t.Fatalf("%s step = %v, err = %v, want hash1", user, step, err)
+		}
+	}
+}
+
+// writePNG encodes img as a PNG file at path.
+func writePNG(t *testing.T, path string, img image.Image) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIngestImagesStoresPerceptualHash(t *testing.T) {
+	a := newTestApp(t, &Config{})
+	ctx := t.Context()
+	writePNG(t, filepath.Join(a.ImagesDir, "big.png"), gradient(360, 240, 0))
+	writePNG(t, filepath.Join(a.ImagesDir, "small.png"), gradient(90, 60, 0))
+
+	if err := a.IngestImages(ctx); err != nil {
+		t.Fatalf("IngestImages: %v", err)
+	}
+
+	groups, err := ListNearDuplicates(ctx, a.Database, DefaultDuplicateDistance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || len(groups[0]) != 2 {
+		t.Fatalf("groups = %v, want the two resized copies grouped", groups)
+	}
+}
+
+func TestParseElapsed(t *testing.T) {
+	for v, want := range map[string]time.Duration{"": 0, "0": 0, "1250": 1250 * time.Millisecond} {
+		if got, err := parseElapsed(v); err != nil || got != want {
+			t.Errorf("parseElapsed(%q) = %s, %v; want %s", v, got, err, want)
+		}
+	}
+	for _, v := range []string{"-5", "1.5", "soon"} {
+		if _, err := parseElapsed(v); !errors.Is(err, ErrInvalidElapsed) {
+			t.Errorf("parseElapsed(%q) error = %v, want ErrInvalidElapsed", v, err)
+		}
+	}
+}
+
+func TestProgressFollowsTaskChanges(t *testing.T) {
+	tasks := func(value string) *Config {
+		return &Config{Tasks: []*ConfigTask{
+			{ID: "quality", Classes: map[string]*ConfigClass{"good": {}, "bad": {}}},
+			{ID: "detail", If: map[string]string{"quality": value}, Classes: map[string]*ConfigClass{"yes": {}}},
+		}}
+	}
+	a := newTestApp(t, tasks("good"))
+	ctx := t.Context()
+	for _, hash := range []string{"hash1", "hash2", "hash3"} {
+		if _, err := a.imageRepo.Create(ctx, hash, hash+".png"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for hash, value := range map[string]string{"hash1": "good", "hash2": "bad"} {
+		if err := a.SubmitAnnotation(ctx, AnnotationResponse{ImageID: hash, TaskID: "quality", User: "alice", Value: value}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	progress, err := a.GetPhaseProgressStats(ctx, "detail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress.Pending != 1 || progress.FilteredWrongClass != 1 || progress.NotYetAnnotated != 1 || progress.Total != 3 {
+		t.Errorf("detail progress = %+v, want 1 pending, 1 filtered, 1 not yet annotated", progress)
+	}
+
+	// Restarting with another If clause recomputes the progress
+	restarted := &AnnotatorApp{ImagesDir: a.ImagesDir, Database: a.Database, Config: tasks("bad"), Logger: a.Logger}
+	restarted.init()
+	step, err := restarted.NextAnnotationStep(ctx, "detail", "")
+	if err != nil || step == nil || step.ImageID != "hash2" || step.ImageName != "hash2.png" {
+		t.Fatalf("detail step after the change = %+v, %v; want hash2", step, err)
+	}
+	if err := restarted.SubmitAnnotation(ctx, AnnotationResponse{ImageID: "hash2", TaskID: "detail", User: "alice", Value: "yes"}); err != nil {
+		t.Fatal(err)
+	}
+	progress, err = restarted.GetPhaseProgressStats(ctx, "detail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress.Completed != 1 || progress.Pending != 0 || progress.FilteredWrongClass != 1 {
+		t.Errorf("detail progress after the change = %+v, want 1 completed and 1 filtered", progress)
+	}
+}
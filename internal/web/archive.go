@@ -0,0 +1,280 @@
+package web
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// Archive errors. Member errors are passed to the WalkArchive callback, which
+// decides whether to skip the member; archive errors abort the walk.
+const (
+	ErrArchiveMemberTooLarge appError = "archive member exceeds the size limit"
+	ErrArchiveRatio          appError = "archive compression ratio exceeds the limit"
+	ErrArchiveTooLarge       appError = "archive exceeds the total size limit"
+	ErrUnsupportedArchive    appError = "unsupported archive format"
+)
+
+// ArchiveLimits protects WalkArchive against decompression bombs.
+type ArchiveLimits struct {
+	// MaxMemberBytes is the largest member that is read into memory
+	MaxMemberBytes int64
+	// MaxTotalBytes bounds the bytes extracted from a single archive
+	MaxTotalBytes int64
+	// MaxRatio bounds uncompressed/compressed size. Images are already
+	// compressed, so a high ratio means the archive is not what it claims.
+	MaxRatio int64
+}
+
+// DefaultArchiveLimits are used by 'rotulador ingest'.
+var DefaultArchiveLimits = ArchiveLimits{
+	MaxMemberBytes: 256 << 20,
+	MaxTotalBytes:  64 << 30,
+	MaxRatio:       100,
+}
+
+// ratioSlack lets small, highly compressible members (tiny PNGs, text files)
+// through the ratio check.
+const ratioSlack = 1 << 20
+
+// IsArchive reports whether path names an archive WalkArchive can read.
+func IsArchive(path string) bool {
+	return archiveKind(path) != ""
+}
+
+func archiveKind(name string) string {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return "zip"
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return "tar.gz"
+	case strings.HasSuffix(name, ".tar"):
+		return "tar"
+	}
+	return ""
+}
+
+// ArchiveWalkFunc is called for every regular file in an archive with its
+// cleaned member path and content. When the member was rejected (unsafe path,
+// size or ratio limit) data is nil and err says why; returning nil skips it.
+// A non-nil return value stops the walk.
+type ArchiveWalkFunc func(member string, data []byte, err error) error
+
+// WalkArchive streams the members of a zip, tar or tar.gz archive to fn
+// without unpacking them to disk. Directories, links and other special
+// members are ignored.
+func WalkArchive(archivePath string, limits ArchiveLimits, fn ArchiveWalkFunc) error {
+	switch archiveKind(archivePath) {
+	case "zip":
+		return walkZip(archivePath, limits, fn)
+	case "tar", "tar.gz":
+		return walkTar(archivePath, limits, fn)
+	}
+	return fmt.Errorf("%w: %s", ErrUnsupportedArchive, archivePath)
+}
+
+// cleanMemberPath rejects member names that would escape the archive root
+// if extracted (zip-slip), so they are never trusted as relative paths.
+func cleanMemberPath(name string) (string, error) {
+	name = strings.ReplaceAll(name, `\`, "/")
+	if path.IsAbs(name) || (len(name) > 1 && name[1] == ':') {
+		return "", fmt.Errorf("%w: %s", ErrPathTraversal, name)
+	}
+	clean := path.Clean(name)
+	if clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("%w: %s", ErrPathTraversal, name)
+	}
+	return clean, nil
+}
+
+// readMember reads at most limit bytes of r; tooBig is returned when r holds more.
+func readMember(r io.Reader, limit int64, tooBig error) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, tooBig
+	}
+	return data, nil
+}
+
+func walkZip(archivePath string, limits ArchiveLimits, fn ArchiveWalkFunc) error {
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return fmt.Errorf("while opening '%s': %w", archivePath, err)
+	}
+	defer func() {
+		if err := zr.Close(); err != nil {
+			ReportError(context.Background(), err, "msg", "failed to close archive", "path", archivePath)
+		}
+	}()
+
+	var total int64
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		member, err := cleanMemberPath(f.Name)
+		if err != nil {
+			if err := fn(f.Name, nil, err); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// The sizes in the header can lie, so they only pick the limit;
+		// readMember enforces it on the bytes actually inflated.
+		limit, tooBig := limits.MaxMemberBytes, error(ErrArchiveMemberTooLarge)
+		if byRatio := int64(f.CompressedSize64)*limits.MaxRatio + ratioSlack; byRatio < limit {
+			limit, tooBig = byRatio, ErrArchiveRatio
+		}
+		data, err := readZipMember(f, limit, tooBig)
+		if errors.Is(err, ErrArchiveMemberTooLarge) || errors.Is(err, ErrArchiveRatio) {
+			if err := fn(member, nil, fmt.Errorf("%w: %s", err, member)); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("while reading '%s' from '%s': %w", member, archivePath, err)
+		}
+
+		total += int64(len(data))
+		if total > limits.MaxTotalBytes {
+			return fmt.Errorf("%w: %s", ErrArchiveTooLarge, archivePath)
+		}
+		if err := fn(member, data, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readZipMember(f *zip.File, limit int64, tooBig error) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rc.Close(); err != nil {
+			ReportError(context.Background(), err, "msg", "failed to close archive member", "member", f.Name)
+		}
+	}()
+	return readMember(rc, limit, tooBig)
+}
+
+// countingReader counts the compressed bytes consumed by a decompressor.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// ratioReader fails with ErrArchiveRatio as soon as the bytes inflated
+// through it exceed maxRatio times the compressed bytes consumed, so a small
+// archive never inflates a whole member before being rejected.
+type ratioReader struct {
+	r          io.Reader
+	compressed *countingReader
+	maxRatio   int64
+	n          int64
+}
+
+func (r *ratioReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	if r.n > r.compressed.n*r.maxRatio+ratioSlack {
+		return n, ErrArchiveRatio
+	}
+	return n, err
+}
+
+func walkTar(archivePath string, limits ArchiveLimits, fn ArchiveWalkFunc) error {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			ReportError(context.Background(), err, "msg", "failed to close archive", "path", archivePath)
+		}
+	}()
+
+	compressed := &countingReader{r: f}
+	var r io.Reader = compressed
+	if archiveKind(archivePath) == "tar.gz" {
+		gz, err := gzip.NewReader(compressed)
+		if err != nil {
+			return fmt.Errorf("while opening '%s': %w", archivePath, err)
+		}
+		defer func() {
+			if err := gz.Close(); err != nil {
+				ReportError(context.Background(), err, "msg", "failed to close archive", "path", archivePath)
+			}
+		}()
+		r = gz
+	}
+
+	// The ratio counts everything inflated, headers and skipped members too
+	tr := tar.NewReader(&ratioReader{r: r, compressed: compressed, maxRatio: limits.MaxRatio})
+	var total int64
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if errors.Is(err, ErrArchiveRatio) {
+			return fmt.Errorf("%w: %s", ErrArchiveRatio, archivePath)
+		}
+		if err != nil {
+			return fmt.Errorf("while reading '%s': %w", archivePath, err)
+		}
+		// Also regular files of legacy writers, flagged TypeRegA
+		if !hdr.FileInfo().Mode().IsRegular() {
+			continue
+		}
+		member, err := cleanMemberPath(hdr.Name)
+		if err != nil {
+			if err := fn(hdr.Name, nil, err); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Skipped members are still inflated by tar.Next, so they count
+		// towards the total as well
+		data, err := readMember(tr, limits.MaxMemberBytes, ErrArchiveMemberTooLarge)
+		total += int64(len(data))
+		if errors.Is(err, ErrArchiveRatio) {
+			return fmt.Errorf("%w: %s", ErrArchiveRatio, archivePath)
+		} else if errors.Is(err, ErrArchiveMemberTooLarge) {
+			total += hdr.Size
+			err = fn(member, nil, fmt.Errorf("%w: %s", err, member))
+		} else if err != nil {
+			return fmt.Errorf("while reading '%s' from '%s': %w", member, archivePath, err)
+		} else {
+			err = fn(member, data, nil)
+		}
+		if err != nil {
+			return err
+		}
+
+		if total > limits.MaxTotalBytes {
+			return fmt.Errorf("%w: %s", ErrArchiveTooLarge, archivePath)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package web
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// archiveMember is a file to put in a test archive
+type archiveMember struct {
+	name string
+	data []byte
+}
+
+func pngBytes(t *testing.T, seed int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, gradient(16, 16, seed)); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func writeZip(t *testing.T, path string, members []archiveMember) {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, m := range members {
+		w, err := zw.Create(m.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(m.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeTarGz(t *testing.T, path string, members []archiveMember) {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, m := range members {
+		if err := tw.WriteHeader(&tar.Header{Name: m.name, Mode: 0o644, Size: int64(len(m.data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(m.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// walkResult collects what WalkArchive passed to its callback
+type walkResult struct {
+	read    map[string][]byte
+	skipped map[string]error
+}
+
+func walkAll(t *testing.T, path string, limits ArchiveLimits) (walkResult, error) {
+	t.Helper()
+	res := walkResult{read: map[string][]byte{}, skipped: map[string]error{}}
+	err := WalkArchive(path, limits, func(member string, data []byte, err error) error {
+		if err != nil {
+			res.skipped[member] = err
+			return nil
+		}
+		res.read[member] = data
+		return nil
+	})
+	return res, err
+}
+
+func TestWalkArchive(t *testing.T) {
+	img := pngBytes(t, 0)
+	members := []archiveMember{
+		{"photos/a.png", img},
+		{"notes.txt", []byte("not an image")},
+		{"../../evil.png", img},
+		{"photos/./b.png", img},
+	}
+	dir := t.TempDir()
+	zipPath := filepath.Join(dir, "dump.zip")
+	tgzPath := filepath.Join(dir, "dump.tar.gz")
+	writeZip(t, zipPath, members)
+	writeTarGz(t, tgzPath, members)
+
+	for _, path := range []string{zipPath, tgzPath} {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			res, err := walkAll(t, path, DefaultArchiveLimits)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(res.read) != 3 || !bytes.Equal(res.read["photos/a.png"], img) || res.read["photos/b.png"] == nil || res.read["notes.txt"] == nil {
+				t.Errorf("read members %v, want photos/a.png, photos/b.png and notes.txt", keys(res.read))
+			}
+			if err := res.skipped["../../evil.png"]; !errors.Is(err, ErrPathTraversal) {
+				t.Errorf("zip-slip member error = %v, want ErrPathTraversal", err)
+			}
+		})
+	}
+}
+
+func TestWalkArchiveLimits(t *testing.T) {
+	// Zeros compress extremely well, like a decompression bomb
+	bomb := make([]byte, 4<<20)
+	img := pngBytes(t, 1)
+	dir := t.TempDir()
+
+	t.Run("zip member ratio", func(t *testing.T) {
+		path := filepath.Join(dir, "bomb.zip")
+		writeZip(t, path, []archiveMember{{"bomb.png", bomb}, {"ok.png", img}})
+		res, err := walkAll(t, path, DefaultArchiveLimits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !errors.Is(res.skipped["bomb.png"], ErrArchiveRatio) || res.read["ok.png"] == nil {
+			t.Errorf("read %v, skipped %v; want bomb.png skipped for its ratio", keys(res.read), res.skipped)
+		}
+	})
+
+	t.Run("tar.gz stream ratio", func(t *testing.T) {
+		path := filepath.Join(dir, "bomb.tar.gz")
+		writeTarGz(t, path, []archiveMember{{"bomb.png", bomb}, {"ok.png", img}})
+		res, err := walkAll(t, path, DefaultArchiveLimits)
+		if !errors.Is(err, ErrArchiveRatio) {
+			t.Errorf("WalkArchive error = %v, want ErrArchiveRatio", err)
+		}
+		// The walk stops while inflating, before the bomb is read whole
+		if len(res.read) != 0 || len(res.skipped) != 0 {
+			t.Errorf("read %v, skipped %v; want the walk stopped inside bomb.png", keys(res.read), res.skipped)
+		}
+	})
+
+	t.Run("member size", func(t *testing.T) {
+		path := filepath.Join(dir, "big.tar.gz")
+		writeTarGz(t, path, []archiveMember{{"big.png", img}, {"small.txt", []byte("x")}})
+		limits := DefaultArchiveLimits
+		limits.MaxMemberBytes = 16
+		res, err := walkAll(t, path, limits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !errors.Is(res.skipped["big.png"], ErrArchiveMemberTooLarge) || res.read["small.txt"] == nil {
+			t.Errorf("read %v, skipped %v; want big.png skipped for its size", keys(res.read), res.skipped)
+		}
+	})
+
+	t.Run("total size", func(t *testing.T) {
+		path := filepath.Join(dir, "many.zip")
+		writeZip(t, path, []archiveMember{{"1.png", img}, {"2.png", img}})
+		limits := DefaultArchiveLimits
+		limits.MaxTotalBytes = int64(len(img)) + 1
+		if _, err := walkAll(t, path, limits); !errors.Is(err, ErrArchiveTooLarge) {
+			t.Errorf("WalkArchive error = %v, want ErrArchiveTooLarge", err)
+		}
+	})
+}
+
+func TestWalkArchiveLegacyRegularFiles(t *testing.T) {
+	img := pngBytes(t, 2)
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{Name: "old.png", Mode: 0o644, Size: int64(len(img)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write(img); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Older writers flag regular files with a NUL byte; the header checksum,
+	// six octal digits, NUL and space, sums its bytes with itself as spaces
+	header := buf.Bytes()[:512]
+	header[156] = 0
+	copy(header[148:156], "        ")
+	var sum int
+	for _, b := range header {
+		sum += int(b)
+	}
+	copy(header[148:156], fmt.Sprintf("%06o\x00 ", sum))
+	path := filepath.Join(t.TempDir(), "legacy.tar")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := walkAll(t, path, DefaultArchiveLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(res.read["old.png"], img) {
+		t.Errorf("read members %v, want old.png", keys(res.read))
+	}
+}
+
+func TestInspectImageBytesRejectsHugeCanvas(t *testing.T) {
+	// A valid PNG header declaring 65535x65535 pixels with no pixel data
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, gradient(1, 1, 0)); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	// IHDR width and height follow the 8-byte signature, length and type
+	copy(data[16:24], []byte{0, 0, 0xff, 0xff, 0, 0, 0xff, 0xff})
+	binary.BigEndian.PutUint32(data[29:33], crc32.ChecksumIEEE(data[12:29]))
+	if _, _, err := InspectImageBytes(data); !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("InspectImageBytes error = %v, want ErrImageTooLarge", err)
+	}
+}
+
+func keys(m map[string][]byte) []string {
+	var out []string
+	for k := range m {
+		out = append(out, k)
+	}
+	return out
+}
@@ -0,0 +1,242 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/lewtec/rotulador/internal/domain"
+	"github.com/lewtec/rotulador/internal/storage"
+	"golang.org/x/image/draw"
+)
+
+// ThumbnailWidth is the variant used by gallery views.
+const ThumbnailWidth = 256
+
+// variantWidths are the widths resized variants are rendered at. A requested
+// width is rounded up to the next one, so arbitrary ?w= values cannot fill
+// the cache; above the largest the original is served.
+var variantWidths = []int{ThumbnailWidth, 512, 1024, 2048}
+
+// variantJPEGQuality is used for variants of JPEG images.
+const variantJPEGQuality = 85
+
+// assetCacheControl lets browsers keep assets forever: an asset URL names
+// the content hash, so it never changes. Assets are behind authentication,
+// hence private.
+const assetCacheControl = "private, max-age=31536000, immutable"
+
+// ErrInvalidAssetWidth is returned for a ?w= that is not a positive integer.
+const ErrInvalidAssetWidth appError = "invalid asset width"
+
+// thumbnailURL is the URL of the gallery thumbnail of an image.
+func thumbnailURL(sha256 string) string {
+	return fmt.Sprintf("/asset/%s?w=%d", sha256, ThumbnailWidth)
+}
+
+// variantWidth rounds a requested width up to one of variantWidths; 0 means
+// the original.
+func variantWidth(query string) (int, error) {
+	if query == "" {
+		return 0, nil
+	}
+	w, err := strconv.Atoi(query)
+	if err != nil || w <= 0 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAssetWidth, query)
+	}
+	for _, width := range variantWidths {
+		if w <= width {
+			return width, nil
+		}
+	}
+	return 0, nil
+}
+
+// handleAsset serves an image file by its SHA256 hash. With ?w= a variant
+// no wider than that is served from the asset cache, rendering it first if
+// needed. Formats browsers cannot display are always served as PNG.
+func (a *AnnotatorApp) handleAsset(w http.ResponseWriter, r *http.Request) {
+	itemPath := pathParts(r.URL.Path)
+	if len(itemPath) != 2 {
+		http.NotFoundHandler().ServeHTTP(w, r)
+		return
+	}
+	sha256 := itemPath[1]
+	width, err := variantWidth(r.URL.Query().Get("w"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	a.Logger.Debug("http: fetching asset", "sha256", sha256, "width", width)
+
+	img, err := a.imageRepo.GetBySHA256(r.Context(), sha256)
+	if err != nil {
+		a.Logger.Warn("http: asset was not found", "sha256", sha256, "err", err)
+		http.NotFoundHandler().ServeHTTP(w, r)
+		return
+	}
+	if img == nil {
+		http.NotFoundHandler().ServeHTTP(w, r)
+		return
+	}
+	filename := img.Filename
+	a.Logger.Debug("http: asset is", "sha256", sha256, "filename", filename)
+
+	if url := a.presignedAssetURL(r.Context(), img, width); url != "" {
+		// Revalidate well before the URL expires
+		maxAge := a.Config.Storage.PresignExpiry() / 2
+		w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(maxAge.Seconds())))
+		http.Redirect(w, r, url, http.StatusFound)
+		return
+	}
+
+	f, err := a.Store.Open(r.Context(), filename)
+	if errors.Is(err, fs.ErrInvalid) {
+		a.Logger.Warn("http: asset path security check failed", "sha256", sha256, "filename", filename, "err", err)
+		http.NotFoundHandler().ServeHTTP(w, r)
+		return
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		http.NotFoundHandler().ServeHTTP(w, r)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		ReportError(r.Context(), err, "msg", "error: http: while serving image asset")
+		return
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			ReportError(r.Context(), err, "msg", "failed to close asset file")
+		}
+	}()
+	modTime := f.Info().ModTime
+
+	config, format, err := image.DecodeConfig(f)
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		ReportError(r.Context(), err, "msg", "error: http: while reading image asset", "sha256", sha256)
+		return
+	}
+
+	// Never upscale: a variant at least as wide as the image is the image
+	if width >= config.Width {
+		width = 0
+	}
+	if width == 0 && IsBrowserFormat(format) {
+		w.Header().Set("Content-Type", "image/"+format)
+		w.Header().Set("ETag", fmt.Sprintf("%q", sha256))
+		w.Header().Set("Cache-Control", assetCacheControl)
+		http.ServeContent(w, r, filename, modTime, f)
+		return
+	}
+
+	content, err := a.assetVariant(r.Context(), sha256, f, format, width)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		ReportError(r.Context(), err, "msg", "error: http: while rendering image variant", "sha256", sha256, "width", width)
+		return
+	}
+	w.Header().Set("Content-Type", "image/"+variantFormat(format))
+	w.Header().Set("ETag", fmt.Sprintf(`"%s-w%d"`, sha256, width))
+	w.Header().Set("Cache-Control", assetCacheControl)
+	http.ServeContent(w, r, "", modTime, bytes.NewReader(content))
+}
+
+// presignedAssetURL returns a presigned URL of the original of img when
+// storage.presign_minutes is set and the store can presign, empty otherwise.
+// Variants and formats browsers cannot display still go through the server,
+// and so do images whose format or width was never recorded.
+func (a *AnnotatorApp) presignedAssetURL(ctx context.Context, img *domain.Image, width int) string {
+	expiry := a.Config.Storage.PresignExpiry()
+	presigner, ok := a.Store.(storage.Presigner)
+	if expiry == 0 || !ok || !IsBrowserFormat(img.Format) || img.Width == 0 || (width > 0 && width < img.Width) {
+		return ""
+	}
+	url, err := presigner.PresignGet(img.Filename, expiry)
+	if err != nil {
+		ReportError(ctx, err, "msg", "failed to presign asset URL", "sha256", img.SHA256)
+		return ""
+	}
+	return url
+}
+
+// variantFormat is the format variants of a format-encoded image are stored
+// in: JPEG stays JPEG, everything else becomes PNG to keep transparency.
+func variantFormat(format string) string {
+	if format == "jpeg" {
+		return "jpeg"
+	}
+	return "png"
+}
+
+// assetVariant returns the variant of the image read from src. Width 0 converts the image without resizing. Variants are
+// cached under CacheDir when set.
+func (a *AnnotatorApp) assetVariant(ctx context.Context, sha256 string, src io.Reader, format string, width int) ([]byte, error) {
+	var cached string
+	if a.CacheDir != "" {
+		name := fmt.Sprintf("%s-w%d.%s", sha256, width, formatExtension(variantFormat(format)))
+		cached = filepath.Join(a.CacheDir, "assets", sha256[:min(2, len(sha256))], name)
+		if content, err := os.ReadFile(cached); err == nil {
+			return content, nil
+		}
+	}
+
+	img, _, err := image.Decode(src)
+	if err != nil {
+		return nil, fmt.Errorf("while decoding image '%s': %w", sha256, err)
+	}
+	if b := img.Bounds(); width > 0 && width < b.Dx() {
+		height := max(1, b.Dy()*width/b.Dx())
+		resized := image.NewRGBA(image.Rect(0, 0, width, height))
+		draw.CatmullRom.Scale(resized, resized.Bounds(), img, b, draw.Src, nil)
+		img = resized
+	}
+	var buf bytes.Buffer
+	if variantFormat(format) == "jpeg" {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: variantJPEGQuality})
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("while encoding variant of '%s': %w", sha256, err)
+	}
+
+	// A variant that cannot be cached is still served
+	if cached != "" {
+		if err := writeCacheFile(cached, buf.Bytes()); err != nil {
+			ReportError(ctx, err, "msg", "failed to cache asset variant", "path", cached)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// writeCacheFile writes data to path through a temporary file, so concurrent
+// requests rendering the same variant never read a partial file.
+func writeCacheFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tempFile := filepath.Join(filepath.Dir(path), fmt.Sprintf(".%s.tmp", uuid.New()))
+	if err := os.WriteFile(tempFile, data, 0o644); err != nil {
+		return errors.Join(err, os.Remove(tempFile))
+	}
+	if err := os.Rename(tempFile, path); err != nil {
+		return errors.Join(err, os.Remove(tempFile))
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+package web
+
+import (
+	"bytes"
+	"errors"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lewtec/rotulador/internal/storage"
+	"golang.org/x/image/tiff"
+)
+
+// getAsset requests target from handleAsset with optional header pairs.
+func getAsset(a *AnnotatorApp, target string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	a.handleAsset(rec, req)
+	return rec
+}
+
+func TestHandleAssetOriginal(t *testing.T) {
+	a := newTestApp(t, &Config{})
+	path := filepath.Join(a.ImagesDir, "photo.png")
+	writePNG(t, path, gradient(12, 8, 1))
+	if _, err := a.imageRepo.Create(t.Context(), "pngsha", "photo.png"); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := getAsset(a, "/asset/pngsha")
+	if rec.Code != http.StatusOK || !bytes.Equal(rec.Body.Bytes(), stored) {
+		t.Fatalf("status %d; want the file byte for byte", rec.Code)
+	}
+	for header, want := range map[string]string{
+		"Content-Type":  "image/png",
+		"ETag":          `"pngsha"`,
+		"Cache-Control": assetCacheControl,
+		"Accept-Ranges": "bytes",
+	} {
+		if got := rec.Header().Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+
+	if rec := getAsset(a, "/asset/pngsha", "If-None-Match", `"pngsha"`); rec.Code != http.StatusNotModified {
+		t.Errorf("conditional request status = %d, want 304", rec.Code)
+	}
+	rec = getAsset(a, "/asset/pngsha", "Range", "bytes=0-7")
+	if rec.Code != http.StatusPartialContent || !bytes.Equal(rec.Body.Bytes(), stored[:8]) {
+		t.Errorf("range request status = %d, body %q; want the PNG signature", rec.Code, rec.Body.Bytes())
+	}
+	// Asking for a variant wider than the image serves the image itself
+	if rec := getAsset(a, "/asset/pngsha?w=1024"); rec.Header().Get("ETag") != `"pngsha"` {
+		t.Errorf("ETag = %q, want the original", rec.Header().Get("ETag"))
+	}
+}
+
+func TestUnknownImageNotFound(t *testing.T) {
+	a := newMetricsApp(t)
+	for _, target := range []string{"/asset/unknown", "/asset/unknown?w=200"} {
+		if rec := getAsset(a, target); rec.Code != http.StatusNotFound {
+			t.Errorf("%s status = %d, want 404", target, rec.Code)
+		}
+	}
+	if _, err := a.GetImageFilename(t.Context(), "unknown"); !errors.Is(err, ErrImageNotFound) {
+		t.Errorf("GetImageFilename() error = %v, want ErrImageNotFound", err)
+	}
+	if rec := scrape(a.GetHTTPHandler(), "alice", "/annotate/quality/unknown"); rec.Code != http.StatusNotFound {
+		t.Errorf("annotate page of an unknown image status = %d, want 404", rec.Code)
+	}
+}
+
+func TestHandleAssetVariants(t *testing.T) {
+	a := newTestApp(t, &Config{})
+	a.CacheDir = t.TempDir()
+	f, err := os.Create(filepath.Join(a.ImagesDir, "big.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := jpeg.Encode(f, gradient(600, 300, 0), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.imageRepo.Create(t.Context(), "jpgsha", "big.jpg"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query          string
+		status         int
+		width, height  int
+		etag, cacheKey string
+	}{
+		{"w=100", http.StatusOK, 256, 128, `"jpgsha-w256"`, "jpgsha-w256.jpg"},
+		{"w=300", http.StatusOK, 512, 256, `"jpgsha-w512"`, "jpgsha-w512.jpg"},
+		{"w=5000", http.StatusOK, 600, 300, `"jpgsha"`, ""},
+		{"w=wide", http.StatusBadRequest, 0, 0, "", ""},
+		{"w=-1", http.StatusBadRequest, 0, 0, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			rec := getAsset(a, "/asset/jpgsha?"+tt.query)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			if rec.Header().Get("ETag") != tt.etag || rec.Header().Get("Content-Type") != "image/jpeg" {
+				t.Errorf("ETag %q, Content-Type %q; want %s as image/jpeg", rec.Header().Get("ETag"), rec.Header().Get("Content-Type"), tt.etag)
+			}
+			config, err := jpeg.DecodeConfig(rec.Body)
+			if err != nil || config.Width != tt.width || config.Height != tt.height {
+				t.Errorf("served %dx%d (err %v), want %dx%d", config.Width, config.Height, err, tt.width, tt.height)
+			}
+			if tt.cacheKey == "" {
+				return
+			}
+			cached, err := os.ReadFile(filepath.Join(a.CacheDir, "assets", "jp", tt.cacheKey))
+			if err != nil {
+				t.Fatalf("variant was not cached: %v", err)
+			}
+			// The second request is served from the cache
+			if again := getAsset(a, "/asset/jpgsha?"+tt.query); !bytes.Equal(again.Body.Bytes(), cached) {
+				t.Error("cached variant differs from the one served")
+			}
+		})
+	}
+
+	rec := getAsset(a, "/asset/jpgsha?w=256", "If-None-Match", `"jpgsha-w256"`)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("conditional variant request status = %d, want 304", rec.Code)
+	}
+}
+
+func TestHandleAssetConvertsTIFF(t *testing.T) {
+	a := newTestApp(t, &Config{})
+	var buf bytes.Buffer
+	if err := tiff.Encode(&buf, gradient(300, 20, 0), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(a.ImagesDir, "scan.tiff"), buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.imageRepo.Create(t.Context(), "tiffsha", "scan.tiff"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query string
+		width int
+		etag  string
+	}{
+		{"", 300, `"tiffsha-w0"`},
+		{"?w=256", 256, `"tiffsha-w256"`},
+	}
+	for _, tt := range tests {
+		rec := getAsset(a, "/asset/tiffsha"+tt.query)
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/png" {
+			t.Fatalf("%q: status %d, Content-Type %q; want a PNG", tt.query, rec.Code, rec.Header().Get("Content-Type"))
+		}
+		if m, err := png.Decode(rec.Body); err != nil || m.Bounds().Dx() != tt.width {
+			t.Errorf("%q: converted asset is not %d pixels wide (err %v)", tt.query, tt.width, err)
+		}
+		if got := rec.Header().Get("ETag"); got != tt.etag {
+			t.Errorf("%q: ETag = %q, want %q", tt.query, got, tt.etag)
+		}
+	}
+}
+
+func TestHandleAssetFromBucket(t *testing.T) {
+	fake := storage.NewFakeS3(t, "images")
+	a := newTestApp(t, &Config{})
+	a.Store = fake.Store(t, "")
+	ctx := t.Context()
+	name, err := IngestImage(ctx, gradient(600, 40, 1), a.Store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.IngestImages(ctx); err != nil {
+		t.Fatal(err)
+	}
+	sha := strings.TrimSuffix(name, ".png")
+	var stored bytes.Buffer
+	if err := png.Encode(&stored, gradient(600, 40, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := getAsset(a, "/asset/"+sha)
+	if rec.Code != http.StatusOK || !bytes.Equal(rec.Body.Bytes(), stored.Bytes()) {
+		t.Fatalf("status %d; want the object byte for byte", rec.Code)
+	}
+	rec = getAsset(a, "/asset/"+sha, "Range", "bytes=8-15")
+	if rec.Code != http.StatusPartialContent || !bytes.Equal(rec.Body.Bytes(), stored.Bytes()[8:16]) {
+		t.Errorf("range request status = %d, body %q", rec.Code, rec.Body.Bytes())
+	}
+
+	// With presigning, originals are fetched from the bucket by the browser
+	a.Config.Storage.PresignMinutes = 10
+	rec = getAsset(a, "/asset/"+sha)
+	if rec.Code != http.StatusFound || rec.Header().Get("Cache-Control") != "private, max-age=300" {
+		t.Fatalf("presigned status = %d, Cache-Control %q; want a redirect", rec.Code, rec.Header().Get("Cache-Control"))
+	}
+	resp, err := http.Get(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil || resp.StatusCode != http.StatusOK || !bytes.Equal(body, stored.Bytes()) {
+		t.Errorf("presigned URL: status %d, err %v; want the object", resp.StatusCode, err)
+	}
+	// Variants are still rendered by the server
+	if rec := getAsset(a, "/asset/"+sha+"?w=256"); rec.Code != http.StatusOK || rec.Header().Get("ETag") != `"`+sha+`-w256"` {
+		t.Errorf("variant status = %d, ETag %q", rec.Code, rec.Header().Get("ETag"))
+	}
+}
@@ -0,0 +1,323 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/lewtec/rotulador/internal/domain"
+	"github.com/lewtec/rotulador/internal/ui/pages"
+)
+
+// browsePageSize is how many thumbnails a /browse page shows.
+const browsePageSize = 60
+
+// ErrInvalidBrowseFilter is returned for /browse filters that cannot be parsed.
+const ErrInvalidBrowseFilter appError = "invalid browse filter"
+
+// parseBrowseFilter reads the /browse filters: task, class, user, from and to
+// (dates, both inclusive), unsure and disagreement.
+func (a *AnnotatorApp) parseBrowseFilter(query url.Values) (domain.AnnotationFilter, pages.BrowseFilter, error) {
+	form := pages.BrowseFilter{
+		Task:         query.Get("task"),
+		Class:        query.Get("class"),
+		User:         query.Get("user"),
+		From:         query.Get("from"),
+		To:           query.Get("to"),
+		Unsure:       query.Get("unsure") != "",
+		Disagreement: query.Get("disagreement") != "",
+	}
+	filter := domain.AnnotationFilter{
+		OptionValue:      form.Class,
+		Username:         form.User,
+		UnsureOnly:       form.Unsure,
+		DisagreementOnly: form.Disagreement,
+	}
+	if form.Task != "" {
+		stageIndex := a.findTaskIndex(form.Task)
+		if stageIndex == -1 {
+			return filter, form, fmt.Errorf("%w: %s", ErrTaskNotFound, form.Task)
+		}
+		filter.StageIndex = &stageIndex
+	}
+	after, before, err := parseDateRange(form.From, form.To)
+	if err != nil {
+		return filter, form, fmt.Errorf("%w: %w", ErrInvalidBrowseFilter, err)
+	}
+	filter.AnnotatedAfter, filter.AnnotatedBefore = after, before
+	return filter, form, nil
+}
+
+// ErrInvalidDate is returned for dates that are not YYYY-MM-DD.
+const ErrInvalidDate appError = "invalid date"
+
+// parseDateRange turns the from and to dates of a form, both inclusive and
+// optional, into the [after, before) bounds repositories take. Empty dates
+// give zero times, which are unbounded.
+func parseDateRange(from, to string) (after, before time.Time, err error) {
+	if from != "" {
+		after, err = time.Parse(time.DateOnly, from)
+		if err != nil {
+			return after, before, fmt.Errorf("%w: from: %q", ErrInvalidDate, from)
+		}
+	}
+	if to != "" {
+		before, err = time.Parse(time.DateOnly, to)
+		if err != nil {
+			return after, before, fmt.Errorf("%w: to: %q", ErrInvalidDate, to)
+		}
+		before = before.AddDate(0, 0, 1)
+	}
+	return after, before, nil
+}
+
+// handleBrowse serves /browse, a paginated thumbnail grid of the annotated
+// images matching the filters, and /browse/{sha256}, the detail page of an
+// image with its annotations in every task. Both show other users' answers,
+// so they are reserved to reviewers.
+func (a *AnnotatorApp) handleBrowse(w http.ResponseWriter, r *http.Request) {
+	if !a.isReviewer(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	itemPath := pathParts(r.URL.Path)
+	if len(itemPath) == 2 {
+		a.handleBrowseImage(w, r, itemPath[1])
+		return
+	}
+	if len(itemPath) != 1 {
+		http.NotFoundHandler().ServeHTTP(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+	filter, form, err := a.parseBrowseFilter(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page := 1
+	if v := query.Get("page"); v != "" {
+		page, err = strconv.Atoi(v)
+		if err != nil || page < 1 {
+			http.Error(w, fmt.Sprintf("invalid page: %q", v), http.StatusBadRequest)
+			return
+		}
+	}
+
+	total, err := a.annotationRepo.CountAnnotatedImages(r.Context(), filter)
+	if err != nil {
+		ReportError(r.Context(), err, "msg", "error counting annotated images")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	images, err := a.annotationRepo.ListAnnotatedImages(r.Context(), filter, browsePageSize, (page-1)*browsePageSize)
+	if err != nil {
+		ReportError(r.Context(), err, "msg", "error listing annotated images")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	data := pages.BrowseData{
+		Filter:  form,
+		Tasks:   make([]pages.BrowseOption, 0, len(a.Config.Tasks)),
+		Classes: a.browseClasses(form.Task),
+		Users:   make([]string, 0, len(a.Config.Authentication)),
+		Images:  make([]pages.GridImage, 0, len(images)),
+		Total:   int(total),
+		Page:    page,
+		Pages:   max(1, (int(total)+browsePageSize-1)/browsePageSize),
+	}
+	for _, task := range a.Config.Tasks {
+		data.Tasks = append(data.Tasks, pages.BrowseOption{ID: task.ID, Name: task.Name})
+	}
+	for user := range a.Config.Authentication {
+		data.Users = append(data.Users, user)
+	}
+	sort.Strings(data.Users)
+	for _, img := range images {
+		data.Images = append(data.Images, pages.GridImage{
+			ID:       img.SHA256,
+			Filename: img.Filename,
+			URL:      thumbnailURL(img.SHA256),
+		})
+	}
+	if page > 1 {
+		data.PrevURL = browsePageURL(query, page-1)
+	}
+	if page < data.Pages {
+		data.NextURL = browsePageURL(query, page+1)
+	}
+
+	err = Render(r.Context(), w, pages.Browse(PageShell("Browse"), data))
+	if err != nil {
+		ReportError(r.Context(), err, "msg", "error rendering browse template")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// browseClasses lists the classes of a task, or of every task when taskID is
+// empty, for the class filter.
+func (a *AnnotatorApp) browseClasses(taskID string) []pages.BrowseOption {
+	seen := map[string]bool{}
+	var classes []pages.BrowseOption
+	for _, task := range a.Config.Tasks {
+		if taskID != "" && task.ID != taskID {
+			continue
+		}
+		for _, class := range classButtons(task) {
+			if seen[class.ID] {
+				continue
+			}
+			seen[class.ID] = true
+			classes = append(classes, pages.BrowseOption{ID: class.ID, Name: class.Name})
+		}
+	}
+	sort.Slice(classes, func(i, j int) bool { return classes[i].ID < classes[j].ID })
+	return classes
+}
+
+// browsePageURL is the /browse URL of another page with the same filters.
+func browsePageURL(query url.Values, page int) string {
+	next := url.Values{}
+	for key, values := range query {
+		next[key] = values
+	}
+	next.Set("page", strconv.Itoa(page))
+	return "/browse?" + next.Encode()
+}
+
+// classMessage is the i18n message id naming a class of a task.
+func classMessage(task *ConfigTask, class string) string {
+	if meta := task.Classes[class]; meta != nil && meta.Name != "" {
+		return meta.Name
+	}
+	return class
+}
+
+// handleBrowseImage serves the detail page of an image: every annotation it
+// has, in every task.
+func (a *AnnotatorApp) handleBrowseImage(w http.ResponseWriter, r *http.Request, sha256 string) {
+	img, err := a.imageRepo.GetBySHA256(r.Context(), sha256)
+	if err != nil {
+		ReportError(r.Context(), err, "msg", "error getting image", "sha256", sha256)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if img == nil {
+		http.NotFoundHandler().ServeHTTP(w, r)
+		return
+	}
+	annotations, err := a.annotationRepo.GetForImage(r.Context(), sha256)
+	if err != nil {
+		ReportError(r.Context(), err, "msg", "error getting annotations for image", "sha256", sha256)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	reviews, err := a.reviewRepo.GetForImage(r.Context(), sha256)
+	if err != nil {
+		ReportError(r.Context(), err, "msg", "error getting reviews for image", "sha256", sha256)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	latestReviews := LatestReviews(reviews)
+	events, err := a.eventRepo.List(r.Context(), domain.EventFilter{ImageSHA256: sha256}, browseHistoryLimit)
+	if err != nil {
+		ReportError(r.Context(), err, "msg", "error getting history of image", "sha256", sha256)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	data := pages.BrowseImageData{
+		ID:          img.SHA256,
+		Filename:    img.Filename,
+		URL:         annotateAssetURL(img.SHA256),
+		SourcePath:  img.SourcePath,
+		Format:      img.Format,
+		Width:       img.Width,
+		Height:      img.Height,
+		Annotations: make([]pages.ImageAnnotation, 0, len(annotations)),
+	}
+	for _, ann := range annotations {
+		row := pages.ImageAnnotation{
+			// Annotations of tasks removed from the config keep their stage
+			TaskName:    fmt.Sprintf("stage %d", ann.StageIndex),
+			Class:       ann.OptionValue,
+			User:        ann.Username,
+			Sure:        ann.Sure,
+			AnnotatedAt: ann.AnnotatedAt.Format(time.DateTime),
+		}
+		if review := latestReviews[ann.ID]; review != nil {
+			row.Reviewer = review.Reviewer
+			row.Corrected = review.Outcome == domain.ReviewCorrected
+			row.ReviewedClass = review.OptionValue
+		}
+		if ann.StageIndex < len(a.Config.Tasks) {
+			task := a.Config.Tasks[ann.StageIndex]
+			row.TaskName = task.Name
+			if row.TaskName == "" {
+				row.TaskName = task.ID
+			}
+			row.Class = classMessage(task, ann.OptionValue)
+			row.ReviewedClass = classMessage(task, row.ReviewedClass)
+			row.BrowseURL = "/browse?" + url.Values{"task": {task.ID}, "class": {ann.OptionValue}}.Encode()
+		}
+		data.Annotations = append(data.Annotations, row)
+	}
+	for _, event := range events {
+		data.History = append(data.History, a.imageEvent(event))
+	}
+
+	err = Render(r.Context(), w, pages.BrowseImage(PageShell(img.Filename), data))
+	if err != nil {
+		ReportError(r.Context(), err, "msg", "error rendering image detail template")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// browseHistoryLimit caps the history on the image detail page; 'rotulador
+// log' shows all of it.
+const browseHistoryLimit = 100
+
+// eventKinds are the i18n message ids of the kinds of annotation events
+var eventKinds = map[string]string{
+	domain.EventCreate:    "Created",
+	domain.EventOverwrite: "Changed",
+	domain.EventDelete:    "Deleted",
+	domain.EventReview:    "Reviewed",
+	domain.EventImport:    "Imported",
+}
+
+// imageEvent describes a history entry for the image detail page, naming
+// tasks and classes like the annotations above it.
+func (a *AnnotatorApp) imageEvent(event *domain.AnnotationEvent) pages.ImageEvent {
+	row := pages.ImageEvent{
+		At:       event.CreatedAt.Format(time.DateTime),
+		Kind:     eventKinds[event.Kind],
+		TaskName: fmt.Sprintf("stage %d", event.StageIndex),
+		User:     event.Username,
+		Source:   event.Source,
+		OldClass: event.OldValue,
+		NewClass: event.NewValue,
+	}
+	if event.Actor != event.Username {
+		row.Actor = event.Actor
+	}
+	if event.StageIndex < len(a.Config.Tasks) {
+		task := a.Config.Tasks[event.StageIndex]
+		row.TaskName = task.Name
+		if row.TaskName == "" {
+			row.TaskName = task.ID
+		}
+		if event.OldValue != "" {
+			row.OldClass = classMessage(task, event.OldValue)
+		}
+		if event.NewValue != "" {
+			row.NewClass = classMessage(task, event.NewValue)
+		}
+	}
+	return row
+}
@@ -0,0 +1,116 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newBrowseApp(t *testing.T) *AnnotatorApp {
+	t.Helper()
+	a := newTestApp(t, &Config{
+		Tasks: []*ConfigTask{{ID: "quality", Name: "Quality", Classes: map[string]*ConfigClass{
+			"bad":  {Name: "Bad"},
+			"good": {Name: "Good"},
+		}}},
+		Authentication: map[string]*ConfigAuth{
+			"admin": {Admin: true},
+			"alice": {},
+			"bob":   {},
+		},
+	})
+	ctx := t.Context()
+	for _, hash := range []string{"hash1", "hash2", "hash3"} {
+		if _, err := a.imageRepo.Create(ctx, hash, hash+".png"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, ann := range []AnnotationResponse{
+		{ImageID: "hash1", TaskID: "quality", User: "alice", Value: "good", Sure: true},
+		{ImageID: "hash1", TaskID: "quality", User: "bob", Value: "bad", Sure: true},
+		{ImageID: "hash2", TaskID: "quality", User: "alice", Value: "good", Sure: false},
+	} {
+		if err := a.SubmitAnnotation(ctx, ann); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return a
+}
+
+func browse(a *AnnotatorApp, user, target string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.SetBasicAuth(user, "")
+	rec := httptest.NewRecorder()
+	a.handleBrowse(rec, req)
+	return rec
+}
+
+func TestHandleBrowseFilters(t *testing.T) {
+	a := newBrowseApp(t)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"hash1", "hash2"}},
+		{"task=quality&class=bad", []string{"hash1"}},
+		{"user=alice", []string{"hash1", "hash2"}},
+		{"unsure=1", []string{"hash2"}},
+		{"disagreement=1", []string{"hash1"}},
+		{"to=2000-01-01", nil},
+	}
+	for _, tt := range tests {
+		rec := browse(a, "admin", "/browse?"+tt.query)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, body %s", tt.query, rec.Code, rec.Body)
+		}
+		body := rec.Body.String()
+		if got := strings.Count(body, `href="/browse/hash`); got != len(tt.want) {
+			t.Errorf("%s: %d images, want %v", tt.query, got, tt.want)
+		}
+		for _, hash := range tt.want {
+			if !strings.Contains(body, `href="/browse/`+hash+`"`) {
+				t.Errorf("%s: %s is not listed", tt.query, hash)
+			}
+		}
+	}
+
+	for _, query := range []string{"task=missing", "from=yesterday", "page=0"} {
+		if rec := browse(a, "admin", "/browse?"+query); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, rec.Code)
+		}
+	}
+	if rec := browse(a, "alice", "/browse"); rec.Code != http.StatusForbidden {
+		t.Errorf("non-reviewer status = %d, want 403", rec.Code)
+	}
+}
+
+func TestBrowsePageURLKeepsFilters(t *testing.T) {
+	got := browsePageURL(map[string][]string{"class": {"good"}, "page": {"1"}}, 2)
+	if want := "/browse?class=good&page=2"; got != want {
+		t.Errorf("browsePageURL() = %q, want %q", got, want)
+	}
+}
+
+func TestHandleBrowseImage(t *testing.T) {
+	a := newBrowseApp(t)
+
+	rec := browse(a, "admin", "/browse/hash1")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{"hash1.png", "alice", "bob", "Good", "Bad", `href="/browse?class=bad&amp;task=quality"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("detail page does not contain %s", want)
+		}
+	}
+
+	if body := browse(a, "admin", "/browse/hash2").Body.String(); !strings.Contains(body, "badge-warning") {
+		t.Error("unsure annotation is not marked on the detail page")
+	}
+	if rec := browse(a, "admin", "/browse/nope"); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown image status = %d, want 404", rec.Code)
+	}
+}
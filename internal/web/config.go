@@ -6,8 +6,12 @@ import (
 	"io"
 	"log/slog"
 	"os"
+	"time"
 
+	"github.com/lewtec/rotulador/internal/domain"
 	"github.com/lewtec/rotulador/internal/i18n"
+	"github.com/lewtec/rotulador/internal/storage"
+	"github.com/lewtec/rotulador/internal/tracing"
 	"gopkg.in/yaml.v3"
 )
 
@@ -18,6 +22,174 @@ type Config struct {
 	Tasks          []*ConfigTask          `yaml:"tasks"`
 	Authentication map[string]*ConfigAuth `yaml:"auth"`
 	I18N           []ConfigI18N           `yaml:"i18n"`
+	Leases         ConfigLeases           `yaml:"leases"`
+	Watch          ConfigWatch            `yaml:"watch"`
+	Prefetch       ConfigPrefetch         `yaml:"prefetch"`
+	Stats          ConfigStats            `yaml:"stats"`
+	Metrics        ConfigMetrics          `yaml:"metrics"`
+	Ready          ConfigReady            `yaml:"ready"`
+	Reporting      ConfigReporting        `yaml:"reporting"`
+	Tracing        ConfigTracing          `yaml:"tracing"`
+	Storage        ConfigStorage          `yaml:"storage"`
+}
+
+// DefaultLeaseMinutes is how long an image stays reserved for the user it was
+// served to when the config does not say otherwise.
+const DefaultLeaseMinutes = 5
+
+// ConfigLeases controls image reservations. Minutes == 0 uses
+// DefaultLeaseMinutes; a negative value disables leases.
+type ConfigLeases struct {
+	Minutes int `yaml:"minutes"`
+}
+
+// Enabled reports whether images are reserved while being annotated.
+func (c ConfigLeases) Enabled() bool {
+	return c.Minutes >= 0
+}
+
+// Duration is the lifetime of a single lease.
+func (c ConfigLeases) Duration() time.Duration {
+	if c.Minutes == 0 {
+		return DefaultLeaseMinutes * time.Minute
+	}
+	return time.Duration(c.Minutes) * time.Minute
+}
+
+// What happens to an image when its last file leaves the images directory.
+const (
+	// OnRemoveMissing hides the image from queues and keeps its annotations
+	OnRemoveMissing = "missing"
+	// OnRemoveDelete deletes the image together with its annotations
+	OnRemoveDelete = "delete"
+)
+
+// ConfigWatch controls live ingestion of files added to or removed from the
+// images directory while the server runs.
+type ConfigWatch struct {
+	Disabled bool `yaml:"disabled"`
+	// OnRemove is OnRemoveMissing (the default) or OnRemoveDelete
+	OnRemove string `yaml:"on_remove"`
+}
+
+// DefaultPrefetchImages is how many upcoming images the annotation page
+// loads ahead when the config does not say otherwise.
+const DefaultPrefetchImages = 3
+
+// ConfigPrefetch controls how far ahead the annotation page works. Images == 0
+// uses DefaultPrefetchImages; a negative value disables prefetching.
+type ConfigPrefetch struct {
+	Images int `yaml:"images"`
+}
+
+// Count is the number of upcoming images to load ahead.
+func (c ConfigPrefetch) Count() int {
+	if c.Images == 0 {
+		return DefaultPrefetchImages
+	}
+	return max(c.Images, 0)
+}
+
+// ConfigStats controls what annotators see of the /stats numbers, which are
+// otherwise reserved to admins.
+type ConfigStats struct {
+	// Leaderboard shows every user how many annotations each one made
+	Leaderboard bool `yaml:"leaderboard"`
+	// MinMedianSeconds flags users whose median time-on-image is lower.
+	// Zero uses DefaultMinMedianSeconds; a negative value disables the flag.
+	MinMedianSeconds float64 `yaml:"min_median_seconds"`
+}
+
+// DefaultMinMedianSeconds is the median time-on-image below which a user is
+// flagged when the config does not say otherwise.
+const DefaultMinMedianSeconds = 1.0
+
+// MinMedian is the median time-on-image below which a user is flagged, zero
+// when flagging is disabled.
+func (c ConfigStats) MinMedian() time.Duration {
+	seconds := c.MinMedianSeconds
+	if seconds == 0 {
+		seconds = DefaultMinMedianSeconds
+	}
+	return time.Duration(max(seconds, 0) * float64(time.Second))
+}
+
+// ConfigMetrics controls the Prometheus endpoint at /metrics.
+type ConfigMetrics struct {
+	Enabled bool `yaml:"enabled"`
+	// Listen serves /metrics on its own address, like "127.0.0.1:9090",
+	// instead of next to the pages
+	Listen string `yaml:"listen"`
+	// Username and Password, when set, are the only credentials accepted
+	// by /metrics. Otherwise only admins can scrape it next to the pages,
+	// and anyone can on Listen.
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// ConfigReady controls when /readyz reports the server ready. IngestPercent
+// is how much of the initial ingestion of the images directory has to be
+// done: zero waits for all of it, a negative value does not wait at all.
+type ConfigReady struct {
+	IngestPercent int `yaml:"ingest_percent"`
+}
+
+// MinIngestPercent is the share of the initial ingestion /readyz waits for,
+// zero when it does not wait.
+func (c ConfigReady) MinIngestPercent() int {
+	if c.IngestPercent == 0 {
+		return 100
+	}
+	return max(c.IngestPercent, 0)
+}
+
+// ConfigReporting sends the errors the server runs into to a Sentry-compatible
+// error tracker, on top of the log. Without a DSN, here or in the SENTRY_DSN
+// environment variable, errors are only logged.
+type ConfigReporting struct {
+	DSN         string `yaml:"dsn"`
+	Environment string `yaml:"environment"`
+	// MaxPerMinute caps the events sent per minute. Zero uses
+	// DefaultMaxReportsPerMinute, a negative value does not cap them.
+	// Repeats of the same error are sent once a minute either way.
+	MaxPerMinute int `yaml:"max_per_minute"`
+}
+
+// DefaultMaxReportsPerMinute is the cap on error events sent per minute when
+// reporting.max_per_minute is not set.
+const DefaultMaxReportsPerMinute = 30
+
+// MaxReports is the cap on events sent per minute, zero when uncapped.
+func (c ConfigReporting) MaxReports() int {
+	if c.MaxPerMinute == 0 {
+		return DefaultMaxReportsPerMinute
+	}
+	return max(c.MaxPerMinute, 0)
+}
+
+// ConfigTracing exports OpenTelemetry spans of requests, queries and
+// ingestion. Exporter is "otlp", "stdout" for local debugging, or empty to
+// not trace.
+type ConfigTracing struct {
+	Exporter string `yaml:"exporter"`
+	// Endpoint is the OTLP/HTTP traces URL, like
+	// http://localhost:4318/v1/traces. When empty, the standard
+	// OTEL_EXPORTER_OTLP_ENDPOINT environment variable applies.
+	Endpoint string `yaml:"endpoint"`
+}
+
+// ConfigStorage controls how images kept in a bucket reach browsers.
+// PresignMinutes > 0 redirects /asset/ requests for images browsers can
+// display to presigned URLs valid that long, so the bytes skip the server;
+// zero proxies them. Local directories are always served by the server.
+type ConfigStorage struct {
+	PresignMinutes int `yaml:"presign_minutes"`
+}
+
+// PresignExpiry is how long presigned asset URLs are valid, zero when assets
+// are not redirected.
+func (c ConfigStorage) PresignExpiry() time.Duration {
+	return time.Duration(max(c.PresignMinutes, 0)) * time.Minute
 }
 
 type ConfigI18N struct {
@@ -27,6 +199,10 @@ type ConfigI18N struct {
 
 type ConfigAuth struct {
 	Password string `yaml:"password"`
+	// Admin grants access to the /admin pages, and everything reviewers can do.
+	Admin bool `yaml:"admin"`
+	// Reviewer grants access to /review and /browse.
+	Reviewer bool `yaml:"reviewer"`
 }
 
 type ConfigTask struct {
@@ -84,6 +260,24 @@ func LoadConfig(filename string) (*Config, error) {
 			return nil, fmt.Errorf("task %s does not have any classes or a compatible type", taskName)
 		}
 	}
+	for _, task := range ret.Tasks {
+		for key, value := range task.If {
+			if _, isTask := _taskDict[key]; isTask || !IsImageField(key) {
+				continue
+			}
+			var filter domain.ImageFilter
+			if err := ParseImageCondition(key, value, &filter); err != nil {
+				return nil, fmt.Errorf("task %s: %w", task.ID, err)
+			}
+		}
+	}
+	switch ret.Watch.OnRemove {
+	case "":
+		ret.Watch.OnRemove = OnRemoveMissing
+	case OnRemoveMissing, OnRemoveDelete:
+	default:
+		return nil, fmt.Errorf("watch.on_remove must be %q or %q, got %q", OnRemoveMissing, OnRemoveDelete, ret.Watch.OnRemove)
+	}
 	if len(ret.Authentication) == 0 {
 		return nil, fmt.Errorf("no users specified")
 	}
@@ -118,6 +312,28 @@ func LoadConfig(filename string) (*Config, error) {
 			ret.Authentication[user].Password = hashedPassword
 		}
 	}
+	if ret.Ready.IngestPercent > 100 {
+		return nil, fmt.Errorf("ready.ingest_percent must be at most 100, got %d", ret.Ready.IngestPercent)
+	}
+	switch ret.Tracing.Exporter {
+	case "", tracing.ExporterOTLP, tracing.ExporterStdout:
+	default:
+		return nil, fmt.Errorf("tracing.exporter must be '%s' or '%s', got '%s'", tracing.ExporterOTLP, tracing.ExporterStdout, ret.Tracing.Exporter)
+	}
+	if ret.Storage.PresignExpiry() > storage.MaxPresignExpiry {
+		return nil, fmt.Errorf("storage.presign_minutes must be at most %d, got %d", int(storage.MaxPresignExpiry/time.Minute), ret.Storage.PresignMinutes)
+	}
+	if (ret.Metrics.Username == "") != (ret.Metrics.Password == "") {
+		return nil, fmt.Errorf("metrics.username and metrics.password must be set together")
+	}
+	if ret.Metrics.Password != "" && !IsBcryptHash(ret.Metrics.Password) {
+		slog.Warn("password for metrics is in plaintext. Hashing it automatically.")
+		hashedPassword, err := HashPassword(ret.Metrics.Password)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash password for metrics: %w", err)
+		}
+		ret.Metrics.Password = hashedPassword
+	}
 	return &ret, nil
 }
 
@@ -1,9 +1,11 @@
 package web
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func writeConfig(t *testing.T, body string) string {
@@ -107,3 +109,141 @@ tasks:
 		t.Fatal("preserved hash no longer verifies")
 	}
 }
+
+func TestLoadConfig_RejectsInvalidImageCondition(t *testing.T) {
+	path := writeConfig(t, `
+auth:
+  admin:
+    password: "changeme"
+tasks:
+  - id: detail
+    name: Detail
+    if:
+      width: "wide"
+    classes:
+      good:
+        name: Good
+`)
+	_, err := LoadConfig(path)
+	if !errors.Is(err, ErrInvalidImageCondition) {
+		t.Fatalf("LoadConfig error = %v, want ErrInvalidImageCondition", err)
+	}
+}
+
+func TestLoadConfig_WatchOnRemove(t *testing.T) {
+	base := `
+auth:
+  admin:
+    password: "changeme"
+tasks:
+  - id: quality
+    name: Quality
+    type: boolean
+`
+	cfg, err := LoadConfig(writeConfig(t, base))
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Watch.OnRemove != OnRemoveMissing {
+		t.Errorf("default on_remove = %q, want %q", cfg.Watch.OnRemove, OnRemoveMissing)
+	}
+
+	if _, err := LoadConfig(writeConfig(t, base+"watch:\n  on_remove: purge\n")); err == nil {
+		t.Error("expected error for unknown on_remove")
+	}
+}
+
+func TestConfigPrefetchCount(t *testing.T) {
+	for images, want := range map[int]int{0: DefaultPrefetchImages, 5: 5, -1: 0} {
+		if got := (ConfigPrefetch{Images: images}).Count(); got != want {
+			t.Errorf("ConfigPrefetch{Images: %d}.Count() = %d, want %d", images, got, want)
+		}
+	}
+}
+
+func TestLoadConfig_HashesMetricsPassword(t *testing.T) {
+	path := writeConfig(t, `
+auth:
+  admin:
+    password: "changeme"
+metrics:
+  enabled: true
+  listen: "127.0.0.1:9090"
+  username: prometheus
+  password: scrape
+tasks:
+  - id: quality
+    name: Quality
+    classes:
+      good:
+        name: Good
+`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if !CheckPasswordHash("scrape", cfg.Metrics.Password) {
+		t.Fatalf("metrics password was not hashed: %q", cfg.Metrics.Password)
+	}
+
+	path = writeConfig(t, `
+auth:
+  admin:
+    password: "changeme"
+metrics:
+  username: prometheus
+tasks:
+  - id: quality
+    name: Quality
+`)
+	if _, err := LoadConfig(path); err == nil {
+		t.Fatal("LoadConfig accepted a metrics username without a password")
+	}
+}
+
+func TestLoadConfig_TracingExporter(t *testing.T) {
+	base := `
+auth:
+  admin:
+    password: "changeme"
+tasks:
+  - id: quality
+    name: Quality
+    type: boolean
+`
+	cfg, err := LoadConfig(writeConfig(t, base+"tracing:\n  exporter: stdout\n"))
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Tracing.Exporter != "stdout" {
+		t.Errorf("exporter = %q, want stdout", cfg.Tracing.Exporter)
+	}
+
+	if _, err := LoadConfig(writeConfig(t, base+"tracing:\n  exporter: jaeger\n")); err == nil {
+		t.Error("expected error for unknown exporter")
+	}
+}
+
+func TestLoadConfig_StoragePresignMinutes(t *testing.T) {
+	base := `
+auth:
+  admin:
+    password: "changeme"
+tasks:
+  - id: quality
+    name: Quality
+    type: boolean
+`
+	cfg, err := LoadConfig(writeConfig(t, base+"storage:\n  presign_minutes: 15\n"))
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if got := cfg.Storage.PresignExpiry(); got != 15*time.Minute {
+		t.Errorf("PresignExpiry() = %s, want 15m", got)
+	}
+
+	// Presigned URLs cannot be valid for more than a week
+	if _, err := LoadConfig(writeConfig(t, base+"storage:\n  presign_minutes: 20000\n")); err == nil {
+		t.Error("expected error for presign_minutes over a week")
+	}
+}
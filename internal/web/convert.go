@@ -0,0 +1,72 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNoHEICConverter is returned for HEIC/HEIF files when no external
+// converter is configured.
+const ErrNoHEICConverter appError = "no converter for HEIC images (hint: install libheif or ImageMagick)"
+
+// heicExtensions have no pure-Go decoder and go through an external converter.
+var heicExtensions = map[string]bool{".heic": true, ".heif": true, ".hif": true}
+
+// IsHEIC reports whether path names a HEIC/HEIF image.
+func IsHEIC(path string) bool {
+	return heicExtensions[strings.ToLower(filepath.Ext(path))]
+}
+
+// ConvertHEIC turns the HEIC/HEIF image at path into PNG bytes by running
+// converter as '<converter> <input> <output.png>', which is how both
+// libheif's heif-convert and ImageMagick's magick are invoked.
+func ConvertHEIC(ctx context.Context, converter, path string) ([]byte, error) {
+	if converter == "" {
+		return nil, fmt.Errorf("%w: %s", ErrNoHEICConverter, path)
+	}
+	dir, err := os.MkdirTemp("", "rotulador-heic-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			ReportError(context.Background(), err, "msg", "failed to remove HEIC conversion directory", "path", dir)
+		}
+	}()
+
+	out := filepath.Join(dir, "image.png")
+	cmd := exec.CommandContext(ctx, converter, path, out)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("while converting '%s' with %s: %w: %s", path, converter, err, strings.TrimSpace(stderr.String()))
+	}
+	return os.ReadFile(out)
+}
+
+// SkipReason names why ingest could not use a file, so skipped files can be
+// summarized per reason.
+func SkipReason(err error) string {
+	switch {
+	case errors.Is(err, image.ErrFormat):
+		return "unsupported format"
+	case errors.Is(err, ErrImageTooLarge):
+		return "too large"
+	case errors.Is(err, ErrPathTraversal):
+		return "unsafe path"
+	case errors.Is(err, ErrArchiveMemberTooLarge), errors.Is(err, ErrArchiveRatio):
+		return "archive limit"
+	case errors.Is(err, ErrNoVideoDecoder):
+		return "no video decoder"
+	case errors.Is(err, ErrNoHEICConverter):
+		return "no HEIC converter"
+	}
+	return "decode error"
+}
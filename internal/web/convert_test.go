@@ -0,0 +1,90 @@
+package web
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+)
+
+func TestInspectImageExtraFormats(t *testing.T) {
+	img := gradient(12, 8, 0)
+	encoders := map[string]func(*bytes.Buffer) error{
+		"tiff": func(b *bytes.Buffer) error { return tiff.Encode(b, img, nil) },
+		"bmp":  func(b *bytes.Buffer) error { return bmp.Encode(b, img) },
+	}
+	for format, encode := range encoders {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := encode(&buf); err != nil {
+				t.Fatal(err)
+			}
+			_, meta, err := InspectImageBytes(buf.Bytes())
+			if err != nil {
+				t.Fatalf("InspectImageBytes: %v", err)
+			}
+			if meta.Format != format || meta.Width != 12 || meta.Height != 8 {
+				t.Errorf("metadata = %+v, want a 12x8 %s", meta, format)
+			}
+		})
+	}
+}
+
+func TestConvertHEIC(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "IMG_0001.HEIC")
+	if err := os.WriteFile(path, []byte("not really"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if !IsHEIC(path) {
+		t.Fatal("IsHEIC() = false for a .HEIC file")
+	}
+	if _, err := ConvertHEIC(t.Context(), "", path); !errors.Is(err, ErrNoHEICConverter) {
+		t.Fatalf("ConvertHEIC without converter error = %v, want ErrNoHEICConverter", err)
+	}
+
+	if runtime.GOOS == "windows" {
+		t.Skip("fake converter is a shell script")
+	}
+	dir := t.TempDir()
+	converted := filepath.Join(dir, "converted.png")
+	writePNG(t, converted, gradient(10, 6, 0))
+	fake := filepath.Join(dir, "heif-convert")
+	script := fmt.Sprintf("#!/bin/sh\ncp '%s' \"$2\"\n", converted)
+	if err := os.WriteFile(fake, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ConvertHEIC(t.Context(), fake, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, meta, err := InspectImageBytes(data); err != nil || meta.Format != "png" || meta.Width != 10 {
+		t.Errorf("converted image = %+v, err = %v; want a 10x6 png", meta, err)
+	}
+}
+
+func TestSkipReason(t *testing.T) {
+	_, _, formatErr := image.Decode(bytes.NewReader([]byte("plain text")))
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{formatErr, "unsupported format"},
+		{fmt.Errorf("%w: 99999x99999", ErrImageTooLarge), "too large"},
+		{fmt.Errorf("%w: a.png", ErrArchiveRatio), "archive limit"},
+		{fmt.Errorf("%w: ../a.png", ErrPathTraversal), "unsafe path"},
+		{ErrNoHEICConverter, "no HEIC converter"},
+		{errors.New("png: invalid format"), "decode error"},
+	}
+	for _, tt := range tests {
+		if got := SkipReason(tt.err); got != tt.want {
+			t.Errorf("SkipReason(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
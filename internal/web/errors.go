@@ -3,11 +3,29 @@ package web
 import (
 	"context"
 	"log/slog"
+	"net/http"
 	"runtime/debug"
+	"sync/atomic"
+
+	"github.com/lewtec/rotulador/internal/report"
 )
 
+// reporter receives the errors passed to ReportError besides the log.
+var reporter atomic.Pointer[report.Reporter]
+
+// SetReporter sends the errors passed to ReportError to r, like a Sentry
+// client, on top of logging them. A nil r only logs.
+func SetReporter(r report.Reporter) {
+	if r == nil {
+		reporter.Store(nil)
+		return
+	}
+	reporter.Store(&r)
+}
+
 // ReportError centralizes error reporting.
-// It logs the error using slog and can be extended to send to Sentry.
+// It logs the error using slog and sends it to the reporter set with
+// SetReporter, with the request it happened in when ctx comes from one.
 func ReportError(ctx context.Context, err error, args ...any) {
 	if err == nil {
 		return
@@ -18,4 +36,39 @@ func ReportError(ctx context.Context, err error, args ...any) {
 
 	// Log using slog
 	slog.ErrorContext(ctx, err.Error(), allArgs...)
+
+	if r := reporter.Load(); r != nil {
+		(*r).Report(ctx, report.NewEvent(ctx, err, 1, args...))
+	}
+}
+
+// reportScopeMiddleware attaches what a request is about to its context so
+// the errors reported while serving it carry the route, user, task and image.
+func reportScopeMiddleware(mux *http.ServeMux, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, route := mux.Handler(r)
+		// authenticationMiddleware already validated these credentials
+		user, _, _ := r.BasicAuth()
+		scope := &report.Scope{
+			Route:  route,
+			Method: r.Method,
+			URL:    r.URL.String(),
+			User:   user,
+			Task:   r.URL.Query().Get("task"),
+		}
+		switch parts := pathParts(r.URL.Path); {
+		case len(parts) == 3 && parts[0] == "annotate":
+			scope.Task = parts[1]
+			if parts[2] != "grid" {
+				scope.Image = parts[2]
+			}
+		case len(parts) == 4 && parts[0] == "api" && parts[1] == "annotate":
+			scope.Task = parts[2]
+		case len(parts) == 2 && (parts[0] == "help" || parts[0] == "review"):
+			scope.Task = parts[1]
+		case len(parts) >= 2 && (parts[0] == "browse" || parts[0] == "asset"):
+			scope.Image = parts[1]
+		}
+		handler.ServeHTTP(w, r.WithContext(report.WithScope(r.Context(), scope)))
+	})
 }
@@ -0,0 +1,74 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/lewtec/rotulador/internal/report"
+)
+
+type recordingReporter struct {
+	mu     sync.Mutex
+	events []*report.Event
+}
+
+func (r *recordingReporter) Report(ctx context.Context, event *report.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func TestReportErrorAttachesRequestScope(t *testing.T) {
+	a := newMetricsApp(t)
+	rec := &recordingReporter{}
+	SetReporter(rec)
+	t.Cleanup(func() { SetReporter(nil) })
+
+	// A file that is not an image fails to decode when served
+	if err := os.WriteFile(filepath.Join(a.ImagesDir, "hash1.png"), []byte("not an image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if res := scrape(a.GetHTTPHandler(), "alice", "/asset/hash1?w=200"); res.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", res.Code)
+	}
+
+	if len(rec.events) != 1 {
+		t.Fatalf("got %d events, want 1", len(rec.events))
+	}
+	event := rec.events[0]
+	if event.Message != "error: http: while reading image asset" || event.Extra["sha256"] != "hash1" {
+		t.Errorf("event = %q %v", event.Message, event.Extra)
+	}
+	want := report.Scope{Route: "/asset/", Method: "GET", URL: "/asset/hash1?w=200", User: "alice", Image: "hash1"}
+	if event.Scope == nil || *event.Scope != want {
+		t.Errorf("scope = %+v, want %+v", event.Scope, want)
+	}
+}
+
+func TestReportScopeFromPath(t *testing.T) {
+	for _, tc := range []struct {
+		target      string
+		task, image string
+	}{
+		{"/annotate/quality/abc", "quality", "abc"},
+		{"/annotate/quality/grid", "quality", ""},
+		{"/annotate/?task=quality", "quality", ""},
+		{"/api/annotate/quality/upcoming", "quality", ""},
+		{"/review/quality", "quality", ""},
+		{"/help/quality", "quality", ""},
+		{"/browse/abc", "", "abc"},
+		{"/stats", "", ""},
+	} {
+		var scope *report.Scope
+		mux := http.NewServeMux()
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) { scope = report.ScopeFrom(r.Context()) })
+		scrape(reportScopeMiddleware(mux, mux), "bob", tc.target)
+		if scope == nil || scope.Task != tc.task || scope.Image != tc.image || scope.User != "bob" {
+			t.Errorf("%s: scope = %+v, want task %q image %q", tc.target, scope, tc.task, tc.image)
+		}
+	}
+}
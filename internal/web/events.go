@@ -0,0 +1,51 @@
+package web
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lewtec/rotulador/internal/domain"
+	"github.com/lewtec/rotulador/internal/repository"
+)
+
+// deleteImage deletes an image together with its annotations, recording a
+// delete event for each of them so the history outlives the image. actor
+// names what removed it, like "ingest" or "fsck".
+func (a *AnnotatorApp) deleteImage(ctx context.Context, sha256, actor string) error {
+	tx, err := a.Database.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("while starting transaction: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			ReportError(ctx, err, "msg", "failed to roll back image deletion", "sha256", sha256)
+		}
+	}()
+
+	annotations, err := repository.NewAnnotationRepositoryWithTx(tx).GetForImage(ctx, sha256)
+	if err != nil {
+		return fmt.Errorf("while getting annotations: %w", err)
+	}
+	eventRepo := repository.NewEventRepositoryWithTx(tx)
+	for _, ann := range annotations {
+		_, err := eventRepo.Create(ctx, &domain.AnnotationEvent{
+			Kind:         domain.EventDelete,
+			Source:       a.EventSource,
+			Actor:        actor,
+			ImageSHA256:  sha256,
+			StageIndex:   ann.StageIndex,
+			Username:     ann.Username,
+			AnnotationID: ann.ID,
+			OldValue:     ann.OptionValue,
+		})
+		if err != nil {
+			return fmt.Errorf("while recording delete event: %w", err)
+		}
+	}
+	if err := repository.NewImageRepositoryWithTx(tx).Delete(ctx, sha256); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
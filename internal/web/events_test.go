@@ -0,0 +1,103 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lewtec/rotulador/internal/domain"
+)
+
+func TestAnnotationHistory(t *testing.T) {
+	a := newBrowseApp(t)
+	ctx := t.Context()
+
+	err := a.SubmitAnnotation(ctx, AnnotationResponse{ImageID: "hash1", TaskID: "quality", User: "alice", Value: "bad", Sure: true, Source: domain.EventSourceAPI})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobs, err := a.annotationRepo.Get(ctx, "hash1", "bob", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.SubmitReview(ctx, "quality", bobs.ID, "admin", "good", domain.EventSourceWeb); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := a.eventRepo.List(ctx, domain.EventFilter{ImageSHA256: "hash1"}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []string
+	for _, event := range events {
+		kinds = append(kinds, event.Kind)
+	}
+	if want := "review overwrite create create"; strings.Join(kinds, " ") != want {
+		t.Fatalf("events of hash1 = %v, want %s", kinds, want)
+	}
+	if e := events[1]; e.OldValue != "good" || e.NewValue != "bad" || e.Source != domain.EventSourceAPI || e.Actor != "alice" {
+		t.Errorf("overwrite = %+v, want alice's change from good to bad through the API", e)
+	}
+	if e := events[0]; e.Actor != "admin" || e.Username != "bob" || e.OldValue != "bad" || e.NewValue != "good" {
+		t.Errorf("review = %+v, want admin correcting bob's bad to good", e)
+	}
+
+	rec := browse(a, "admin", "/browse/hash1")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("browse status = %d", rec.Code)
+	}
+	for _, want := range []string{"History", "Changed", "Reviewed", "by admin"} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("image detail page does not contain %s", want)
+		}
+	}
+
+	// The history outlives the image
+	if err := a.deleteImage(ctx, "hash1", "fsck"); err != nil {
+		t.Fatal(err)
+	}
+	if img, err := a.imageRepo.GetBySHA256(ctx, "hash1"); err != nil || img != nil {
+		t.Fatalf("image after deleteImage = %+v, %v; want nil", img, err)
+	}
+	events, err = a.eventRepo.List(ctx, domain.EventFilter{ImageSHA256: "hash1"}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range events {
+		if e.Kind != domain.EventDelete || e.Actor != "fsck" || e.OldValue == "" || e.Source != domain.EventSourceWeb {
+			t.Errorf("event after deleteImage = %+v, want a delete by fsck", e)
+		}
+	}
+}
+
+func TestAnnotationSourceFollowsRoute(t *testing.T) {
+	a := newMetricsApp(t)
+	handler := a.GetHTTPHandler()
+	post := func(user, target, contentType, body string) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		req.SetBasicAuth(user, "secret")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code >= 400 {
+			t.Fatalf("POST %s status = %d, body %s", target, rec.Code, rec.Body)
+		}
+	}
+	// Neither request looks like a browser's; only the route tells them apart
+	post("alice", "/annotate/quality/hash3", "application/x-www-form-urlencoded", "selectedClass=good&sure=on")
+	post("bob", "/api/annotate/quality/hash3", "application/json", `{"class": "bad", "sure": true}`)
+
+	events, err := a.eventRepo.List(t.Context(), domain.EventFilter{ImageSHA256: "hash3"}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sources := map[string]string{}
+	for _, e := range events {
+		sources[e.Actor] = e.Source
+	}
+	if sources["alice"] != domain.EventSourceWeb || sources["bob"] != domain.EventSourceAPI {
+		t.Errorf("sources = %v, want web for the form and api for JSON", sources)
+	}
+}
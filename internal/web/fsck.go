@@ -0,0 +1,266 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lewtec/rotulador/internal/domain"
+)
+
+// FsckIssueKind classifies a mismatch between the images table and ImagesDir.
+type FsckIssueKind string
+
+const (
+	// FsckMissingFile is an images row whose file is not in ImagesDir
+	FsckMissingFile FsckIssueKind = "missing-file"
+	// FsckOrphanFile is a file in ImagesDir that no images row points to
+	FsckOrphanFile FsckIssueKind = "orphan-file"
+	// FsckHashMismatch is a file whose content no longer hashes to its row
+	FsckHashMismatch FsckIssueKind = "hash-mismatch"
+)
+
+// FsckIssue is a single inconsistency found by CheckImages.
+type FsckIssue struct {
+	Kind FsckIssueKind
+	// SHA256 and Filename come from the images row, empty for orphan files
+	SHA256   string
+	Filename string
+	// Path is the file in ImagesDir, empty for missing files
+	Path string
+	// ActualSHA256 is the hash of the file content for hash mismatches
+	ActualSHA256 string
+}
+
+// What FixImages does with the issues found by CheckImages.
+const (
+	// FsckQuarantine moves orphan and mismatched files out of ImagesDir and
+	// marks rows without a file missing
+	FsckQuarantine = "quarantine"
+	// FsckRehash ingests orphan and mismatched files under their real hash
+	// and marks rows without a file missing
+	FsckRehash = "rehash"
+	// FsckPurge deletes rows without a matching file, with their annotations
+	FsckPurge = "purge"
+)
+
+// CheckImages compares the images table with the files in ImagesDir. Files
+// whose size and modification time match the ingest index are trusted;
+// everything else is hashed again. Images kept in a bucket cannot be checked.
+func (a *AnnotatorApp) CheckImages(ctx context.Context) ([]FsckIssue, error) {
+	if !a.isLocalStore() {
+		return nil, ErrRemoteImages
+	}
+	images, err := a.imageRepo.ListFiltered(ctx, domain.ImageFilter{IncludeMissing: true})
+	if err != nil {
+		return nil, fmt.Errorf("while listing images: %w", err)
+	}
+	entries, err := a.ingestRepo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("while loading ingest index: %w", err)
+	}
+	indexed := make(map[string]*domain.IngestIndexEntry, len(entries))
+	for _, entry := range entries {
+		indexed[entry.Path] = entry
+	}
+
+	var issues []FsckIssue
+	referenced := make(map[string]bool, len(images))
+	for _, img := range images {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		// Missing images were already found to have no file of their own;
+		// their filename may since belong to another image.
+		if !img.MissingAt.IsZero() {
+			issues = append(issues, FsckIssue{Kind: FsckMissingFile, SHA256: img.SHA256, Filename: img.Filename})
+			continue
+		}
+		referenced[img.Filename] = true
+
+		fullPath, err := secureJoin(a.ImagesDir, img.Filename)
+		if err != nil {
+			return nil, err
+		}
+		info, err := os.Stat(fullPath)
+		if errors.Is(err, fs.ErrNotExist) {
+			issues = append(issues, FsckIssue{Kind: FsckMissingFile, SHA256: img.SHA256, Filename: img.Filename})
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("while checking '%s': %w", fullPath, err)
+		}
+
+		entry := indexed[img.Filename]
+		if entry != nil && entry.ImageSHA256 == img.SHA256 &&
+			entry.SizeBytes == info.Size() && entry.ModTime.Equal(info.ModTime()) {
+			continue
+		}
+		actual, err := HashFile(fullPath)
+		if err != nil {
+			return nil, fmt.Errorf("while hashing '%s': %w", fullPath, err)
+		}
+		if actual != img.SHA256 {
+			issues = append(issues, FsckIssue{
+				Kind:         FsckHashMismatch,
+				SHA256:       img.SHA256,
+				Filename:     img.Filename,
+				Path:         fullPath,
+				ActualSHA256: actual,
+			})
+		}
+	}
+
+	err = filepath.WalkDir(a.ImagesDir, func(fullPath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !entry.Type().IsRegular() {
+			return nil
+		}
+		relPath, err := a.relPath(fullPath)
+		if err != nil {
+			return err
+		}
+		if !referenced[relPath] {
+			issues = append(issues, FsckIssue{Kind: FsckOrphanFile, Path: fullPath})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("while listing '%s': %w", a.ImagesDir, err)
+	}
+	return issues, nil
+}
+
+// FixImages resolves issues found by CheckImages using mode (FsckQuarantine,
+// FsckRehash or FsckPurge). Quarantined files are moved to quarantineDir.
+func (a *AnnotatorApp) FixImages(ctx context.Context, issues []FsckIssue, mode, quarantineDir string) error {
+	switch mode {
+	case FsckQuarantine, FsckRehash, FsckPurge:
+	default:
+		return fmt.Errorf("unknown fix mode %q", mode)
+	}
+	if mode == FsckQuarantine {
+		// Quarantined files would be picked up again by the next ingestion
+		absImages, err := filepath.Abs(a.ImagesDir)
+		if err != nil {
+			return err
+		}
+		absQuarantine, err := filepath.Abs(quarantineDir)
+		if err != nil {
+			return err
+		}
+		if absQuarantine == absImages || strings.HasPrefix(absQuarantine, absImages+string(os.PathSeparator)) {
+			return fmt.Errorf("quarantine directory '%s' is inside the images directory", quarantineDir)
+		}
+	}
+
+	for _, issue := range issues {
+		var err error
+		switch {
+		case mode == FsckPurge && issue.SHA256 != "":
+			err = a.deleteImage(ctx, issue.SHA256, "fsck")
+		case mode == FsckPurge:
+			// Orphan files are left alone; purge only touches the database
+		case issue.Kind == FsckMissingFile:
+			err = a.imageRepo.MarkMissing(ctx, issue.SHA256)
+		case mode == FsckQuarantine:
+			err = a.quarantineFile(ctx, issue, quarantineDir)
+		case mode == FsckRehash:
+			err = a.rehashFile(ctx, issue)
+		}
+		if err != nil {
+			return fmt.Errorf("while fixing %s %s%s: %w", issue.Kind, issue.SHA256, issue.Path, err)
+		}
+	}
+	return nil
+}
+
+// quarantineFile moves a file out of ImagesDir, keeping its relative path
+// under quarantineDir. The row of a mismatched file is marked missing since
+// it no longer has a file.
+func (a *AnnotatorApp) quarantineFile(ctx context.Context, issue FsckIssue, quarantineDir string) error {
+	relPath, err := a.relPath(issue.Path)
+	if err != nil {
+		return err
+	}
+	target := filepath.Join(quarantineDir, filepath.FromSlash(relPath))
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return fmt.Errorf("while creating quarantine directory: %w", err)
+	}
+	if err := os.Rename(issue.Path, target); err != nil {
+		return err
+	}
+	if err := a.ingestRepo.Delete(ctx, relPath); err != nil {
+		return err
+	}
+	if issue.Kind == FsckHashMismatch {
+		return a.imageRepo.MarkMissing(ctx, issue.SHA256)
+	}
+	return nil
+}
+
+// rehashFile records a file under the hash of its current content. The row
+// of a mismatched file is marked missing since it no longer has a file.
+func (a *AnnotatorApp) rehashFile(ctx context.Context, issue FsckIssue) error {
+	info, err := os.Stat(issue.Path)
+	if err != nil {
+		return err
+	}
+	relPath, err := a.relPath(issue.Path)
+	if err != nil {
+		return err
+	}
+	if err := a.ingestFile(ctx, localInfo(relPath, info)); err != nil {
+		return err
+	}
+	if issue.Kind == FsckHashMismatch {
+		return a.imageRepo.MarkMissing(ctx, issue.SHA256)
+	}
+	return nil
+}
+
+// checkImagesAtStartup marks rows whose file disappeared while the server was
+// down as missing, so annotators are not served broken images, and logs the
+// other issues for 'rotulador fsck'. Buckets are skipped: the ingestion
+// already marked the images whose object is gone.
+func (a *AnnotatorApp) checkImagesAtStartup(ctx context.Context) error {
+	if !a.isLocalStore() {
+		return nil
+	}
+	issues, err := a.CheckImages(ctx)
+	if err != nil {
+		return fmt.Errorf("while checking images: %w", err)
+	}
+	known, err := a.imageRepo.Count(ctx)
+	if err != nil {
+		return err
+	}
+	counts := make(map[FsckIssueKind]int)
+	for _, issue := range issues {
+		counts[issue.Kind]++
+		if issue.Kind == FsckMissingFile {
+			if err := a.imageRepo.MarkMissing(ctx, issue.SHA256); err != nil {
+				return fmt.Errorf("while marking image '%s' missing: %w", issue.SHA256, err)
+			}
+		}
+	}
+	remaining, err := a.imageRepo.Count(ctx)
+	if err != nil {
+		return err
+	}
+	if known != remaining {
+		a.Logger.Warn("CheckImages: marked images without a file as missing", "count", known-remaining)
+	}
+	if counts[FsckOrphanFile] > 0 || counts[FsckHashMismatch] > 0 {
+		a.Logger.Warn("CheckImages: database and images directory disagree; run 'rotulador fsck' for details",
+			"orphanFiles", counts[FsckOrphanFile],
+			"hashMismatches", counts[FsckHashMismatch])
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package web
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newFsckApp ingests a.png, b.png and c.png, then deletes a.png, rewrites
+// b.png and adds d.png behind the app's back.
+func newFsckApp(t *testing.T) *AnnotatorApp {
+	t.Helper()
+	a := newTestApp(t, &Config{})
+	for i, name := range []string{"a.png", "b.png", "c.png"} {
+		writePNG(t, filepath.Join(a.ImagesDir, name), gradient(16, 16, i))
+	}
+	if err := a.IngestImages(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Remove(filepath.Join(a.ImagesDir, "a.png")); err != nil {
+		t.Fatal(err)
+	}
+	changed := filepath.Join(a.ImagesDir, "b.png")
+	writePNG(t, changed, gradient(24, 24, 1))
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(changed, later, later); err != nil {
+		t.Fatal(err)
+	}
+	writePNG(t, filepath.Join(a.ImagesDir, "d.png"), gradient(32, 32, 0))
+	return a
+}
+
+// issueKinds counts issues per kind
+func issueKinds(issues []FsckIssue) map[FsckIssueKind]int {
+	kinds := make(map[FsckIssueKind]int)
+	for _, issue := range issues {
+		kinds[issue.Kind]++
+	}
+	return kinds
+}
+
+func TestCheckImages(t *testing.T) {
+	a := newFsckApp(t)
+	issues, err := a.CheckImages(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 3 {
+		t.Fatalf("got %d issues, want 3: %+v", len(issues), issues)
+	}
+	for _, issue := range issues {
+		switch issue.Kind {
+		case FsckMissingFile:
+			if issue.Filename != "a.png" {
+				t.Errorf("missing file = %s, want a.png", issue.Filename)
+			}
+		case FsckOrphanFile:
+			if filepath.Base(issue.Path) != "d.png" {
+				t.Errorf("orphan file = %s, want d.png", issue.Path)
+			}
+		case FsckHashMismatch:
+			if issue.Filename != "b.png" || issue.ActualSHA256 == issue.SHA256 {
+				t.Errorf("unexpected mismatch %+v", issue)
+			}
+		}
+	}
+}
+
+func TestFixImages(t *testing.T) {
+	t.Run("quarantine", func(t *testing.T) {
+		a := newFsckApp(t)
+		issues, err := a.CheckImages(t.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+		quarantine := filepath.Join(t.TempDir(), "quarantine")
+		if err := a.FixImages(t.Context(), issues, FsckQuarantine, quarantine); err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{"b.png", "d.png"} {
+			if _, err := os.Stat(filepath.Join(quarantine, name)); err != nil {
+				t.Errorf("%s not quarantined: %v", name, err)
+			}
+		}
+		if count := imageCount(t, a)(); count != 1 {
+			t.Errorf("%d images left in queues, want only c.png", count)
+		}
+		after, err := a.CheckImages(t.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if kinds := issueKinds(after); kinds[FsckOrphanFile] != 0 || kinds[FsckHashMismatch] != 0 {
+			t.Errorf("issues left after quarantine: %+v", after)
+		}
+	})
+
+	t.Run("rehash", func(t *testing.T) {
+		a := newFsckApp(t)
+		issues, err := a.CheckImages(t.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := a.FixImages(t.Context(), issues, FsckRehash, ""); err != nil {
+			t.Fatal(err)
+		}
+		if count := imageCount(t, a)(); count != 3 {
+			t.Errorf("%d images in queues, want b.png, c.png and d.png", count)
+		}
+		after, err := a.CheckImages(t.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if kinds := issueKinds(after); kinds[FsckOrphanFile] != 0 || kinds[FsckHashMismatch] != 0 {
+			t.Errorf("issues left after rehash: %+v", after)
+		}
+	})
+
+	t.Run("purge", func(t *testing.T) {
+		a := newFsckApp(t)
+		issues, err := a.CheckImages(t.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := a.FixImages(t.Context(), issues, FsckPurge, ""); err != nil {
+			t.Fatal(err)
+		}
+		after, err := a.CheckImages(t.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The rows of a.png and the old b.png are gone; both files on disk
+		// are now unrecorded
+		if kinds := issueKinds(after); kinds[FsckMissingFile] != 0 || kinds[FsckHashMismatch] != 0 || kinds[FsckOrphanFile] != 2 {
+			t.Errorf("unexpected issues after purge: %+v", after)
+		}
+	})
+
+	t.Run("unknown mode", func(t *testing.T) {
+		a := newTestApp(t, &Config{})
+		if err := a.FixImages(t.Context(), nil, "shred", ""); err == nil {
+			t.Error("expected error for unknown mode")
+		}
+	})
+}
+
+func TestIngestImagesMarksDeletedFilesMissing(t *testing.T) {
+	a := newTestApp(t, &Config{})
+	for i, name := range []string{"a.png", "b.png"} {
+		writePNG(t, filepath.Join(a.ImagesDir, name), gradient(16, 16, i))
+	}
+	if err := a.IngestImages(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	// Deleted while the server was down
+	if err := os.Remove(filepath.Join(a.ImagesDir, "a.png")); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.IngestImages(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if count := imageCount(t, a)(); count != 1 {
+		t.Errorf("image count = %d, want 1", count)
+	}
+}
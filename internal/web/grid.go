@@ -0,0 +1,202 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lewtec/rotulador/internal/domain"
+	"github.com/lewtec/rotulador/internal/ui/pages"
+)
+
+// DefaultGridSize is how many images a grid page shows unless ?n= says
+// otherwise; maxGridSize bounds it.
+const (
+	DefaultGridSize = 24
+	maxGridSize     = 100
+)
+
+// gridLabelPrefix prefixes the form fields carrying the class picked for
+// each image of a grid page: label:{sha256}={class}.
+const gridLabelPrefix = "label:"
+
+// handleAnnotateGrid serves /annotate/{task}/grid: a page of pending images
+// that all start with a default class, so only the exceptions need a click.
+// The page is submitted as a whole and recorded in one transaction.
+func (a *AnnotatorApp) handleAnnotateGrid(w http.ResponseWriter, r *http.Request) {
+	taskID := pathParts(r.URL.Path)[1]
+	task := a.GetTask(taskID)
+	if task == nil {
+		http.NotFoundHandler().ServeHTTP(w, r)
+		return
+	}
+	// authenticationMiddleware already validated these credentials
+	user, _, _ := r.BasicAuth()
+
+	if r.Method == http.MethodPost {
+		a.submitGrid(w, r, task, user)
+		return
+	}
+
+	classes := classButtons(task)
+	if len(classes) == 0 {
+		http.Error(w, "grid mode needs a task with classes", http.StatusBadRequest)
+		return
+	}
+	query := r.URL.Query()
+	size := DefaultGridSize
+	if v := query.Get("n"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, fmt.Sprintf("invalid n: %q", v), http.StatusBadRequest)
+			return
+		}
+		size = min(n, maxGridSize)
+	}
+	defaultClass := query.Get("default")
+	if defaultClass == "" {
+		defaultClass = classes[0].ID
+	}
+	if _, ok := task.Classes[defaultClass]; !ok {
+		http.Error(w, fmt.Sprintf("unknown class: %q", defaultClass), http.StatusBadRequest)
+		return
+	}
+
+	steps, err := a.selectSteps(r.Context(), taskID, user, size, nil, true)
+	if err != nil {
+		ReportError(r.Context(), err, "msg", "error selecting grid images", "task", taskID)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if len(steps) == 0 {
+		a.redirectAfterTask(w, r, taskID, user)
+		return
+	}
+
+	phaseProgress, err := a.GetPhaseProgressStats(r.Context(), taskID)
+	if err != nil {
+		ReportError(r.Context(), err, "msg", "error getting phase progress")
+		phaseProgress = &PhaseProgress{}
+	}
+
+	images := make([]pages.GridImage, 0, len(steps))
+	for _, step := range steps {
+		images = append(images, pages.GridImage{
+			ID:       step.ImageID,
+			Filename: step.ImageName,
+			URL:      thumbnailURL(step.ImageID),
+		})
+	}
+	err = Render(r.Context(), w, pages.AnnotateGrid(PageShell("annotation"), pages.AnnotateGridData{
+		TaskID:        taskID,
+		TaskName:      task.Name,
+		DefaultClass:  defaultClass,
+		Size:          size,
+		Classes:       classes,
+		Images:        images,
+		PhaseProgress: ProgressUI(phaseProgress),
+	}))
+	if err != nil {
+		ReportError(r.Context(), err, "msg", "error rendering grid template")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// submitGrid records the labels of a grid page and sends the annotator to
+// the next page with the same settings.
+func (a *AnnotatorApp) submitGrid(w http.ResponseWriter, r *http.Request, task *ConfigTask, user string) {
+	if user == "" {
+		w.Header().Set("WWW-Authenticate", `Basic realm="rotulador"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	elapsed, err := parseElapsed(r.PostForm.Get("elapsed_ms"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var annotations []AnnotationResponse
+	for key, values := range r.PostForm {
+		imageID, ok := strings.CutPrefix(key, gridLabelPrefix)
+		if !ok {
+			continue
+		}
+		class := values[len(values)-1]
+		if _, ok := task.Classes[class]; !ok {
+			http.Error(w, fmt.Sprintf("unknown class %q for image %s", class, imageID), http.StatusBadRequest)
+			return
+		}
+		img, err := a.imageRepo.GetBySHA256(r.Context(), imageID)
+		if err != nil {
+			ReportError(r.Context(), err, "msg", "error looking up grid image", "sha256", imageID)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if img == nil {
+			http.Error(w, fmt.Sprintf("%s: %s", ErrImageNotFound, imageID), http.StatusBadRequest)
+			return
+		}
+		annotations = append(annotations, AnnotationResponse{
+			ImageID: imageID,
+			TaskID:  task.ID,
+			User:    user,
+			Value:   class,
+			Sure:    true,
+			Source:  domain.EventSourceWeb,
+		})
+	}
+	if len(annotations) == 0 {
+		http.Error(w, "no labels submitted", http.StatusBadRequest)
+		return
+	}
+	// The page was labeled as a whole; each image gets an equal share
+	for i := range annotations {
+		annotations[i].Duration = elapsed / time.Duration(len(annotations))
+	}
+
+	if err := a.SubmitAnnotations(r.Context(), annotations); err != nil {
+		ReportError(r.Context(), err, "msg", "error while submitting grid annotations", "task", task.ID)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	a.Logger.Info("grid: annotations submitted", "task", task.ID, "user", user, "count", len(annotations))
+
+	next := url.Values{}
+	for _, key := range []string{"default", "n"} {
+		if v := r.PostForm.Get(key); v != "" {
+			next.Set(key, v)
+		}
+	}
+	target := fmt.Sprintf("/annotate/%s/grid", task.ID)
+	if len(next) > 0 {
+		target += "?" + next.Encode()
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+// redirectAfterTask sends a user who finished a task on to the next task
+// with pending images, or home when everything is done.
+func (a *AnnotatorApp) redirectAfterTask(w http.ResponseWriter, r *http.Request, taskID, user string) {
+	step, err := a.NextAnnotationStep(r.Context(), "", user)
+	if err != nil {
+		ReportError(r.Context(), err, "msg", "error while getting next step at the end of task")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	target := "/"
+	if step != nil && step.TaskID != taskID {
+		target = fmt.Sprintf("/help/%s", step.TaskID)
+	} else if step != nil {
+		target = fmt.Sprintf("/annotate/%s/%s", taskID, step.ImageID)
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
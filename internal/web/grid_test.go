@@ -0,0 +1,155 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newGridApp(t *testing.T, images ...string) *AnnotatorApp {
+	t.Helper()
+	a := newTestApp(t, &Config{
+		Tasks: []*ConfigTask{{ID: "quality", Name: "Quality", Classes: map[string]*ConfigClass{
+			"bad":  {Name: "Bad"},
+			"good": {Name: "Good"},
+		}}},
+	})
+	for _, hash := range images {
+		if _, err := a.imageRepo.Create(t.Context(), hash, hash+".png"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return a
+}
+
+func gridRequest(a *AnnotatorApp, method, target string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	req.SetBasicAuth("alice", "")
+	rec := httptest.NewRecorder()
+	a.handleAnnotateGrid(rec, req)
+	return rec
+}
+
+func TestAnnotateGridPage(t *testing.T) {
+	a := newGridApp(t, "hash1", "hash2", "hash3")
+
+	rec := gridRequest(a, http.MethodGet, "/annotate/quality/grid?n=2&default=good", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	body := rec.Body.String()
+	if got := strings.Count(body, `class="grid-tile `); got != 2 {
+		t.Errorf("grid shows %d tiles, want 2", got)
+	}
+	if got := strings.Count(body, `value="good"></button>`); got != 2 {
+		t.Errorf("%d tiles default to good, want 2", got)
+	}
+	if !strings.Contains(body, `src="/asset/hash`) || !strings.Contains(body, `?w=256"`) {
+		t.Error("grid tiles do not use thumbnails")
+	}
+
+	// The images on the page are reserved for alice
+	leases, err := a.leaseRepo.ListActive(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leases) != 2 {
+		t.Errorf("active leases = %+v, want the 2 images on the page", leases)
+	}
+
+	for target, want := range map[string]int{
+		"/annotate/quality/grid?n=zero":       http.StatusBadRequest,
+		"/annotate/quality/grid?default=meh":  http.StatusBadRequest,
+		"/annotate/missing/grid":              http.StatusNotFound,
+		"/annotate/quality/grid?default=good": http.StatusOK,
+	} {
+		if rec := gridRequest(a, http.MethodGet, target, nil); rec.Code != want {
+			t.Errorf("GET %s status = %d, want %d", target, rec.Code, want)
+		}
+	}
+}
+
+func TestAnnotateGridSubmit(t *testing.T) {
+	a := newGridApp(t, "hash1", "hash2", "hash3")
+	ctx := t.Context()
+	if rec := gridRequest(a, http.MethodGet, "/annotate/quality/grid?n=3", nil); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+
+	rec := gridRequest(a, http.MethodPost, "/annotate/quality/grid", url.Values{
+		"default":     {"good"},
+		"n":           {"3"},
+		"label:hash1": {"good"},
+		"label:hash2": {"bad"},
+		"label:hash3": {"good"},
+		"elapsed_ms":  {"4500"},
+	})
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	if got, want := rec.Header().Get("Location"), "/annotate/quality/grid?default=good&n=3"; got != want {
+		t.Errorf("redirect = %q, want %q", got, want)
+	}
+	for hash, want := range map[string]string{"hash1": "good", "hash2": "bad", "hash3": "good"} {
+		annotation, err := a.annotationRepo.Get(ctx, hash, "alice", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if annotation == nil || annotation.OptionValue != want {
+			t.Errorf("annotation of %s = %+v, want %s", hash, annotation, want)
+		}
+		// The time on the page is split between its images
+		if annotation != nil && annotation.Duration != 1500*time.Millisecond {
+			t.Errorf("duration of %s = %s, want 1.5s", hash, annotation.Duration)
+		}
+	}
+	leases, err := a.leaseRepo.ListActive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leases) != 0 {
+		t.Errorf("active leases = %+v, want none after submitting", leases)
+	}
+}
+
+func TestAnnotateGridSubmitRejectsWholeBatch(t *testing.T) {
+	a := newGridApp(t, "hash1", "hash2")
+	ctx := t.Context()
+
+	for name, form := range map[string]url.Values{
+		"unknown class": {"label:hash1": {"good"}, "label:hash2": {"meh"}},
+		"unknown image": {"label:hash1": {"good"}, "label:nope": {"good"}},
+		"no labels":     {"default": {"good"}},
+		"bad elapsed":   {"label:hash1": {"good"}, "elapsed_ms": {"-1"}},
+	} {
+		if rec := gridRequest(a, http.MethodPost, "/annotate/quality/grid", form); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", name, rec.Code)
+		}
+	}
+	if n, err := a.annotationRepo.CountByUser(ctx, "alice"); err != nil || n != 0 {
+		t.Errorf("alice has %d annotations (err %v), want none from rejected pages", n, err)
+	}
+}
+
+func TestSubmitAnnotationsRollsBack(t *testing.T) {
+	a := newGridApp(t, "hash1", "hash2")
+	ctx := t.Context()
+
+	err := a.SubmitAnnotations(ctx, []AnnotationResponse{
+		{ImageID: "hash1", TaskID: "quality", User: "alice", Value: "good"},
+		{ImageID: "hash2", TaskID: "missing", User: "alice", Value: "good"},
+	})
+	if !errors.Is(err, ErrTaskNotFound) {
+		t.Fatalf("SubmitAnnotations error = %v, want ErrTaskNotFound", err)
+	}
+	if n, err := a.annotationRepo.CountByUser(ctx, "alice"); err != nil || n != 0 {
+		t.Errorf("alice has %d annotations (err %v), want the batch rolled back", n, err)
+	}
+}
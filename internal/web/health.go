@@ -0,0 +1,111 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// HealthStatus is the body of /healthz.
+type HealthStatus struct {
+	// Status is "ok" or "unavailable"
+	Status string `json:"status"`
+	// Error explains why the database did not answer
+	Error string `json:"error,omitempty"`
+}
+
+// ReadyStatus is the body of /readyz.
+type ReadyStatus struct {
+	Ready bool `json:"ready"`
+	// Migrated is set once the database schema is up to date
+	Migrated bool         `json:"migrated"`
+	Ingest   IngestStatus `json:"ingest"`
+}
+
+// IngestStatus describes the ingestion of the images directory for /readyz.
+type IngestStatus struct {
+	Running bool `json:"running"`
+	// Finished is set once the initial ingestion is over, even if it failed
+	Finished  bool `json:"finished"`
+	Total     int  `json:"total"`
+	Processed int  `json:"processed"`
+	Skipped   int  `json:"skipped"`
+	Percent   int  `json:"percent"`
+	// RequiredPercent is how much has to be done for the server to be ready
+	RequiredPercent int        `json:"required_percent"`
+	StartedAt       *time.Time `json:"started_at,omitempty"`
+	FinishedAt      *time.Time `json:"finished_at,omitempty"`
+	LastError       string     `json:"last_error,omitempty"`
+	LastErrorAt     *time.Time `json:"last_error_at,omitempty"`
+}
+
+// handleHealthz reports whether the process is up and the database answers.
+func (a *AnnotatorApp) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	status := HealthStatus{Status: "ok"}
+	code := http.StatusOK
+	if err := a.Database.PingContext(r.Context()); err != nil {
+		status = HealthStatus{Status: "unavailable", Error: err.Error()}
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, r, code, status)
+}
+
+// handleReadyz reports whether the server is ready for annotators: the
+// migrations ran and enough of the initial ingestion is done.
+func (a *AnnotatorApp) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	status := a.ReadyStatus()
+	code := http.StatusOK
+	if !status.Ready {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, r, code, status)
+}
+
+// ReadyStatus returns the readiness of the server and what it is waiting for.
+func (a *AnnotatorApp) ReadyStatus() ReadyStatus {
+	a.ingest.mu.Lock()
+	p, finished := a.ingest.progress, a.ingest.finished
+	a.ingest.mu.Unlock()
+
+	ingest := IngestStatus{
+		Running:         p.Running,
+		Finished:        finished,
+		Total:           p.Total,
+		Processed:       p.Processed,
+		Skipped:         p.Skipped,
+		RequiredPercent: a.Config.Ready.MinIngestPercent(),
+		StartedAt:       optionalTime(p.StartedAt),
+		FinishedAt:      optionalTime(p.FinishedAt),
+		LastError:       p.LastError,
+		LastErrorAt:     optionalTime(p.LastErrorAt),
+	}
+	switch {
+	case finished:
+		ingest.Percent = 100
+	case p.Total > 0:
+		ingest.Percent = p.Done() * 100 / p.Total
+	}
+
+	ingested := finished || (ingest.RequiredPercent < 100 && ingest.Percent >= ingest.RequiredPercent)
+	return ReadyStatus{
+		Ready:    a.migrated.Load() && ingested,
+		Migrated: a.migrated.Load(),
+		Ingest:   ingest,
+	}
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		ReportError(r.Context(), err, "msg", "error encoding JSON response")
+	}
+}
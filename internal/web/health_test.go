@@ -0,0 +1,80 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func probe(t *testing.T, handler http.Handler, target string, v any) int {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	if err := json.NewDecoder(rec.Body).Decode(v); err != nil {
+		t.Fatalf("%s: %v", target, err)
+	}
+	return rec.Code
+}
+
+func TestReadyz(t *testing.T) {
+	a := newTestApp(t, &Config{Authentication: map[string]*ConfigAuth{"admin": {Admin: true}}})
+	handler := a.GetHTTPHandler()
+	ctx := t.Context()
+
+	var ready ReadyStatus
+	if code := probe(t, handler, "/readyz", &ready); code != http.StatusServiceUnavailable || ready.Ready || ready.Migrated {
+		t.Fatalf("/readyz before migrations = %d %+v, want 503", code, ready)
+	}
+	if err := a.PrepareDatabaseMigrations(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	a.ingest.update(func(p *IngestProgress) {
+		*p = IngestProgress{Running: true, Total: 10, Processed: 4, Skipped: 2}
+	})
+	if code := probe(t, handler, "/readyz", &ready); code != http.StatusServiceUnavailable || ready.Ingest.Percent != 60 || ready.Ingest.RequiredPercent != 100 {
+		t.Fatalf("/readyz during ingestion = %d %+v, want 503 at 60%%", code, ready)
+	}
+	a.Config.Ready.IngestPercent = 50
+	if code := probe(t, handler, "/readyz", &ready); code != http.StatusOK || !ready.Ready {
+		t.Errorf("/readyz past ready.ingest_percent = %d %+v, want 200", code, ready)
+	}
+	a.Config.Ready.IngestPercent = 0
+
+	if err := a.IngestImages(ctx); err != nil {
+		t.Fatal(err)
+	}
+	a.recordIngestError(errors.New("disk on fire"))
+	if code := probe(t, handler, "/readyz", &ready); code != http.StatusOK || !ready.Ingest.Finished || ready.Ingest.Percent != 100 {
+		t.Fatalf("/readyz after ingestion = %d %+v, want 200", code, ready)
+	}
+	if ready.Ingest.LastError != "disk on fire" || ready.Ingest.LastErrorAt == nil || ready.Ingest.FinishedAt == nil {
+		t.Errorf("/readyz ingest = %+v, want the last error and when ingestion finished", ready.Ingest)
+	}
+}
+
+func TestHealthz(t *testing.T) {
+	a := newTestApp(t, &Config{Authentication: map[string]*ConfigAuth{"admin": {Admin: true}}})
+	handler := a.GetHTTPHandler()
+
+	var health HealthStatus
+	if code := probe(t, handler, "/healthz", &health); code != http.StatusOK || health.Status != "ok" {
+		t.Fatalf("/healthz = %d %+v, want 200 without credentials", code, health)
+	}
+	if err := a.Database.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if code := probe(t, handler, "/healthz", &health); code != http.StatusServiceUnavailable || health.Error == "" {
+		t.Errorf("/healthz with the database closed = %d %+v, want 503", code, health)
+	}
+}
+
+func TestConfigReadyMinIngestPercent(t *testing.T) {
+	for _, tt := range []struct{ percent, want int }{{0, 100}, {80, 80}, {-1, 0}} {
+		if got := (ConfigReady{IngestPercent: tt.percent}).MinIngestPercent(); got != tt.want {
+			t.Errorf("MinIngestPercent() with %d = %d, want %d", tt.percent, got, tt.want)
+		}
+	}
+}
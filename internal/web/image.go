@@ -1,8 +1,10 @@
 package web
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"image"
 	_ "image/gif"
@@ -10,9 +12,15 @@ import (
 	"image/png"
 	"io"
 	"os"
-	"path/filepath"
+	"strings"
+	"time"
 
-	"github.com/google/uuid"
+	"github.com/lewtec/rotulador/internal/domain"
+	"github.com/lewtec/rotulador/internal/storage"
+	"github.com/rwcarlsen/goexif/exif"
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
 )
 
 func DecodeImage(path string) (image.Image, error) {
@@ -32,38 +40,159 @@ func DecodeImage(path string) (image.Image, error) {
 	return m, nil
 }
 
-func IngestImage(img image.Image, outputDir string) error {
-	tempFile := filepath.Join(outputDir, fmt.Sprintf("%s.png", uuid.New()))
-	f, err := os.Create(tempFile)
+// InspectImage decodes the image at path and describes the file: decoder
+// format, dimensions and size on disk. SourcePath is left for the caller.
+func InspectImage(path string) (image.Image, domain.ImageMetadata, error) {
+	f, err := os.Open(path)
 	if err != nil {
-		return err
+		return nil, domain.ImageMetadata{}, err
 	}
-
-	hasher := sha256.New()
-	w := io.MultiWriter(f, hasher)
-	if err := png.Encode(w, img); err != nil {
-		if closeErr := f.Close(); closeErr != nil {
-			ReportError(context.Background(), closeErr, "msg", "failed to close temp image after encode error", "path", tempFile)
-		}
-		if removeErr := os.Remove(tempFile); removeErr != nil {
-			ReportError(context.Background(), removeErr, "msg", "failed to remove temp file after encode error", "path", tempFile)
+	defer func() {
+		if err := f.Close(); err != nil {
+			ReportError(context.Background(), err, "msg", "failed to close image file", "path", path)
 		}
-		return err
+	}()
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, domain.ImageMetadata{}, err
 	}
+	return inspectImage(f, stat.Size())
+}
+
+// InspectImageBytes is InspectImage for an image already held in memory,
+// such as an archive member.
+func InspectImageBytes(data []byte) (image.Image, domain.ImageMetadata, error) {
+	return inspectImage(bytes.NewReader(data), int64(len(data)))
+}
+
+// MaxImagePixels bounds the dimensions accepted by InspectImage, so a small
+// file declaring a huge canvas cannot exhaust memory when decoded.
+const MaxImagePixels = 1 << 28
+
+// ErrImageTooLarge is returned for images above MaxImagePixels.
+var ErrImageTooLarge = errors.New("image dimensions exceed the pixel limit")
 
-	if err := f.Close(); err != nil {
-		if removeErr := os.Remove(tempFile); removeErr != nil {
-			ReportError(context.Background(), removeErr, "msg", "failed to remove temp file after close error", "path", tempFile)
+func inspectImage(r io.ReadSeeker, size int64) (image.Image, domain.ImageMetadata, error) {
+	config, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, domain.ImageMetadata{}, err
+	}
+	if int64(config.Width)*int64(config.Height) > MaxImagePixels {
+		return nil, domain.ImageMetadata{}, fmt.Errorf("%w: %dx%d", ErrImageTooLarge, config.Width, config.Height)
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, domain.ImageMetadata{}, err
+	}
+	m, format, err := image.Decode(r)
+	if err != nil {
+		return nil, domain.ImageMetadata{}, err
+	}
+	b := m.Bounds()
+	meta := domain.ImageMetadata{
+		Format:    format,
+		Width:     b.Dx(),
+		Height:    b.Dy(),
+		SizeBytes: size,
+	}
+	if format == "jpeg" {
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			return nil, domain.ImageMetadata{}, err
 		}
-		return err
+		meta.CapturedAt, meta.Camera = readEXIF(r)
+	}
+	return m, meta, nil
+}
+
+// readEXIF returns the capture time and camera recorded in EXIF, or zero
+// values when r carries none. EXIF times have no zone, so the wall clock is
+// kept as UTC.
+func readEXIF(r io.Reader) (time.Time, string) {
+	x, err := exif.Decode(r)
+	if err != nil {
+		return time.Time{}, ""
+	}
+
+	var capturedAt time.Time
+	if t, err := x.DateTime(); err == nil {
+		capturedAt = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+	}
+
+	var maker, model string
+	if tag, err := x.Get(exif.Make); err == nil {
+		maker, _ = tag.StringVal()
+	}
+	if tag, err := x.Get(exif.Model); err == nil {
+		model, _ = tag.StringVal()
 	}
+	maker, model = strings.TrimSpace(maker), strings.TrimSpace(model)
+	// Most vendors repeat the make in the model ("Canon" / "Canon EOS 5D")
+	camera := model
+	if maker != "" && !strings.HasPrefix(strings.ToLower(model), strings.ToLower(maker)) {
+		camera = strings.TrimSpace(maker + " " + model)
+	}
+	return capturedAt, camera
+}
+
+// browserFormats are the decoder formats every browser displays as is.
+// Images stored in other formats are converted to PNG when served.
+var browserFormats = map[string]bool{"jpeg": true, "png": true, "gif": true, "webp": true, "bmp": true}
 
-	finalPath := filepath.Join(outputDir, fmt.Sprintf("%x.png", hasher.Sum(nil)))
-	if err := os.Rename(tempFile, finalPath); err != nil {
-		if removeErr := os.Remove(tempFile); removeErr != nil {
-			ReportError(context.Background(), removeErr, "msg", "failed to remove temp file", "path", tempFile)
+// IsBrowserFormat reports whether browsers can display the decoder format.
+func IsBrowserFormat(format string) bool {
+	return browserFormats[format]
+}
+
+// formatExtension returns the file extension used for a decoder format name.
+func formatExtension(format string) string {
+	if format == "jpeg" {
+		return "jpg"
+	}
+	return strings.ToLower(format)
+}
+
+// IngestImage re-encodes img as PNG into store as <sha256>.png and returns
+// the file name.
+func IngestImage(ctx context.Context, img image.Image, store storage.Store) (string, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+	return putHashed(ctx, store, buf.Bytes(), "png")
+}
+
+// IngestOriginal copies the file at path into store unchanged as
+// <sha256>.<ext>, so the stored hash matches the source file and metadata
+// such as EXIF and ICC profiles survive. The caller is expected to have
+// validated that the file decodes as format. Returns the file name.
+func IngestOriginal(ctx context.Context, path, format string, store storage.Store) (string, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err := src.Close(); err != nil {
+			ReportError(ctx, err, "msg", "failed to close source image", "path", path)
 		}
-		return err
+	}()
+	return IngestOriginalFrom(ctx, src, format, store)
+}
+
+// IngestOriginalFrom is IngestOriginal for bytes that do not come from a
+// file of their own, such as an archive member.
+func IngestOriginalFrom(ctx context.Context, src io.Reader, format string, store storage.Store) (string, error) {
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return "", err
+	}
+	return putHashed(ctx, store, data, formatExtension(format))
+}
+
+// putHashed stores data as <sha256>.<ext>. The name is only known once the
+// whole image is hashed, hence data is held in memory rather than streamed.
+func putHashed(ctx context.Context, store storage.Store, data []byte, ext string) (string, error) {
+	name := fmt.Sprintf("%x.%s", sha256.Sum256(data), ext)
+	if err := store.Put(ctx, name, bytes.NewReader(data), int64(len(data))); err != nil {
+		return "", fmt.Errorf("while storing '%s': %w", name, err)
 	}
-	return nil
+	return name, nil
 }
@@ -1,12 +1,19 @@
 package web
 
 import (
+	"bytes"
+	"encoding/binary"
 	"image"
 	"image/color"
+	"image/jpeg"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/lewtec/rotulador/internal/domain"
+	"github.com/lewtec/rotulador/internal/storage"
 )
 
 func TestIngestImageWritesHashedPNG(t *testing.T) {
@@ -14,7 +21,8 @@ func TestIngestImageWritesHashedPNG(t *testing.T) {
 	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
 	img.Set(0, 0, color.RGBA{R: 255, A: 255})
 
-	if err := IngestImage(img, dir); err != nil {
+	written, err := IngestImage(t.Context(), img, storage.NewLocal(dir))
+	if err != nil {
 		t.Fatalf("IngestImage: %v", err)
 	}
 
@@ -26,6 +34,9 @@ func TestIngestImageWritesHashedPNG(t *testing.T) {
 		t.Fatalf("expected 1 file, got %d", len(entries))
 	}
 	name := entries[0].Name()
+	if name != written {
+		t.Fatalf("IngestImage returned %q, wrote %q", written, name)
+	}
 	if !strings.HasSuffix(name, ".png") || len(name) != 64+4 {
 		t.Fatalf("expected sha256.png filename, got %q", name)
 	}
@@ -65,7 +76,7 @@ func TestIngestImageCleansTempOnEncodeFailure(t *testing.T) {
 	if err := os.WriteFile(notDir, []byte("x"), 0o644); err != nil {
 		t.Fatal(err)
 	}
-	err := IngestImage(img, notDir)
+	_, err := IngestImage(t.Context(), img, storage.NewLocal(notDir))
 	if err == nil {
 		t.Fatal("expected error when outputDir is a file")
 	}
@@ -78,3 +89,118 @@ func TestIngestImageCleansTempOnEncodeFailure(t *testing.T) {
 		t.Fatalf("unexpected entries after failed ingest: %v", entries)
 	}
 }
+
+func TestIngestOriginalKeepsBytes(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "photo.jpeg")
+	f, err := os.Create(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := jpeg.Encode(f, gradient(32, 16, 0), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, meta, err := InspectImage(src)
+	if err != nil {
+		t.Fatalf("InspectImage: %v", err)
+	}
+	stat, err := os.Stat(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := domain.ImageMetadata{Format: "jpeg", Width: 32, Height: 16, SizeBytes: stat.Size()}
+	if meta != want {
+		t.Errorf("InspectImage metadata = %+v, want %+v", meta, want)
+	}
+
+	dir := t.TempDir()
+	name, err := IngestOriginal(t.Context(), src, meta.Format, storage.NewLocal(dir))
+	if err != nil {
+		t.Fatalf("IngestOriginal: %v", err)
+	}
+	sum, err := HashFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != sum+".jpg" {
+		t.Errorf("IngestOriginal name = %q, want %q", name, sum+".jpg")
+	}
+	original, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copied, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(original, copied) {
+		t.Error("copied bytes differ from the source file")
+	}
+}
+
+// withEXIF inserts an APP1 segment carrying Make, Model and DateTime right
+// after the SOI marker of a JPEG stream.
+func withEXIF(t *testing.T, jpg []byte, maker, model, dateTime string) []byte {
+	t.Helper()
+	type entry struct {
+		tag   uint16
+		value string
+	}
+	entries := []entry{{0x010F, maker}, {0x0110, model}, {0x0132, dateTime}}
+
+	var tiff bytes.Buffer
+	le := binary.LittleEndian
+	tiff.WriteString("II")
+	_ = binary.Write(&tiff, le, uint16(42))
+	_ = binary.Write(&tiff, le, uint32(8))
+	_ = binary.Write(&tiff, le, uint16(len(entries)))
+	dataOffset := uint32(8 + 2 + 12*len(entries) + 4)
+	var data bytes.Buffer
+	for _, e := range entries {
+		value := e.value + "\x00"
+		_ = binary.Write(&tiff, le, e.tag)
+		_ = binary.Write(&tiff, le, uint16(2)) // ASCII
+		_ = binary.Write(&tiff, le, uint32(len(value)))
+		_ = binary.Write(&tiff, le, dataOffset+uint32(data.Len()))
+		data.WriteString(value)
+	}
+	_ = binary.Write(&tiff, le, uint32(0)) // no next IFD
+	tiff.Write(data.Bytes())
+
+	payload := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	var out bytes.Buffer
+	out.Write(jpg[:2])
+	out.Write([]byte{0xFF, 0xE1})
+	_ = binary.Write(&out, binary.BigEndian, uint16(len(payload)+2))
+	out.Write(payload)
+	out.Write(jpg[2:])
+	return out.Bytes()
+}
+
+func TestInspectImageReadsEXIF(t *testing.T) {
+	var jpg bytes.Buffer
+	if err := jpeg.Encode(&jpg, gradient(8, 8, 0), nil); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "shot.jpg")
+	if err := os.WriteFile(path, withEXIF(t, jpg.Bytes(), "Canon", "Canon EOS 5D", "2024:05:01 10:30:00"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, meta, err := InspectImage(path)
+	if err != nil {
+		t.Fatalf("InspectImage: %v", err)
+	}
+	if want := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC); !meta.CapturedAt.Equal(want) {
+		t.Errorf("CapturedAt = %v, want %v", meta.CapturedAt, want)
+	}
+	if meta.Camera != "Canon EOS 5D" {
+		t.Errorf("Camera = %q, want %q", meta.Camera, "Canon EOS 5D")
+	}
+	if meta.Width != 8 || meta.Format != "jpeg" {
+		t.Errorf("metadata = %+v, want 8px wide jpeg", meta)
+	}
+}
@@ -0,0 +1,343 @@
+package web
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lewtec/rotulador/internal/domain"
+	"github.com/lewtec/rotulador/internal/storage"
+	"github.com/lewtec/rotulador/internal/tracing"
+	"github.com/lewtec/rotulador/internal/ui/pages"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ingestLogInterval is how often IngestImages logs its progress.
+const ingestLogInterval = 5 * time.Second
+
+// IngestProgress is a snapshot of the images directory ingestion.
+type IngestProgress struct {
+	Running bool
+	// Total is the number of files found in the images directory
+	Total int
+	// Processed files were decoded and hashed, Skipped ones were unchanged
+	Processed int
+	Skipped   int
+	StartedAt time.Time
+	// FinishedAt is zero while running
+	FinishedAt time.Time
+	// LastError is the last failure of the ingestion or of the watcher
+	LastError   string
+	LastErrorAt time.Time
+}
+
+// Done returns how many files have been handled so far.
+func (p IngestProgress) Done() int {
+	return p.Processed + p.Skipped
+}
+
+// Rate returns the files handled per second.
+func (p IngestProgress) Rate() float64 {
+	end := p.FinishedAt
+	if end.IsZero() {
+		end = time.Now()
+	}
+	elapsed := end.Sub(p.StartedAt).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(p.Done()) / elapsed
+}
+
+// ETA estimates the time left at the current rate, zero when unknown.
+func (p IngestProgress) ETA() time.Duration {
+	rate := p.Rate()
+	if !p.Running || rate == 0 {
+		return 0
+	}
+	return time.Duration(float64(p.Total-p.Done()) / rate * float64(time.Second))
+}
+
+// ingestState guards the progress shared between IngestImages and handlers.
+type ingestState struct {
+	mu       sync.Mutex
+	progress IngestProgress
+	// finished is set once the first ingestion is over, for /readyz
+	finished bool
+}
+
+func (s *ingestState) update(fn func(p *IngestProgress)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&s.progress)
+}
+
+// recordIngestError keeps err as the last ingestion error for /readyz and
+// counts it in the metrics.
+func (a *AnnotatorApp) recordIngestError(err error) {
+	ingestErrors.Inc()
+	a.ingest.update(func(p *IngestProgress) {
+		p.LastError = err.Error()
+		p.LastErrorAt = time.Now()
+	})
+}
+
+// IngestProgress returns the progress of the current or last ingestion.
+func (a *AnnotatorApp) IngestProgress() IngestProgress {
+	a.ingest.mu.Lock()
+	defer a.ingest.mu.Unlock()
+	return a.ingest.progress
+}
+
+// ingestStatusUI converts a running ingestion for the home page, nil otherwise.
+func ingestStatusUI(p IngestProgress) *pages.IngestStatus {
+	if !p.Running {
+		return nil
+	}
+	status := &pages.IngestStatus{
+		Done:  p.Done(),
+		Total: p.Total,
+		Rate:  fmt.Sprintf("%.1f/s", p.Rate()),
+		ETA:   "-",
+	}
+	if p.Total > 0 {
+		status.Percent = p.Done() * 100 / p.Total
+	}
+	if eta := p.ETA(); eta > 0 {
+		status.ETA = eta.Round(time.Second).String()
+	}
+	return status
+}
+
+// relPath returns the slash-separated path of fullPath relative to ImagesDir.
+func (a *AnnotatorApp) relPath(fullPath string) (string, error) {
+	relPath, err := filepath.Rel(a.ImagesDir, fullPath)
+	if err != nil {
+		return "", err
+	}
+	relPath = filepath.ToSlash(relPath)
+	if relPath == "." || relPath == ".." || strings.HasPrefix(relPath, "../") {
+		return "", fmt.Errorf("%w: %s", ErrPathTraversal, fullPath)
+	}
+	return relPath, nil
+}
+
+// IngestImages scans the images directory and loads all images into the database.
+// Files whose size and modification time match the ingest index are skipped,
+// so only new or changed files are decoded and hashed.
+// This can be called asynchronously after the HTTP server starts.
+func (a *AnnotatorApp) IngestImages(ctx context.Context) error {
+	ctx, span := tracing.Start(ctx, "IngestImages", attribute.String("rotulador.images_dir", a.ImagesDir))
+	defer span.End()
+
+	a.Logger.Info("IngestImages: starting image ingestion from directory", "dir", a.ImagesDir)
+	a.ingest.update(func(p *IngestProgress) {
+		*p = IngestProgress{Running: true, StartedAt: time.Now()}
+	})
+
+	err := a.ingestImages(ctx)
+	a.ingest.mu.Lock()
+	a.ingest.progress.Running = false
+	a.ingest.progress.FinishedAt = time.Now()
+	a.ingest.finished = true
+	progress := a.ingest.progress
+	a.ingest.mu.Unlock()
+	span.SetAttributes(
+		attribute.Int("rotulador.ingest.total", progress.Total),
+		attribute.Int("rotulador.ingest.processed", progress.Processed),
+		attribute.Int("rotulador.ingest.skipped", progress.Skipped),
+	)
+	if err != nil {
+		tracing.Fail(span, err)
+		if !errors.Is(err, context.Canceled) {
+			a.recordIngestError(err)
+		}
+		return fmt.Errorf("while ingesting images: %w", err)
+	}
+	if err := a.checkImagesAtStartup(ctx); err != nil {
+		ReportError(ctx, err, "msg", "startup image check failed")
+	}
+
+	a.Logger.Info("IngestImages: completed successfully!",
+		"processed", progress.Processed,
+		"skipped", progress.Skipped,
+		"duration", progress.FinishedAt.Sub(progress.StartedAt).Round(time.Millisecond))
+	return nil
+}
+
+func (a *AnnotatorApp) ingestImages(ctx context.Context) error {
+	// The name of a file in the store is the filename recorded for the image
+	var files []storage.Info
+	err := a.Store.Walk(ctx, func(info storage.Info) error {
+		files = append(files, info)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	a.ingest.update(func(p *IngestProgress) { p.Total = len(files) })
+
+	entries, err := a.ingestRepo.List(ctx)
+	if err != nil {
+		return fmt.Errorf("while loading ingest index: %w", err)
+	}
+	known := make(map[string]*domain.IngestIndexEntry, len(entries))
+	for _, entry := range entries {
+		known[entry.Path] = entry
+	}
+
+	lastLog := time.Now()
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		entry, ok := known[file.Name]
+		delete(known, file.Name)
+		if ok && entry.SizeBytes == file.Size && entry.ModTime.Equal(file.ModTime) {
+			a.ingest.update(func(p *IngestProgress) { p.Skipped++ })
+		} else {
+			if err := a.ingestFile(ctx, file); err != nil {
+				return err
+			}
+			a.ingest.update(func(p *IngestProgress) { p.Processed++ })
+		}
+
+		if time.Since(lastLog) >= ingestLogInterval {
+			lastLog = time.Now()
+			progress := a.IngestProgress()
+			a.Logger.Info("IngestImages: progress",
+				"done", progress.Done(),
+				"total", progress.Total,
+				"rate", fmt.Sprintf("%.1f/s", progress.Rate()),
+				"eta", progress.ETA().Round(time.Second))
+		}
+	}
+
+	// Whatever is left in the index no longer exists in the store
+	for path := range known {
+		if err := a.forgetPath(ctx, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// forgetPath drops a file that left the images directory from the ingest
+// index. When no other file holds the same image, the image is marked missing
+// or deleted according to Config.Watch.OnRemove.
+func (a *AnnotatorApp) forgetPath(ctx context.Context, relPath string) error {
+	entry, err := a.ingestRepo.Get(ctx, relPath)
+	if err != nil {
+		return fmt.Errorf("while looking up ingest index entry '%s': %w", relPath, err)
+	}
+	if entry == nil {
+		return nil
+	}
+	if err := a.ingestRepo.Delete(ctx, relPath); err != nil {
+		return fmt.Errorf("while pruning ingest index entry '%s': %w", relPath, err)
+	}
+	return a.releaseImage(ctx, entry.ImageSHA256, relPath)
+}
+
+// releaseImage marks an image missing, or deletes it, once relPath was the
+// last file holding it.
+func (a *AnnotatorApp) releaseImage(ctx context.Context, sha256, relPath string) error {
+	remaining, err := a.ingestRepo.CountForImage(ctx, sha256)
+	if err != nil {
+		return fmt.Errorf("while counting files of image '%s': %w", sha256, err)
+	}
+	if remaining > 0 {
+		return nil
+	}
+
+	if a.Config.Watch.OnRemove == OnRemoveDelete {
+		a.Logger.Info("IngestImages: deleting removed image", "path", relPath, "sha256", sha256)
+		if err := a.deleteImage(ctx, sha256, "ingest"); err != nil {
+			return fmt.Errorf("while deleting image '%s': %w", sha256, err)
+		}
+		return nil
+	}
+	a.Logger.Info("IngestImages: marking removed image as missing", "path", relPath, "sha256", sha256)
+	if err := a.imageRepo.MarkMissing(ctx, sha256); err != nil {
+		return fmt.Errorf("while marking image '%s' missing: %w", sha256, err)
+	}
+	return nil
+}
+
+// ingestFile decodes, hashes and records a single new or changed file.
+func (a *AnnotatorApp) ingestFile(ctx context.Context, file storage.Info) (err error) {
+	ctx, span := tracing.Start(ctx, "ingestFile", attribute.String("rotulador.path", file.Name))
+	defer func() {
+		tracing.Fail(span, err)
+		span.End()
+	}()
+
+	a.Logger.Debug("IngestImages: processing image", "path", file.Name)
+
+	data, err := a.readImage(ctx, file.Name)
+	if err != nil {
+		return fmt.Errorf("while reading image '%s': %w", file.Name, err)
+	}
+
+	// Verify it's an image
+	img, meta, err := InspectImageBytes(data)
+	if err != nil {
+		return fmt.Errorf("while checking if item '%s' is an image: %w", file.Name, err)
+	}
+
+	// Hash the file to get SHA256
+	fileHash := fmt.Sprintf("%x", sha256.Sum256(data))
+
+	// Use repository to create image (with upsert behavior via ON CONFLICT).
+	// The source path is only known to 'ingest', so it is left untouched here.
+	_, err = a.imageRepo.CreateWithMetadata(ctx, fileHash, file.Name, meta)
+	if err != nil && !isSQLiteConstraint(err) {
+		return fmt.Errorf("while inserting image '%s': %w", file.Name, err)
+	}
+
+	// The image is already decoded, so the perceptual hash is nearly free
+	if err := a.imageRepo.SetPHash(ctx, fileHash, PerceptualHash(img)); err != nil {
+		return fmt.Errorf("while storing perceptual hash of '%s': %w", file.Name, err)
+	}
+
+	previous, err := a.ingestRepo.Get(ctx, file.Name)
+	if err != nil {
+		return fmt.Errorf("while looking up ingest index entry '%s': %w", file.Name, err)
+	}
+	err = a.ingestRepo.Put(ctx, domain.IngestIndexEntry{
+		Path:        file.Name,
+		SizeBytes:   file.Size,
+		ModTime:     file.ModTime,
+		ImageSHA256: fileHash,
+	})
+	if err != nil {
+		return fmt.Errorf("while indexing '%s': %w", file.Name, err)
+	}
+
+	// The file was overwritten with a different image
+	if previous != nil && previous.ImageSHA256 != fileHash {
+		return a.releaseImage(ctx, previous.ImageSHA256, file.Name)
+	}
+	return nil
+}
+
+// readImage reads a whole file of the store.
+func (a *AnnotatorApp) readImage(ctx context.Context, name string) ([]byte, error) {
+	f, err := a.Store.Open(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			ReportError(ctx, err, "msg", "failed to close image file", "path", name)
+		}
+	}()
+	return io.ReadAll(f)
+}
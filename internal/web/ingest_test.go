@@ -0,0 +1,174 @@
+package web
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lewtec/rotulador/internal/domain"
+	"github.com/lewtec/rotulador/internal/storage"
+)
+
+func TestIngestImagesSkipsUnchangedFiles(t *testing.T) {
+	a := newTestApp(t, &Config{})
+	ctx := t.Context()
+	writePNG(t, filepath.Join(a.ImagesDir, "a.png"), gradient(32, 32, 0))
+	writePNG(t, filepath.Join(a.ImagesDir, "b.png"), gradient(32, 32, 1))
+
+	if err := a.IngestImages(ctx); err != nil {
+		t.Fatalf("first IngestImages: %v", err)
+	}
+	if p := a.IngestProgress(); p.Running || p.Total != 2 || p.Processed != 2 || p.Skipped != 0 {
+		t.Fatalf("first run progress = %+v, want 2 processed", p)
+	}
+
+	if err := a.IngestImages(ctx); err != nil {
+		t.Fatalf("second IngestImages: %v", err)
+	}
+	if p := a.IngestProgress(); p.Processed != 0 || p.Skipped != 2 {
+		t.Fatalf("unchanged run progress = %+v, want 2 skipped", p)
+	}
+
+	// Rewrite one file with new content and a different mtime
+	changed := filepath.Join(a.ImagesDir, "b.png")
+	writePNG(t, changed, gradient(32, 32, 2))
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(changed, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.IngestImages(ctx); err != nil {
+		t.Fatalf("third IngestImages: %v", err)
+	}
+	if p := a.IngestProgress(); p.Processed != 1 || p.Skipped != 1 {
+		t.Fatalf("changed run progress = %+v, want 1 processed and 1 skipped", p)
+	}
+	count, err := a.imageRepo.Count(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("image count = %d, want 2 (the old version of b.png is missing)", count)
+	}
+}
+
+func TestIngestProgressETA(t *testing.T) {
+	p := IngestProgress{
+		Running:   true,
+		Total:     100,
+		Processed: 25,
+		StartedAt: time.Now().Add(-10 * time.Second),
+	}
+	if rate := p.Rate(); rate < 2.4 || rate > 2.6 {
+		t.Errorf("Rate() = %v, want about 2.5", rate)
+	}
+	if eta := p.ETA(); eta < 29*time.Second || eta > 31*time.Second {
+		t.Errorf("ETA() = %v, want about 30s", eta)
+	}
+	if status := ingestStatusUI(p); status == nil || status.Percent != 25 {
+		t.Errorf("ingestStatusUI() = %+v, want 25%%", status)
+	}
+
+	p.Running = false
+	if ingestStatusUI(p) != nil {
+		t.Error("ingestStatusUI() should be nil once finished")
+	}
+}
+
+func TestIngestImagesNestedDirectories(t *testing.T) {
+	a := newTestApp(t, &Config{})
+	ctx := t.Context()
+	files := map[string]int{
+		"root.png":              0,
+		"cameraA/1.png":         1,
+		"cameraA/night/2.png":   2,
+		"cameraB/deep/er/3.png": 3,
+	}
+	for name, seed := range files {
+		path := filepath.Join(a.ImagesDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		writePNG(t, path, gradient(16+seed*8, 16, seed))
+	}
+
+	if err := a.IngestImages(ctx); err != nil {
+		t.Fatalf("IngestImages: %v", err)
+	}
+	for name := range files {
+		img, err := a.imageRepo.GetByFilename(ctx, name)
+		if err != nil || img == nil {
+			t.Fatalf("image %s not recorded under its relative path: %v", name, err)
+		}
+		fullPath, err := secureJoin(a.ImagesDir, img.Filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(fullPath); err != nil {
+			t.Errorf("%s does not resolve to its file: %v", name, err)
+		}
+	}
+
+	var filter domain.ImageFilter
+	if err := ParseImageCondition("source_dir", "cameraA", &filter); err != nil {
+		t.Fatal(err)
+	}
+	matched, err := a.imageRepo.ListFiltered(ctx, filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matched) != 2 {
+		t.Errorf("source_dir cameraA matched %d images, want 2", len(matched))
+	}
+}
+
+func TestIngestImagesFromBucket(t *testing.T) {
+	fake := storage.NewFakeS3(t, "images")
+	fake.PageSize = 1
+	a := newTestApp(t, &Config{})
+	a.Store = fake.Store(t, "dataset")
+	ctx := t.Context()
+
+	var names []string
+	for seed := range 2 {
+		name, err := IngestImage(ctx, gradient(32, 32, seed), a.Store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	if err := a.IngestImages(ctx); err != nil {
+		t.Fatalf("IngestImages: %v", err)
+	}
+	if p := a.IngestProgress(); p.Total != 2 || p.Processed != 2 {
+		t.Fatalf("progress = %+v, want 2 processed", p)
+	}
+	sha := strings.TrimSuffix(names[0], ".png")
+	img, err := a.imageRepo.GetBySHA256(ctx, sha)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Filename != names[0] || img.Format != "png" || img.Width != 32 {
+		t.Errorf("image = %+v, want %s recorded with its metadata", img, names[0])
+	}
+
+	// Objects deleted from the bucket are marked missing by the next run
+	if err := a.Store.Delete(ctx, names[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.IngestImages(ctx); err != nil {
+		t.Fatalf("second IngestImages: %v", err)
+	}
+	if p := a.IngestProgress(); p.Total != 1 || p.Skipped != 1 {
+		t.Errorf("second run progress = %+v, want 1 skipped", p)
+	}
+	if img, err := a.imageRepo.GetBySHA256(ctx, sha); err != nil || img.MissingAt.IsZero() {
+		t.Errorf("deleted object: image = %+v, %v; want missing", img, err)
+	}
+
+	if _, err := a.CheckImages(ctx); !errors.Is(err, ErrRemoteImages) {
+		t.Errorf("CheckImages() error = %v, want ErrRemoteImages", err)
+	}
+}
@@ -0,0 +1,40 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// PruneLeases deletes expired leases once per lease duration, so the leases
+// table only holds the reservations of images being annotated. It blocks
+// until ctx is done and returns at once when leases are disabled.
+func (a *AnnotatorApp) PruneLeases(ctx context.Context) error {
+	if !a.Config.Leases.Enabled() {
+		return nil
+	}
+	ticker := time.NewTicker(a.Config.Leases.Duration())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := a.pruneLeases(ctx); err != nil {
+				ReportError(ctx, err, "msg", "failed to prune expired leases")
+			}
+		}
+	}
+}
+
+// pruneLeases deletes the expired leases.
+func (a *AnnotatorApp) pruneLeases(ctx context.Context) error {
+	deleted, err := a.leaseRepo.DeleteExpired(ctx)
+	if err != nil {
+		return fmt.Errorf("while deleting expired leases: %w", err)
+	}
+	if deleted > 0 {
+		a.Logger.Debug("PruneLeases: deleted expired leases", "count", deleted)
+	}
+	return nil
+}
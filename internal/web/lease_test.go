@@ -0,0 +1,43 @@
+package web
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPruneLeases(t *testing.T) {
+	a := newTestApp(t, &Config{Tasks: []*ConfigTask{{ID: "quality", Classes: map[string]*ConfigClass{"good": {}}}}})
+	ctx := t.Context()
+	for _, hash := range []string{"a", "b"} {
+		if _, err := a.imageRepo.Create(ctx, hash, hash+".png"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := a.leaseRepo.Acquire(ctx, "a", 0, "alice", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Database.ExecContext(ctx, `INSERT INTO image_leases (image_sha256, stage_index, username, expires_at)
+		VALUES ('b', 0, 'bob', datetime('now', '-1 minute'))`); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.pruneLeases(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var usernames []string
+	rows, err := a.Database.QueryContext(ctx, "SELECT username FROM image_leases")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var username string
+		if err := rows.Scan(&username); err != nil {
+			t.Fatal(err)
+		}
+		usernames = append(usernames, username)
+	}
+	if len(usernames) != 1 || usernames[0] != "alice" {
+		t.Errorf("leases left = %v, want only alice's active one", usernames)
+	}
+}
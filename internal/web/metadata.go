@@ -0,0 +1,166 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lewtec/rotulador/internal/domain"
+)
+
+// ErrInvalidImageCondition is returned for a metadata condition that does not parse.
+var ErrInvalidImageCondition = errors.New("invalid image metadata condition")
+
+// ImageFields are the image metadata keys accepted by ParseImageCondition.
+// Task If clauses use them for keys that are not task IDs, for example
+// `width: ">512"` or `tag: night`.
+var ImageFields = []string{"width", "height", "size_bytes", "format", "camera", "tag", "source_path", "source_dir", "captured_at"}
+
+// IsImageField reports whether key names an image metadata field.
+func IsImageField(key string) bool {
+	for _, field := range ImageFields {
+		if field == key {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseImageCondition narrows filter by one metadata condition.
+//
+// Numeric fields (width, height, size_bytes) and captured_at accept an
+// optional comparison prefix: >, >=, <, <= or =. Dates are YYYY-MM-DD or
+// RFC 3339; a bare date matches the whole day. source_path matches by
+// prefix, source_dir a directory of the images directory and everything
+// below it, every other field by equality.
+func ParseImageCondition(key, value string, filter *domain.ImageFilter) error {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return fmt.Errorf("%w: %s has an empty value", ErrInvalidImageCondition, key)
+	}
+
+	switch key {
+	case "format":
+		filter.Format = strings.ToLower(value)
+	case "camera":
+		filter.Camera = value
+	case "tag":
+		filter.Tag = value
+	case "source_path":
+		filter.SourcePrefix = value
+	case "source_dir":
+		dir := strings.Trim(path.Clean("/"+value), "/")
+		if dir == "" {
+			return fmt.Errorf("%w: %s %q is the images directory itself", ErrInvalidImageCondition, key, value)
+		}
+		filter.SourceDir = dir
+	case "width":
+		return parseIntCondition(key, value, &filter.MinWidth, &filter.MaxWidth)
+	case "height":
+		return parseIntCondition(key, value, &filter.MinHeight, &filter.MaxHeight)
+	case "size_bytes":
+		var minSize, maxSize int
+		if err := parseIntCondition(key, value, &minSize, &maxSize); err != nil {
+			return err
+		}
+		if minSize != 0 {
+			filter.MinSize = int64(minSize)
+		}
+		if maxSize != 0 {
+			filter.MaxSize = int64(maxSize)
+		}
+	case "captured_at":
+		return parseTimeCondition(key, value, filter)
+	default:
+		return fmt.Errorf("%w: unknown field %s", ErrInvalidImageCondition, key)
+	}
+	return nil
+}
+
+// ImageFilterFromValues builds a filter from conditions keyed by field name,
+// such as URL query parameters. Keys that are not image fields are ignored.
+func ImageFilterFromValues(values url.Values) (domain.ImageFilter, error) {
+	var filter domain.ImageFilter
+	for _, field := range ImageFields {
+		for _, value := range values[field] {
+			if err := ParseImageCondition(field, value, &filter); err != nil {
+				return domain.ImageFilter{}, err
+			}
+		}
+	}
+	return filter, nil
+}
+
+// splitComparison separates a leading comparison operator from value.
+func splitComparison(value string) (string, string) {
+	for _, op := range []string{">=", "<=", ">", "<", "="} {
+		if rest, ok := strings.CutPrefix(value, op); ok {
+			return op, strings.TrimSpace(rest)
+		}
+	}
+	return "=", value
+}
+
+func parseIntCondition(key, value string, minValue, maxValue *int) error {
+	op, operand := splitComparison(value)
+	n, err := strconv.Atoi(operand)
+	if err != nil {
+		return fmt.Errorf("%w: %s %q is not a number", ErrInvalidImageCondition, key, value)
+	}
+
+	lo, hi := 0, 0
+	switch op {
+	case ">":
+		lo = n + 1
+	case ">=":
+		lo = n
+	case "<":
+		hi = n - 1
+	case "<=":
+		hi = n
+	default:
+		lo, hi = n, n
+	}
+	// Zero means "no bound" in domain.ImageFilter
+	if lo < 0 || hi < 0 || (lo == 0 && hi == 0) {
+		return fmt.Errorf("%w: %s %q matches no image", ErrInvalidImageCondition, key, value)
+	}
+	if lo != 0 {
+		*minValue = lo
+	}
+	if hi != 0 {
+		*maxValue = hi
+	}
+	return nil
+}
+
+func parseTimeCondition(key, value string, filter *domain.ImageFilter) error {
+	op, operand := splitComparison(value)
+	t, err := time.Parse(time.RFC3339, operand)
+	step := time.Second
+	if err != nil {
+		t, err = time.Parse(time.DateOnly, operand)
+		step = 24 * time.Hour
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %s %q is not a date", ErrInvalidImageCondition, key, value)
+	}
+
+	switch op {
+	case ">":
+		filter.CapturedAfter = t.Add(step)
+	case ">=":
+		filter.CapturedAfter = t
+	case "<":
+		filter.CapturedBefore = t
+	case "<=":
+		filter.CapturedBefore = t.Add(step)
+	default:
+		filter.CapturedAfter, filter.CapturedBefore = t, t.Add(step)
+	}
+	return nil
+}
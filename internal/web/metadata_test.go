@@ -0,0 +1,80 @@
+package web
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lewtec/rotulador/internal/domain"
+)
+
+func TestParseImageCondition(t *testing.T) {
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		key, value string
+		want       domain.ImageFilter
+	}{
+		{"width", ">512", domain.ImageFilter{MinWidth: 513}},
+		{"width", ">= 512", domain.ImageFilter{MinWidth: 512}},
+		{"height", "<100", domain.ImageFilter{MaxHeight: 99}},
+		{"height", "480", domain.ImageFilter{MinHeight: 480, MaxHeight: 480}},
+		{"size_bytes", "<=2048", domain.ImageFilter{MaxSize: 2048}},
+		{"format", "JPEG", domain.ImageFilter{Format: "jpeg"}},
+		{"tag", "night", domain.ImageFilter{Tag: "night"}},
+		{"source_path", "/data/cam_a/", domain.ImageFilter{SourcePrefix: "/data/cam_a/"}},
+		{"source_dir", "cameraA/", domain.ImageFilter{SourceDir: "cameraA"}},
+		{"captured_at", "2024-05-01", domain.ImageFilter{CapturedAfter: day, CapturedBefore: day.AddDate(0, 0, 1)}},
+		{"captured_at", ">2024-05-01", domain.ImageFilter{CapturedAfter: day.AddDate(0, 0, 1)}},
+		{"captured_at", "<2024-05-01T00:00:00Z", domain.ImageFilter{CapturedBefore: day}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key+tt.value, func(t *testing.T) {
+			var got domain.ImageFilter
+			if err := ParseImageCondition(tt.key, tt.value, &got); err != nil {
+				t.Fatalf("ParseImageCondition() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseImageCondition() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	for _, bad := range [][2]string{{"width", "wide"}, {"width", "<1"}, {"captured_at", "yesterday"}, {"color", "red"}, {"tag", " "}, {"source_dir", ".."}} {
+		var f domain.ImageFilter
+		if err := ParseImageCondition(bad[0], bad[1], &f); !errors.Is(err, ErrInvalidImageCondition) {
+			t.Errorf("ParseImageCondition(%q, %q) error = %v, want ErrInvalidImageCondition", bad[0], bad[1], err)
+		}
+	}
+}
+
+func TestImageFieldInIfClause(t *testing.T) {
+	a := newTestApp(t, &Config{
+		Tasks: []*ConfigTask{{
+			ID:      "detail",
+			If:      map[string]string{"width": ">512"},
+			Classes: map[string]*ConfigClass{"good": {}},
+		}},
+	})
+	ctx := t.Context()
+	for sha, width := range map[string]int{"wide": 1024, "narrow": 256} {
+		meta := domain.ImageMetadata{Format: "png", Width: width, Height: 100}
+		if _, err := a.imageRepo.CreateWithMetadata(ctx, sha, sha+".png", meta); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	eligible, err := a.CountEligibleImages(ctx, "detail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if eligible != 1 {
+		t.Errorf("CountEligibleImages() = %d, want 1", eligible)
+	}
+	step, err := a.NextAnnotationStep(ctx, "detail", "")
+	if err != nil || step == nil {
+		t.Fatalf("NextAnnotationStep() = %v, %v", step, err)
+	}
+	if step.ImageID != "wide" {
+		t.Errorf("NextAnnotationStep() image = %s, want wide", step.ImageID)
+	}
+}
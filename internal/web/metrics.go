@@ -0,0 +1,143 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lewtec/rotulador/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Process-wide metrics; the ones read from the database or the app state are
+// computed on every scrape by handleMetrics.
+var (
+	httpRequests = metrics.Register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "rotulador_http_requests_total",
+		Help: "HTTP requests by route pattern, method and status code.",
+	}, []string{"route", "method", "code"}))
+	httpDuration = metrics.Register(prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "rotulador_http_request_duration_seconds",
+		Help:    "Latency of HTTP requests by route pattern and method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method"}))
+	annotationsSubmitted = metrics.Register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "rotulador_annotations_submitted_total",
+		Help: "Annotations saved, overwrites included, by task, user and source.",
+	}, []string{"task", "user", "source"}))
+	authFailures = metrics.Register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "rotulador_auth_failures_total",
+		Help: "Rejected HTTP credentials by reason.",
+	}, []string{"reason"}))
+	ingestErrors = metrics.Register(prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "rotulador_ingest_errors_total",
+		Help: "Failed ingestions of the images directory and of files the watcher picked up.",
+	}))
+)
+
+// metricsMiddleware counts requests and their latency by the pattern of mux
+// that serves them, which keeps the number of routes small.
+func metricsMiddleware(mux *http.ServeMux, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		_, route := mux.Handler(r)
+		if route == "" {
+			route = "unmatched"
+		}
+		wr := NewStatusCodeRecorderResponseWriter(w)
+		handler.ServeHTTP(wr, r)
+		httpRequests.WithLabelValues(route, r.Method, strconv.Itoa(wr.Status)).Inc()
+		httpDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+	})
+}
+
+// MetricsHandler serves the metrics on the metrics.listen address, to the
+// metrics credentials when they are configured and to anyone otherwise.
+func (a *AnnotatorApp) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.Config.Metrics.Username != "" && !a.metricsCredentials(r) {
+			unauthorized(w)
+			return
+		}
+		a.handleMetrics(w, r)
+	})
+}
+
+// withMetricsEndpoint serves /metrics next to the pages, outside of the
+// authentication of handler so scrapers can use the metrics credentials.
+// Without them only admins can scrape.
+func (a *AnnotatorApp) withMetricsEndpoint(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metrics" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		if a.Config.Metrics.Username != "" {
+			if !a.metricsCredentials(r) {
+				unauthorized(w)
+				return
+			}
+			a.handleMetrics(w, r)
+			return
+		}
+		a.authenticationMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !a.isAdmin(r) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			a.handleMetrics(w, r)
+		})).ServeHTTP(w, r)
+	})
+}
+
+// metricsCredentials reports whether r carries the metrics credentials.
+func (a *AnnotatorApp) metricsCredentials(r *http.Request) bool {
+	username, password, ok := r.BasicAuth()
+	if ok && username == a.Config.Metrics.Username && CheckPasswordHash(password, a.Config.Metrics.Password) {
+		return true
+	}
+	authFailures.WithLabelValues("metrics").Inc()
+	a.Logger.Warn("auth for metrics: not ok", "username", username)
+	return false
+}
+
+// handleMetrics writes the process-wide metrics along with the queue depth
+// of every task and the progress of the images directory ingestion.
+func (a *AnnotatorApp) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	queue := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "rotulador_queue_depth",
+		Help: "Images still available for annotation by task.",
+	}, []string{"task"})
+	for _, task := range a.Config.Tasks {
+		available, err := a.CountAvailableImages(r.Context(), task.ID)
+		if err != nil {
+			ReportError(r.Context(), err, "msg", "error counting available images for metrics", "task", task.ID)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		queue.WithLabelValues(task.ID).Set(float64(available))
+	}
+
+	progress := a.IngestProgress()
+	running := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "rotulador_ingest_running",
+		Help: "Whether the images directory is being ingested.",
+	})
+	if progress.Running {
+		running.Set(1)
+	}
+	files := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "rotulador_ingest_files",
+		Help: "Files of the current or last ingestion by state: found, processed or skipped as unchanged.",
+	}, []string{"state"})
+	files.WithLabelValues("total").Set(float64(progress.Total))
+	files.WithLabelValues("processed").Set(float64(progress.Processed))
+	files.WithLabelValues("skipped").Set(float64(progress.Skipped))
+
+	metrics.Handler(queue, running, files).ServeHTTP(w, r)
+}
@@ -0,0 +1,133 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+func newMetricsApp(t *testing.T) *AnnotatorApp {
+	t.Helper()
+	a := newBrowseApp(t)
+	hash, err := HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, auth := range a.Config.Authentication {
+		auth.Password = hash
+	}
+	a.Config.Metrics.Enabled = true
+	return a
+}
+
+func scrape(handler http.Handler, user, target string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if user != "" {
+		req.SetBasicAuth(user, "secret")
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	a := newMetricsApp(t)
+	handler := a.GetHTTPHandler()
+
+	if rec := scrape(handler, "admin", "/"); rec.Code != http.StatusOK {
+		t.Fatalf("home status = %d", rec.Code)
+	}
+	if rec := scrape(handler, "", "/metrics"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous /metrics status = %d, want 401", rec.Code)
+	}
+	if rec := scrape(handler, "alice", "/metrics"); rec.Code != http.StatusForbidden {
+		t.Errorf("annotator /metrics status = %d, want 403", rec.Code)
+	}
+
+	rec := scrape(handler, "admin", "/metrics")
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("status = %d, content type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(rec.Body)
+	if err != nil {
+		t.Fatalf("metrics are not in the text format: %v", err)
+	}
+	// sample finds the sample of a family with the given labels
+	sample := func(name string, labels map[string]string) *dto.Metric {
+		t.Helper()
+		family := families[name]
+		if family == nil {
+			t.Errorf("metrics do not contain %s", name)
+			return nil
+		}
+	samples:
+		for _, m := range family.Metric {
+			for _, label := range m.Label {
+				if want, ok := labels[label.GetName()]; ok && want != label.GetValue() {
+					continue samples
+				}
+			}
+			return m
+		}
+		t.Errorf("%s has no sample with labels %v", name, labels)
+		return nil
+	}
+
+	if m := sample("rotulador_http_requests_total", map[string]string{"route": "/", "method": "GET", "code": "200"}); m != nil && m.GetCounter().GetValue() < 1 {
+		t.Errorf("home requests = %v, want at least 1", m.GetCounter().GetValue())
+	}
+	if m := sample("rotulador_http_request_duration_seconds", map[string]string{"route": "/", "method": "GET"}); m != nil && m.GetHistogram().GetSampleCount() < 1 {
+		t.Error("home request latency has no observation")
+	}
+	sample("rotulador_annotations_submitted_total", map[string]string{"task": "quality", "user": "alice", "source": "web"})
+	sample("rotulador_auth_failures_total", map[string]string{"reason": "no_credentials"})
+	sample("rotulador_db_query_duration_seconds", map[string]string{"query": "CreateAnnotation"})
+	// Only hash3 is left to annotate
+	if m := sample("rotulador_queue_depth", map[string]string{"task": "quality"}); m != nil && m.GetGauge().GetValue() != 1 {
+		t.Errorf("queue depth = %v, want 1", m.GetGauge().GetValue())
+	}
+	if m := sample("rotulador_ingest_running", nil); m != nil && m.GetGauge().GetValue() != 0 {
+		t.Errorf("ingest running = %v, want 0", m.GetGauge().GetValue())
+	}
+	if m := sample("rotulador_ingest_files", map[string]string{"state": "total"}); m != nil && m.GetGauge().GetValue() != 0 {
+		t.Errorf("ingest files = %v, want 0", m.GetGauge().GetValue())
+	}
+	if family := families["rotulador_ingest_errors_total"]; family == nil || family.GetType() != dto.MetricType_COUNTER {
+		t.Errorf("rotulador_ingest_errors_total = %v, want a counter", family)
+	}
+}
+
+func TestMetricsCredentials(t *testing.T) {
+	a := newMetricsApp(t)
+	a.Config.Metrics.Username = "prometheus"
+	a.Config.Metrics.Password = a.Config.Authentication["admin"].Password
+	handler := a.GetHTTPHandler()
+
+	if rec := scrape(handler, "prometheus", "/metrics"); rec.Code != http.StatusOK {
+		t.Errorf("/metrics with the metrics credentials status = %d, want 200", rec.Code)
+	}
+	if rec := scrape(handler, "admin", "/metrics"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("/metrics with admin credentials status = %d, want 401 once metrics credentials are set", rec.Code)
+	}
+	if rec := scrape(a.MetricsHandler(), "", "/metrics"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("metrics listener without credentials status = %d, want 401", rec.Code)
+	}
+	if rec := scrape(a.MetricsHandler(), "prometheus", "/metrics"); rec.Code != http.StatusOK {
+		t.Errorf("metrics listener with credentials status = %d, want 200", rec.Code)
+	}
+
+	a.Config.Metrics.Username, a.Config.Metrics.Password = "", ""
+	if rec := scrape(a.MetricsHandler(), "", "/metrics"); rec.Code != http.StatusOK {
+		t.Errorf("metrics listener without configured credentials status = %d, want 200", rec.Code)
+	}
+	// On its own address, /metrics is no longer served next to the pages
+	a.Config.Metrics.Listen = "127.0.0.1:0"
+	if rec := scrape(a.GetHTTPHandler(), "admin", "/metrics"); rec.Code != http.StatusNotFound {
+		t.Errorf("/metrics next to the pages with metrics.listen set status = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,192 @@
+package web
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"image"
+	"math/bits"
+	"sort"
+
+	"github.com/lewtec/rotulador/internal/repository"
+)
+
+// DefaultDuplicateDistance is the largest Hamming distance between two
+// perceptual hashes still reported as a near-duplicate. Resized and
+// re-compressed copies usually land within a handful of bits.
+const DefaultDuplicateDistance = 6
+
+// PerceptualHash computes a 64-bit difference hash (dHash) of img.
+// The image is reduced to a 9x8 grayscale grid by box averaging and each bit
+// records whether a cell is brighter than its right neighbour, so the hash
+// survives resizing, re-encoding and small colour shifts.
+func PerceptualHash(img image.Image) uint64 {
+	const w, h = 9, 8
+	var sum [h][w]float64
+	var count [h][w]int
+
+	b := img.Bounds()
+	if b.Empty() {
+		return 0
+	}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		cy := (y - b.Min.Y) * h / b.Dy()
+		for x := b.Min.X; x < b.Max.X; x++ {
+			cx := (x - b.Min.X) * w / b.Dx()
+			r, g, bl, _ := img.At(x, y).RGBA()
+			// ITU-R BT.601 luma
+			sum[cy][cx] += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)
+			count[cy][cx]++
+		}
+	}
+
+	var cells [h][w]float64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if count[y][x] > 0 {
+				cells[y][x] = sum[y][x] / float64(count[y][x])
+			}
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w-1; x++ {
+			hash <<= 1
+			if cells[y][x] > cells[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// HammingDistance returns the number of differing bits between two hashes.
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// DuplicateCandidate is an image with a known perceptual hash.
+type DuplicateCandidate struct {
+	SHA256   string
+	Filename string
+	PHash    uint64
+}
+
+// GroupNearDuplicates clusters candidates whose hashes are within maxDistance
+// of each other (transitively). Only groups with two or more members are
+// returned, largest first; members keep the input order.
+func GroupNearDuplicates(candidates []DuplicateCandidate, maxDistance int) [][]DuplicateCandidate {
+	parent := make([]int, len(candidates))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		for parent[i] != i {
+			parent[i] = parent[parent[i]]
+			i = parent[i]
+		}
+		return i
+	}
+
+	// A BK-tree keeps lookups well below O(n²) for realistic datasets.
+	tree := &bkNode{}
+	for i, c := range candidates {
+		for _, j := range tree.search(c.PHash, maxDistance, candidates) {
+			if ri, rj := find(i), find(j); ri != rj {
+				parent[ri] = rj
+			}
+		}
+		tree.insert(i, c.PHash, candidates)
+	}
+
+	byRoot := make(map[int][]DuplicateCandidate)
+	var roots []int
+	for i, c := range candidates {
+		root := find(i)
+		if _, ok := byRoot[root]; !ok {
+			roots = append(roots, root)
+		}
+		byRoot[root] = append(byRoot[root], c)
+	}
+
+	var groups [][]DuplicateCandidate
+	for _, root := range roots {
+		if len(byRoot[root]) > 1 {
+			groups = append(groups, byRoot[root])
+		}
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return len(groups[i]) > len(groups[j])
+	})
+	return groups
+}
+
+// bkNode is a BK-tree over Hamming distance. The zero value is an empty tree.
+type bkNode struct {
+	index    int
+	used     bool
+	children map[int]*bkNode
+}
+
+func (n *bkNode) insert(index int, hash uint64, candidates []DuplicateCandidate) {
+	if !n.used {
+		n.index, n.used = index, true
+		return
+	}
+	for {
+		d := HammingDistance(hash, candidates[n.index].PHash)
+		child, ok := n.children[d]
+		if !ok {
+			if n.children == nil {
+				n.children = make(map[int]*bkNode)
+			}
+			n.children[d] = &bkNode{index: index, used: true}
+			return
+		}
+		n = child
+	}
+}
+
+func (n *bkNode) search(hash uint64, maxDistance int, candidates []DuplicateCandidate) []int {
+	if !n.used {
+		return nil
+	}
+	var found []int
+	stack := []*bkNode{n}
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		d := HammingDistance(hash, candidates[node.index].PHash)
+		if d <= maxDistance {
+			found = append(found, node.index)
+		}
+		for childDistance, child := range node.children {
+			if childDistance >= d-maxDistance && childDistance <= d+maxDistance {
+				stack = append(stack, child)
+			}
+		}
+	}
+	return found
+}
+
+// ListNearDuplicates groups every image in db with a perceptual hash by
+// Hamming distance. Images ingested before hashes were introduced are only
+// included after the next ingestion pass.
+func ListNearDuplicates(ctx context.Context, db *sql.DB, maxDistance int) ([][]DuplicateCandidate, error) {
+	images, err := repository.NewImageRepository(db).ListWithPHash(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("while listing perceptual hashes: %w", err)
+	}
+
+	candidates := make([]DuplicateCandidate, 0, len(images))
+	for _, img := range images {
+		candidates = append(candidates, DuplicateCandidate{
+			SHA256:   img.SHA256,
+			Filename: img.Filename,
+			PHash:    *img.PHash,
+		})
+	}
+	return GroupNearDuplicates(candidates, maxDistance), nil
+}
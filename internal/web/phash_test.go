@@ -0,0 +1,62 @@
+package web
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// gradient draws a diagonal gradient with a bright square whose position
+// depends on seed, so different seeds produce clearly different hashes.
+func gradient(w, h, seed int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := uint8((x*255/w + y*128/h) % 256)
+			if (x*4/w+y*4/h+seed)%3 == 0 {
+				v = 255 - v
+			}
+			img.Set(x, y, color.RGBA{R: v, G: v, B: v, A: 255})
+		}
+	}
+	return img
+}
+
+func TestPerceptualHashSurvivesResize(t *testing.T) {
+	big := PerceptualHash(gradient(360, 240, 0))
+	small := PerceptualHash(gradient(90, 60, 0))
+	other := PerceptualHash(gradient(360, 240, 1))
+
+	if d := HammingDistance(big, small); d > DefaultDuplicateDistance {
+		t.Errorf("resized copy distance = %d, want <= %d", d, DefaultDuplicateDistance)
+	}
+	if d := HammingDistance(big, other); d <= DefaultDuplicateDistance {
+		t.Errorf("different image distance = %d, want > %d", d, DefaultDuplicateDistance)
+	}
+}
+
+func TestGroupNearDuplicates(t *testing.T) {
+	candidates := []DuplicateCandidate{
+		{SHA256: "a", PHash: 0x0000000000000000},
+		{SHA256: "b", PHash: 0x0000000000000003}, // 2 bits from a
+		{SHA256: "c", PHash: 0x000000000000000F}, // 2 bits from b, 4 from a
+		{SHA256: "d", PHash: 0xFFFFFFFFFFFFFFFF},
+		{SHA256: "e", PHash: 0xFFFFFFFFFFFFFFFE},
+		{SHA256: "f", PHash: 0x00000000FFFF0000},
+	}
+
+	groups := GroupNearDuplicates(candidates, 2)
+	if len(groups) != 2 {
+		t.Fatalf("got %d groups, want 2: %v", len(groups), groups)
+	}
+	if len(groups[0]) != 3 || groups[0][0].SHA256 != "a" || groups[0][2].SHA256 != "c" {
+		t.Errorf("first group = %v, want a,b,c (transitive)", groups[0])
+	}
+	if len(groups[1]) != 2 || groups[1][0].SHA256 != "d" {
+		t.Errorf("second group = %v, want d,e", groups[1])
+	}
+
+	if groups := GroupNearDuplicates(candidates, 0); len(groups) != 0 {
+		t.Errorf("distance 0 groups = %v, want none", groups)
+	}
+}
@@ -0,0 +1,133 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/lewtec/rotulador/internal/ui/pages"
+)
+
+// annotateImageWidth is the asset variant shown on the annotation page.
+// Prefetch links request the very same URL, so the browser reuses them.
+const annotateImageWidth = 2048
+
+// maxPrefetchImages bounds how many upcoming images a client may ask for.
+const maxPrefetchImages = 20
+
+// annotateAssetURL is the URL the annotation page shows an image from.
+func annotateAssetURL(sha256 string) string {
+	return fmt.Sprintf("/asset/%s?w=%d", sha256, annotateImageWidth)
+}
+
+// UpcomingSteps picks up to n more images of a task for username to annotate
+// after the ones in exclude. They are not reserved: loading an image ahead
+// must not keep it from other annotators, so the annotation page reserves
+// each one only as it shows it.
+func (a *AnnotatorApp) UpcomingSteps(ctx context.Context, taskID, username string, exclude []string, n int) ([]*AnnotationStep, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	skip := make(map[string]bool, len(exclude))
+	for _, sha256 := range exclude {
+		skip[sha256] = true
+	}
+	return a.selectSteps(ctx, taskID, username, n, skip, false)
+}
+
+// upcomingUI converts steps for the annotation page.
+func upcomingUI(steps []*AnnotationStep) []pages.UpcomingImage {
+	images := make([]pages.UpcomingImage, 0, len(steps))
+	for _, step := range steps {
+		images = append(images, pages.UpcomingImage{
+			ID:       step.ImageID,
+			Filename: step.ImageName,
+			URL:      annotateAssetURL(step.ImageID),
+		})
+	}
+	return images
+}
+
+// handleAnnotateAPI serves the requests the annotation page makes while it
+// swaps images client-side, and answers from scripts.
+func (a *AnnotatorApp) handleAnnotateAPI(w http.ResponseWriter, r *http.Request) {
+	itemPath := pathParts(r.URL.Path)
+	switch {
+	case len(itemPath) == 4 && itemPath[3] == "upcoming":
+		a.handleUpcoming(w, r, itemPath[2])
+	case len(itemPath) == 4:
+		a.handleAPIAnnotate(w, r, itemPath[2], itemPath[3])
+	case len(itemPath) == 5 && itemPath[4] == "reserve":
+		a.handleReserve(w, r, itemPath[2], itemPath[3])
+	default:
+		http.NotFoundHandler().ServeHTTP(w, r)
+	}
+}
+
+// handleUpcoming lists more images to load ahead on the annotation page as
+// JSON: /api/annotate/{task}/upcoming?exclude={sha256}&n=3. The client
+// excludes the image it shows and the ones it already holds.
+func (a *AnnotatorApp) handleUpcoming(w http.ResponseWriter, r *http.Request, taskID string) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if a.GetTask(taskID) == nil {
+		http.NotFoundHandler().ServeHTTP(w, r)
+		return
+	}
+	n := a.Config.Prefetch.Count()
+	if value := r.URL.Query().Get("n"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			http.Error(w, fmt.Sprintf("invalid n: %q", value), http.StatusBadRequest)
+			return
+		}
+		n = min(parsed, maxPrefetchImages)
+	}
+
+	// authenticationMiddleware already validated these credentials
+	user, _, _ := r.BasicAuth()
+	steps, err := a.UpcomingSteps(r.Context(), taskID, user, r.URL.Query()["exclude"], n)
+	if err != nil {
+		ReportError(r.Context(), err, "msg", "error getting upcoming steps", "task", taskID)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(upcomingUI(steps)); err != nil {
+		ReportError(r.Context(), err, "msg", "error encoding upcoming steps")
+	}
+}
+
+// handleReserve reserves an image the annotation page is about to show:
+// POST /api/annotate/{task}/{sha256}/reserve. It answers 409 Conflict when
+// another user holds the image, so the page moves on to the next one.
+func (a *AnnotatorApp) handleReserve(w http.ResponseWriter, r *http.Request, taskID, imageID string) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if a.GetTask(taskID) == nil {
+		http.NotFoundHandler().ServeHTTP(w, r)
+		return
+	}
+
+	// authenticationMiddleware already validated these credentials
+	user, _, _ := r.BasicAuth()
+	reserved, err := a.ReserveImage(r.Context(), taskID, imageID, user)
+	if err != nil {
+		ReportError(r.Context(), err, "msg", "error reserving image", "sha256", imageID)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if !reserved {
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
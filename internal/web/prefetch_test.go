@@ -0,0 +1,189 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lewtec/rotulador/internal/ui/pages"
+)
+
+func newPrefetchApp(t *testing.T, images ...string) *AnnotatorApp {
+	t.Helper()
+	a := newTestApp(t, &Config{
+		Tasks: []*ConfigTask{{ID: "quality", Classes: map[string]*ConfigClass{"good": {}}}},
+	})
+	for _, hash := range images {
+		if _, err := a.imageRepo.Create(t.Context(), hash, hash+".png"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return a
+}
+
+func TestUpcomingStepsDoNotReserveImages(t *testing.T) {
+	a := newPrefetchApp(t, "hash1", "hash2", "hash3")
+	ctx := t.Context()
+
+	reserved, err := a.ReserveImage(ctx, "quality", "hash1", "alice")
+	if err != nil || !reserved {
+		t.Fatalf("ReserveImage() = %v, %v", reserved, err)
+	}
+	steps, err := a.UpcomingSteps(ctx, "quality", "alice", []string{"hash1"}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(steps) != 2 || steps[0].ImageID == steps[1].ImageID {
+		t.Fatalf("upcoming = %+v, want 2 distinct images", steps)
+	}
+	for _, step := range steps {
+		if step.ImageID == "hash1" {
+			t.Fatalf("upcoming includes the excluded image: %+v", steps)
+		}
+	}
+
+	// Loading images ahead leaves them to bob, who only can't have the one
+	// alice is looking at
+	leases, err := a.leaseRepo.ListActive(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leases) != 1 || leases[0].ImageSHA256 != "hash1" {
+		t.Fatalf("active leases = %+v, want only the shown image", leases)
+	}
+	bob, err := a.UpcomingSteps(ctx, "quality", "bob", nil, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bob) != 2 {
+		t.Fatalf("bob upcoming = %+v, want the 2 images alice only loaded ahead", bob)
+	}
+	step, err := a.NextAnnotationStep(ctx, "quality", "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if step == nil || step.ImageID == "hash1" {
+		t.Fatalf("bob next step = %+v, want an image alice only loaded ahead", step)
+	}
+
+	// Once bob holds it, alice can't show it anymore
+	if reserved, err := a.ReserveImage(ctx, "quality", step.ImageID, "alice"); err != nil || reserved {
+		t.Errorf("ReserveImage() of bob's image = %v, %v; want false", reserved, err)
+	}
+}
+
+func TestHandleUpcoming(t *testing.T) {
+	a := newPrefetchApp(t, "hash1", "hash2", "hash3")
+
+	get := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.SetBasicAuth("alice", "")
+		rec := httptest.NewRecorder()
+		a.handleAnnotateAPI(rec, req)
+		return rec
+	}
+
+	rec := get("/api/annotate/quality/upcoming?exclude=hash1&exclude=hash2")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	var upcoming []pages.UpcomingImage
+	if err := json.Unmarshal(rec.Body.Bytes(), &upcoming); err != nil {
+		t.Fatal(err)
+	}
+	want := pages.UpcomingImage{ID: "hash3", Filename: "hash3.png", URL: "/asset/hash3?w=2048"}
+	if len(upcoming) != 1 || upcoming[0] != want {
+		t.Errorf("upcoming = %+v, want [%+v]", upcoming, want)
+	}
+
+	if rec := get("/api/annotate/quality/upcoming?n=0"); rec.Body.String() != "[]\n" {
+		t.Errorf("n=0 body = %q, want an empty list", rec.Body)
+	}
+	if rec := get("/api/annotate/quality/upcoming?n=many"); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid n status = %d, want 400", rec.Code)
+	}
+	if rec := get("/api/annotate/missing/upcoming"); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown task status = %d, want 404", rec.Code)
+	}
+}
+
+func TestAnnotateTemplatePrefetchesUpcoming(t *testing.T) {
+	var buf bytes.Buffer
+	err := pages.Annotate(PageShell("Annotate"), pages.AnnotateData{
+		TaskID:   "quality",
+		ImageID:  "hash1",
+		ImageURL: annotateAssetURL("hash1"),
+		Upcoming: upcomingUI([]*AnnotationStep{{TaskID: "quality", ImageID: "hash2", ImageName: "b.png"}}),
+	}).Render(t.Context(), &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`<link rel="prefetch" as="image" href="/asset/hash2?w=2048">`,
+		`src="/asset/hash1?w=2048"`,
+		`data-upcoming="[{&#34;id&#34;:&#34;hash2&#34;`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("annotate page does not contain %s", want)
+		}
+	}
+}
+
+func TestHandleReserve(t *testing.T) {
+	a := newPrefetchApp(t, "hash1")
+
+	post := func(user, target string) int {
+		req := httptest.NewRequest(http.MethodPost, target, nil)
+		req.SetBasicAuth(user, "")
+		rec := httptest.NewRecorder()
+		a.handleAnnotateAPI(rec, req)
+		return rec.Code
+	}
+	if code := post("alice", "/api/annotate/quality/hash1/reserve"); code != http.StatusNoContent {
+		t.Errorf("alice reserve status = %d, want 204", code)
+	}
+	if code := post("alice", "/api/annotate/quality/hash1/reserve"); code != http.StatusNoContent {
+		t.Errorf("alice renew status = %d, want 204", code)
+	}
+	if code := post("bob", "/api/annotate/quality/hash1/reserve"); code != http.StatusConflict {
+		t.Errorf("bob reserve status = %d, want 409", code)
+	}
+	if code := post("bob", "/api/annotate/missing/hash1/reserve"); code != http.StatusNotFound {
+		t.Errorf("unknown task status = %d, want 404", code)
+	}
+}
+
+func TestAnswerDoesNotReserveNextImage(t *testing.T) {
+	a := newMetricsApp(t)
+	handler := a.GetHTTPHandler()
+
+	// The annotation page saves in the background: the answer may reach the
+	// server before the page reserves the next image, with no lease held
+	req := httptest.NewRequest(http.MethodPost, "/annotate/quality/hash2", strings.NewReader("selectedClass=good&sure=on"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth("bob", "secret")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Header().Get("HX-Redirect") != "/annotate/quality/hash3" {
+		t.Fatalf("answer status = %d, redirect %q; want 200 to hash3", rec.Code, rec.Header().Get("HX-Redirect"))
+	}
+	leases, err := a.leaseRepo.ListActive(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leases) != 0 {
+		t.Errorf("active leases after an answer = %+v, want none", leases)
+	}
+
+	// Following the redirect shows the image, reserving it
+	if rec := scrape(handler, "bob", "/annotate/quality/hash3"); rec.Code != http.StatusOK {
+		t.Fatalf("next image status = %d", rec.Code)
+	}
+	if reserved, err := a.ReserveImage(t.Context(), "quality", "hash3", "alice"); err != nil || reserved {
+		t.Errorf("ReserveImage() of the image bob shows = %v, %v; want false", reserved, err)
+	}
+}
@@ -0,0 +1,83 @@
+package web
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/lewtec/rotulador/internal/domain"
+	"github.com/lewtec/rotulador/internal/repository"
+)
+
+// taskRules translates the tasks of the config into the rules the progress
+// tables are computed from. If keys naming a task become dependencies,
+// image fields narrow the filter, and anything else can never be satisfied.
+func (a *AnnotatorApp) taskRules() ([]domain.TaskRule, error) {
+	rules := make([]domain.TaskRule, len(a.Config.Tasks))
+	for i, task := range a.Config.Tasks {
+		rule := domain.TaskRule{StageIndex: i}
+		for key, value := range task.If {
+			if depStageIndex := a.findTaskIndex(key); depStageIndex != -1 {
+				rule.Dependencies = append(rule.Dependencies, domain.TaskDependency{StageIndex: depStageIndex, Value: value})
+				continue
+			}
+			if !IsImageField(key) {
+				rule.Dependencies = append(rule.Dependencies, domain.TaskDependency{StageIndex: domain.UnknownDependency, Value: value})
+				continue
+			}
+			if err := ParseImageCondition(key, value, &rule.Filter); err != nil {
+				return nil, fmt.Errorf("task %s: %w", task.ID, err)
+			}
+		}
+		// Map order is random, and the rules are compared by fingerprint
+		sort.Slice(rule.Dependencies, func(x, y int) bool {
+			return rule.Dependencies[x].StageIndex < rule.Dependencies[y].StageIndex
+		})
+		rules[i] = rule
+	}
+	return rules, nil
+}
+
+// SyncTaskRules stores the task rules of the config once per process,
+// recomputing the progress of every image if they changed since the last
+// run. Annotations, reviews and images keep it up to date from then on.
+// Only apps holding the full task config may call it: with no tasks, it
+// drops the progress of every task, under a server that already synced.
+func (a *AnnotatorApp) SyncTaskRules(ctx context.Context) error {
+	if a.rulesSynced.Load() {
+		return nil
+	}
+	a.rules.Lock()
+	defer a.rules.Unlock()
+	if a.rulesSynced.Load() {
+		return nil
+	}
+
+	rules, err := a.taskRules()
+	if err != nil {
+		return err
+	}
+	tx, err := a.Database.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("while starting transaction: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			ReportError(ctx, err, "msg", "failed to roll back task rules")
+		}
+	}()
+	changed, err := repository.NewTaskProgressRepositoryWithTx(tx).SyncRules(ctx, rules)
+	if err != nil {
+		return fmt.Errorf("while syncing task rules: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("while committing task rules: %w", err)
+	}
+	if changed {
+		a.Logger.Info("task rules changed, recomputed progress of every image")
+	}
+	a.rulesSynced.Store(true)
+	return nil
+}
@@ -0,0 +1,239 @@
+package web
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/lewtec/rotulador/internal/domain"
+	"github.com/lewtec/rotulador/internal/repository"
+	"github.com/lewtec/rotulador/internal/ui/pages"
+)
+
+// Errors returned by SubmitReview
+const (
+	ErrAnnotationNotFound appError = "annotation not found"
+	ErrUnknownClass       appError = "unknown class"
+	ErrOwnAnnotation      appError = "reviewers cannot review their own annotations"
+)
+
+// SubmitReview records the verdict of reviewer on an annotation of a task:
+// accepted when class is the annotated one, corrected otherwise. source is
+// recorded with the event in the history.
+func (a *AnnotatorApp) SubmitReview(ctx context.Context, taskID string, annotationID int64, reviewer, class, source string) (*domain.Review, error) {
+	stageIndex := a.findTaskIndex(taskID)
+	if stageIndex == -1 {
+		return nil, fmt.Errorf("%w: %s", ErrTaskNotFound, taskID)
+	}
+	if _, ok := a.Config.Tasks[stageIndex].Classes[class]; !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownClass, class)
+	}
+	ann, err := a.annotationRepo.GetByID(ctx, annotationID)
+	if err != nil {
+		return nil, fmt.Errorf("while getting annotation: %w", err)
+	}
+	if ann == nil || ann.StageIndex != stageIndex {
+		return nil, fmt.Errorf("%w: %d", ErrAnnotationNotFound, annotationID)
+	}
+	if ann.Username == reviewer {
+		return nil, ErrOwnAnnotation
+	}
+
+	outcome := domain.ReviewAccepted
+	if class != ann.OptionValue {
+		outcome = domain.ReviewCorrected
+	}
+
+	tx, err := a.Database.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("while starting transaction: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			ReportError(ctx, err, "msg", "failed to roll back review")
+		}
+	}()
+	review, err := repository.NewReviewRepositoryWithTx(tx).Create(ctx, annotationID, reviewer, outcome, class)
+	if err != nil {
+		return nil, fmt.Errorf("while creating review: %w", err)
+	}
+	_, err = repository.NewEventRepositoryWithTx(tx).Create(ctx, &domain.AnnotationEvent{
+		Kind:         domain.EventReview,
+		Source:       source,
+		Actor:        reviewer,
+		ImageSHA256:  ann.ImageSHA256,
+		StageIndex:   stageIndex,
+		Username:     ann.Username,
+		AnnotationID: ann.ID,
+		OldValue:     ann.OptionValue,
+		NewValue:     class,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("while recording review event: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("while committing review: %w", err)
+	}
+	return review, nil
+}
+
+// ReviewedLabels maps stage indexes to the label of the latest review of an
+// image in that stage, which takes precedence over its annotations. reviews
+// are ordered oldest first, as ReviewRepository.GetForImage returns them.
+func ReviewedLabels(reviews []*domain.ReviewWithAnnotation) map[int]string {
+	labels := make(map[int]string, len(reviews))
+	for _, review := range reviews {
+		labels[review.StageIndex] = review.OptionValue
+	}
+	return labels
+}
+
+// LatestReviews maps annotation IDs to their latest review.
+func LatestReviews(reviews []*domain.ReviewWithAnnotation) map[int64]*domain.ReviewWithAnnotation {
+	latest := make(map[int64]*domain.ReviewWithAnnotation, len(reviews))
+	for _, review := range reviews {
+		latest[review.AnnotationID] = review
+	}
+	return latest
+}
+
+// handleReview serves /review, the tasks with annotations waiting for a
+// review, and /review/{task}: the oldest unreviewed annotation of the task,
+// which the reviewer accepts or corrects.
+func (a *AnnotatorApp) handleReview(w http.ResponseWriter, r *http.Request) {
+	if !a.isReviewer(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	// authenticationMiddleware already validated these credentials
+	reviewer, _, _ := r.BasicAuth()
+
+	itemPath := pathParts(r.URL.Path)
+	if len(itemPath) == 1 {
+		a.handleReviewIndex(w, r, reviewer)
+		return
+	}
+	if len(itemPath) != 2 {
+		http.NotFoundHandler().ServeHTTP(w, r)
+		return
+	}
+	task := a.GetTask(itemPath[1])
+	if task == nil {
+		http.NotFoundHandler().ServeHTTP(w, r)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		a.submitReviewForm(w, r, task, reviewer)
+		return
+	}
+
+	stageIndex := a.findTaskIndex(task.ID)
+	next, err := a.reviewRepo.NextToReview(r.Context(), stageIndex, reviewer)
+	if err != nil {
+		ReportError(r.Context(), err, "msg", "error getting next annotation to review", "task", task.ID)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	pending, err := a.reviewRepo.CountToReview(r.Context(), stageIndex, reviewer)
+	if err != nil {
+		ReportError(r.Context(), err, "msg", "error counting annotations to review", "task", task.ID)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	rates, err := a.reviewRepo.AcceptanceRates(r.Context(), stageIndex)
+	if err != nil {
+		ReportError(r.Context(), err, "msg", "error getting acceptance rates", "task", task.ID)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	data := pages.ReviewData{
+		TaskID:   task.ID,
+		TaskName: task.Name,
+		Pending:  int(pending),
+		Classes:  classButtons(task),
+		Rates:    acceptanceRows(rates),
+	}
+	if next != nil {
+		data.AnnotationID = next.ID
+		data.ImageID = next.ImageSHA256
+		data.ImageFilename = next.ImageFilename
+		data.ImageURL = annotateAssetURL(next.ImageSHA256)
+		data.Annotator = next.Username
+		data.Label = next.OptionValue
+		data.Sure = next.Sure
+	}
+	err = Render(r.Context(), w, pages.Review(PageShell("Review"), data))
+	if err != nil {
+		ReportError(r.Context(), err, "msg", "error rendering review template")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// submitReviewForm records a verdict posted from the review page and moves
+// on to the next annotation.
+func (a *AnnotatorApp) submitReviewForm(w http.ResponseWriter, r *http.Request, task *ConfigTask, reviewer string) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	annotationID, err := strconv.ParseInt(r.PostForm.Get("annotation"), 10, 64)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid annotation: %q", r.PostForm.Get("annotation")), http.StatusBadRequest)
+		return
+	}
+
+	review, err := a.SubmitReview(r.Context(), task.ID, annotationID, reviewer, r.PostForm.Get("class"), domain.EventSourceWeb)
+	switch {
+	case errors.Is(err, ErrOwnAnnotation):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case errors.Is(err, ErrUnknownClass), errors.Is(err, ErrAnnotationNotFound):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		ReportError(r.Context(), err, "msg", "error while submitting review", "task", task.ID)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	a.Logger.Info("review: submitted", "task", task.ID, "annotation", annotationID, "reviewer", reviewer, "outcome", review.Outcome)
+
+	http.Redirect(w, r, fmt.Sprintf("/review/%s", task.ID), http.StatusSeeOther)
+}
+
+// handleReviewIndex lists the tasks with how many annotations each has
+// waiting for the reviewer.
+func (a *AnnotatorApp) handleReviewIndex(w http.ResponseWriter, r *http.Request, reviewer string) {
+	data := pages.ReviewIndexData{Tasks: make([]pages.ReviewTask, 0, len(a.Config.Tasks))}
+	for i, task := range a.Config.Tasks {
+		pending, err := a.reviewRepo.CountToReview(r.Context(), i, reviewer)
+		if err != nil {
+			ReportError(r.Context(), err, "msg", "error counting annotations to review", "task", task.ID)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		data.Tasks = append(data.Tasks, pages.ReviewTask{ID: task.ID, Name: task.Name, Pending: int(pending)})
+	}
+	err := Render(r.Context(), w, pages.ReviewIndex(PageShell("Review"), data))
+	if err != nil {
+		ReportError(r.Context(), err, "msg", "error rendering review index template")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func acceptanceRows(rates []*domain.AcceptanceRate) []pages.AcceptanceRow {
+	rows := make([]pages.AcceptanceRow, 0, len(rates))
+	for _, rate := range rates {
+		rows = append(rows, pages.AcceptanceRow{
+			Username: rate.Username,
+			Reviewed: int(rate.Reviewed),
+			Accepted: int(rate.Accepted),
+			Percent:  int(rate.Rate()*100 + 0.5),
+		})
+	}
+	return rows
+}
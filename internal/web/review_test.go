@@ -0,0 +1,191 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/lewtec/rotulador/internal/domain"
+)
+
+func newReviewApp(t *testing.T) *AnnotatorApp {
+	t.Helper()
+	classes := map[string]*ConfigClass{"bad": {Name: "Bad"}, "good": {Name: "Good"}}
+	a := newTestApp(t, &Config{
+		Tasks: []*ConfigTask{
+			{ID: "quality", Name: "Quality", Classes: classes},
+			{ID: "detail", Name: "Detail", Classes: classes, If: map[string]string{"quality": "good"}},
+		},
+		Authentication: map[string]*ConfigAuth{
+			"alice": {},
+			"bob":   {Reviewer: true},
+			"carol": {Reviewer: true},
+		},
+	})
+	ctx := t.Context()
+	for _, hash := range []string{"hash1", "hash2"} {
+		if _, err := a.imageRepo.Create(ctx, hash, hash+".png"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, ann := range []AnnotationResponse{
+		{ImageID: "hash1", TaskID: "quality", User: "alice", Value: "good", Sure: true},
+		{ImageID: "hash2", TaskID: "quality", User: "bob", Value: "good", Sure: true},
+	} {
+		if err := a.SubmitAnnotation(ctx, ann); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return a
+}
+
+func reviewRequest(a *AnnotatorApp, user, method, target string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	req.SetBasicAuth(user, "")
+	rec := httptest.NewRecorder()
+	a.handleReview(rec, req)
+	return rec
+}
+
+func TestReviewCorrectionTakesPrecedence(t *testing.T) {
+	a := newReviewApp(t)
+	ctx := t.Context()
+	ann, err := a.annotationRepo.Get(ctx, "hash1", "alice", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := reviewRequest(a, "carol", http.MethodGet, "/review/quality", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	if want := fmt.Sprintf(`name="annotation" value="%d"`, ann.ID); !strings.Contains(rec.Body.String(), want) {
+		t.Fatalf("review page does not show alice's annotation, the oldest one")
+	}
+
+	if available, err := a.CountAvailableImages(ctx, "detail"); err != nil || available != 2 {
+		t.Fatalf("detail available = %d, %v; want both images labeled good", available, err)
+	}
+	rec = reviewRequest(a, "carol", http.MethodPost, "/review/quality", url.Values{
+		"annotation": {fmt.Sprint(ann.ID)},
+		"class":      {"bad"},
+	})
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/review/quality" {
+		t.Fatalf("status = %d, location %q, body %s", rec.Code, rec.Header().Get("Location"), rec.Body)
+	}
+	// The correction overrides alice's answer in If gating
+	if available, err := a.CountAvailableImages(ctx, "detail"); err != nil || available != 1 {
+		t.Errorf("detail available = %d, %v; want 1 after correcting hash1 to bad", available, err)
+	}
+
+	rates, err := a.reviewRepo.AcceptanceRates(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rates) != 1 || rates[0].Username != "alice" || rates[0].Accepted != 0 || rates[0].Reviewed != 1 {
+		t.Errorf("acceptance rates = %+v, want alice 0/1", rates)
+	}
+
+	// Reviewers browse without being admins, and see the correction there
+	rec = browse(a, "carol", "/browse/hash1")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("browse status = %d, want 200 for a reviewer", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "corrected to") {
+		t.Error("image detail page does not show the correction")
+	}
+}
+
+func TestOverwriteDropsStaleReviews(t *testing.T) {
+	a := newReviewApp(t)
+	ctx := t.Context()
+	ann, err := a.annotationRepo.Get(ctx, "hash1", "alice", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.SubmitReview(ctx, "quality", ann.ID, "carol", "bad", domain.EventSourceWeb); err != nil {
+		t.Fatal(err)
+	}
+	if available, err := a.CountAvailableImages(ctx, "detail"); err != nil || available != 1 {
+		t.Fatalf("detail available = %d, %v; want 1 after correcting hash1 to bad", available, err)
+	}
+
+	// Answering the same again keeps the verdict on it
+	resubmit := func(value string) {
+		t.Helper()
+		err := a.SubmitAnnotation(ctx, AnnotationResponse{ImageID: "hash1", TaskID: "quality", User: "alice", Value: value, Sure: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	resubmit("good")
+	if reviews, err := a.reviewRepo.GetForImage(ctx, "hash1"); err != nil || len(reviews) != 1 {
+		t.Fatalf("reviews after the same answer = %+v, %v; want carol's kept", reviews, err)
+	}
+
+	// A new answer was never reviewed: the old correction must not override it
+	resubmit("bad")
+	resubmit("good")
+	if reviews, err := a.reviewRepo.GetForImage(ctx, "hash1"); err != nil || len(reviews) != 0 {
+		t.Errorf("reviews after overwriting = %+v, %v; want none", reviews, err)
+	}
+	if available, err := a.CountAvailableImages(ctx, "detail"); err != nil || available != 2 {
+		t.Errorf("detail available = %d, %v; want 2 with hash1 answered good again", available, err)
+	}
+	next, err := a.reviewRepo.NextToReview(ctx, 0, "carol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next == nil || next.ID != ann.ID {
+		t.Errorf("next to review = %+v, want alice's new answer back in the queue", next)
+	}
+}
+
+func TestSubmitReview(t *testing.T) {
+	a := newReviewApp(t)
+	ctx := t.Context()
+	bobs, err := a.annotationRepo.Get(ctx, "hash2", "bob", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	review, err := a.SubmitReview(ctx, "quality", bobs.ID, "carol", "good", domain.EventSourceWeb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if review.Outcome != domain.ReviewAccepted || review.OptionValue != "good" {
+		t.Errorf("review = %+v, want accepted as good", review)
+	}
+
+	tests := []struct {
+		name   string
+		user   string
+		form   url.Values
+		status int
+	}{
+		{"own annotation", "bob", url.Values{"annotation": {fmt.Sprint(bobs.ID)}, "class": {"good"}}, http.StatusForbidden},
+		{"unknown class", "carol", url.Values{"annotation": {fmt.Sprint(bobs.ID)}, "class": {"meh"}}, http.StatusBadRequest},
+		{"unknown annotation", "carol", url.Values{"annotation": {"999"}, "class": {"good"}}, http.StatusBadRequest},
+		{"other task", "carol", url.Values{"annotation": {fmt.Sprint(bobs.ID)}, "class": {"good"}}, http.StatusBadRequest},
+		{"not a reviewer", "alice", url.Values{"annotation": {fmt.Sprint(bobs.ID)}, "class": {"good"}}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		target := "/review/quality"
+		if tt.name == "other task" {
+			target = "/review/detail"
+		}
+		if rec := reviewRequest(a, tt.user, http.MethodPost, target, tt.form); rec.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.status)
+		}
+	}
+
+	if rec := reviewRequest(a, "carol", http.MethodGet, "/review", nil); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `href="/review/quality"`) {
+		t.Errorf("review index status = %d, want a link to each task", rec.Code)
+	}
+}
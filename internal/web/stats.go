@@ -0,0 +1,472 @@
+package web
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/lewtec/rotulador/internal/domain"
+	"github.com/lewtec/rotulador/internal/ui/pages"
+)
+
+// UserTaskStats are the numbers of a user in a task
+type UserTaskStats struct {
+	domain.UserStageStats
+	// TaskID is empty for stages no longer in the config
+	TaskID string
+	// Median is the median time-on-image, zero when none was recorded
+	Median time.Duration
+}
+
+// UnsureRate is the share of annotations answered as unsure.
+func (s *UserTaskStats) UnsureRate() float64 {
+	if s.Annotations == 0 {
+		return 0
+	}
+	return float64(s.Unsure) / float64(s.Annotations)
+}
+
+// Agreement is the share of other annotators' answers to the same images
+// that match the user's; ok is false when nobody else answered them.
+func (s *UserTaskStats) Agreement() (rate float64, ok bool) {
+	if s.Compared == 0 {
+		return 0, false
+	}
+	return float64(s.Agreed) / float64(s.Compared), true
+}
+
+// GoldAccuracy is the share of reviewed annotations matching the review;
+// ok is false when none of the user's images were reviewed.
+func (s *UserTaskStats) GoldAccuracy() (rate float64, ok bool) {
+	if s.Graded == 0 {
+		return 0, false
+	}
+	return float64(s.Correct) / float64(s.Graded), true
+}
+
+// ClassTimeStats sums up the time-on-image of the annotations of a task
+// answered with a class
+type ClassTimeStats struct {
+	StageIndex int
+	// TaskID is empty for stages no longer in the config
+	TaskID string
+	Class  string
+	// Timed counts the annotations with a recorded time-on-image
+	Timed  int
+	Median time.Duration
+	Total  time.Duration
+}
+
+// StatsReport holds the numbers of the /stats pages for a period
+type StatsReport struct {
+	Users   []*UserTaskStats
+	Classes []*ClassTimeStats
+	// Medians is the median time-on-image of each user across tasks
+	Medians map[string]time.Duration
+}
+
+// TooFast lists the users whose median time-on-image is below limit, sorted.
+// A zero limit flags nobody.
+func (r *StatsReport) TooFast(limit time.Duration) []string {
+	var users []string
+	for user, median := range r.Medians {
+		if median < limit {
+			users = append(users, user)
+		}
+	}
+	sort.Strings(users)
+	return users
+}
+
+// Stats returns the numbers of every user in every task, and the time spent
+// per task and class, for annotations made in [after, before); zero times
+// are unbounded.
+func (a *AnnotatorApp) Stats(ctx context.Context, after, before time.Time) (*StatsReport, error) {
+	stats, err := a.annotationRepo.UserStageStats(ctx, after, before)
+	if err != nil {
+		return nil, fmt.Errorf("while getting annotation stats: %w", err)
+	}
+	durations, err := a.annotationRepo.ListDurations(ctx, after, before)
+	if err != nil {
+		return nil, fmt.Errorf("while listing annotation durations: %w", err)
+	}
+
+	byUser := map[string][]time.Duration{}
+	byUserStage := map[userStage][]time.Duration{}
+	byClass := map[stageClass][]time.Duration{}
+	for _, d := range durations {
+		byUser[d.Username] = append(byUser[d.Username], d.Duration)
+		key := userStage{d.Username, d.StageIndex}
+		byUserStage[key] = append(byUserStage[key], d.Duration)
+		class := stageClass{d.StageIndex, d.OptionValue}
+		byClass[class] = append(byClass[class], d.Duration)
+	}
+
+	report := &StatsReport{
+		Users:   make([]*UserTaskStats, len(stats)),
+		Classes: make([]*ClassTimeStats, 0, len(byClass)),
+		Medians: make(map[string]time.Duration, len(byUser)),
+	}
+	for i, s := range stats {
+		report.Users[i] = &UserTaskStats{
+			UserStageStats: *s,
+			TaskID:         a.stageTaskID(s.StageIndex),
+			Median:         median(byUserStage[userStage{s.Username, s.StageIndex}]),
+		}
+	}
+	for key, ds := range byClass {
+		c := &ClassTimeStats{
+			StageIndex: key.stageIndex,
+			TaskID:     a.stageTaskID(key.stageIndex),
+			Class:      key.class,
+			Timed:      len(ds),
+			Median:     median(ds),
+		}
+		for _, d := range ds {
+			c.Total += d
+		}
+		report.Classes = append(report.Classes, c)
+	}
+	sort.Slice(report.Classes, func(i, j int) bool {
+		if report.Classes[i].StageIndex != report.Classes[j].StageIndex {
+			return report.Classes[i].StageIndex < report.Classes[j].StageIndex
+		}
+		return report.Classes[i].Class < report.Classes[j].Class
+	})
+	for user, ds := range byUser {
+		report.Medians[user] = median(ds)
+	}
+	return report, nil
+}
+
+// stageTaskID is the ID of the task of a stage, empty for stages no longer
+// in the config.
+func (a *AnnotatorApp) stageTaskID(stageIndex int) string {
+	if stageIndex < len(a.Config.Tasks) {
+		return a.Config.Tasks[stageIndex].ID
+	}
+	return ""
+}
+
+type userStage struct {
+	username   string
+	stageIndex int
+}
+
+type stageClass struct {
+	stageIndex int
+	class      string
+}
+
+// median sorts ds and returns its median, zero when empty.
+func median(ds []time.Duration) time.Duration {
+	if len(ds) == 0 {
+		return 0
+	}
+	slices.Sort(ds)
+	if len(ds)%2 == 1 {
+		return ds[len(ds)/2]
+	}
+	return (ds[len(ds)/2-1] + ds[len(ds)/2]) / 2
+}
+
+// handleStats serves /stats, the per-user numbers admins use to follow and
+// pay annotators, its CSV downloads /stats/users.csv, /stats/classes.csv and
+// /stats/daily.csv, and /stats/leaderboard, which every user sees when stats.leaderboard is
+// set. All of them take optional from and to dates, both inclusive.
+func (a *AnnotatorApp) handleStats(w http.ResponseWriter, r *http.Request) {
+	itemPath := pathParts(r.URL.Path)
+	if len(itemPath) > 2 {
+		http.NotFoundHandler().ServeHTTP(w, r)
+		return
+	}
+	var page string
+	if len(itemPath) == 2 {
+		page = itemPath[1]
+	}
+	if page == "leaderboard" && !a.Config.Stats.Leaderboard && !a.isAdmin(r) {
+		http.NotFoundHandler().ServeHTTP(w, r)
+		return
+	}
+	if page != "leaderboard" && !a.isAdmin(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	query := r.URL.Query()
+	period := pages.StatsPeriod{From: query.Get("from"), To: query.Get("to")}
+	after, before, err := parseDateRange(period.From, period.To)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch page {
+	case "daily.csv":
+		days, err := a.annotationRepo.UserDailyCounts(r.Context(), after, before)
+		if err != nil {
+			ReportError(r.Context(), err, "msg", "error getting daily annotation counts")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="daily.csv"`)
+		if err := writeDailyCSV(w, days); err != nil {
+			ReportError(r.Context(), err, "msg", "error writing daily stats")
+		}
+		return
+	case "", "users.csv", "classes.csv", "leaderboard":
+	default:
+		http.NotFoundHandler().ServeHTTP(w, r)
+		return
+	}
+
+	report, err := a.Stats(r.Context(), after, before)
+	if err != nil {
+		ReportError(r.Context(), err, "msg", "error getting user stats")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	switch page {
+	case "users.csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="users.csv"`)
+		if err := writeUserStatsCSV(w, report, a.Config.Stats.MinMedian()); err != nil {
+			ReportError(r.Context(), err, "msg", "error writing user stats")
+		}
+	case "classes.csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="classes.csv"`)
+		if err := writeClassStatsCSV(w, report.Classes); err != nil {
+			ReportError(r.Context(), err, "msg", "error writing class stats")
+		}
+	case "leaderboard":
+		// authenticationMiddleware already validated these credentials
+		user, _, _ := r.BasicAuth()
+		err = Render(r.Context(), w, pages.Leaderboard(PageShell("Leaderboard"), pages.LeaderboardData{
+			Period: period,
+			Rows:   leaderboardRows(report.Users, user),
+		}))
+		if err != nil {
+			ReportError(r.Context(), err, "msg", "error rendering leaderboard template")
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	default:
+		days, err := a.annotationRepo.UserDailyCounts(r.Context(), after, before)
+		if err != nil {
+			ReportError(r.Context(), err, "msg", "error getting daily annotation counts")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		data := a.statsData(report, days)
+		data.Period = period
+		data.Leaderboard = a.Config.Stats.Leaderboard
+		err = Render(r.Context(), w, pages.Stats(PageShell("Statistics"), data))
+		if err != nil {
+			ReportError(r.Context(), err, "msg", "error rendering stats template")
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}
+}
+
+// statsData lays out the numbers of the /stats page: a row per user and
+// task, a row per task and class, and a row per day with a column per user.
+func (a *AnnotatorApp) statsData(report *StatsReport, days []*domain.UserDayCount) pages.StatsData {
+	minMedian := a.Config.Stats.MinMedian()
+	data := pages.StatsData{
+		Rows:      make([]pages.StatsRow, 0, len(report.Users)),
+		Classes:   make([]pages.StatsClassRow, 0, len(report.Classes)),
+		TooFast:   report.TooFast(minMedian),
+		MinMedian: formatDuration(minMedian),
+	}
+	for _, s := range report.Users {
+		data.Rows = append(data.Rows, pages.StatsRow{
+			Username:    s.Username,
+			TaskName:    a.taskShortName(s.TaskID),
+			Annotations: int(s.Annotations),
+			UnsureRate:  percent(s.UnsureRate(), true),
+			Median:      formatDuration(s.Median),
+			Agreement:   percent(s.Agreement()),
+			Gold:        percent(s.GoldAccuracy()),
+			TooFast:     slices.Contains(data.TooFast, s.Username),
+		})
+	}
+	for _, c := range report.Classes {
+		row := pages.StatsClassRow{
+			TaskName:  a.taskShortName(c.TaskID),
+			ClassName: c.Class,
+			Timed:     c.Timed,
+			Median:    formatDuration(c.Median),
+			Total:     formatDuration(c.Total.Round(time.Second)),
+		}
+		if task := a.GetTask(c.TaskID); task != nil {
+			if class := task.Classes[c.Class]; class != nil && class.Name != "" {
+				row.ClassName = class.Name
+			}
+		}
+		data.Classes = append(data.Classes, row)
+	}
+
+	for _, d := range days {
+		if !slices.Contains(data.Users, d.Username) {
+			data.Users = append(data.Users, d.Username)
+		}
+	}
+	sort.Strings(data.Users)
+	column := make(map[string]int, len(data.Users))
+	for i, user := range data.Users {
+		column[user] = i
+	}
+	for _, d := range days {
+		if n := len(data.Days); n == 0 || data.Days[n-1].Day != d.Day {
+			data.Days = append(data.Days, pages.StatsDay{Day: d.Day, Counts: make([]int, len(data.Users))})
+		}
+		day := &data.Days[len(data.Days)-1]
+		day.Counts[column[d.Username]] = int(d.Annotations)
+		day.Total += int(d.Annotations)
+	}
+	return data
+}
+
+// leaderboardRows ranks users by how many annotations they made in any task.
+// Users with the same count share a rank.
+func leaderboardRows(stats []*UserTaskStats, currentUser string) []pages.LeaderboardRow {
+	totals := map[string]int{}
+	for _, s := range stats {
+		totals[s.Username] += int(s.Annotations)
+	}
+	rows := make([]pages.LeaderboardRow, 0, len(totals))
+	for user, total := range totals {
+		rows = append(rows, pages.LeaderboardRow{Username: user, Annotations: total, Current: user == currentUser})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Annotations != rows[j].Annotations {
+			return rows[i].Annotations > rows[j].Annotations
+		}
+		return rows[i].Username < rows[j].Username
+	})
+	for i := range rows {
+		rows[i].Rank = i + 1
+		if i > 0 && rows[i].Annotations == rows[i-1].Annotations {
+			rows[i].Rank = rows[i-1].Rank
+		}
+	}
+	return rows
+}
+
+// taskShortName names a task in stats tables, falling back to its ID.
+func (a *AnnotatorApp) taskShortName(taskID string) string {
+	if task := a.GetTask(taskID); task != nil {
+		return task.ShortName
+	}
+	return taskID
+}
+
+// formatDuration formats a time-on-image for the stats page, empty when
+// unknown.
+func formatDuration(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	return d.Round(100 * time.Millisecond).String()
+}
+
+// percent formats a rate for the stats page, or a dash when it is unknown.
+func percent(rate float64, ok bool) string {
+	if !ok {
+		return "—"
+	}
+	return fmt.Sprintf("%.0f%%", rate*100)
+}
+
+// userStatsColumns is the header of /stats/users.csv
+var userStatsColumns = []string{
+	"username", "task", "stage_index", "annotations", "unsure", "unsure_rate",
+	"median_seconds", "compared", "agreed", "agreement", "graded", "correct", "gold_accuracy",
+	"too_fast",
+}
+
+// writeUserStatsCSV writes a row per user and task. Rates are fractions, and
+// left empty when unknown like the median time. too_fast flags users whose
+// median time-on-image across tasks is below minMedian.
+func writeUserStatsCSV(out io.Writer, report *StatsReport, minMedian time.Duration) error {
+	w := csv.NewWriter(out)
+	if err := w.Write(userStatsColumns); err != nil {
+		return err
+	}
+	tooFast := report.TooFast(minMedian)
+	for _, s := range report.Users {
+		row := []string{
+			s.Username, s.TaskID, strconv.Itoa(s.StageIndex),
+			strconv.FormatInt(s.Annotations, 10), strconv.FormatInt(s.Unsure, 10), csvRate(s.UnsureRate(), true),
+			csvSeconds(s.Median),
+			strconv.FormatInt(s.Compared, 10), strconv.FormatInt(s.Agreed, 10), csvRate(s.Agreement()),
+			strconv.FormatInt(s.Graded, 10), strconv.FormatInt(s.Correct, 10), csvRate(s.GoldAccuracy()),
+			strconv.FormatBool(slices.Contains(tooFast, s.Username)),
+		}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// classStatsColumns is the header of /stats/classes.csv
+var classStatsColumns = []string{"task", "stage_index", "class", "timed", "median_seconds", "total_seconds"}
+
+// writeClassStatsCSV writes a row per task and class with the time spent on
+// its annotations.
+func writeClassStatsCSV(out io.Writer, classes []*ClassTimeStats) error {
+	w := csv.NewWriter(out)
+	if err := w.Write(classStatsColumns); err != nil {
+		return err
+	}
+	for _, c := range classes {
+		row := []string{
+			c.TaskID, strconv.Itoa(c.StageIndex), c.Class, strconv.Itoa(c.Timed),
+			csvSeconds(c.Median), csvSeconds(c.Total),
+		}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// writeDailyCSV writes a row per user and day.
+func writeDailyCSV(out io.Writer, days []*domain.UserDayCount) error {
+	w := csv.NewWriter(out)
+	if err := w.Write([]string{"day", "username", "annotations"}); err != nil {
+		return err
+	}
+	for _, d := range days {
+		if err := w.Write([]string{d.Day, d.Username, strconv.FormatInt(d.Annotations, 10)}); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func csvSeconds(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	return strconv.FormatFloat(d.Seconds(), 'f', 1, 64)
+}
+
+func csvRate(rate float64, ok bool) string {
+	if !ok {
+		return ""
+	}
+	return strconv.FormatFloat(rate, 'f', 4, 64)
+}
@@ -0,0 +1,196 @@
+package web
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lewtec/rotulador/internal/domain"
+)
+
+func TestStats(t *testing.T) {
+	a := newBrowseApp(t)
+	ctx := t.Context()
+	for _, ann := range []AnnotationResponse{
+		{ImageID: "hash3", TaskID: "quality", User: "alice", Value: "good", Sure: true, Duration: 2 * time.Second},
+		{ImageID: "hash3", TaskID: "quality", User: "bob", Value: "bad", Sure: true, Duration: 300 * time.Millisecond},
+		{ImageID: "hash2", TaskID: "quality", User: "bob", Value: "bad", Sure: true, Duration: 500 * time.Millisecond},
+		{ImageID: "hash1", TaskID: "quality", User: "admin", Value: "good", Sure: true, Duration: 6 * time.Second},
+	} {
+		if err := a.SubmitAnnotation(ctx, ann); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := a.Stats(ctx, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The fixture's annotations have no time-on-image and are left out
+	wantMedians := map[string]time.Duration{"admin": 6 * time.Second, "alice": 2 * time.Second, "bob": 400 * time.Millisecond}
+	if len(report.Medians) != len(wantMedians) {
+		t.Errorf("Medians = %v, want %v", report.Medians, wantMedians)
+	}
+	for user, want := range wantMedians {
+		if report.Medians[user] != want {
+			t.Errorf("median of %s = %s, want %s", user, report.Medians[user], want)
+		}
+	}
+	for _, s := range report.Users {
+		if s.Username == "bob" && s.Median != 400*time.Millisecond {
+			t.Errorf("bob's median in quality = %s, want 400ms", s.Median)
+		}
+	}
+
+	var classes []string
+	for _, c := range report.Classes {
+		classes = append(classes, fmt.Sprintf("%s/%s:%d:%s:%s", c.TaskID, c.Class, c.Timed, c.Median, c.Total))
+	}
+	if want := "quality/bad:2:400ms:800ms quality/good:2:4s:8s"; strings.Join(classes, " ") != want {
+		t.Errorf("Classes = %v, want %s", classes, want)
+	}
+
+	if got := report.TooFast(time.Second); len(got) != 1 || got[0] != "bob" {
+		t.Errorf("TooFast(1s) = %v, want bob", got)
+	}
+	if got := report.TooFast(0); len(got) != 0 {
+		t.Errorf("TooFast(0) = %v, want nobody", got)
+	}
+}
+
+func TestConfigStatsMinMedian(t *testing.T) {
+	for _, tt := range []struct {
+		seconds float64
+		want    time.Duration
+	}{
+		{0, time.Second},
+		{2.5, 2500 * time.Millisecond},
+		{-1, 0},
+	} {
+		if got := (ConfigStats{MinMedianSeconds: tt.seconds}).MinMedian(); got != tt.want {
+			t.Errorf("MinMedian() with %v = %s, want %s", tt.seconds, got, tt.want)
+		}
+	}
+}
+
+func TestLeaderboardRows(t *testing.T) {
+	stats := []*UserTaskStats{
+		{UserStageStats: domain.UserStageStats{Username: "alice", StageIndex: 0, Annotations: 3}},
+		{UserStageStats: domain.UserStageStats{Username: "alice", StageIndex: 1, Annotations: 2}},
+		{UserStageStats: domain.UserStageStats{Username: "bob", Annotations: 5}},
+		{UserStageStats: domain.UserStageStats{Username: "carol", Annotations: 1}},
+	}
+	rows := leaderboardRows(stats, "bob")
+	var got []string
+	for _, row := range rows {
+		got = append(got, fmt.Sprintf("%s:%d", row.Username, row.Rank))
+	}
+	if want := "alice:1 bob:1 carol:3"; strings.Join(got, " ") != want {
+		t.Errorf("leaderboardRows() = %v, want %s", got, want)
+	}
+	if !rows[1].Current || rows[0].Current {
+		t.Error("the current user is not the one highlighted")
+	}
+}
+
+func newStatsApp(t *testing.T, leaderboard bool) *AnnotatorApp {
+	t.Helper()
+	a := newBrowseApp(t)
+	a.Config.Stats.Leaderboard = leaderboard
+	return a
+}
+
+func stats(a *AnnotatorApp, user, target string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.SetBasicAuth(user, "")
+	rec := httptest.NewRecorder()
+	a.handleStats(rec, req)
+	return rec
+}
+
+func TestHandleStats(t *testing.T) {
+	a := newStatsApp(t, false)
+
+	rec := stats(a, "admin", "/stats")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	for _, want := range []string{"alice", "bob", time.Now().UTC().Format(time.DateOnly), `href="/stats/users.csv"`} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("stats page does not contain %s", want)
+		}
+	}
+
+	rec = stats(a, "admin", "/stats/users.csv?from=2000-01-01")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/csv; charset=utf-8" {
+		t.Fatalf("users.csv status = %d, content type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// alice answered hash1 and hash2, the second unsure; bob disagreed on hash1
+	want := [][]string{
+		userStatsColumns,
+		{"alice", "quality", "0", "2", "1", "0.5000", "", "1", "0", "0.0000", "0", "0", "", "false"},
+		{"bob", "quality", "0", "1", "0", "0.0000", "", "1", "0", "0.0000", "0", "0", "", "false"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("users.csv = %v, want %v", records, want)
+	}
+	for i := range want {
+		if strings.Join(records[i], ",") != strings.Join(want[i], ",") {
+			t.Errorf("users.csv row %d = %v, want %v", i, records[i], want[i])
+		}
+	}
+
+	rec = stats(a, "admin", "/stats/daily.csv")
+	if body := rec.Body.String(); !strings.HasPrefix(body, "day,username,annotations\n") || !strings.Contains(body, ",alice,2\n") {
+		t.Errorf("daily.csv = %q", body)
+	}
+	if body := stats(a, "admin", "/stats/classes.csv").Body.String(); body != strings.Join(classStatsColumns, ",")+"\n" {
+		t.Errorf("classes.csv without any time-on-image = %q, want the header only", body)
+	}
+	if body := stats(a, "admin", "/stats/users.csv?to=2000-01-01").Body.String(); body != strings.Join(userStatsColumns, ",")+"\n" {
+		t.Errorf("users.csv before any annotation = %q, want the header only", body)
+	}
+
+	for _, tt := range []struct {
+		user, target string
+		status       int
+	}{
+		{"alice", "/stats", http.StatusForbidden},
+		{"alice", "/stats/users.csv", http.StatusForbidden},
+		{"alice", "/stats/leaderboard", http.StatusNotFound},
+		{"admin", "/stats/leaderboard", http.StatusOK},
+		{"admin", "/stats?from=yesterday", http.StatusBadRequest},
+		{"admin", "/stats/nope", http.StatusNotFound},
+	} {
+		if rec := stats(a, tt.user, tt.target); rec.Code != tt.status {
+			t.Errorf("%s %s: status = %d, want %d", tt.user, tt.target, rec.Code, tt.status)
+		}
+	}
+}
+
+func TestHandleStatsLeaderboard(t *testing.T) {
+	a := newStatsApp(t, true)
+
+	rec := stats(a, "bob", "/stats/leaderboard")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	body := rec.Body.String()
+	if alice, bob := strings.Index(body, "alice"), strings.Index(body, "bob"); alice == -1 || bob == -1 || alice > bob {
+		t.Error("leaderboard does not rank alice above bob")
+	}
+	if !strings.Contains(body, "bg-primary/10") {
+		t.Error("leaderboard does not highlight the current user")
+	}
+	if rec := stats(a, "bob", "/stats"); rec.Code != http.StatusForbidden {
+		t.Errorf("/stats status = %d, want 403 for annotators even with the leaderboard on", rec.Code)
+	}
+}
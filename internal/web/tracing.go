@@ -0,0 +1,41 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/lewtec/rotulador/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+)
+
+// tracingMiddleware starts the server span of every request, named after the
+// pattern of mux that serves it, so the spans of the middlewares, handlers
+// and queries below hang off it. A trace started by the caller, like a
+// reverse proxy, is continued through the traceparent header.
+func tracingMiddleware(mux *http.ServeMux, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, route := mux.Handler(r)
+		if route == "" {
+			route = "unmatched"
+		}
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracing.StartServer(ctx, r.Method+" "+route,
+			semconv.HTTPRequestMethodKey.String(r.Method),
+			semconv.HTTPRoute(route),
+			semconv.URLPath(r.URL.Path),
+		)
+		defer span.End()
+		if user, _, ok := r.BasicAuth(); ok {
+			span.SetAttributes(semconv.EnduserID(user))
+		}
+
+		wr := NewStatusCodeRecorderResponseWriter(w)
+		handler.ServeHTTP(wr, r.WithContext(ctx))
+		span.SetAttributes(semconv.HTTPResponseStatusCode(wr.Status))
+		if wr.Status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(wr.Status))
+		}
+	})
+}
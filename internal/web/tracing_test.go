@@ -0,0 +1,112 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans installs a tracer provider keeping the ended spans in memory
+// for the duration of the test.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	previous, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+	return recorder
+}
+
+func spansByName(recorder *tracetest.SpanRecorder) map[string]sdktrace.ReadOnlySpan {
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	return spans
+}
+
+func TestTracingRequest(t *testing.T) {
+	a := newMetricsApp(t)
+	recorder := recordSpans(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/annotate/quality/hash3", nil)
+	req.SetBasicAuth("alice", "secret")
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+	a.GetHTTPHandler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+
+	spans := spansByName(recorder)
+	server := spans["GET /annotate/"]
+	if server == nil {
+		t.Fatalf("no server span among %v", spans)
+	}
+	if got := server.Parent().SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Errorf("server span parent = %s, want the traceparent of the request", got)
+	}
+	for _, span := range recorder.Ended() {
+		if span.SpanContext().TraceID() != server.SpanContext().TraceID() {
+			t.Errorf("span %s is not part of the request trace", span.Name())
+		}
+	}
+
+	// Every query hangs off a repository method, under the server span
+	parentOf := make(map[string]string)
+	for _, span := range recorder.Ended() {
+		parentOf[span.SpanContext().SpanID().String()] = span.Parent().SpanID().String()
+	}
+	query := spans["GetImage"]
+	repo := spans["ImageRepository.GetBySHA256"]
+	if query == nil || repo == nil {
+		t.Fatalf("missing repository or query spans among %v", spans)
+	}
+	if query.Parent().SpanID() != repo.SpanContext().SpanID() {
+		t.Errorf("query span should be a child of the repository span")
+	}
+	for id := repo.SpanContext().SpanID().String(); id != server.SpanContext().SpanID().String(); id = parentOf[id] {
+		if _, ok := parentOf[id]; !ok {
+			t.Fatal("repository span does not descend from the server span")
+		}
+	}
+	for _, name := range []string{"CheckPasswordHash", "GetPhaseProgressStats"} {
+		if spans[name] == nil {
+			t.Errorf("missing %s span", name)
+		}
+	}
+}
+
+func TestTracingIngest(t *testing.T) {
+	a := newTestApp(t, &Config{})
+	writePNG(t, filepath.Join(a.ImagesDir, "a.png"), gradient(32, 32, 0))
+	recorder := recordSpans(t)
+
+	if err := a.IngestImages(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := spansByName(recorder)
+	ingest, file := spans["IngestImages"], spans["ingestFile"]
+	if ingest == nil || file == nil {
+		t.Fatalf("missing ingestion spans among %v", spans)
+	}
+	if file.Parent().SpanID() != ingest.SpanContext().SpanID() {
+		t.Error("ingestFile span should be a child of IngestImages")
+	}
+	for _, attr := range ingest.Attributes() {
+		if attr.Key == "rotulador.ingest.processed" && attr.Value.AsInt64() != 1 {
+			t.Errorf("processed = %d, want 1", attr.Value.AsInt64())
+		}
+	}
+}
@@ -0,0 +1,509 @@
+package web
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Video errors.
+const (
+	ErrNoVideoDecoder appError = "no decoder for this video format (hint: install ffmpeg)"
+	ErrInvalidVideo   appError = "invalid video"
+)
+
+// Frame rates assumed where the container does not say.
+const (
+	// rawMJPEGFrameRate is used for bare .mjpeg streams, which carry no timing
+	rawMJPEGFrameRate = 25
+	// externalFrameRate is how many frames per second ffmpeg hands over when
+	// frames are picked by scene changes
+	externalFrameRate = 10
+	// maxFrameBytes bounds a single encoded frame read into memory
+	maxFrameBytes = 64 << 20
+)
+
+// externalVideoExtensions are decoded by ffmpeg when it is available.
+var externalVideoExtensions = map[string]bool{
+	".mp4": true, ".m4v": true, ".mov": true, ".mkv": true, ".webm": true,
+	".mpg": true, ".mpeg": true, ".ts": true, ".wmv": true, ".flv": true, ".3gp": true,
+}
+
+// VideoOptions controls which frames ExtractFrames keeps. With neither
+// Interval nor SceneThreshold set every frame is kept.
+type VideoOptions struct {
+	// Interval keeps a frame when this much time passed since the last kept one
+	Interval time.Duration
+	// SceneThreshold keeps a frame when its perceptual hash differs from the
+	// last kept one by at least this many bits
+	SceneThreshold int
+	// FFmpeg is the external decoder for formats without a native one;
+	// empty disables them
+	FFmpeg string
+}
+
+// VideoFrame is a frame sampled by ExtractFrames.
+type VideoFrame struct {
+	Image image.Image
+	// Offset is the time of the frame from the start of the video
+	Offset time.Duration
+	// JPEG holds the encoded frame for MJPEG sources, so it can be stored
+	// without re-encoding; nil otherwise
+	JPEG []byte
+}
+
+func videoKind(path string) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	switch {
+	case ext == ".avi":
+		return "avi"
+	case ext == ".mjpeg", ext == ".mjpg":
+		return "mjpeg"
+	case ext == ".gif":
+		return "gif"
+	case externalVideoExtensions[ext]:
+		return "external"
+	}
+	return ""
+}
+
+// IsVideo reports whether ExtractFrames should read path. GIFs only count
+// when they have more than one frame; a still GIF is an image.
+func IsVideo(path string) bool {
+	switch videoKind(path) {
+	case "":
+		return false
+	case "gif":
+		f, err := os.Open(path)
+		if err != nil {
+			return false
+		}
+		defer func() {
+			if err := f.Close(); err != nil {
+				ReportError(context.Background(), err, "msg", "failed to close video file", "path", path)
+			}
+		}()
+		g, err := gif.DecodeAll(f)
+		return err == nil && len(g.Image) > 1
+	}
+	return true
+}
+
+// ExtractFrames decodes the video at path and calls fn with the frames picked
+// by opts, in order. Animated GIF, MJPEG AVI and bare MJPEG streams are
+// decoded natively; other formats need opts.FFmpeg.
+func ExtractFrames(ctx context.Context, path string, opts VideoOptions, fn func(VideoFrame) error) error {
+	sampler := &frameSampler{opts: opts}
+	keep := func(frame VideoFrame) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !sampler.keep(frame) {
+			return nil
+		}
+		return fn(frame)
+	}
+
+	switch videoKind(path) {
+	case "gif":
+		return extractGIF(path, keep)
+	case "avi":
+		return extractAVI(path, keep)
+	case "mjpeg":
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := f.Close(); err != nil {
+				ReportError(ctx, err, "msg", "failed to close video file", "path", path)
+			}
+		}()
+		return extractMJPEG(f, time.Second/rawMJPEGFrameRate, keep)
+	case "external":
+		if opts.FFmpeg == "" {
+			return fmt.Errorf("%w: %s", ErrNoVideoDecoder, path)
+		}
+		return extractExternal(ctx, path, opts, keep)
+	}
+	return fmt.Errorf("%w: %s", ErrNoVideoDecoder, path)
+}
+
+// frameSampler applies VideoOptions to a sequence of frames.
+type frameSampler struct {
+	opts       VideoOptions
+	kept       bool
+	lastOffset time.Duration
+	lastHash   uint64
+}
+
+func (s *frameSampler) keep(frame VideoFrame) bool {
+	var hash uint64
+	if s.opts.SceneThreshold > 0 {
+		hash = PerceptualHash(frame.Image)
+	}
+	keep := !s.kept ||
+		(s.opts.Interval <= 0 && s.opts.SceneThreshold <= 0) ||
+		(s.opts.Interval > 0 && frame.Offset-s.lastOffset >= s.opts.Interval) ||
+		(s.opts.SceneThreshold > 0 && HammingDistance(hash, s.lastHash) >= s.opts.SceneThreshold)
+	if keep {
+		s.kept = true
+		s.lastOffset = frame.Offset
+		s.lastHash = hash
+	}
+	return keep
+}
+
+// extractGIF composes the frames of an animated GIF the way browsers show them.
+func extractGIF(path string, fn func(VideoFrame) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			ReportError(context.Background(), err, "msg", "failed to close video file", "path", path)
+		}
+	}()
+	config, err := gif.DecodeConfig(f)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrInvalidVideo, path, err)
+	}
+	if int64(config.Width)*int64(config.Height) > MaxImagePixels {
+		return fmt.Errorf("%w: %dx%d", ErrImageTooLarge, config.Width, config.Height)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	g, err := gif.DecodeAll(f)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrInvalidVideo, path, err)
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, g.Config.Width, g.Config.Height))
+	var offset time.Duration
+	for i, frame := range g.Image {
+		var disposal byte
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		var previous *image.RGBA
+		if disposal == gif.DisposalPrevious {
+			previous = cloneRGBA(canvas)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		if err := fn(VideoFrame{Image: cloneRGBA(canvas), Offset: offset}); err != nil {
+			return err
+		}
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+		if i < len(g.Delay) {
+			offset += time.Duration(g.Delay[i]) * 10 * time.Millisecond
+		}
+	}
+	return nil
+}
+
+func cloneRGBA(img *image.RGBA) *image.RGBA {
+	out := image.NewRGBA(img.Bounds())
+	copy(out.Pix, img.Pix)
+	return out
+}
+
+// extractAVI reads the JPEG frames of an MJPEG AVI. The RIFF tree is walked
+// flat: list headers are stepped into, so only the main header ('avih', for
+// the frame duration) and video chunks ('##dc', '##db') need handling.
+func extractAVI(path string, fn func(VideoFrame) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			ReportError(context.Background(), err, "msg", "failed to close video file", "path", path)
+		}
+	}()
+	r := bufio.NewReader(f)
+
+	var header [12]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil || string(header[0:4]) != "RIFF" || string(header[8:12]) != "AVI " {
+		return fmt.Errorf("%w: %s is not an AVI file", ErrInvalidVideo, path)
+	}
+
+	frameDuration := time.Second / rawMJPEGFrameRate
+	index := 0
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		id := string(chunk[0:4])
+		size := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+		// Chunks are word aligned
+		pad := size % 2
+
+		switch {
+		case id == "RIFF" || id == "LIST":
+			// Step into the list: skip its form type, its chunks follow
+			if _, err := r.Discard(4); err != nil {
+				return fmt.Errorf("%w: %s: truncated list", ErrInvalidVideo, path)
+			}
+			continue
+		case id == "avih" && size >= 4:
+			var avih [4]byte
+			if _, err := io.ReadFull(r, avih[:]); err != nil {
+				return fmt.Errorf("%w: %s: truncated header", ErrInvalidVideo, path)
+			}
+			if us := binary.LittleEndian.Uint32(avih[:]); us > 0 {
+				frameDuration = time.Duration(us) * time.Microsecond
+			}
+			size -= 4
+		case strings.HasSuffix(id, "dc") || strings.HasSuffix(id, "db"):
+			if size > maxFrameBytes {
+				return fmt.Errorf("%w: %s: frame of %d bytes", ErrInvalidVideo, path, size)
+			}
+			data := make([]byte, size)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return fmt.Errorf("%w: %s: truncated frame", ErrInvalidVideo, path)
+			}
+			offset := time.Duration(index) * frameDuration
+			index++
+			// Empty chunks are dropped frames that repeat the previous one
+			if size > 0 {
+				if err := emitJPEG(data, offset, fn); err != nil {
+					return err
+				}
+			}
+			size = 0
+		}
+
+		if _, err := r.Discard(int(size + pad)); err != nil {
+			return nil // trailing garbage after the last complete chunk
+		}
+	}
+}
+
+// extractMJPEG reads a stream of concatenated JPEG images, frameDuration apart.
+func extractMJPEG(src io.Reader, frameDuration time.Duration, fn func(VideoFrame) error) error {
+	r := bufio.NewReader(src)
+	for index := 0; ; index++ {
+		data, err := readJPEG(r)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := emitJPEG(data, time.Duration(index)*frameDuration, fn); err != nil {
+			return err
+		}
+	}
+}
+
+func emitJPEG(data []byte, offset time.Duration, fn func(VideoFrame) error) error {
+	data = withHuffmanTables(data)
+	config, err := jpeg.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%w: frame at %s: %w", ErrInvalidVideo, offset, err)
+	}
+	if int64(config.Width)*int64(config.Height) > MaxImagePixels {
+		return fmt.Errorf("%w: %dx%d", ErrImageTooLarge, config.Width, config.Height)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%w: frame at %s: %w", ErrInvalidVideo, offset, err)
+	}
+	return fn(VideoFrame{Image: img, Offset: offset, JPEG: data})
+}
+
+// readJPEG reads one JPEG image from r by following its marker segments, so
+// markers inside embedded thumbnails do not end it early. io.EOF means r
+// held no further image.
+func readJPEG(r *bufio.Reader) ([]byte, error) {
+	// Skip padding some encoders put between frames
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, io.EOF
+		}
+		if b != 0xFF {
+			continue
+		}
+		next, err := r.Peek(1)
+		if err != nil {
+			return nil, io.EOF
+		}
+		if next[0] == 0xD8 {
+			_, _ = r.ReadByte()
+			break
+		}
+	}
+
+	buf := bytes.NewBuffer([]byte{0xFF, 0xD8})
+	truncated := fmt.Errorf("%w: truncated JPEG frame", ErrInvalidVideo)
+	// scanned is the marker that ended the previous scan, already consumed
+	var scanned byte
+	for {
+		if buf.Len() > maxFrameBytes {
+			return nil, fmt.Errorf("%w: JPEG frame over %d bytes", ErrInvalidVideo, maxFrameBytes)
+		}
+		marker := [2]byte{0xFF, scanned}
+		if scanned == 0 {
+			if _, err := io.ReadFull(r, marker[:]); err != nil {
+				return nil, truncated
+			}
+		}
+		scanned = 0
+		if marker[0] != 0xFF {
+			return nil, fmt.Errorf("%w: bad JPEG marker %x", ErrInvalidVideo, marker)
+		}
+		buf.Write(marker[:])
+		switch {
+		case marker[1] == 0xD9:
+			return buf.Bytes(), nil
+		case marker[1] == 0x01 || (marker[1] >= 0xD0 && marker[1] <= 0xD7):
+			continue // no payload
+		}
+
+		var length [2]byte
+		if _, err := io.ReadFull(r, length[:]); err != nil {
+			return nil, truncated
+		}
+		buf.Write(length[:])
+		if _, err := io.CopyN(buf, r, int64(binary.BigEndian.Uint16(length[:]))-2); err != nil {
+			return nil, truncated
+		}
+		if marker[1] != 0xDA {
+			continue
+		}
+
+		// Entropy-coded data runs until a marker other than a stuffed zero
+		// or a restart marker
+		for {
+			b, err := r.ReadByte()
+			if err != nil {
+				return nil, truncated
+			}
+			if b != 0xFF {
+				buf.WriteByte(b)
+				continue
+			}
+			next, err := r.ReadByte()
+			if err != nil {
+				return nil, truncated
+			}
+			if next == 0x00 || (next >= 0xD0 && next <= 0xD7) {
+				buf.WriteByte(b)
+				buf.WriteByte(next)
+				continue
+			}
+			scanned = next
+			break
+		}
+	}
+}
+
+var (
+	standardDHTOnce sync.Once
+	standardDHT     []byte
+)
+
+// withHuffmanTables adds the standard Huffman tables to MJPEG frames that
+// omit them, as many cameras do, so they decode and display as plain JPEGs.
+func withHuffmanTables(data []byte) []byte {
+	for i := 2; i+4 <= len(data) && data[i] == 0xFF; {
+		marker := data[i+1]
+		if marker == 0xC4 {
+			return data
+		}
+		if marker == 0xDA {
+			break
+		}
+		i += 2 + int(binary.BigEndian.Uint16(data[i+2:i+4]))
+	}
+
+	// image/jpeg writes the tables of the JPEG standard (Annex K.3)
+	standardDHTOnce.Do(func() {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 8, 8)), nil); err != nil {
+			return
+		}
+		encoded := buf.Bytes()
+		for i := 2; i+4 <= len(encoded) && encoded[i] == 0xFF; {
+			end := i + 2 + int(binary.BigEndian.Uint16(encoded[i+2:i+4]))
+			if encoded[i+1] == 0xC4 {
+				standardDHT = append(standardDHT, encoded[i:end]...)
+			}
+			if encoded[i+1] == 0xDA {
+				break
+			}
+			i = end
+		}
+	})
+
+	fixed := make([]byte, 0, len(data)+len(standardDHT))
+	fixed = append(fixed, data[:2]...)
+	fixed = append(fixed, standardDHT...)
+	return append(fixed, data[2:]...)
+}
+
+// extractExternal pipes the video through ffmpeg as MJPEG. When only an
+// interval is asked for, ffmpeg drops the other frames itself.
+func extractExternal(ctx context.Context, path string, opts VideoOptions, fn func(VideoFrame) error) error {
+	rate := float64(externalFrameRate)
+	if opts.Interval > 0 && opts.SceneThreshold <= 0 {
+		rate = 1 / opts.Interval.Seconds()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, opts.FFmpeg,
+		"-hide_banner", "-loglevel", "error", "-nostdin",
+		"-i", path,
+		"-vf", fmt.Sprintf("fps=%g", rate),
+		"-f", "image2pipe", "-c:v", "mjpeg", "-q:v", "2", "-")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("while starting %s: %w", opts.FFmpeg, err)
+	}
+
+	extractErr := extractMJPEG(stdout, time.Duration(float64(time.Second)/rate), fn)
+	if extractErr != nil {
+		cancel()
+	}
+	waitErr := cmd.Wait()
+	if extractErr != nil {
+		return extractErr
+	}
+	if waitErr != nil {
+		return fmt.Errorf("while decoding '%s' with %s: %w: %s", path, opts.FFmpeg, waitErr, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
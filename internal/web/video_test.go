@@ -0,0 +1,218 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func writeGIF(t *testing.T, path string, frames []image.Image, delay int) {
+	t.Helper()
+	anim := &gif.GIF{}
+	for _, frame := range frames {
+		p := image.NewPaletted(frame.Bounds(), palette.Plan9)
+		draw.Draw(p, p.Bounds(), frame, image.Point{}, draw.Src)
+		anim.Image = append(anim.Image, p)
+		anim.Delay = append(anim.Delay, delay)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	if err := gif.EncodeAll(f, anim); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// jpegWithoutDHT encodes img and drops its Huffman tables, like MJPEG
+// cameras do.
+func jpegWithoutDHT(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	out := append([]byte{}, data[:2]...)
+	for i := 2; ; {
+		end := i + 2 + int(binary.BigEndian.Uint16(data[i+2:i+4]))
+		if data[i+1] == 0xDA {
+			return append(out, data[i:]...)
+		}
+		if data[i+1] != 0xC4 {
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+}
+
+func riffChunk(id string, data []byte) []byte {
+	out := append([]byte(id), binary.LittleEndian.AppendUint32(nil, uint32(len(data)))...)
+	out = append(out, data...)
+	if len(data)%2 == 1 {
+		out = append(out, 0)
+	}
+	return out
+}
+
+func riffList(kind, form string, chunks ...[]byte) []byte {
+	body := []byte(form)
+	for _, c := range chunks {
+		body = append(body, c...)
+	}
+	return append(append([]byte(kind), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...), body...)
+}
+
+func collectFrames(t *testing.T, path string, opts VideoOptions) []VideoFrame {
+	t.Helper()
+	var frames []VideoFrame
+	if err := ExtractFrames(t.Context(), path, opts, func(f VideoFrame) error {
+		frames = append(frames, f)
+		return nil
+	}); err != nil {
+		t.Fatalf("ExtractFrames: %v", err)
+	}
+	return frames
+}
+
+func offsets(frames []VideoFrame) []time.Duration {
+	var out []time.Duration
+	for _, f := range frames {
+		out = append(out, f.Offset)
+	}
+	return out
+}
+
+func TestExtractFramesGIF(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clip.gif")
+	still := gradient(32, 32, 0)
+	writeGIF(t, path, []image.Image{still, still, still, gradient(32, 32, 1)}, 50)
+
+	if !IsVideo(path) {
+		t.Fatal("IsVideo() = false for an animated GIF")
+	}
+	stillPath := filepath.Join(t.TempDir(), "still.gif")
+	writeGIF(t, stillPath, []image.Image{still}, 0)
+	if IsVideo(stillPath) {
+		t.Error("IsVideo() = true for a single-frame GIF")
+	}
+
+	tests := []struct {
+		name string
+		opts VideoOptions
+		want []time.Duration
+	}{
+		{"every frame", VideoOptions{}, []time.Duration{0, 500 * time.Millisecond, time.Second, 1500 * time.Millisecond}},
+		{"interval", VideoOptions{Interval: time.Second}, []time.Duration{0, time.Second}},
+		{"scene change", VideoOptions{SceneThreshold: DefaultDuplicateDistance}, []time.Duration{0, 1500 * time.Millisecond}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frames := collectFrames(t, path, tt.opts)
+			got := offsets(frames)
+			if len(got) != len(tt.want) {
+				t.Fatalf("offsets = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("offsets = %v, want %v", got, tt.want)
+				}
+			}
+			if frames[0].JPEG != nil || frames[0].Image.Bounds().Dx() != 32 {
+				t.Errorf("unexpected frame %+v", frames[0])
+			}
+		})
+	}
+}
+
+func TestExtractFramesAVI(t *testing.T) {
+	frame := jpegWithoutDHT(t, gradient(16, 16, 0))
+	if _, err := jpeg.Decode(bytes.NewReader(frame)); err == nil {
+		t.Fatal("test frame should not decode without Huffman tables")
+	}
+
+	// 5 frames per second; an odd-sized chunk checks word alignment and an
+	// empty chunk is a dropped frame
+	avih := binary.LittleEndian.AppendUint32(nil, 200000)
+	avih = append(avih, make([]byte, 52)...)
+	avi := riffList("RIFF", "AVI ",
+		riffList("LIST", "hdrl", riffChunk("avih", avih)),
+		riffChunk("JUNK", []byte{1, 2, 3}),
+		riffList("LIST", "movi",
+			riffChunk("00dc", frame),
+			riffChunk("01wb", []byte{0, 0}),
+			riffChunk("00dc", nil),
+			riffChunk("00dc", frame),
+		),
+		riffChunk("idx1", make([]byte, 16)),
+	)
+	path := filepath.Join(t.TempDir(), "dashcam.avi")
+	if err := os.WriteFile(path, avi, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	frames := collectFrames(t, path, VideoOptions{})
+	got := offsets(frames)
+	if len(got) != 2 || got[0] != 0 || got[1] != 400*time.Millisecond {
+		t.Fatalf("offsets = %v, want [0 400ms]", got)
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(frames[0].JPEG)); err != nil {
+		t.Errorf("stored frame does not decode on its own: %v", err)
+	}
+}
+
+func TestExtractFramesExternal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clip.mp4")
+	if err := os.WriteFile(path, []byte("not really"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := ExtractFrames(t.Context(), path, VideoOptions{}, func(VideoFrame) error { return nil })
+	if !errors.Is(err, ErrNoVideoDecoder) {
+		t.Fatalf("ExtractFrames without ffmpeg error = %v, want ErrNoVideoDecoder", err)
+	}
+
+	if runtime.GOOS == "windows" {
+		t.Skip("fake decoder is a shell script")
+	}
+	// Stand-in for ffmpeg that writes two JPEGs, with padding between them
+	var stream bytes.Buffer
+	for seed := range 2 {
+		if err := jpeg.Encode(&stream, gradient(16, 16, seed), nil); err != nil {
+			t.Fatal(err)
+		}
+		stream.Write([]byte{0, 0})
+	}
+	dir := t.TempDir()
+	frames := filepath.Join(dir, "frames.mjpeg")
+	if err := os.WriteFile(frames, stream.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fake := filepath.Join(dir, "ffmpeg")
+	if err := os.WriteFile(fake, []byte("#!/bin/sh\ncat '"+frames+"'\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []VideoFrame
+	err = ExtractFrames(context.Background(), path, VideoOptions{Interval: 2 * time.Second, FFmpeg: fake}, func(f VideoFrame) error {
+		got = append(got, f)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[1].Offset != 2*time.Second {
+		t.Errorf("offsets = %v, want [0 2s]", offsets(got))
+	}
+}
@@ -0,0 +1,182 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/lewtec/rotulador/internal/storage"
+)
+
+// watchSettleDelay is how long a new file must stay quiet before it is
+// ingested, so files that are still being copied are not decoded half-written.
+const watchSettleDelay = time.Second
+
+// WatchImages ingests files added to the images directory or any of its
+// subdirectories while the server runs and forgets removed or renamed ones
+// (see forgetPath). It blocks until ctx is done. Buckets cannot be watched.
+func (a *AnnotatorApp) WatchImages(ctx context.Context) error {
+	if !a.isLocalStore() {
+		return ErrRemoteImages
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("while creating images directory watcher: %w", err)
+	}
+	defer func() {
+		if err := watcher.Close(); err != nil {
+			ReportError(ctx, err, "msg", "failed to close images directory watcher")
+		}
+	}()
+	// Written files are ingested once they settle; see watchSettleDelay
+	pending := make(map[string]time.Time)
+	if err := watchTree(watcher, a.ImagesDir, nil); err != nil {
+		return err
+	}
+	a.Logger.Info("WatchImages: watching images directory", "dir", a.ImagesDir)
+	ticker := time.NewTicker(watchSettleDelay / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			ReportError(ctx, err, "msg", "images directory watcher error")
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			switch {
+			case event.Has(fsnotify.Create):
+				// A directory moved in arrives as a single event, so its
+				// files are queued here rather than one by one
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := watchTree(watcher, event.Name, pending); err != nil {
+						ReportError(ctx, err, "msg", "failed to watch new directory", "path", event.Name)
+					}
+					continue
+				}
+				pending[event.Name] = time.Now()
+			case event.Has(fsnotify.Write):
+				pending[event.Name] = time.Now()
+			case event.Has(fsnotify.Remove), event.Has(fsnotify.Rename):
+				delete(pending, event.Name)
+				if err := a.forgetTree(ctx, event.Name); err != nil {
+					ReportError(ctx, err, "msg", "failed to handle removed image", "path", event.Name)
+					a.recordIngestError(err)
+				}
+			}
+
+		case now := <-ticker.C:
+			for path, changedAt := range pending {
+				if now.Sub(changedAt) < watchSettleDelay {
+					continue
+				}
+				delete(pending, path)
+				if err := a.ingestPath(ctx, path); err != nil {
+					ReportError(ctx, err, "msg", "failed to ingest new image", "path", path)
+					a.recordIngestError(err)
+				}
+			}
+		}
+	}
+}
+
+// watchTree watches dir and every directory below it. Files found on the way
+// are queued in pending when it is not nil.
+func watchTree(watcher *fsnotify.Watcher, dir string, pending map[string]time.Time) error {
+	return filepath.WalkDir(dir, func(fullPath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			if pending != nil && entry.Type().IsRegular() {
+				pending[fullPath] = time.Now()
+			}
+			return nil
+		}
+		if err := watcher.Add(fullPath); err != nil {
+			return fmt.Errorf("while watching '%s': %w", fullPath, err)
+		}
+		return nil
+	})
+}
+
+// forgetTree forgets a removed file, or every indexed file below a removed
+// directory.
+func (a *AnnotatorApp) forgetTree(ctx context.Context, fullPath string) error {
+	relPath, err := a.relPath(fullPath)
+	if err != nil {
+		return err
+	}
+	entry, err := a.ingestRepo.Get(ctx, relPath)
+	if err != nil {
+		return err
+	}
+	if entry != nil {
+		return a.forgetPath(ctx, relPath)
+	}
+
+	entries, err := a.ingestRepo.List(ctx)
+	if err != nil {
+		return fmt.Errorf("while loading ingest index: %w", err)
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Path, relPath+"/") {
+			if err := a.forgetPath(ctx, entry.Path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// localInfo describes a file of the images directory as the store would.
+func localInfo(relPath string, info fs.FileInfo) storage.Info {
+	return storage.Info{Name: relPath, Size: info.Size(), ModTime: info.ModTime()}
+}
+
+// ingestPath ingests a single file of the images directory unless the ingest
+// index says it is unchanged. Files that vanished in the meantime are ignored.
+func (a *AnnotatorApp) ingestPath(ctx context.Context, fullPath string) error {
+	info, err := os.Stat(fullPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	relPath, err := a.relPath(fullPath)
+	if err != nil {
+		return err
+	}
+	entry, err := a.ingestRepo.Get(ctx, relPath)
+	if err != nil {
+		return err
+	}
+	if entry != nil && entry.SizeBytes == info.Size() && entry.ModTime.Equal(info.ModTime()) {
+		return nil
+	}
+
+	if err := a.ingestFile(ctx, localInfo(relPath, info)); err != nil {
+		return err
+	}
+	a.Logger.Info("WatchImages: ingested new image", "path", fullPath)
+	return nil
+}
@@ -0,0 +1,138 @@
+package web
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lewtec/rotulador/internal/domain"
+)
+
+// waitFor polls cond until it holds or the deadline passes. touch is called
+// now and then so events lost before the watcher started are repeated; not
+// too often, since every write restarts watchSettleDelay.
+func waitFor(t *testing.T, what string, touch func(), cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	lastTouch := time.Now()
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(watchSettleDelay / 4)
+		if touch != nil && time.Since(lastTouch) > 3*watchSettleDelay {
+			touch()
+			lastTouch = time.Now()
+		}
+	}
+}
+
+func startWatch(t *testing.T, a *AnnotatorApp) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() { done <- a.WatchImages(ctx) }()
+	// Give the watcher a moment to subscribe; waitFor covers a slow start
+	time.Sleep(100 * time.Millisecond)
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func imageCount(t *testing.T, a *AnnotatorApp) func() int64 {
+	return func() int64 {
+		count, err := a.imageRepo.Count(t.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return count
+	}
+}
+
+func TestWatchImagesIngestsAndMarksMissing(t *testing.T) {
+	a := newTestApp(t, &Config{})
+	count := imageCount(t, a)
+	startWatch(t, a)
+
+	path := filepath.Join(a.ImagesDir, "new.png")
+	write := func() { writePNG(t, path, gradient(16, 16, 0)) }
+	write()
+	waitFor(t, "new image to be ingested", write, func() bool { return count() == 1 })
+
+	renamed := filepath.Join(a.ImagesDir, "renamed.png")
+	if err := os.Rename(path, renamed); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "renamed image to be ingested", nil, func() bool {
+		img, err := a.imageRepo.GetByFilename(t.Context(), "renamed.png")
+		return err == nil && img != nil && img.MissingAt.IsZero()
+	})
+
+	if err := os.Remove(renamed); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "removed image to be marked missing", nil, func() bool { return count() == 0 })
+
+	images, err := a.imageRepo.ListFiltered(t.Context(), domain.ImageFilter{IncludeMissing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(images) != 1 || images[0].MissingAt.IsZero() {
+		t.Errorf("images = %+v, want the removed image kept and marked missing", images)
+	}
+}
+
+func TestWatchImagesDeletesRemovedImages(t *testing.T) {
+	a := newTestApp(t, &Config{Watch: ConfigWatch{OnRemove: OnRemoveDelete}})
+	count := imageCount(t, a)
+	startWatch(t, a)
+
+	path := filepath.Join(a.ImagesDir, "new.png")
+	write := func() { writePNG(t, path, gradient(16, 16, 1)) }
+	write()
+	waitFor(t, "new image to be ingested", write, func() bool { return count() == 1 })
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "removed image to be deleted", nil, func() bool {
+		images, err := a.imageRepo.ListFiltered(t.Context(), domain.ImageFilter{})
+		return err == nil && len(images) == 0
+	})
+}
+
+func TestWatchImagesFollowsSubdirectories(t *testing.T) {
+	a := newTestApp(t, &Config{})
+	count := imageCount(t, a)
+	startWatch(t, a)
+
+	// A directory built elsewhere and moved in produces a single event
+	staging := filepath.Join(t.TempDir(), "cameraA")
+	if err := os.MkdirAll(filepath.Join(staging, "night"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writePNG(t, filepath.Join(staging, "1.png"), gradient(16, 16, 0))
+	writePNG(t, filepath.Join(staging, "night", "2.png"), gradient(24, 16, 1))
+	dir := filepath.Join(a.ImagesDir, "cameraA")
+	if err := os.Rename(staging, dir); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "moved directory to be ingested", nil, func() bool { return count() == 2 })
+
+	// Files added later to the new subdirectory are watched too
+	path := filepath.Join(dir, "night", "3.png")
+	write := func() { writePNG(t, path, gradient(32, 16, 2)) }
+	write()
+	waitFor(t, "file in subdirectory to be ingested", write, func() bool {
+		img, err := a.imageRepo.GetByFilename(t.Context(), "cameraA/night/3.png")
+		return err == nil && img != nil
+	})
+
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "removed directory to be marked missing", nil, func() bool { return count() == 0 })
+}